@@ -1 +1,77 @@
 package configs
+
+import (
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Application struct {
+		Name    string `mapstructure:"name"`
+		Host    string `mapstructure:"host"`
+		Port    int    `mapstructure:"port"`
+		NodeId  string `mapstructure:"nodeId"`
+		Timeout int    `mapstructure:"timeout"`
+
+		// 内容审核, 包含关键词的文本消息被拒绝
+		Moderation struct {
+			Keywords []string `mapstructure:"keywords"`
+		} `mapstructure:"moderation"`
+
+		Component struct {
+			Consul struct {
+				Endpoint string `mapstructure:"endpoint"`
+				Port     int    `mapstructure:"port"`
+				Scheme   string `mapstructure:"scheme"`
+				Service  struct {
+					Register bool     `mapstructure:"register"`
+					Tags     []string `mapstructure:"tags"`
+				} `mapstructure:"service"`
+			} `mapstructure:"consul"`
+
+			Redis struct {
+				Nodes    []string `mapstructure:"nodes"`
+				Password string   `mapstructure:"password"`
+			} `mapstructure:"redis"`
+
+			Kafka struct {
+				Brokers   []string `mapstructure:"brokers"`
+				Topic     string   `mapstructure:"topic"`
+				PushTopic string   `mapstructure:"pushTopic"` // 推送至gateway的topic前缀
+			} `mapstructure:"kafka"`
+		} `mapstructure:"component"`
+	} `mapstructure:"application"`
+}
+
+var Cfg atomic.Pointer[Config]
+
+func Get() *Config {
+	return Cfg.Load()
+}
+
+// Load 从工作目录读取 center.yaml
+func Load() (*Config, error) {
+	v := viper.New()
+
+	v.SetConfigName("center")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+
+	v.SetDefault("application.port", 50051)
+
+	v.SetEnvPrefix("CENTER")
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	Cfg.Store(&c)
+
+	return &c, nil
+}
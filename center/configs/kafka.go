@@ -1,6 +1,11 @@
 package configs
 
-import "crypto/tls"
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
 
 type KafkaConfig struct {
 	Broker []string
@@ -16,7 +21,9 @@ type TLSConfig struct {
 }
 
 func newDefaultKafka() *KafkaConfig {
-	return &KafkaConfig{}
+	return &KafkaConfig{
+		Broker: []string{"127.0.0.1:9092"},
+	}
 }
 
 type KafkaParams struct {
@@ -38,7 +45,9 @@ func DefineKafkaParams(c string) KafkaParamsOpts {
 	}
 }
 
-func NewKafka(opts ...KafkaParamsOpts) {
+// NewKafka 创建kafka生产者
+// 不指定topic时由每条消息自行指定
+func NewKafka(opts ...KafkaParamsOpts) *kafka.Writer {
 	p := &KafkaParams{}
 	for _, opt := range opts {
 		opt(p)
@@ -48,4 +57,11 @@ func NewKafka(opts ...KafkaParamsOpts) {
 		p.defaultParams = newDefaultKafka()
 	}
 
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(p.defaultParams.Broker...),
+		Topic:                  p.defaultParams.Topic,
+		Balancer:               &kafka.Hash{},
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
 }
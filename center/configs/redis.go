@@ -1 +1,53 @@
 package configs
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisConfig struct {
+	Addrs        []string
+	Password     string
+	PoolSize     int
+	MinIdle      int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	MaxRetries   int
+}
+
+func newDefaultRedisConfig() *RedisConfig {
+	return &RedisConfig{
+		Addrs:        []string{"127.0.0.1:6379"},
+		PoolSize:     200,
+		MinIdle:      20,
+		DialTimeout:  3 * time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 2 * time.Second,
+		MaxRetries:   3,
+	}
+}
+
+func NewRedisClient(cfg *RedisConfig) *redis.ClusterClient {
+	if cfg == nil {
+		cfg = newDefaultRedisConfig()
+	}
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        cfg.Addrs,
+		Password:     cfg.Password,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdle,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		MaxRetries:   cfg.MaxRetries,
+	})
+}
+
+var Client atomic.Pointer[redis.ClusterClient]
+
+func RedisTemplate() *redis.ClusterClient {
+	return Client.Load()
+}
@@ -36,10 +36,18 @@ func newDefaultGrpcConfig() *GrpcConfig {
 	}
 }
 
-func StartgRPCServer(cfg *GrpcConfig) {
+// StartgRPCServer 启动gRPC服务, 阻塞直到ctx结束后优雅关闭
+// register: 注册业务服务
+func StartgRPCServer(ctx context.Context, cfg *GrpcConfig, register func(*grpc.Server)) {
 	if cfg == nil {
 		cfg = newDefaultGrpcConfig()
 	}
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 5 * time.Second
+	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	lis, err := net.Listen(cfg.Network, addr)
@@ -59,8 +67,9 @@ func StartgRPCServer(cfg *GrpcConfig) {
 	// start grpc server
 	srv := grpc.NewServer(opts...)
 
-	// TODO: register your gRPC services here
-	// pb.RegisterXXXServer(srv, &xxxServer{})
+	if register != nil {
+		register(srv)
+	}
 
 	go func() {
 		if err := srv.Serve(lis); err != nil {
@@ -68,8 +77,17 @@ func StartgRPCServer(cfg *GrpcConfig) {
 		}
 	}()
 
-	<-context.Background().Done()
-	_, cancel := context.WithTimeout(context.Background(), cfg.StopTimeout)
-	defer cancel()
-	srv.GracefulStop()
+	<-ctx.Done()
+
+	// 超时未完成优雅关闭则强制关闭
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(cfg.StopTimeout):
+		srv.Stop()
+	}
 }
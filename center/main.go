@@ -1,5 +1,117 @@
-package utils
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"center/configs"
+	"center/core"
+	"center/service"
+
+	pb "github.com/atoncooper/im/proto"
+	seqpb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+const (
+	STORAGE_SERVICE = "storage"
+	SIGNAL_SERVICE  = "signal"
+)
 
 func main() {
+	cfg, err := configs.Load()
+	if err != nil {
+		panic(err)
+	}
+	app := cfg.Application
+
+	// 初始化redis
+	rdb := configs.NewRedisClient(&configs.RedisConfig{
+		Addrs:        app.Component.Redis.Nodes,
+		Password:     app.Component.Redis.Password,
+		PoolSize:     100,
+		MinIdle:      10,
+		DialTimeout:  3 * time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 2 * time.Second,
+		MaxRetries:   3,
+	})
+	configs.Client.Store(rdb)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		panic(err)
+	}
+
+	// 初始化kafka, topic由每条消息指定
+	writer := configs.NewKafka(configs.DefaultKafkaParams(&configs.KafkaConfig{
+		Broker: app.Component.Kafka.Brokers,
+	}))
+	defer writer.Close()
+
+	// 初始化推送
+	service.InitPusher(rdb, writer, app.Component.Kafka.PushTopic)
+
+	// 注册consul服务
+	consul, err := configs.NewConsulClient(&configs.ConsulConf{
+		Address: fmt.Sprintf("%s:%d", app.Component.Consul.Endpoint, app.Component.Consul.Port),
+		Scheme:  app.Component.Consul.Scheme,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if app.Component.Consul.Service.Register {
+		err = consul.RegisterService(app.NodeId, app.Name, app.Host, app.Port, app.Component.Consul.Service.Tags)
+		if err != nil {
+			panic(err)
+		}
+		defer consul.DeregisterService(app.NodeId)
+	}
+
+	runCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 连接storage
+	storageConn, err := core.DialService(runCtx, consul.Client, STORAGE_SERVICE)
+	if err != nil {
+		panic(err)
+	}
+	defer storageConn.Close()
+	history := storagepb.NewHistoryServiceClient(storageConn)
+
+	// 连接signal, 为消息分配id与seq
+	signalConn, err := core.DialService(runCtx, consul.Client, SIGNAL_SERVICE)
+	if err != nil {
+		panic(err)
+	}
+	defer signalConn.Close()
+	seq := seqpb.NewSequenceServiceClient(signalConn)
+
+	// 用户时间线
+	timeline := service.NewTimeline(seq, storagepb.NewTimelineServiceClient(storageConn))
+
+	// 内容审核
+	moderator := service.NewKeywordModerator(app.Moderation.Keywords)
+
+	// 阅后即焚
+	disappear := service.NewDisappearHandle(rdb, history, seq, timeline)
+	go disappear.Run(runCtx)
 
+	log.Println("[INFO] Starting center server...")
+	core.StartgRPCServer(runCtx, &core.GrpcConfig{
+		Host: app.Host,
+		Port: app.Port,
+	}, func(s *grpc.Server) {
+		pb.RegisterMessageServiceServer(s, service.NewRPCHandle(rdb, history, storagepb.NewSyncServiceClient(storageConn), seq, disappear, timeline, moderator))
+		pb.RegisterReceiptServiceServer(s, service.NewReceiptHandle(rdb, history, disappear, timeline))
+		pb.RegisterDisappearServiceServer(s, disappear)
+		pb.RegisterKeyServiceServer(s, service.NewKeyHandle(rdb))
+	})
+	log.Println("[INFO] center server stopped")
 }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// 送达回执
+//
+// 送达状态按消息存储为hash
+// key : delivery:{messageId}
+// field :
+//   sender   发送者uid, 取自storage中的消息
+//   at       首次送达时间, 任一接收设备ack即写入
+//   r:{uid}  该接收者首次ack时间
+//
+// 消息首次送达时向发送者推送送达事件, 状态保留 DELIVERY_TTL
+
+const (
+	DELIVERY_PREFIX = "delivery:"
+	DELIVERY_TTL    = 7 * 24 * time.Hour
+
+	deliveryReceiverField = "r:"
+)
+
+var ErrNotSender = errors.New("only the sender can query the delivery status")
+
+// markDelivered 记录一次设备ack, 返回1表示消息首次送达
+var markDelivered = redis.NewScript(`
+local first = redis.call('HSETNX', KEYS[1], 'at', ARGV[3])
+redis.call('HSETNX', KEYS[1], 'sender', ARGV[1])
+redis.call('HSETNX', KEYS[1], 'r:' .. ARGV[2], ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return first
+`)
+
+// DeliveredEvent 推送给发送者的送达事件
+type DeliveredEvent struct {
+	Type           string `json:"type"`
+	MessageId      string `json:"message_id"`
+	ConversationId string `json:"conversation_id"`
+	SessionType    string `json:"session_type"`
+	Receiver       string `json:"receiver"`
+	Seq            int64  `json:"seq"`
+	DeliveredAt    int64  `json:"delivered_at"`
+}
+
+// ReportDelivered 记录设备ack, ack中只信任gateway填写的接收者uid
+// 发送者、会话与seq以storage中的消息为准, 接收者看不到的消息(不存在、不是发给他的)直接丢弃
+func (r *ReceiptHandle) ReportDelivered(ctx context.Context, in *pb.ReportDeliveredRequest) (*pb.ReportDeliveredResponse, error) {
+	// 按接收者分组, 每个接收者一次查询
+	byReceiver := make(map[string][]string)
+	for _, ack := range in.Acks {
+		if ack.MessageId == "" || ack.ReceiverId == "" {
+			continue
+		}
+		byReceiver[ack.ReceiverId] = append(byReceiver[ack.ReceiverId], ack.MessageId)
+	}
+	visible := make(map[string]*pb.MessageData)
+	for receiver, ids := range byReceiver {
+		resp, err := r.history.GetMessages(ctx, &storagepb.GetMessagesRequest{Uid: receiver, MessageIds: ids})
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range resp.Messages {
+			visible[receiver+"|"+msg.Id] = msg
+		}
+	}
+
+	acks := make([]*pb.DeliveryAck, 0, len(in.Acks))
+	for _, ack := range in.Acks {
+		msg, ok := visible[ack.ReceiverId+"|"+ack.MessageId]
+		// 发送者自己的其他设备ack不算送达
+		if !ok || msg.SenderId == ack.ReceiverId {
+			continue
+		}
+		// 发送者视角的会话: 私聊为接收者uid, 群聊为群id
+		conversationId := ack.ReceiverId
+		if msg.SesstionType == pb.SesstionType_GROUP {
+			conversationId = msg.ReceiverId
+		}
+		accepted := &pb.DeliveryAck{
+			MessageId:      msg.Id,
+			SenderId:       msg.SenderId,
+			ReceiverId:     ack.ReceiverId,
+			DeviceId:       ack.DeviceId,
+			ConversationId: conversationId,
+			SessionType:    msg.SesstionType,
+			Seq:            msg.Seq,
+			AckTime:        ack.AckTime,
+		}
+		if accepted.AckTime <= 0 {
+			accepted.AckTime = time.Now().UnixMilli()
+		}
+		acks = append(acks, accepted)
+	}
+	if len(acks) == 0 {
+		return &pb.ReportDeliveredResponse{}, nil
+	}
+
+	// 同一批次的ack通过pipeline一次写入
+	pipe := r.redis.Pipeline()
+	cmds := make([]*redis.Cmd, len(acks))
+	for i, ack := range acks {
+		cmds[i] = markDelivered.Eval(ctx, pipe,
+			[]string{DELIVERY_PREFIX + ack.MessageId},
+			ack.SenderId, ack.ReceiverId, ack.AckTime, int64(DELIVERY_TTL.Seconds()),
+		)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, ack := range acks {
+		first, err := cmds[i].Int()
+		if err != nil || first == 0 {
+			continue
+		}
+
+		sessionType := "single"
+		if ack.SessionType == pb.SesstionType_GROUP {
+			sessionType = "group"
+		}
+		_ = PusherTemplate().Push(ctx, ack.SenderId, KIND_RECEIPT, DeliveredEvent{
+			Type:           "delivered",
+			MessageId:      ack.MessageId,
+			ConversationId: ack.ConversationId,
+			SessionType:    sessionType,
+			Receiver:       ack.ReceiverId,
+			Seq:            ack.Seq,
+			DeliveredAt:    ack.AckTime,
+		})
+	}
+
+	return &pb.ReportDeliveredResponse{Accepted: int32(len(acks))}, nil
+}
+
+// QueryDelivery 只有发送者可以查询, 尚未送达的消息没有送达状态, 返回未送达
+func (r *ReceiptHandle) QueryDelivery(ctx context.Context, in *pb.QueryDeliveryRequest) (*pb.QueryDeliveryResponse, error) {
+	if in.MessageId == "" || in.Uid == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	fields, err := r.redis.HGetAll(ctx, DELIVERY_PREFIX+in.MessageId).Result()
+	if err != nil {
+		return nil, err
+	}
+	if sender, ok := fields["sender"]; ok && sender != in.Uid {
+		return nil, ErrNotSender
+	}
+
+	resp := &pb.QueryDeliveryResponse{}
+	for k, v := range fields {
+		switch {
+		case k == "at":
+			resp.Delivered = true
+			resp.DeliveredAt, _ = strconv.ParseInt(v, 10, 64)
+		case strings.HasPrefix(k, deliveryReceiverField):
+			resp.AckedUids = append(resp.AckedUids, strings.TrimPrefix(k, deliveryReceiverField))
+		}
+	}
+	resp.AckedCount = int32(len(resp.AckedUids))
+	sort.Strings(resp.AckedUids)
+
+	return resp, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// 测试送达回执以storage中的消息为准, 伪造的发送者与接收者看不到的消息被丢弃
+func TestDeliveredFromStoredMessage(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice", "mallory")
+	history := &fakeHistory{
+		saved: []*pb.MessageData{
+			{Id: "m1", SenderId: "alice", ReceiverId: "bob", Seq: 3},
+			{Id: "g-m1", SenderId: "alice", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 7},
+		},
+		groups: map[string][]string{"g1": {"alice", "bob"}},
+	}
+	r := NewReceiptHandle(rdb, history, NewDisappearHandle(rdb, history, nil, nil), nil)
+
+	resp, err := r.ReportDelivered(ctx, &pb.ReportDeliveredRequest{Acks: []*pb.DeliveryAck{
+		// 伪造的发送者与seq
+		{MessageId: "m1", SenderId: "mallory", ReceiverId: "bob", Seq: 99},
+		// 不是发给 carol 的消息
+		{MessageId: "m1", SenderId: "alice", ReceiverId: "carol"},
+		{MessageId: "missing", SenderId: "mallory", ReceiverId: "bob"},
+		// 发送者自己的其他设备
+		{MessageId: "m1", SenderId: "alice", ReceiverId: "alice"},
+		{MessageId: "g-m1", ReceiverId: "bob", ConversationId: "other", SessionType: pb.SesstionType_SINGLE},
+		{MessageId: "g-m1", SenderId: "alice", ReceiverId: "carol", SessionType: pb.SesstionType_GROUP},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Accepted != 2 {
+		t.Fatalf("expected 2 accepted, got %d", resp.Accepted)
+	}
+	if sender := rdb.HGet(ctx, DELIVERY_PREFIX+"m1", "sender").Val(); sender != "alice" {
+		t.Fatalf("expected stored sender alice, got %q", sender)
+	}
+	if rdb.Exists(ctx, DELIVERY_PREFIX+"missing").Val() != 0 || rdb.HExists(ctx, DELIVERY_PREFIX+"g-m1", "r:carol").Val() {
+		t.Fatal("ack for invisible message recorded")
+	}
+	if len(pushes.pushed("mallory", KIND_RECEIPT)) != 0 {
+		t.Fatal("delivered event pushed to forged sender")
+	}
+
+	events := pushes.pushed("alice", KIND_RECEIPT)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 delivered events, got %d", len(events))
+	}
+	want := map[string]DeliveredEvent{
+		"m1":   {Type: "delivered", MessageId: "m1", ConversationId: "bob", SessionType: "single", Receiver: "bob", Seq: 3},
+		"g-m1": {Type: "delivered", MessageId: "g-m1", ConversationId: "g1", SessionType: "group", Receiver: "bob", Seq: 7},
+	}
+	for _, body := range events {
+		var event DeliveredEvent
+		json.Unmarshal(body, &event)
+		event.DeliveredAt = 0
+		if event != want[event.MessageId] {
+			t.Fatalf("unexpected delivered event %+v", event)
+		}
+	}
+
+	// 再次送达不重复通知
+	if _, err := r.ReportDelivered(ctx, &pb.ReportDeliveredRequest{Acks: []*pb.DeliveryAck{{MessageId: "m1", ReceiverId: "bob"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(pushes.pushed("alice", KIND_RECEIPT)) != 2 {
+		t.Fatal("delivered event pushed twice")
+	}
+}
+
+// 测试只有发送者可以查询送达状态
+func TestQueryDeliveryBySender(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	newTestPusher(t, rdb, "alice")
+	history := &fakeHistory{saved: []*pb.MessageData{{Id: "m1", SenderId: "alice", ReceiverId: "bob", Seq: 1}}}
+	r := NewReceiptHandle(rdb, history, NewDisappearHandle(rdb, history, nil, nil), nil)
+
+	if _, err := r.ReportDelivered(ctx, &pb.ReportDeliveredRequest{Acks: []*pb.DeliveryAck{{MessageId: "m1", ReceiverId: "bob"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.QueryDelivery(ctx, &pb.QueryDeliveryRequest{Uid: "alice", MessageId: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Delivered || resp.AckedCount != 1 || resp.AckedUids[0] != "bob" {
+		t.Fatalf("unexpected delivery status %v", resp)
+	}
+	for _, uid := range []string{"bob", "mallory"} {
+		if _, err := r.QueryDelivery(ctx, &pb.QueryDeliveryRequest{Uid: uid, MessageId: "m1"}); err != ErrNotSender {
+			t.Fatalf("expected ErrNotSender for %s, got %v", uid, err)
+		}
+	}
+	if _, err := r.QueryDelivery(ctx, &pb.QueryDeliveryRequest{MessageId: "m1"}); err != ErrInvalidArgument {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+}
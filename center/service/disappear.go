@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	seqpb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// 阅后即焚
+//
+// 计时器按会话存储为hash
+// key : disappear:{conversation}
+// field : ttl 存活秒数, start 开始计时的时机, by 修改者, at 修改时间
+//
+// 开启后发送的消息在 ext 中记录 disappear_ttl / disappear_start, 发送后计时的消息同时记录 expire_at
+// 1. 发送后计时 : 消息落库后直接加入到期队列
+// 2. 已读后计时 : 消息先加入待读集合, 接收者已读游标越过消息seq时移入到期队列
+//    私聊按接收者区分 disappearPending:{conversation}:{uid}, 群聊任一成员(发送者除外)已读即开始
+//
+// 到期队列 disappearQueue 为zset, score 为到期时间(毫秒), member 为 {conversation}|{messageId}
+// 各center节点定期扫描到期的消息, 通过 ZREM 抢占后删除, 同一条消息只会被一个节点处理
+// 删除后向会话成员推送删除事件, 离线设备依据 ext 中的计时信息自行清理
+
+const (
+	DISAPPEAR_PREFIX         = "disappear:"
+	DISAPPEAR_PENDING_PREFIX = "disappearPending:"
+	DISAPPEAR_QUEUE          = "disappearQueue"
+
+	DISAPPEAR_INTERVAL = time.Second
+	DISAPPEAR_BATCH    = 100
+	// 删除失败后重试的间隔
+	DISAPPEAR_RETRY = 30 * time.Second
+	// 计时器最长存活时间
+	MAX_DISAPPEAR_TTL = 30 * 24 * time.Hour
+)
+
+// MessageData.ext 中的计时信息
+const (
+	EXT_DISAPPEAR_TTL   = "disappear_ttl"
+	EXT_DISAPPEAR_START = "disappear_start"
+	EXT_EXPIRE_AT       = "expire_at"
+)
+
+// 通知消息类型, NOTICE 消息 payload 中的 type
+const NOTICE_DISAPPEAR_TIMER = "disappear_timer"
+
+// TimerNotice 计时器修改通知的payload
+type TimerNotice struct {
+	Type       string `json:"type"`
+	TtlSeconds int64  `json:"ttl_seconds"`
+	Start      string `json:"start"`
+	Operator   string `json:"operator"`
+}
+
+// DeleteEvent 推送给会话成员的删除事件
+type DeleteEvent struct {
+	Type           string   `json:"type"`
+	ConversationId string   `json:"conversation_id"`
+	SessionType    string   `json:"session_type"`
+	MessageIds     []string `json:"message_ids"`
+	Reason         string   `json:"reason"`
+}
+
+// NoticeEvent 推送给会话成员的通知消息
+type NoticeEvent struct {
+	Type           string          `json:"type"`
+	MessageId      string          `json:"message_id"`
+	ConversationId string          `json:"conversation_id"`
+	SessionType    string          `json:"session_type"`
+	Sender         string          `json:"sender"`
+	Seq            int64           `json:"seq"`
+	SendTime       int64           `json:"send_time"`
+	Notice         json.RawMessage `json:"notice"`
+}
+
+type DisappearHandle struct {
+	pb.UnimplementedDisappearServiceServer
+	redis    redis.UniversalClient
+	history  storagepb.HistoryServiceClient
+	seq      seqpb.SequenceServiceClient
+	timeline *Timeline
+}
+
+// timeline 为nil时通知消息不写入时间线
+func NewDisappearHandle(redis redis.UniversalClient, history storagepb.HistoryServiceClient, seq seqpb.SequenceServiceClient, timeline *Timeline) *DisappearHandle {
+	return &DisappearHandle{
+		redis:    redis,
+		history:  history,
+		seq:      seq,
+		timeline: timeline,
+	}
+}
+
+func (d *DisappearHandle) SetTimer(ctx context.Context, in *pb.SetDisappearTimerRequest) (*pb.SetDisappearTimerResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.TtlSeconds < 0 ||
+		time.Duration(in.TtlSeconds)*time.Second > MAX_DISAPPEAR_TTL {
+		return nil, ErrInvalidArgument
+	}
+	if err := d.checkMember(ctx, in.Uid, in.ConversationId, in.SessionType); err != nil {
+		return nil, err
+	}
+
+	timer := &pb.DisappearTimer{
+		TtlSeconds: in.TtlSeconds,
+		Start:      in.Start,
+		UpdatedBy:  in.Uid,
+		UpdatedAt:  time.Now().UnixMilli(),
+	}
+	key := DISAPPEAR_PREFIX + conversationKey(in.Uid, in.ConversationId, in.SessionType)
+	err := d.redis.HSet(ctx, key,
+		"ttl", timer.TtlSeconds,
+		"start", int32(timer.Start),
+		"by", timer.UpdatedBy,
+		"at", timer.UpdatedAt,
+	).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	notice, err := d.announce(ctx, in, timer)
+	if err != nil {
+		// 计时器已生效, 通知失败不回滚, 成员下次查询计时器时可获得最新设置
+		log.Printf("[WARN] announce disappear timer of %s: %v", key, err)
+	}
+	return &pb.SetDisappearTimerResponse{Timer: timer, Notice: notice}, nil
+}
+
+func (d *DisappearHandle) GetTimer(ctx context.Context, in *pb.GetDisappearTimerRequest) (*pb.DisappearTimer, error) {
+	if in.Uid == "" || in.ConversationId == "" {
+		return nil, ErrInvalidArgument
+	}
+	if err := d.checkMember(ctx, in.Uid, in.ConversationId, in.SessionType); err != nil {
+		return nil, err
+	}
+	return d.timer(ctx, conversationKey(in.Uid, in.ConversationId, in.SessionType))
+}
+
+// Stamp 会话开启了计时器时在消息 ext 中记录计时信息, 需在落库前调用
+func (d *DisappearHandle) Stamp(ctx context.Context, msg *pb.MessageData) error {
+	if msg.MessageType == pb.MessageType_NOTICE {
+		return nil
+	}
+	timer, err := d.timer(ctx, conversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType))
+	if err != nil || timer.TtlSeconds == 0 {
+		return err
+	}
+
+	if msg.Ext == nil {
+		msg.Ext = make(map[string]string)
+	}
+	msg.Ext[EXT_DISAPPEAR_TTL] = strconv.FormatInt(timer.TtlSeconds, 10)
+	msg.Ext[EXT_DISAPPEAR_START] = strings.ToLower(strings.TrimPrefix(timer.Start.String(), "START_ON_"))
+	if timer.Start == pb.DisappearStart_START_ON_SEND {
+		if msg.SendTime <= 0 {
+			msg.SendTime = time.Now().UnixMilli()
+		}
+		msg.Ext[EXT_EXPIRE_AT] = strconv.FormatInt(msg.SendTime+timer.TtlSeconds*1000, 10)
+	}
+	return nil
+}
+
+// Schedule 为已落库的计时消息开始计时或等待已读
+func (d *DisappearHandle) Schedule(ctx context.Context, msg *pb.MessageData) error {
+	ttl := msg.Ext[EXT_DISAPPEAR_TTL]
+	if ttl == "" {
+		return nil
+	}
+	conversation := conversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType)
+
+	if expireAt, ok := msg.Ext[EXT_EXPIRE_AT]; ok {
+		score, err := strconv.ParseFloat(expireAt, 64)
+		if err != nil {
+			return err
+		}
+		return d.redis.ZAdd(ctx, DISAPPEAR_QUEUE, redis.Z{Score: score, Member: conversation + "|" + msg.Id}).Err()
+	}
+
+	pending := DISAPPEAR_PENDING_PREFIX + conversation
+	if msg.SesstionType != pb.SesstionType_GROUP {
+		pending += ":" + msg.ReceiverId
+	}
+	return d.redis.ZAdd(ctx, pending, redis.Z{
+		Score:  float64(msg.Seq),
+		Member: ttl + "|" + msg.SenderId + "|" + msg.Id,
+	}).Err()
+}
+
+// OnRead 已读游标前进到 seq 时, 对该用户已读的待读消息开始计时
+func (d *DisappearHandle) OnRead(ctx context.Context, uid, conversationId string, typ pb.SesstionType, seq int64) error {
+	conversation := conversationKey(uid, conversationId, typ)
+	pending := DISAPPEAR_PENDING_PREFIX + conversation
+	if typ != pb.SesstionType_GROUP {
+		pending += ":" + uid
+	}
+
+	members, err := d.redis.ZRangeByScore(ctx, pending, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(seq, 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	for _, member := range members {
+		parts := strings.SplitN(member, "|", 3)
+		if len(parts) != 3 || parts[1] == uid {
+			continue
+		}
+		ttl, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		// 抢占成功的一方负责开始计时
+		removed, err := d.redis.ZRem(ctx, pending, member).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		err = d.redis.ZAdd(ctx, DISAPPEAR_QUEUE, redis.Z{
+			Score:  float64(now + ttl*1000),
+			Member: conversation + "|" + parts[2],
+		}).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run 定期删除到期的消息, 阻塞直到ctx结束
+func (d *DisappearHandle) Run(ctx context.Context) {
+	ticker := time.NewTicker(DISAPPEAR_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := d.expire(ctx, now); err != nil {
+				log.Printf("[WARN] expire disappearing messages: %v", err)
+			}
+		}
+	}
+}
+
+func (d *DisappearHandle) expire(ctx context.Context, now time.Time) error {
+	members, err := d.redis.ZRangeByScore(ctx, DISAPPEAR_QUEUE, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: DISAPPEAR_BATCH,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	// 按会话分组, 每个会话一次删除、一次推送
+	expired := make(map[string][]string)
+	claimed := make(map[string][]string)
+	for _, member := range members {
+		conversation, id, ok := strings.Cut(member, "|")
+		if !ok {
+			d.redis.ZRem(ctx, DISAPPEAR_QUEUE, member)
+			continue
+		}
+		removed, err := d.redis.ZRem(ctx, DISAPPEAR_QUEUE, member).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		expired[conversation] = append(expired[conversation], id)
+		claimed[conversation] = append(claimed[conversation], member)
+	}
+
+	for conversation, ids := range expired {
+		_, err := d.history.DeleteMessages(ctx, &storagepb.DeleteMessagesRequest{MessageIds: ids})
+		if err != nil {
+			// 放回队列稍后重试
+			retry := make([]redis.Z, len(claimed[conversation]))
+			for i, member := range claimed[conversation] {
+				retry[i] = redis.Z{Score: float64(now.Add(DISAPPEAR_RETRY).UnixMilli()), Member: member}
+			}
+			d.redis.ZAdd(ctx, DISAPPEAR_QUEUE, retry...)
+			log.Printf("[WARN] delete disappearing messages of %s: %v", conversation, err)
+			continue
+		}
+		d.broadcast(ctx, conversation, KIND_DELETE, func(uid, conversationId, sessionType string) any {
+			return DeleteEvent{
+				Type:           "delete",
+				ConversationId: conversationId,
+				SessionType:    sessionType,
+				MessageIds:     ids,
+				Reason:         "disappear",
+			}
+		})
+	}
+	return nil
+}
+
+// announce 在会话中生成计时器修改的通知消息, 落库后推送给在线成员
+func (d *DisappearHandle) announce(ctx context.Context, in *pb.SetDisappearTimerRequest, timer *pb.DisappearTimer) (*pb.MessageData, error) {
+	notice, err := json.Marshal(TimerNotice{
+		Type:       NOTICE_DISAPPEAR_TIMER,
+		TtlSeconds: timer.TtlSeconds,
+		Start:      strings.ToLower(strings.TrimPrefix(timer.Start.String(), "START_ON_")),
+		Operator:   in.Uid,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := d.seq.GenerateMessageId(ctx, &seqpb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	seq, err := d.seq.GenerateMessageSeq(ctx, &seqpb.MessageSeqRequest{
+		SenderId:    in.Uid,
+		ReceiverId:  in.ConversationId,
+		SessionType: in.SessionType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.MessageData{
+		Id:           id.Id,
+		SenderId:     in.Uid,
+		ReceiverId:   in.ConversationId,
+		MessageType:  pb.MessageType_NOTICE,
+		SesstionType: in.SessionType,
+		Payload:      notice,
+		Seq:          seq.Seq,
+		SendTime:     timer.UpdatedAt,
+	}
+	if _, err := d.history.SaveMessages(ctx, &storagepb.SaveMessagesRequest{Messages: []*pb.MessageData{msg}}); err != nil {
+		return nil, err
+	}
+
+	uids, err := conversationMembers(ctx, d.redis, msg)
+	if err == nil {
+		err = d.timeline.Append(ctx, uids, &storagepb.TimelineEvent{
+			Type:           storagepb.EventType_EVENT_MESSAGE,
+			ConversationId: conversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType),
+			Seq:            msg.Seq,
+			MessageId:      msg.Id,
+			Time:           msg.SendTime,
+		})
+	}
+	if err != nil {
+		log.Printf("[WARN] append notice %s to timeline: %v", msg.Id, err)
+	}
+
+	d.broadcast(ctx, conversationKey(in.Uid, in.ConversationId, in.SessionType), KIND_NOTICE, func(uid, conversationId, sessionType string) any {
+		return NoticeEvent{
+			Type:           "notice",
+			MessageId:      msg.Id,
+			ConversationId: conversationId,
+			SessionType:    sessionType,
+			Sender:         msg.SenderId,
+			Seq:            msg.Seq,
+			SendTime:       msg.SendTime,
+			Notice:         notice,
+		}
+	})
+	return msg, nil
+}
+
+// broadcast 向会话的全部成员推送事件, event 按成员视角生成事件
+// 私聊的会话id为对方uid, 群聊为群id
+func (d *DisappearHandle) broadcast(ctx context.Context, conversation, kind string, event func(uid, conversationId, sessionType string) any) {
+	var (
+		members     []string
+		peers       = make(map[string]string)
+		sessionType = "single"
+	)
+	switch {
+	case strings.HasPrefix(conversation, "g:"):
+		gid := strings.TrimPrefix(conversation, "g:")
+		uids, err := d.redis.SMembers(ctx, GROUP_MEMBER_PREFIX+gid).Result()
+		if err != nil {
+			log.Printf("[WARN] load members of %s: %v", conversation, err)
+			return
+		}
+		for _, uid := range uids {
+			peers[uid] = gid
+		}
+		members, sessionType = uids, "group"
+	case strings.HasPrefix(conversation, "s:"):
+		a, b, ok := strings.Cut(strings.TrimPrefix(conversation, "s:"), ":")
+		if !ok {
+			return
+		}
+		members = []string{a, b}
+		peers[a], peers[b] = b, a
+	}
+
+	for _, uid := range members {
+		// 推送失败不影响删除, 客户端可依据 ext 中的计时信息自行清理
+		_ = PusherTemplate().Push(ctx, uid, kind, event(uid, peers[uid], sessionType))
+	}
+}
+
+// timer 读取会话的计时器, 未设置时返回关闭状态
+func (d *DisappearHandle) timer(ctx context.Context, conversation string) (*pb.DisappearTimer, error) {
+	fields, err := d.redis.HGetAll(ctx, DISAPPEAR_PREFIX+conversation).Result()
+	if err != nil {
+		return nil, err
+	}
+	timer := &pb.DisappearTimer{UpdatedBy: fields["by"]}
+	timer.TtlSeconds, _ = strconv.ParseInt(fields["ttl"], 10, 64)
+	timer.UpdatedAt, _ = strconv.ParseInt(fields["at"], 10, 64)
+	start, _ := strconv.ParseInt(fields["start"], 10, 32)
+	timer.Start = pb.DisappearStart(start)
+	return timer, nil
+}
+
+func (d *DisappearHandle) checkMember(ctx context.Context, uid, conversationId string, typ pb.SesstionType) error {
+	if typ != pb.SesstionType_GROUP {
+		return nil
+	}
+	ok, err := d.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+conversationId, uid).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotMember
+	}
+	return nil
+}
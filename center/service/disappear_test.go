@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	seqpb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// fakeHistory 记录写入与删除的消息, err 不为nil时删除失败
+// groups 为群成员, 查询消息时与storage一样只返回uid可见的消息
+type fakeHistory struct {
+	storagepb.HistoryServiceClient
+	saved   []*pb.MessageData
+	deleted []string
+	groups  map[string][]string
+	err     error
+}
+
+func (f *fakeHistory) GetMessages(_ context.Context, in *storagepb.GetMessagesRequest, _ ...grpc.CallOption) (*storagepb.GetMessagesResponse, error) {
+	resp := &storagepb.GetMessagesResponse{}
+	for _, msg := range f.saved {
+		if !slices.Contains(in.MessageIds, msg.Id) {
+			continue
+		}
+		if msg.SesstionType == pb.SesstionType_GROUP {
+			if slices.Contains(f.groups[msg.ReceiverId], in.Uid) {
+				resp.Messages = append(resp.Messages, msg)
+			}
+		} else if msg.SenderId == in.Uid || msg.ReceiverId == in.Uid {
+			resp.Messages = append(resp.Messages, msg)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeHistory) SaveMessages(_ context.Context, in *storagepb.SaveMessagesRequest, _ ...grpc.CallOption) (*storagepb.SaveMessagesResponse, error) {
+	f.saved = append(f.saved, in.Messages...)
+	return &storagepb.SaveMessagesResponse{Saved: int32(len(in.Messages))}, nil
+}
+
+func (f *fakeHistory) RecallMessage(_ context.Context, in *storagepb.RecallMessageRequest, _ ...grpc.CallOption) (*storagepb.RecallMessageResponse, error) {
+	for _, msg := range f.saved {
+		if msg.Id != in.MessageId {
+			continue
+		}
+		if msg.SenderId != in.Uid {
+			return nil, errors.New("not sender")
+		}
+		msg.Status = 1
+		return &storagepb.RecallMessageResponse{Message: msg}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (f *fakeHistory) DeleteMessages(_ context.Context, in *storagepb.DeleteMessagesRequest, _ ...grpc.CallOption) (*storagepb.DeleteMessagesResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.deleted = append(f.deleted, in.MessageIds...)
+	return &storagepb.DeleteMessagesResponse{Deleted: int32(len(in.MessageIds))}, nil
+}
+
+// fakeSeq 依次分配消息id与seq, inbox seq 按用户递增
+type fakeSeq struct {
+	seqpb.SequenceServiceClient
+	next  int64
+	inbox map[string]int64
+}
+
+func (f *fakeSeq) GenerateMessageId(context.Context, *seqpb.Empty, ...grpc.CallOption) (*seqpb.MessageIdResponse, error) {
+	f.next++
+	return &seqpb.MessageIdResponse{Id: "n" + strconv.FormatInt(f.next, 10)}, nil
+}
+
+func (f *fakeSeq) GenerateMessageSeq(context.Context, *seqpb.MessageSeqRequest, ...grpc.CallOption) (*seqpb.MessageResponse, error) {
+	return &seqpb.MessageResponse{Seq: f.next}, nil
+}
+
+func (f *fakeSeq) GenerateInboxSeqs(_ context.Context, in *seqpb.InboxSeqsRequest, _ ...grpc.CallOption) (*seqpb.InboxSeqsResponse, error) {
+	if f.inbox == nil {
+		f.inbox = make(map[string]int64)
+	}
+	resp := &seqpb.InboxSeqsResponse{}
+	for _, uid := range in.Uids {
+		f.inbox[uid]++
+		resp.Seqs = append(resp.Seqs, &seqpb.InboxSeq{Uid: uid, Seq: f.inbox[uid]})
+	}
+	return resp, nil
+}
+
+// stampAndSchedule 模拟发送: 落库前记录计时信息, 落库后开始计时或等待已读
+func stampAndSchedule(t *testing.T, d *DisappearHandle, msg *pb.MessageData) {
+	t.Helper()
+	ctx := context.Background()
+	if err := d.Stamp(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Schedule(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setTimer(t *testing.T, d *DisappearHandle, in *pb.SetDisappearTimerRequest) *pb.SetDisappearTimerResponse {
+	t.Helper()
+	resp, err := d.SetTimer(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// 测试发送后计时: 落库后加入到期队列, 到期后从storage删除并通知双方
+func TestDisappearStartOnSend(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice", "bob")
+	history := &fakeHistory{}
+	d := NewDisappearHandle(rdb, history, &fakeSeq{}, nil)
+
+	setTimer(t, d, &pb.SetDisappearTimerRequest{Uid: "alice", ConversationId: "bob", TtlSeconds: 60, Start: pb.DisappearStart_START_ON_SEND})
+
+	sent := time.Now()
+	msg := &pb.MessageData{Id: "m1", SenderId: "alice", ReceiverId: "bob", MessageType: pb.MessageType_TEXT, Seq: 1, SendTime: sent.UnixMilli()}
+	stampAndSchedule(t, d, msg)
+	expireAt := sent.UnixMilli() + 60*1000
+	if msg.Ext[EXT_DISAPPEAR_TTL] != "60" || msg.Ext[EXT_DISAPPEAR_START] != "send" || msg.Ext[EXT_EXPIRE_AT] != strconv.FormatInt(expireAt, 10) {
+		t.Fatalf("unexpected ext %v", msg.Ext)
+	}
+	if score := rdb.ZScore(ctx, DISAPPEAR_QUEUE, "s:alice:bob|m1").Val(); int64(score) != expireAt {
+		t.Fatalf("expected expire at %d, got %v", expireAt, score)
+	}
+
+	// 未到期时不删除
+	if err := d.expire(ctx, sent.Add(59*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.deleted) != 0 {
+		t.Fatalf("deleted before expiry: %v", history.deleted)
+	}
+
+	if err := d.expire(ctx, sent.Add(60*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(history.deleted, []string{"m1"}) {
+		t.Fatalf("expected m1 deleted, got %v", history.deleted)
+	}
+	if n := rdb.ZCard(ctx, DISAPPEAR_QUEUE).Val(); n != 0 {
+		t.Fatalf("expected empty queue, got %d", n)
+	}
+	for uid, peer := range map[string]string{"alice": "bob", "bob": "alice"} {
+		events := pushes.pushed(uid, KIND_DELETE)
+		if len(events) != 1 {
+			t.Fatalf("expected 1 delete event for %s, got %d", uid, len(events))
+		}
+		var event DeleteEvent
+		json.Unmarshal(events[0], &event)
+		if event.ConversationId != peer || !slices.Equal(event.MessageIds, []string{"m1"}) || event.Reason != "disappear" {
+			t.Fatalf("unexpected delete event for %s: %+v", uid, event)
+		}
+	}
+
+	// 关闭计时器后发送的消息不再计时
+	setTimer(t, d, &pb.SetDisappearTimerRequest{Uid: "bob", ConversationId: "alice", TtlSeconds: 0})
+	msg = &pb.MessageData{Id: "m2", SenderId: "bob", ReceiverId: "alice", MessageType: pb.MessageType_TEXT, Seq: 2}
+	stampAndSchedule(t, d, msg)
+	if msg.Ext != nil || rdb.ZCard(ctx, DISAPPEAR_QUEUE).Val() != 0 {
+		t.Fatalf("message stamped after timer disabled: %v", msg.Ext)
+	}
+}
+
+// 测试已读后计时: 接收者已读游标越过消息seq时才开始计时, 发送者自己已读不计时
+func TestDisappearStartOnRead(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	newTestPusher(t, rdb)
+	d := NewDisappearHandle(rdb, &fakeHistory{}, &fakeSeq{}, nil)
+	r := NewReceiptHandle(rdb, &fakeHistory{}, d, nil)
+
+	setTimer(t, d, &pb.SetDisappearTimerRequest{Uid: "alice", ConversationId: "bob", TtlSeconds: 30, Start: pb.DisappearStart_START_ON_READ})
+	for seq := int64(1); seq <= 2; seq++ {
+		msg := &pb.MessageData{Id: "m" + strconv.FormatInt(seq, 10), SenderId: "alice", ReceiverId: "bob", MessageType: pb.MessageType_TEXT, Seq: seq}
+		stampAndSchedule(t, d, msg)
+		if _, ok := msg.Ext[EXT_EXPIRE_AT]; ok || msg.Ext[EXT_DISAPPEAR_START] != "read" {
+			t.Fatalf("unexpected ext %v", msg.Ext)
+		}
+	}
+	if n := rdb.ZCard(ctx, DISAPPEAR_QUEUE).Val(); n != 0 {
+		t.Fatalf("timer started before read: %d", n)
+	}
+
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "alice", ConversationId: "bob", Seq: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if n := rdb.ZCard(ctx, DISAPPEAR_QUEUE).Val(); n != 0 {
+		t.Fatalf("timer started by sender: %d", n)
+	}
+
+	before := time.Now().UnixMilli()
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "bob", ConversationId: "alice", Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	queued := rdb.ZRangeWithScores(ctx, DISAPPEAR_QUEUE, 0, -1).Val()
+	if len(queued) != 1 || queued[0].Member != "s:alice:bob|m1" ||
+		int64(queued[0].Score) < before+30*1000 || int64(queued[0].Score) > time.Now().UnixMilli()+30*1000 {
+		t.Fatalf("unexpected queue %v", queued)
+	}
+	if pending := rdb.ZRange(ctx, DISAPPEAR_PENDING_PREFIX+"s:alice:bob:bob", 0, -1).Val(); len(pending) != 1 {
+		t.Fatalf("expected m2 still pending, got %v", pending)
+	}
+
+	// 群聊中任一其他成员已读即开始计时
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g1", "alice", "bob", "carol")
+	setTimer(t, d, &pb.SetDisappearTimerRequest{Uid: "alice", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, TtlSeconds: 30})
+	stampAndSchedule(t, d, &pb.MessageData{Id: "g-m1", SenderId: "alice", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, MessageType: pb.MessageType_TEXT, Seq: 1})
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "alice", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if rdb.ZScore(ctx, DISAPPEAR_QUEUE, "g:g1|g-m1").Err() == nil {
+		t.Fatal("timer started by group sender")
+	}
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "carol", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.ZScore(ctx, DISAPPEAR_QUEUE, "g:g1|g-m1").Err(); err != nil {
+		t.Fatalf("timer not started by group member: %v", err)
+	}
+}
+
+// 测试storage删除失败时放回队列, 稍后重试
+func TestDisappearExpireRetry(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice")
+	history := &fakeHistory{err: errors.New("storage unavailable")}
+	d := NewDisappearHandle(rdb, history, &fakeSeq{}, nil)
+
+	setTimer(t, d, &pb.SetDisappearTimerRequest{Uid: "alice", ConversationId: "bob", TtlSeconds: 1, Start: pb.DisappearStart_START_ON_SEND})
+	sent := time.Now()
+	stampAndSchedule(t, d, &pb.MessageData{Id: "m1", SenderId: "alice", ReceiverId: "bob", MessageType: pb.MessageType_TEXT, Seq: 1, SendTime: sent.UnixMilli()})
+
+	now := sent.Add(time.Second)
+	if err := d.expire(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if score := rdb.ZScore(ctx, DISAPPEAR_QUEUE, "s:alice:bob|m1").Val(); int64(score) != now.Add(DISAPPEAR_RETRY).UnixMilli() {
+		t.Fatalf("expected retry at %v, got %v", now.Add(DISAPPEAR_RETRY).UnixMilli(), score)
+	}
+	if len(pushes.pushed("alice", KIND_DELETE)) != 0 {
+		t.Fatal("delete event pushed before deletion")
+	}
+
+	history.err = nil
+	if err := d.expire(ctx, now.Add(DISAPPEAR_RETRY)); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(history.deleted, []string{"m1"}) || len(pushes.pushed("alice", KIND_DELETE)) != 1 {
+		t.Fatalf("expected m1 deleted on retry, got %v", history.deleted)
+	}
+}
+
+// 测试修改计时器时在会话中生成通知消息, 落库并推送给全部成员
+func TestDisappearTimerNotice(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice", "bob", "carol")
+	history := &fakeHistory{}
+	d := NewDisappearHandle(rdb, history, &fakeSeq{}, nil)
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g1", "alice", "bob", "carol")
+
+	in := &pb.SetDisappearTimerRequest{Uid: "bob", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, TtlSeconds: 3600, Start: pb.DisappearStart_START_ON_SEND}
+	resp := setTimer(t, d, in)
+
+	notice := resp.Notice
+	if notice == nil || notice.MessageType != pb.MessageType_NOTICE || notice.Id != "n1" || notice.Seq != 1 || notice.SenderId != "bob" {
+		t.Fatalf("unexpected notice %v", notice)
+	}
+	var payload TimerNotice
+	if err := json.Unmarshal(notice.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload != (TimerNotice{Type: NOTICE_DISAPPEAR_TIMER, TtlSeconds: 3600, Start: "send", Operator: "bob"}) {
+		t.Fatalf("unexpected notice payload %+v", payload)
+	}
+	if len(history.saved) != 1 || history.saved[0] != notice {
+		t.Fatalf("notice not saved: %v", history.saved)
+	}
+	for _, uid := range []string{"alice", "bob", "carol"} {
+		events := pushes.pushed(uid, KIND_NOTICE)
+		if len(events) != 1 {
+			t.Fatalf("expected 1 notice for %s, got %d", uid, len(events))
+		}
+		var event NoticeEvent
+		json.Unmarshal(events[0], &event)
+		if event.MessageId != "n1" || event.ConversationId != "g1" || event.SessionType != "group" {
+			t.Fatalf("unexpected notice event for %s: %+v", uid, event)
+		}
+	}
+
+	// 通知消息本身不计时
+	stampAndSchedule(t, d, notice)
+	if _, ok := notice.Ext[EXT_DISAPPEAR_TTL]; ok {
+		t.Fatal("notice stamped with timer")
+	}
+
+	timer, err := d.GetTimer(ctx, &pb.GetDisappearTimerRequest{Uid: "alice", ConversationId: "g1", SessionType: pb.SesstionType_GROUP})
+	if err != nil || timer.TtlSeconds != 3600 || timer.Start != pb.DisappearStart_START_ON_SEND || timer.UpdatedBy != "bob" {
+		t.Fatalf("unexpected timer %v, %v", timer, err)
+	}
+	if _, err := d.SetTimer(ctx, &pb.SetDisappearTimerRequest{Uid: "mallory", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, TtlSeconds: 1}); err != ErrNotMember {
+		t.Fatalf("expected ErrNotMember, got %v", err)
+	}
+}
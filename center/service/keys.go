@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+// 端到端加密密钥分发
+//
+// 设备列表按用户存储为hash, field 为设备id, value 为序列化的 Device
+// key : e2eeDevices:{uid}
+// 一次性预共享公钥按设备存储为list, 分发时从头部弹出, 保证每个公钥只被使用一次
+// key : e2eePrekeys:{uid}:{deviceId}
+// key中的 {uid} 为hash tag, 同一用户的设备与公钥位于同一个slot, 可以在事务中一起修改
+// 注册设备时 WATCH 设备列表, 设备数量的检查与写入在同一个事务中完成, 并发注册时不会超出上限
+//
+// 私聊开启端到端加密后记录开启者与开启时间, 只有开启的会话可以发送 ENCRYPTED 消息
+// key : e2eeConversation:{conversation}
+//
+// 服务端不校验签名, 也不保存任何私钥, 由客户端校验身份公钥与签名预共享公钥
+
+const (
+	E2EE_DEVICES_PREFIX      = "e2eeDevices:"
+	E2EE_PREKEYS_PREFIX      = "e2eePrekeys:"
+	E2EE_CONVERSATION_PREFIX = "e2eeConversation:"
+
+	// 每台设备最多保存的一次性公钥, 超出时丢弃最早上传的
+	MAX_PREKEYS = 200
+	// 每个用户最多注册的设备数
+	MAX_DEVICES = 16
+	// 注册设备时设备列表被并发修改后的最大重试次数
+	MAX_REGISTER_RETRIES = 5
+)
+
+var (
+	ErrDeviceNotFound = errors.New("device not found")
+	ErrTooManyDevices = errors.New("too many devices")
+	ErrNoDevice       = errors.New("both users must register a device before enabling encryption")
+)
+
+type KeyHandle struct {
+	pb.UnimplementedKeyServiceServer
+	redis redis.UniversalClient
+}
+
+func NewKeyHandle(redis redis.UniversalClient) *KeyHandle {
+	return &KeyHandle{redis: redis}
+}
+
+func devicesKey(uid string) string {
+	return E2EE_DEVICES_PREFIX + "{" + uid + "}"
+}
+
+func prekeysKey(uid, deviceId string) string {
+	return E2EE_PREKEYS_PREFIX + "{" + uid + "}:" + deviceId
+}
+
+func (k *KeyHandle) RegisterDevice(ctx context.Context, in *pb.RegisterDeviceRequest) (*pb.RegisterDeviceResponse, error) {
+	if in.Uid == "" || in.DeviceId == "" || len(in.IdentityKey) == 0 || !validSignedPrekey(in.SignedPrekey) || !validPrekeys(in.Prekeys) {
+		return nil, ErrInvalidArgument
+	}
+
+	var device *pb.Device
+	register := func(tx *redis.Tx) error {
+		vals, err := tx.HGetAll(ctx, devicesKey(in.Uid)).Result()
+		if err != nil {
+			return err
+		}
+		devices, err := parseDevices(vals)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixMilli()
+		device = &pb.Device{
+			DeviceId:       in.DeviceId,
+			RegistrationId: in.RegistrationId,
+			IdentityKey:    in.IdentityKey,
+			SignedPrekey:   in.SignedPrekey,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		// 身份密钥不变时视为重复注册, 保留剩余的一次性公钥
+		reset := true
+		if old, ok := devices[in.DeviceId]; ok {
+			device.CreatedAt = old.CreatedAt
+			reset = !bytes.Equal(old.IdentityKey, in.IdentityKey)
+		} else if len(devices) >= MAX_DEVICES {
+			return ErrTooManyDevices
+		}
+
+		data, err := proto.Marshal(device)
+		if err != nil {
+			return err
+		}
+		key := prekeysKey(in.Uid, in.DeviceId)
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, devicesKey(in.Uid), in.DeviceId, data)
+			if reset {
+				p.Del(ctx, key)
+			}
+			k.pushPrekeys(ctx, p, key, in.Prekeys)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for i := 0; i < MAX_REGISTER_RETRIES; i++ {
+		// 读取后设备列表被修改时事务不执行, 重新读取后再检查
+		err = k.redis.Watch(ctx, register, devicesKey(in.Uid))
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RegisterDeviceResponse{Device: device}, nil
+}
+
+func (k *KeyHandle) UploadPrekeys(ctx context.Context, in *pb.UploadPrekeysRequest) (*pb.UploadPrekeysResponse, error) {
+	if in.Uid == "" || in.DeviceId == "" || !validPrekeys(in.Prekeys) ||
+		(in.SignedPrekey != nil && !validSignedPrekey(in.SignedPrekey)) {
+		return nil, ErrInvalidArgument
+	}
+
+	device, err := k.device(ctx, in.Uid, in.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if in.SignedPrekey != nil {
+		device.SignedPrekey = in.SignedPrekey
+		device.UpdatedAt = time.Now().UnixMilli()
+		if data, err = proto.Marshal(device); err != nil {
+			return nil, err
+		}
+	}
+
+	key := prekeysKey(in.Uid, in.DeviceId)
+	var count *redis.IntCmd
+	_, err = k.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if data != nil {
+			p.HSet(ctx, devicesKey(in.Uid), in.DeviceId, data)
+		}
+		k.pushPrekeys(ctx, p, key, in.Prekeys)
+		count = p.LLen(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UploadPrekeysResponse{PrekeyCount: count.Val()}, nil
+}
+
+// FetchBundles 为对方的每台设备弹出一个一次性公钥
+// 对方将请求者拉黑时拒绝, 避免被用来耗尽对方的一次性公钥
+func (k *KeyHandle) FetchBundles(ctx context.Context, in *pb.FetchBundlesRequest) (*pb.FetchBundlesResponse, error) {
+	if in.Uid == "" || in.Target == "" {
+		return nil, ErrInvalidArgument
+	}
+	blocked, err := k.blocked(ctx, in.Uid, in.Target)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrPermissionDenied
+	}
+
+	devices, err := k.devices(ctx, in.Target)
+	if err != nil {
+		return nil, err
+	}
+	if in.DeviceId != "" {
+		device, ok := devices[in.DeviceId]
+		if !ok {
+			return nil, ErrDeviceNotFound
+		}
+		devices = map[string]*pb.Device{in.DeviceId: device}
+	}
+
+	ids := sortedDevices(devices)
+	pops := make([]*redis.StringCmd, len(ids))
+	_, err = k.redis.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			pops[i] = p.LPop(ctx, prekeysKey(in.Target, id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	bundles := make([]*pb.PrekeyBundle, 0, len(ids))
+	for i, id := range ids {
+		device := devices[id]
+		bundle := &pb.PrekeyBundle{
+			Uid:            in.Target,
+			DeviceId:       id,
+			RegistrationId: device.RegistrationId,
+			IdentityKey:    device.IdentityKey,
+			SignedPrekey:   device.SignedPrekey,
+		}
+		if data, err := pops[i].Bytes(); err == nil {
+			var prekey pb.Prekey
+			if proto.Unmarshal(data, &prekey) == nil {
+				bundle.Prekey = &prekey
+			}
+		}
+		bundles = append(bundles, bundle)
+	}
+	return &pb.FetchBundlesResponse{Bundles: bundles}, nil
+}
+
+func (k *KeyHandle) ListDevices(ctx context.Context, in *pb.ListDevicesRequest) (*pb.ListDevicesResponse, error) {
+	if in.Uid == "" {
+		return nil, ErrInvalidArgument
+	}
+	devices, err := k.devices(ctx, in.Uid)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := sortedDevices(devices)
+	counts := make([]*redis.IntCmd, len(ids))
+	_, err = k.redis.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			counts[i] = p.LLen(ctx, prekeysKey(in.Uid, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListDevicesResponse{Devices: make([]*pb.Device, 0, len(ids))}
+	for i, id := range ids {
+		device := devices[id]
+		device.PrekeyCount = counts[i].Val()
+		resp.Devices = append(resp.Devices, device)
+	}
+	return resp, nil
+}
+
+func (k *KeyHandle) RemoveDevice(ctx context.Context, in *pb.RemoveDeviceRequest) (*pb.RemoveDeviceResponse, error) {
+	if in.Uid == "" || in.DeviceId == "" {
+		return nil, ErrInvalidArgument
+	}
+	var removed *redis.IntCmd
+	_, err := k.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		removed = p.HDel(ctx, devicesKey(in.Uid), in.DeviceId)
+		p.Del(ctx, prekeysKey(in.Uid, in.DeviceId))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RemoveDeviceResponse{Removed: removed.Val() > 0}, nil
+}
+
+// devices 用户的所有设备, key 为设备id
+func (k *KeyHandle) devices(ctx context.Context, uid string) (map[string]*pb.Device, error) {
+	vals, err := k.redis.HGetAll(ctx, devicesKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseDevices(vals)
+}
+
+func parseDevices(vals map[string]string) (map[string]*pb.Device, error) {
+	devices := make(map[string]*pb.Device, len(vals))
+	for id, val := range vals {
+		var device pb.Device
+		if err := proto.Unmarshal([]byte(val), &device); err != nil {
+			return nil, err
+		}
+		devices[id] = &device
+	}
+	return devices, nil
+}
+
+func (k *KeyHandle) device(ctx context.Context, uid, deviceId string) (*pb.Device, error) {
+	data, err := k.redis.HGet(ctx, devicesKey(uid), deviceId).Bytes()
+	if err == redis.Nil {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var device pb.Device
+	if err := proto.Unmarshal(data, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// EnableEncryption 为私聊开启端到端加密
+// 双方都注册了设备才能开启, 对方将请求者拉黑时拒绝, 重复开启时返回首次开启的状态
+func (k *KeyHandle) EnableEncryption(ctx context.Context, in *pb.EnableEncryptionRequest) (*pb.EnableEncryptionResponse, error) {
+	if in.Uid == "" || in.Peer == "" || in.Uid == in.Peer {
+		return nil, ErrInvalidArgument
+	}
+	blocked, err := k.blocked(ctx, in.Uid, in.Peer)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrPermissionDenied
+	}
+	for _, uid := range []string{in.Uid, in.Peer} {
+		n, err := k.redis.HLen(ctx, devicesKey(uid)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrNoDevice
+		}
+	}
+
+	key := encryptionKey(in.Uid, in.Peer)
+	_, err = k.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSetNX(ctx, key, "by", in.Uid)
+		p.HSetNX(ctx, key, "at", time.Now().UnixMilli())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	state, err := encryptionState(ctx, k.redis, in.Uid, in.Peer)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.EnableEncryptionResponse{State: state}, nil
+}
+
+func (k *KeyHandle) GetEncryption(ctx context.Context, in *pb.GetEncryptionRequest) (*pb.EncryptionState, error) {
+	if in.Uid == "" || in.Peer == "" {
+		return nil, ErrInvalidArgument
+	}
+	return encryptionState(ctx, k.redis, in.Uid, in.Peer)
+}
+
+func encryptionKey(uid, peer string) string {
+	return E2EE_CONVERSATION_PREFIX + conversationKey(uid, peer, pb.SesstionType_SINGLE)
+}
+
+// encryptionState 私聊的端到端加密状态, 未开启时 Enabled 为false
+func encryptionState(ctx context.Context, rdb redis.UniversalClient, uid, peer string) (*pb.EncryptionState, error) {
+	fields, err := rdb.HGetAll(ctx, encryptionKey(uid, peer)).Result()
+	if err != nil {
+		return nil, err
+	}
+	state := &pb.EncryptionState{Enabled: fields["by"] != "", EnabledBy: fields["by"]}
+	state.EnabledAt, _ = strconv.ParseInt(fields["at"], 10, 64)
+	return state, nil
+}
+
+// pushPrekeys 追加一次性公钥并只保留最新的 MAX_PREKEYS 个
+func (k *KeyHandle) pushPrekeys(ctx context.Context, p redis.Pipeliner, key string, prekeys []*pb.Prekey) {
+	if len(prekeys) == 0 {
+		return
+	}
+	vals := make([]any, 0, len(prekeys))
+	for _, prekey := range prekeys {
+		data, _ := proto.Marshal(prekey)
+		vals = append(vals, data)
+	}
+	p.RPush(ctx, key, vals...)
+	p.LTrim(ctx, key, -MAX_PREKEYS, -1)
+}
+
+// blocked 对方的黑名单中是否有请求者
+func (k *KeyHandle) blocked(ctx context.Context, uid, target string) (bool, error) {
+	vals, err := k.redis.LRange(ctx, "blackList:"+target, 0, -1).Result()
+	if err != nil {
+		return false, err
+	}
+	for _, v := range vals {
+		if v == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sortedDevices(devices map[string]*pb.Device) []string {
+	ids := make([]string, 0, len(devices))
+	for id := range devices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func validSignedPrekey(key *pb.SignedPrekey) bool {
+	return key != nil && len(key.PublicKey) > 0 && len(key.Signature) > 0
+}
+
+func validPrekeys(prekeys []*pb.Prekey) bool {
+	if len(prekeys) > MAX_PREKEYS {
+		return false
+	}
+	for _, prekey := range prekeys {
+		if prekey == nil || len(prekey.PublicKey) == 0 {
+			return false
+		}
+	}
+	return true
+}
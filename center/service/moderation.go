@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// 内容审核
+//
+// 消息落库前交给审核服务检查, 审核不通过的消息直接拒绝
+// 端到端加密消息的payload为密文, 服务端无法解析, 不经过审核
+// 只有开启了端到端加密的私聊(见 keys.go)可以发送加密消息, 其他会话中标记为加密的消息直接拒绝, 不能借此绕过审核
+
+var (
+	ErrRejected       = errors.New("message rejected by moderation")
+	ErrEncryptedGroup = errors.New("end-to-end encrypted messages are only supported in single chats")
+	ErrNotEncrypted   = errors.New("end-to-end encryption is not enabled for the conversation")
+)
+
+// Moderator 审核消息内容, 不通过时返回 ErrRejected
+type Moderator interface {
+	Review(ctx context.Context, msg *pb.MessageData) error
+}
+
+// KeywordModerator 拒绝包含关键词的文本消息, 不区分大小写
+type KeywordModerator struct {
+	keywords []string
+}
+
+func NewKeywordModerator(keywords []string) *KeywordModerator {
+	m := &KeywordModerator{}
+	for _, word := range keywords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			m.keywords = append(m.keywords, word)
+		}
+	}
+	return m
+}
+
+func (m *KeywordModerator) Review(ctx context.Context, msg *pb.MessageData) error {
+	if msg.MessageType != pb.MessageType_TEXT {
+		return nil
+	}
+	content := strings.ToLower(string(msg.Payload))
+	for _, word := range m.keywords {
+		if strings.Contains(content, word) {
+			return ErrRejected
+		}
+	}
+	return nil
+}
+
+// moderate 端到端加密消息仅限已开启加密的私聊, 并跳过内容审核
+func (r *RPCHandle) moderate(ctx context.Context, msg *pb.MessageData) error {
+	if msg.MessageType == pb.MessageType_ENCRYPTED {
+		if msg.SesstionType != pb.SesstionType_SINGLE {
+			return ErrEncryptedGroup
+		}
+		state, err := encryptionState(ctx, r.redis, msg.SenderId, msg.ReceiverId)
+		if err != nil {
+			return err
+		}
+		if !state.Enabled {
+			return ErrNotEncrypted
+		}
+		return nil
+	}
+	if r.moderator == nil {
+		return nil
+	}
+	return r.moderator.Review(ctx, msg)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/redis/go-redis/v9"
+)
+
+func registerDevice(k *KeyHandle, uid, deviceId string) error {
+	_, err := k.RegisterDevice(context.Background(), &pb.RegisterDeviceRequest{
+		Uid:          uid,
+		DeviceId:     deviceId,
+		IdentityKey:  []byte("identity-" + deviceId),
+		SignedPrekey: &pb.SignedPrekey{KeyId: 1, PublicKey: []byte("spk"), Signature: []byte("sig")},
+	})
+	return err
+}
+
+// 测试只有开启了端到端加密的私聊可以发送加密消息, 其他消息照常审核
+func TestModerateEncrypted(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	newTestPusher(t, rdb)
+	history := &fakeHistory{}
+	keys := NewKeyHandle(rdb)
+	r := NewRPCHandle(rdb, history, &fakeSync{}, &fakeSeq{}, NewDisappearHandle(rdb, history, nil, nil), nil, NewKeywordModerator([]string{" Spam "}))
+
+	send := func(id string, typ pb.MessageType, session pb.SesstionType, payload string) error {
+		_, err := r.SendMessage(ctx, &pb.SendMessageRequest{Message: &pb.MessageData{
+			Id: id, SenderId: "alice", ReceiverId: "bob", MessageType: typ, SesstionType: session, Payload: []byte(payload),
+		}})
+		return err
+	}
+
+	// 未开启时标记为加密的明文不能绕过审核
+	if err := send("m1", pb.MessageType_ENCRYPTED, pb.SesstionType_SINGLE, "buy SPAM"); err != ErrNotEncrypted {
+		t.Fatalf("expected ErrNotEncrypted, got %v", err)
+	}
+	if err := send("m2", pb.MessageType_TEXT, pb.SesstionType_SINGLE, "buy SPAM"); err != ErrRejected {
+		t.Fatalf("expected ErrRejected, got %v", err)
+	}
+	if err := send("m3", pb.MessageType_ENCRYPTED, pb.SesstionType_GROUP, "ciphertext"); err != ErrEncryptedGroup {
+		t.Fatalf("expected ErrEncryptedGroup, got %v", err)
+	}
+
+	// 双方都注册设备后才能开启
+	if _, err := keys.EnableEncryption(ctx, &pb.EnableEncryptionRequest{Uid: "alice", Peer: "bob"}); err != ErrNoDevice {
+		t.Fatalf("expected ErrNoDevice, got %v", err)
+	}
+	registerDevice(keys, "alice", "d1")
+	registerDevice(keys, "bob", "d1")
+	rdb.RPush(ctx, "blackList:bob", "alice")
+	if _, err := keys.EnableEncryption(ctx, &pb.EnableEncryptionRequest{Uid: "alice", Peer: "bob"}); err != ErrPermissionDenied {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	rdb.Del(ctx, "blackList:bob")
+
+	resp, err := keys.EnableEncryption(ctx, &pb.EnableEncryptionRequest{Uid: "bob", Peer: "alice"})
+	if err != nil || !resp.State.Enabled || resp.State.EnabledBy != "bob" {
+		t.Fatalf("unexpected state %v, %v", resp, err)
+	}
+	// 重复开启时保留首次开启的状态
+	again, err := keys.EnableEncryption(ctx, &pb.EnableEncryptionRequest{Uid: "alice", Peer: "bob"})
+	if err != nil || again.State.EnabledBy != "bob" || again.State.EnabledAt != resp.State.EnabledAt {
+		t.Fatalf("unexpected state %v, %v", again, err)
+	}
+
+	if err := send("m4", pb.MessageType_ENCRYPTED, pb.SesstionType_SINGLE, "ciphertext"); err != nil {
+		t.Fatalf("encrypted message rejected: %v", err)
+	}
+	// 开启后明文消息仍然审核
+	if err := send("m5", pb.MessageType_TEXT, pb.SesstionType_SINGLE, "spam"); err != ErrRejected {
+		t.Fatalf("expected ErrRejected, got %v", err)
+	}
+	if len(history.saved) != 1 || history.saved[0].Id != "m4" {
+		t.Fatalf("unexpected saved messages %v", history.saved)
+	}
+}
+
+// 测试并发注册新设备时不会超出设备数量上限
+func TestRegisterDeviceLimit(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	keys := NewKeyHandle(rdb)
+	for i := 0; i < MAX_DEVICES-1; i++ {
+		if err := registerDevice(keys, "alice", fmt.Sprintf("d%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := registerDevice(keys, "alice", id)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrTooManyDevices) && !errors.Is(err, redis.TxFailedErr):
+				t.Errorf("register %s: %v", id, err)
+			}
+		}(fmt.Sprintf("new%d", i))
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("expected exactly 1 new device, got %d", succeeded)
+	}
+	if n := rdb.HLen(ctx, devicesKey("alice")).Val(); n != MAX_DEVICES {
+		t.Fatalf("expected %d devices, got %d", MAX_DEVICES, n)
+	}
+	// 已有设备重复注册不受上限影响
+	if err := registerDevice(keys, "alice", "d0"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+// pusher
+//
+// 经由gateway向用户推送事件 (回执等) 及下发消息
+// 通过在线状态 status:{uid} 找到用户所在的gateway节点
+// 写入该节点专属的topic {topic}.{节点id}, 以uid作为key保证同一用户的事件有序, 并通过header标记事件类型与目标用户
+// 用户离线时不推送, 由客户端重连后主动查询; 消息由调用方写入离线收件箱
+
+// 与gateway约定的kafka header, 普通消息不带 kind
+const (
+	HEADER_KIND = "kind"
+	HEADER_UID  = "uid"
+)
+
+// 推送事件类型
+const (
+	KIND_RECEIPT = "receipt"
+	KIND_DELETE  = "delete" // 消息被删除(阅后即焚到期)
+	KIND_NOTICE  = "notice" // 会话中的系统通知
+)
+
+// NodeTopic 与gateway约定的节点topic, 每个gateway节点只消费自己的topic
+func NodeTopic(topic, nodeId string) string {
+	return topic + "." + nodeId
+}
+
+// 在线状态
+// key : status:{uid}
+const STATUS_PREFIX = "status:"
+
+// presence 与gateway写入的在线状态结构一致
+type presence struct {
+	ServerId       string
+	ServerAddr     string
+	UserRemoteAddr string
+	Status         string
+}
+
+// messageWriter 写入kafka, 由 *kafka.Writer 实现
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type pusher struct {
+	redis  redis.UniversalClient
+	writer messageWriter
+	topic  string
+}
+
+var (
+	pusherIns  *pusher
+	pusherOnce sync.Once
+)
+
+func NewPusher(redis redis.UniversalClient, writer messageWriter, topic string) *pusher {
+	return &pusher{
+		redis:  redis,
+		writer: writer,
+		topic:  topic,
+	}
+}
+
+func InitPusher(redis redis.UniversalClient, writer messageWriter, topic string) {
+	pusherOnce.Do(func() {
+		pusherIns = NewPusher(redis, writer, topic)
+	})
+}
+
+func PusherTemplate() *pusher {
+	if pusherIns == nil {
+		panic("pusher: call InitPusher first")
+	}
+	return pusherIns
+}
+
+// Push 向在线用户推送事件, 用户离线时直接返回
+func (p *pusher) Push(ctx context.Context, uid string, kind string, event any) error {
+	_, err := p.send(ctx, uid, kind, event)
+	return err
+}
+
+// Deliver 向在线用户下发消息, 由gateway按seq顺序投递给连接
+// 用户离线时返回false
+func (p *pusher) Deliver(ctx context.Context, uid string, event any) (bool, error) {
+	return p.send(ctx, uid, "", event)
+}
+
+func (p *pusher) send(ctx context.Context, uid string, kind string, event any) (bool, error) {
+	online, err := p.presence(ctx, uid)
+	if err != nil || online == nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+
+	headers := []kafka.Header{{Key: HEADER_UID, Value: []byte(uid)}}
+	if kind != "" {
+		headers = append(headers, kafka.Header{Key: HEADER_KIND, Value: []byte(kind)})
+	}
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   NodeTopic(p.topic, online.ServerId),
+		Key:     []byte(uid),
+		Value:   body,
+		Headers: headers,
+	})
+	return err == nil, err
+}
+
+// presence 查询在线状态, 离线时返回nil
+func (p *pusher) presence(ctx context.Context, uid string) (*presence, error) {
+	data, err := p.redis.Get(ctx, STATUS_PREFIX+uid).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta presence
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	if meta.Status != "online" {
+		return nil, nil
+	}
+	return &meta, nil
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// 已读回执
+//
+// 已读游标按会话存储为hash, field 为uid, value 为该用户在会话中已读的最大seq
+// key : readCursor:{conversation}
+// 私聊会话为双方uid排序拼接 s:{a}:{b}, 群聊会话为 g:{groupId}
+//
+// 私聊中游标前进时向对方推送已读事件
+// 群聊不推送, 由发送者按需查询已读/未读成员
+
+const (
+	READ_CURSOR_PREFIX  = "readCursor:"
+	GROUP_MEMBER_PREFIX = "groupMember:"
+)
+
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrNotMember       = errors.New("user is not a member of the conversation")
+)
+
+// advanceCursor 仅当新seq大于当前游标时才更新, 返回1表示游标前进
+var advanceCursor = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if cur and tonumber(cur) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// ReadEvent 推送给客户端的已读事件
+type ReadEvent struct {
+	Type           string `json:"type"`
+	ConversationId string `json:"conversation_id"`
+	SessionType    string `json:"session_type"`
+	Reader         string `json:"reader"`
+	Seq            int64  `json:"seq"`
+}
+
+type ReceiptHandle struct {
+	pb.UnimplementedReceiptServiceServer
+	redis     redis.UniversalClient
+	history   storagepb.HistoryServiceClient
+	disappear *DisappearHandle
+	timeline  *Timeline
+}
+
+// timeline 为nil时不写入时间线
+func NewReceiptHandle(redis redis.UniversalClient, history storagepb.HistoryServiceClient, disappear *DisappearHandle, timeline *Timeline) *ReceiptHandle {
+	return &ReceiptHandle{redis: redis, history: history, disappear: disappear, timeline: timeline}
+}
+
+// conversationKey 会话标识
+// 私聊双方得到同一个会话, 群聊以群id区分
+func conversationKey(uid, conversationId string, typ pb.SesstionType) string {
+	if typ == pb.SesstionType_GROUP {
+		return "g:" + conversationId
+	}
+	a, b := uid, conversationId
+	if a > b {
+		a, b = b, a
+	}
+	return "s:" + a + ":" + b
+}
+
+func (r *ReceiptHandle) ReportRead(ctx context.Context, in *pb.ReportReadRequest) (*pb.ReportReadResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.Seq <= 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	if in.SessionType == pb.SesstionType_GROUP {
+		ok, err := r.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+in.ConversationId, in.Uid).Result()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotMember
+		}
+	}
+
+	key := READ_CURSOR_PREFIX + conversationKey(in.Uid, in.ConversationId, in.SessionType)
+	advanced, err := advanceCursor.Run(ctx, r.redis, []string{key}, in.Uid, in.Seq).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	if advanced == 0 {
+		// 重复或过期的上报, 返回当前游标
+		cur, err := r.redis.HGet(ctx, key, in.Uid).Int64()
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ReportReadResponse{ReadSeq: cur}, nil
+	}
+
+	// 已读后计时的阅后即焚消息开始计时
+	if err := r.disappear.OnRead(ctx, in.Uid, in.ConversationId, in.SessionType, in.Seq); err != nil {
+		log.Printf("[WARN] start disappear timers of %s: %v", key, err)
+	}
+
+	// 已读事件写入本人的时间线供其他设备同步, 私聊同时写入对方的时间线作为回执
+	uids := []string{in.Uid}
+	if in.SessionType != pb.SesstionType_GROUP {
+		uids = append(uids, in.ConversationId)
+	}
+	err = r.timeline.Append(ctx, uids, &storagepb.TimelineEvent{
+		Type:           storagepb.EventType_EVENT_READ,
+		ConversationId: conversationKey(in.Uid, in.ConversationId, in.SessionType),
+		Seq:            in.Seq,
+		Time:           time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("[WARN] append read of %s to timeline: %v", key, err)
+	}
+
+	if in.SessionType != pb.SesstionType_GROUP {
+		// 推送失败不影响游标, 对方可通过查询获得
+		_ = PusherTemplate().Push(ctx, in.ConversationId, KIND_RECEIPT, ReadEvent{
+			Type:           "read",
+			ConversationId: in.Uid,
+			SessionType:    "single",
+			Reader:         in.Uid,
+			Seq:            in.Seq,
+		})
+	}
+
+	return &pb.ReportReadResponse{ReadSeq: in.Seq}, nil
+}
+
+func (r *ReceiptHandle) QueryReadReceipt(ctx context.Context, in *pb.QueryReadReceiptRequest) (*pb.QueryReadReceiptResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.Seq <= 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	key := READ_CURSOR_PREFIX + conversationKey(in.Uid, in.ConversationId, in.SessionType)
+
+	if in.SessionType != pb.SesstionType_GROUP {
+		cur, err := r.redis.HGet(ctx, key, in.ConversationId).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		resp := &pb.QueryReadReceiptResponse{Read: cur >= in.Seq}
+		if resp.Read {
+			resp.ReadCount = 1
+		} else {
+			resp.UnreadCount = 1
+		}
+		return resp, nil
+	}
+
+	members, err := r.redis.SMembers(ctx, GROUP_MEMBER_PREFIX+in.ConversationId).Result()
+	if err != nil {
+		return nil, err
+	}
+	cursors, err := r.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.QueryReadReceiptResponse{}
+	joined := false
+	for _, uid := range members {
+		if uid == in.Uid {
+			joined = true
+			continue
+		}
+		cur, _ := strconv.ParseInt(cursors[uid], 10, 64)
+		if cur >= in.Seq {
+			resp.ReadCount++
+			if in.WithMembers {
+				resp.ReadUids = append(resp.ReadUids, uid)
+			}
+		} else {
+			resp.UnreadCount++
+			if in.WithMembers {
+				resp.UnreadUids = append(resp.UnreadUids, uid)
+			}
+		}
+	}
+	if !joined {
+		return nil, ErrNotMember
+	}
+
+	sort.Strings(resp.ReadUids)
+	sort.Strings(resp.UnreadUids)
+	return resp, nil
+}
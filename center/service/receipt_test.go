@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// pushRecorder 记录写入kafka的推送事件
+type pushRecorder struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+}
+
+func (r *pushRecorder) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msgs...)
+	return nil
+}
+
+// pushed 推送给 uid 的 kind 类型事件
+func (r *pushRecorder) pushed(uid, kind string) []json.RawMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []json.RawMessage
+	for _, msg := range r.msgs {
+		var u, k string
+		for _, h := range msg.Headers {
+			switch h.Key {
+			case HEADER_UID:
+				u = string(h.Value)
+			case HEADER_KIND:
+				k = string(h.Value)
+			}
+		}
+		if u == uid && k == kind {
+			events = append(events, msg.Value)
+		}
+	}
+	return events
+}
+
+// newTestPusher 替换全局的pusher, uids 为在线的用户
+func newTestPusher(t *testing.T, rdb redis.UniversalClient, uids ...string) *pushRecorder {
+	t.Helper()
+	for _, uid := range uids {
+		meta, _ := json.Marshal(presence{ServerId: "gateway-1", Status: "online"})
+		rdb.Set(context.Background(), STATUS_PREFIX+uid, meta, 0)
+	}
+	r := &pushRecorder{}
+	prev := pusherIns
+	pusherIns = NewPusher(rdb, r, "im")
+	t.Cleanup(func() { pusherIns = prev })
+	return r
+}
+
+// 测试已读游标只前进不后退, 只有游标前进时才通知对方并写入时间线
+func TestReadCursorForward(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice")
+	seq := &fakeSeq{}
+	timeline := &fakeEvents{}
+	r := NewReceiptHandle(rdb, &fakeHistory{}, NewDisappearHandle(rdb, nil, nil, nil), NewTimeline(seq, timeline))
+
+	for _, step := range []struct{ report, want int64 }{{5, 5}, {3, 5}, {5, 5}, {7, 7}} {
+		resp, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "bob", ConversationId: "alice", Seq: step.report})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ReadSeq != step.want {
+			t.Fatalf("report %d: expected cursor %d, got %d", step.report, step.want, resp.ReadSeq)
+		}
+	}
+	if cur, _ := rdb.HGet(ctx, READ_CURSOR_PREFIX+"s:alice:bob", "bob").Int64(); cur != 7 {
+		t.Fatalf("expected stored cursor 7, got %d", cur)
+	}
+
+	events := pushes.pushed("alice", KIND_RECEIPT)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 read events, got %d", len(events))
+	}
+	var last ReadEvent
+	json.Unmarshal(events[1], &last)
+	if last.Reader != "bob" || last.ConversationId != "bob" || last.Seq != 7 {
+		t.Fatalf("unexpected read event %+v", last)
+	}
+
+	for _, uid := range []string{"alice", "bob"} {
+		reads := timeline.timelineOf(uid, storagepb.EventType_EVENT_READ)
+		if len(reads) != 2 || reads[1].Seq != 7 || reads[1].InboxSeq != 2 || reads[1].ConversationId != "s:alice:bob" {
+			t.Fatalf("unexpected read events of %s: %v", uid, reads)
+		}
+	}
+
+	// 私聊中对方的已读状态
+	for _, c := range []struct {
+		seq  int64
+		read bool
+	}{{7, true}, {8, false}} {
+		resp, err := r.QueryReadReceipt(ctx, &pb.QueryReadReceiptRequest{Uid: "alice", ConversationId: "bob", Seq: c.seq})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Read != c.read {
+			t.Fatalf("seq %d: expected read=%v, got %+v", c.seq, c.read, resp)
+		}
+	}
+
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "bob", ConversationId: "alice"}); err != ErrInvalidArgument {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+// 测试群聊已读与未读成员的统计, 不计入查询者本人
+func TestReadGroupCounts(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice", "bob", "carol", "dave")
+	r := NewReceiptHandle(rdb, &fakeHistory{}, NewDisappearHandle(rdb, nil, nil, nil), nil)
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g1", "alice", "bob", "carol", "dave")
+
+	report := func(uid string, seq int64) {
+		t.Helper()
+		_, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: uid, ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: seq})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	report("alice", 2)
+	report("bob", 10)
+	report("carol", 4)
+	report("carol", 3)
+
+	resp, err := r.QueryReadReceipt(ctx, &pb.QueryReadReceiptRequest{
+		Uid: "alice", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 4, WithMembers: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReadCount != 2 || resp.UnreadCount != 1 ||
+		!slices.Equal(resp.ReadUids, []string{"bob", "carol"}) || !slices.Equal(resp.UnreadUids, []string{"dave"}) {
+		t.Fatalf("unexpected receipt %+v", resp)
+	}
+
+	resp, err = r.QueryReadReceipt(ctx, &pb.QueryReadReceiptRequest{
+		Uid: "bob", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReadCount != 0 || resp.UnreadCount != 3 || resp.ReadUids != nil {
+		t.Fatalf("unexpected receipt %+v", resp)
+	}
+
+	// 群聊不推送已读事件
+	for _, uid := range []string{"alice", "bob", "carol", "dave"} {
+		if len(pushes.pushed(uid, KIND_RECEIPT)) != 0 {
+			t.Fatalf("read event pushed to %s in group", uid)
+		}
+	}
+
+	// 非成员不能上报与查询
+	if _, err := r.ReportRead(ctx, &pb.ReportReadRequest{Uid: "mallory", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 1}); err != ErrNotMember {
+		t.Fatalf("expected ErrNotMember, got %v", err)
+	}
+	if _, err := r.QueryReadReceipt(ctx, &pb.QueryReadReceiptRequest{Uid: "mallory", ConversationId: "g1", SessionType: pb.SesstionType_GROUP, Seq: 1}); err != ErrNotMember {
+		t.Fatalf("expected ErrNotMember, got %v", err)
+	}
+}
@@ -2,21 +2,229 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
 
 	pb "github.com/atoncooper/im/proto"
-	"github.com/segmentio/kafka-go"
+	seqpb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var ErrPermissionDenied = errors.New("sender has no permission to send message")
+
+// MessageEvent 下发给客户端的消息, 与gateway的 MessageDTO 一致
+type MessageEvent struct {
+	SenderId    string        `json:"sender_id"`
+	ReceiverId  string        `json:"receiver_id"` // 群聊为群id
+	MessageType string        `json:"message_type"`
+	Content     string        `json:"content"`
+	Time        time.Duration `json:"time"`
+	Status      string        `json:"status"`
+	Id          string        `json:"id"`
+	Seq         int64         `json:"seq"`
+	SessionType string        `json:"session_type"`
+}
+
 type RPCHandle struct {
 	pb.UnimplementedMessageServiceServer
-	redis      *redis.ClusterClient
-	kafkaWrite *kafka.Writer
+	redis     redis.UniversalClient
+	history   storagepb.HistoryServiceClient
+	sync      storagepb.SyncServiceClient
+	seq       seqpb.SequenceServiceClient
+	disappear *DisappearHandle
+	timeline  *Timeline
+	moderator Moderator
+}
+
+// timeline 为nil时不写入时间线, moderator 为nil时不审核
+func NewRPCHandle(
+	redis redis.UniversalClient,
+	history storagepb.HistoryServiceClient,
+	sync storagepb.SyncServiceClient,
+	seq seqpb.SequenceServiceClient,
+	disappear *DisappearHandle,
+	timeline *Timeline,
+	moderator Moderator,
+) *RPCHandle {
+	return &RPCHandle{
+		redis:     redis,
+		history:   history,
+		sync:      sync,
+		seq:       seq,
+		disappear: disappear,
+		timeline:  timeline,
+		moderator: moderator,
+	}
 }
 
+// SendMessage
+//
+// 校验权限与内容后分配id与seq, 先落库再投递, 保证历史记录中不会缺失已投递的消息
+// 已由上游分配id或seq的消息 (如重试) 沿用原值, storage按消息id去重
 func (r *RPCHandle) SendMessage(ctx context.Context, in *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
-	return nil, nil
+	msg := in.Message
+	if msg == nil || msg.SenderId == "" || msg.ReceiverId == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	typ := "single"
+	if msg.SesstionType == pb.SesstionType_GROUP {
+		typ = "group"
+	}
+	if !r.permission(ctx, msg.SenderId, msg.ReceiverId, typ) {
+		return nil, ErrPermissionDenied
+	}
+	if err := r.moderate(ctx, msg); err != nil {
+		return nil, err
+	}
+	if err := r.allocate(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	// 会话开启阅后即焚时记录计时信息, 随消息一起落库
+	if err := r.disappear.Stamp(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	if err := r.pushStorage(ctx, msg); err != nil {
+		return nil, err
+	}
+	if err := r.disappear.Schedule(ctx, msg); err != nil {
+		log.Printf("[WARN] schedule disappearing message %s: %v", msg.Id, err)
+	}
+	r.deliver(ctx, msg)
+
+	return &pb.SendMessageResponse{Id: msg.Id, Seq: msg.Seq}, nil
+}
+
+// allocate 向signal申请消息id与会话内的seq
+func (r *RPCHandle) allocate(ctx context.Context, msg *pb.MessageData) error {
+	if msg.Id == "" {
+		id, err := r.seq.GenerateMessageId(ctx, &seqpb.Empty{})
+		if err != nil {
+			return err
+		}
+		msg.Id = id.Id
+	}
+	if msg.Seq == 0 {
+		seq, err := r.seq.GenerateMessageSeq(ctx, &seqpb.MessageSeqRequest{
+			SenderId:    msg.SenderId,
+			ReceiverId:  msg.ReceiverId,
+			SessionType: msg.SesstionType,
+		})
+		if err != nil {
+			return err
+		}
+		msg.Seq = seq.Seq
+	}
+	if msg.SendTime == 0 {
+		msg.SendTime = time.Now().UnixMilli()
+	}
+	return nil
+}
+
+// RecallMessage 由storage校验发送者并标记撤回, 撤回事件写入会话成员的时间线
+func (r *RPCHandle) RecallMessage(ctx context.Context, in *pb.RecallMessageRequest) (*pb.RecallMessageResponse, error) {
+	if in.Uid == "" || in.MessageId == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	resp, err := r.history.RecallMessage(ctx, &storagepb.RecallMessageRequest{
+		Uid:       in.Uid,
+		MessageId: in.MessageId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg := resp.Message
+
+	members, err := conversationMembers(ctx, r.redis, msg)
+	if err != nil {
+		log.Printf("[WARN] load members of message %s: %v", msg.Id, err)
+	}
+	err = r.timeline.Append(ctx, members, &storagepb.TimelineEvent{
+		Type:           storagepb.EventType_EVENT_RECALL,
+		ConversationId: conversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType),
+		Seq:            msg.Seq,
+		MessageId:      msg.Id,
+		Time:           time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("[WARN] append recall of %s to timeline: %v", msg.Id, err)
+	}
+
+	return &pb.RecallMessageResponse{Message: msg}, nil
+}
+
+// deliver 投递已落库的消息给接收者, 群聊投递给除发送者外的全部成员
+// 在线时经由所在的gateway节点下发, 离线或下发失败时写入离线收件箱
+// 消息事件写入包括发送者在内的会话成员的时间线, 供其他设备同步
+// 投递失败不影响发送结果, 接收者可以按seq从消息历史中补齐
+func (r *RPCHandle) deliver(ctx context.Context, msg *pb.MessageData) {
+	members, err := conversationMembers(ctx, r.redis, msg)
+	if err != nil {
+		log.Printf("[WARN] load members of group %s: %v", msg.ReceiverId, err)
+		return
+	}
+
+	event := newMessageEvent(msg)
+	for _, uid := range members {
+		if uid == msg.SenderId {
+			continue
+		}
+		delivered, err := PusherTemplate().Deliver(ctx, uid, event)
+		if err != nil {
+			log.Printf("[WARN] deliver message %s to %s: %v", msg.Id, uid, err)
+		}
+		if delivered {
+			continue
+		}
+		_, err = r.sync.PushOffline(ctx, &storagepb.PushOfflineRequest{Uid: uid, Message: msg})
+		if err != nil {
+			log.Printf("[WARN] push offline message %s to %s: %v", msg.Id, uid, err)
+		}
+	}
+
+	err = r.timeline.Append(ctx, members, &storagepb.TimelineEvent{
+		Type:           storagepb.EventType_EVENT_MESSAGE,
+		ConversationId: conversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType),
+		Seq:            msg.Seq,
+		MessageId:      msg.Id,
+		Time:           msg.SendTime,
+	})
+	if err != nil {
+		log.Printf("[WARN] append message %s to timeline: %v", msg.Id, err)
+	}
+}
+
+// conversationMembers 消息所属会话的成员, 私聊为双方, 群聊为全部群成员
+func conversationMembers(ctx context.Context, rdb redis.UniversalClient, msg *pb.MessageData) ([]string, error) {
+	if msg.SesstionType != pb.SesstionType_GROUP {
+		return []string{msg.SenderId, msg.ReceiverId}, nil
+	}
+	return rdb.SMembers(ctx, GROUP_MEMBER_PREFIX+msg.ReceiverId).Result()
+}
+
+func newMessageEvent(msg *pb.MessageData) *MessageEvent {
+	event := &MessageEvent{
+		SenderId:    msg.SenderId,
+		ReceiverId:  msg.ReceiverId,
+		MessageType: strings.ToLower(msg.MessageType.String()),
+		Content:     string(msg.Payload),
+		Time:        time.Duration(msg.SendTime) * time.Millisecond,
+		Status:      "send",
+		Id:          msg.Id,
+		Seq:         msg.Seq,
+		SessionType: "single",
+	}
+	if msg.SesstionType == pb.SesstionType_GROUP {
+		event.SessionType = "group"
+	}
+	return event
 }
 
 // permission
@@ -66,4 +274,11 @@ func (r *RPCHandle) permission(ctx context.Context,
 	return true
 }
 
-func (r *RPCHandle) pushStorage(ctx, typ string, message string) error { return nil }
+// pushStorage 持久化消息至storage的消息历史
+// storage按消息id去重, 失败时上游可以直接重试
+func (r *RPCHandle) pushStorage(ctx context.Context, msg *pb.MessageData) error {
+	_, err := r.history.SaveMessages(ctx, &storagepb.SaveMessagesRequest{
+		Messages: []*pb.MessageData{msg},
+	})
+	return err
+}
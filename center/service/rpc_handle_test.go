@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// fakeSync 记录写入离线收件箱的消息
+type fakeSync struct {
+	storagepb.SyncServiceClient
+	offline map[string][]*pb.MessageData
+}
+
+func (f *fakeSync) PushOffline(_ context.Context, in *storagepb.PushOfflineRequest, _ ...grpc.CallOption) (*storagepb.PushOfflineResponse, error) {
+	if f.offline == nil {
+		f.offline = make(map[string][]*pb.MessageData)
+	}
+	f.offline[in.Uid] = append(f.offline[in.Uid], in.Message)
+	return &storagepb.PushOfflineResponse{Cursor: int64(len(f.offline[in.Uid]))}, nil
+}
+
+// fakeEvents 记录写入时间线的事件
+type fakeEvents struct {
+	storagepb.TimelineServiceClient
+	events []*storagepb.TimelineEvent
+}
+
+func (f *fakeEvents) AppendEvents(_ context.Context, in *storagepb.AppendEventsRequest, _ ...grpc.CallOption) (*storagepb.AppendEventsResponse, error) {
+	f.events = append(f.events, in.Events...)
+	return &storagepb.AppendEventsResponse{Appended: int32(len(in.Events))}, nil
+}
+
+// timelineOf uid 时间线中 typ 类型的事件
+func (f *fakeEvents) timelineOf(uid string, typ storagepb.EventType) []*storagepb.TimelineEvent {
+	var events []*storagepb.TimelineEvent
+	for _, e := range f.events {
+		if e.Uid == uid && e.Type == typ {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// 测试消息分配id与seq并落库后, 在线成员经由gateway下发, 离线成员写入离线收件箱, 发送者不投递
+func TestSendMessageDeliver(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	pushes := newTestPusher(t, rdb, "alice", "bob")
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g1", "alice", "bob", "carol")
+	history := &fakeHistory{}
+	sync := &fakeSync{}
+	seq := &fakeSeq{next: 41}
+	timeline := &fakeEvents{}
+	r := NewRPCHandle(rdb, history, sync, seq, NewDisappearHandle(rdb, history, nil, nil), NewTimeline(seq, timeline), nil)
+
+	resp, err := r.SendMessage(ctx, &pb.SendMessageRequest{Message: &pb.MessageData{
+		SenderId: "alice", ReceiverId: "g1", MessageType: pb.MessageType_TEXT, SesstionType: pb.SesstionType_GROUP, Payload: []byte("hi"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != "n42" || resp.Seq != 42 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(history.saved) != 1 || history.saved[0].Id != "n42" || history.saved[0].Seq != 42 || history.saved[0].SendTime == 0 {
+		t.Fatalf("unexpected saved messages %v", history.saved)
+	}
+
+	events := pushes.pushed("bob", "")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 message to bob, got %d", len(events))
+	}
+	var event MessageEvent
+	json.Unmarshal(events[0], &event)
+	if event.Id != "n42" || event.Seq != 42 || event.SessionType != "group" || event.ReceiverId != "g1" ||
+		event.MessageType != "text" || event.Content != "hi" {
+		t.Fatalf("unexpected message event %+v", event)
+	}
+	// 写入bob所在节点的topic
+	for _, msg := range pushes.msgs {
+		if msg.Topic != "im.gateway-1" {
+			t.Fatalf("message written to topic %q", msg.Topic)
+		}
+	}
+	if len(pushes.pushed("alice", "")) != 0 || len(sync.offline["alice"]) != 0 {
+		t.Fatal("message delivered back to sender")
+	}
+
+	offline := sync.offline["carol"]
+	if len(offline) != 1 || offline[0].Id != "n42" || offline[0].Seq != 42 || offline[0].SesstionType != pb.SesstionType_GROUP {
+		t.Fatalf("unexpected offline messages %v", offline)
+	}
+	if len(sync.offline["bob"]) != 0 {
+		t.Fatal("online member written to offline inbox")
+	}
+
+	// 消息事件写入包括发送者在内的全部成员的时间线
+	for _, uid := range []string{"alice", "bob", "carol"} {
+		events := timeline.timelineOf(uid, storagepb.EventType_EVENT_MESSAGE)
+		if len(events) != 1 || events[0].InboxSeq != 1 || events[0].MessageId != "n42" ||
+			events[0].Seq != 42 || events[0].ConversationId != "g:g1" {
+			t.Fatalf("unexpected timeline of %s: %v", uid, events)
+		}
+	}
+}
+
+// 测试只有发送者可以撤回, 撤回事件写入私聊双方的时间线
+func TestRecallMessage(t *testing.T) {
+	ctx := context.Background()
+	rdb := newTestRedis(t)
+	newTestPusher(t, rdb)
+	history := &fakeHistory{}
+	seq := &fakeSeq{}
+	timeline := &fakeEvents{}
+	r := NewRPCHandle(rdb, history, &fakeSync{}, seq, NewDisappearHandle(rdb, history, nil, nil), NewTimeline(seq, timeline), nil)
+
+	sent, err := r.SendMessage(ctx, &pb.SendMessageRequest{Message: &pb.MessageData{
+		SenderId: "bob", ReceiverId: "alice", MessageType: pb.MessageType_TEXT, SesstionType: pb.SesstionType_SINGLE, Payload: []byte("oops"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.RecallMessage(ctx, &pb.RecallMessageRequest{Uid: "alice", MessageId: sent.Id}); err == nil {
+		t.Fatal("receiver recalled the message")
+	}
+	if len(timeline.timelineOf("alice", storagepb.EventType_EVENT_RECALL)) != 0 {
+		t.Fatal("failed recall appended to timeline")
+	}
+
+	resp, err := r.RecallMessage(ctx, &pb.RecallMessageRequest{Uid: "bob", MessageId: sent.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message.Status != 1 {
+		t.Fatalf("message not recalled: %v", resp.Message)
+	}
+	for _, uid := range []string{"alice", "bob"} {
+		events := timeline.timelineOf(uid, storagepb.EventType_EVENT_RECALL)
+		if len(events) != 1 || events[0].InboxSeq != 2 || events[0].MessageId != sent.Id ||
+			events[0].Seq != sent.Seq || events[0].ConversationId != "s:alice:bob" {
+			t.Fatalf("unexpected recall events of %s: %v", uid, events)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+
+	seqpb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
+)
+
+// 用户时间线
+//
+// 投递给用户的每个事件(消息、撤回、已读)向signal申请该用户的inbox seq后写入storage的时间线
+// 客户端以一个游标同步全部会话的变化, 见 storage 的 TimelineService
+// 消息事件在消息落库之后写入; 写入失败时只记录日志, 客户端仍可按会话seq补齐
+
+// TIMELINE_BATCH 单次申请inbox seq及写入的事件数, 不超过storage单次写入的上限
+const TIMELINE_BATCH = 200
+
+type Timeline struct {
+	seq    seqpb.SequenceServiceClient
+	events storagepb.TimelineServiceClient
+}
+
+func NewTimeline(seq seqpb.SequenceServiceClient, events storagepb.TimelineServiceClient) *Timeline {
+	return &Timeline{
+		seq:    seq,
+		events: events,
+	}
+}
+
+// Append 为每个uid写入一条事件, event 为不含 uid 与 inbox_seq 的事件模板
+// timeline 为nil时不写入
+func (t *Timeline) Append(ctx context.Context, uids []string, event *storagepb.TimelineEvent) error {
+	if t == nil {
+		return nil
+	}
+	for start := 0; start < len(uids); start += TIMELINE_BATCH {
+		batch := uids[start:min(start+TIMELINE_BATCH, len(uids))]
+		resp, err := t.seq.GenerateInboxSeqs(ctx, &seqpb.InboxSeqsRequest{Uids: batch})
+		if err != nil {
+			return err
+		}
+
+		events := make([]*storagepb.TimelineEvent, 0, len(resp.Seqs))
+		for _, s := range resp.Seqs {
+			events = append(events, &storagepb.TimelineEvent{
+				Uid:            s.Uid,
+				InboxSeq:       s.Seq,
+				Type:           event.Type,
+				ConversationId: event.ConversationId,
+				Seq:            event.Seq,
+				MessageId:      event.MessageId,
+				Payload:        event.Payload,
+				Time:           event.Time,
+			})
+		}
+		if _, err := t.events.AppendEvents(ctx, &storagepb.AppendEventsRequest{Events: events}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,60 +1,39 @@
 package utils
 
 import (
-	"errors"
-	"sync"
-	"time"
-)
+	"context"
 
-const (
-	timeBits    = 41
-	bizBits     = 4
-	dcBits      = 3
-	machineBits = 6
-	seqBits     = 9
-	maxSeq      = 1<<seqBits - 1
-	workerBits  = bizBits + dcBits + machineBits
-	epoch       = 1704067200000
+	"github.com/atoncooper/im/utils/idgen"
 )
 
+// GeneratorID 41位时间戳 | 4位业务 | 3位数据中心 | 6位机器id | 9位序列号
+// 时钟回拨的处理与时间戳持久化见 idgen.Generator
 type GeneratorID struct {
-	mu       sync.Mutex
-	lastTs   int64
-	sequence int64
-	workerID int64
+	*idgen.Generator
 }
 
-func NewGeneratorID(biz, dc, machine int) (*GeneratorID, error) {
-	worker := (int64(biz)&((1<<bizBits)-1))<<(dcBits+machineBits) |
-		(int64(dc)&((1<<dcBits)-1))<<machineBits |
-		(int64(machine) & ((1 << machineBits) - 1))
-	if worker >= 1<<workerBits {
-		return nil, errors.New("workerID overflow")
+// NewGeneratorID conf 为空时使用默认配置: 回拨不超过1s时等待, 不持久化
+// conf 中的 Layout 与 Worker 由本函数设置
+func NewGeneratorID(ctx context.Context, biz, dc, machine int, conf *idgen.Config) (*GeneratorID, error) {
+	worker, err := idgen.CenterLayout.Worker(int64(biz), int64(dc), int64(machine))
+	if err != nil {
+		return nil, err
 	}
-	return &GeneratorID{workerID: worker}, nil
-}
-
-func (g *GeneratorID) Next() int64 {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	now := time.Now().UnixMilli()
-	if now < g.lastTs {
-		time.Sleep(time.Duration(g.lastTs-now) * time.Millisecond)
-		now = time.Now().UnixMilli()
+	c := idgen.Config{}
+	if conf != nil {
+		c = *conf
 	}
+	c.Layout = idgen.CenterLayout
+	c.Worker = worker
 
-	if now == g.lastTs {
-		g.sequence = (g.sequence + 1) & maxSeq
-		if g.sequence == 0 {
-			for now <= g.lastTs {
-				now = time.Now().UnixMilli()
-			}
-		}
-	} else {
-		g.sequence = 0
+	g, err := idgen.New(ctx, &c)
+	if err != nil {
+		return nil, err
 	}
-	g.lastTs = now
+	return &GeneratorID{Generator: g}, nil
+}
 
-	return (now-epoch)<<22 | g.workerID<<9 | g.sequence
+// DecodeID 解析 GeneratorID 生成的id
+func DecodeID(id int64) (idgen.ID, error) {
+	return idgen.CenterLayout.Decode(id)
 }
@@ -29,6 +29,9 @@ type Application struct {
 	Cors      CorsConfig      `yaml:"cors"`
 	Logger    LoggerConfig    `yaml:"logger"`
 	Component ComponentConfig `yaml:"component"`
+	Ephemeral EphemeralConfig `yaml:"ephemeral"`
+	Ack       AckConfig       `yaml:"ack"`
+	Admin     AdminConfig     `yaml:"admin"`
 }
 
 type CorsConfig struct {
@@ -61,6 +64,23 @@ type WebsocketConfig struct {
 	WriteWait        string `yaml:"writeWait"`
 }
 
+// EphemeralConfig 瞬时信号限流配置
+type EphemeralConfig struct {
+	Rate  float64 `yaml:"rate"`  // 每个用户每秒允许的信号数
+	Burst int     `yaml:"burst"` // 允许的瞬时突发数
+}
+
+// AckConfig 设备ack批量上报配置
+type AckConfig struct {
+	BatchSize     int    `yaml:"batchSize"`
+	FlushInterval string `yaml:"flushInterval"`
+}
+
+// AdminConfig 管理接口配置, token 为空时管理接口不可用
+type AdminConfig struct {
+	Token string `yaml:"token"`
+}
+
 type ComponentConfig struct {
 	Consul Consul `yaml:"consul"`
 	Redis  Redis  `yaml:"redis"`
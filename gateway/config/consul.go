@@ -108,6 +108,13 @@ func NewConsul(conf *ConsulConf, meta ServerMeta) error {
 	return nil
 }
 
+func ConsulTemplate() *ConsulClient {
+	if consulClient == nil {
+		panic("consul: call NewConsul first")
+	}
+	return consulClient
+}
+
 func GetNodeIdTemplate() string {
 	return serverNodeId
 }
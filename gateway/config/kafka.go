@@ -17,28 +17,39 @@ var (
 
 type KafkaConf struct {
 	Brokers      []string
-	Topic        string
+	Topic        string // topic前缀, 见 NodeTopic
+	NodeId       string
 	RequiredAcks int
 	MaxRetry     int
 	GroupId      string
 	DLQTopic     string
 }
 
+// NodeTopic 节点专属的topic, 转发给某个gateway节点的消息只写入它的topic
+// 各节点只消费自己的topic, 不会收到其他节点连接上的消息
+func NodeTopic(topic, nodeId string) string {
+	return topic + "." + nodeId
+}
+
+// NewKafak
+//
+// 生产者不指定topic, 由每条消息按目标节点指定
+// 消费者只消费本节点的topic
 func NewKafak(conf *KafkaConf) error {
 
 	var err error
 	kafkaOnce.Do(func() {
 		producer = kafka.NewWriter(kafka.WriterConfig{
 			Brokers:      conf.Brokers,
-			Topic:        conf.Topic,
 			RequiredAcks: conf.RequiredAcks,
 			MaxAttempts:  conf.MaxRetry,
 			BatchTimeout: 10 * time.Millisecond,
 		})
+		producer.AllowAutoTopicCreation = true
 
 		consumer = kafka.NewReader(kafka.ReaderConfig{
 			Brokers:     conf.Brokers,
-			Topic:       conf.Topic,
+			Topic:       NodeTopic(conf.Topic, conf.NodeId),
 			GroupID:     conf.GroupId,
 			MaxWait:     30 * time.Second,
 			StartOffset: kafka.FirstOffset,
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"crypto/subtle"
+	"gateway/config"
+	"gateway/service"
+	"net/http"
+	"net/url"
+	"strings"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// 管理接口
+//
+// 请求头 Authorization: Bearer {admin.token}, 未配置token时全部拒绝
+// operator 为操作者, 与 reason 一起写入storage的审计日志
+
+// adminAuth 校验管理token
+func adminAuth(c *gin.Context) {
+	token := config.GatewayCfg.Application.Admin.Token
+	got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	c.Next()
+}
+
+// exportUser
+//
+// 导出用户的全部数据, 以zip附件下载, media=true 时同时打包消息引用的媒体文件
+// GET /admin/users/:uid/export?operator=&reason=&media=
+func exportUser(c *gin.Context) {
+	uid := c.Param("uid")
+	operator := c.Query("operator")
+	if uid == "" || operator == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and operator are required"})
+		return
+	}
+
+	w := &attachmentWriter{c: c, name: "user-" + url.PathEscape(uid) + ".zip"}
+	err := service.ExportUser(c.Request.Context(), &storagepb.ExportUserRequest{
+		Uid:          uid,
+		Operator:     operator,
+		Reason:       c.Query("reason"),
+		IncludeMedia: c.Query("media") == "true",
+	}, w)
+	if err != nil {
+		// 已开始下载时无法再返回错误, 客户端得到的zip不完整
+		if !w.started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		c.Abort()
+	}
+}
+
+// eraseUser
+//
+// 擦除用户数据, mode 为 delete(删除发送的消息) 或 anonymize(清空内容保留占位), 默认 delete
+// POST /admin/users/:uid/erase?operator=&reason=&mode=
+func eraseUser(c *gin.Context) {
+	uid := c.Param("uid")
+	operator := c.Query("operator")
+	if uid == "" || operator == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and operator are required"})
+		return
+	}
+	mode := storagepb.EraseMode_ERASE_DELETE
+	switch c.Query("mode") {
+	case "", "delete":
+	case "anonymize":
+		mode = storagepb.EraseMode_ERASE_ANONYMIZE
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be delete or anonymize"})
+		return
+	}
+
+	audit, err := service.EraseUser(c.Request.Context(), &storagepb.EraseUserRequest{
+		Uid:      uid,
+		Operator: operator,
+		Reason:   c.Query("reason"),
+		Mode:     mode,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit": audit})
+}
+
+// attachmentWriter 收到第一个分块时才写入响应头, 之前出错仍可返回json错误
+type attachmentWriter struct {
+	c       *gin.Context
+	name    string
+	started bool
+}
+
+func (w *attachmentWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", "application/zip")
+		w.c.Header("Content-Disposition", `attachment; filename="`+w.name+`"`)
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(b)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"gateway/service"
+	"net/http"
+	"strconv"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// archiveSegments
+//
+// 查询会话的归档分段, conversation_id 为私聊 s:{较小uid}:{较大uid} 或群聊 g:{groupId}
+// GET /admin/archive/segments?conversation_id=
+func archiveSegments(c *gin.Context) {
+	conversationId := c.Query("conversation_id")
+	if conversationId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id is required"})
+		return
+	}
+
+	segs, err := service.ListSegments(c.Request.Context(), conversationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segments": segs})
+}
+
+// restoreArchive
+//
+// 将与 [from_seq, to_seq] 有交集的分段整体恢复到主存储
+// POST /admin/archive/restore?conversation_id=&from_seq=&to_seq=
+func restoreArchive(c *gin.Context) {
+	conversationId := c.Query("conversation_id")
+	fromSeq, err1 := strconv.ParseInt(c.Query("from_seq"), 10, 64)
+	toSeq, err2 := strconv.ParseInt(c.Query("to_seq"), 10, 64)
+	if conversationId == "" || err1 != nil || err2 != nil || fromSeq > toSeq {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id, from_seq and to_seq are required"})
+		return
+	}
+
+	resp, err := service.RestoreRange(c.Request.Context(), &storagepb.RestoreRangeRequest{
+		ConversationId: conversationId,
+		FromSeq:        fromSeq,
+		ToSeq:          toSeq,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segments": resp.Segments, "messages": resp.Messages})
+}
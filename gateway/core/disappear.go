@@ -0,0 +1,63 @@
+package core
+
+import (
+	"gateway/service"
+	"net/http"
+	"strconv"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/gin-gonic/gin"
+)
+
+// setDisappearTimer
+//
+// 修改会话的阅后即焚计时器, ttl 为存活秒数, 为0时关闭; start 为 read(已读后计时) 或 send(发送后计时)
+// POST /disappear/timer?uid=&conversation_id=&session_type=&ttl=&start=
+func setDisappearTimer(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	ttl, err := strconv.ParseInt(c.Query("ttl"), 10, 64)
+	if uid == "" || conversationId == "" || err != nil || ttl < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid, conversation_id and ttl are required"})
+		return
+	}
+
+	resp, err := service.SetDisappearTimer(c.Request.Context(), &pb.SetDisappearTimerRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		SessionType:    service.SessionType(c.Query("session_type")),
+		TtlSeconds:     ttl,
+		Start:          service.DisappearStart(c.Query("start")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"timer": resp.Timer, "notice": resp.Notice})
+}
+
+// disappearTimer
+//
+// 查询会话的阅后即焚计时器
+// GET /disappear/timer?uid=&conversation_id=&session_type=
+func disappearTimer(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	if uid == "" || conversationId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and conversation_id are required"})
+		return
+	}
+
+	timer, err := service.GetDisappearTimer(c.Request.Context(), &pb.GetDisappearTimerRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		SessionType:    service.SessionType(c.Query("session_type")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"timer": timer})
+}
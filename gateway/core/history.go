@@ -0,0 +1,161 @@
+package core
+
+import (
+	"gateway/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// historyBySeq
+//
+// 按seq翻页查询会话历史, direction 默认 backward (从新到旧翻页)
+// GET /history/messages?uid=&conversation_id=&session_type=&anchor_seq=&direction=&limit=
+func historyBySeq(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	if uid == "" || conversationId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and conversation_id are required"})
+		return
+	}
+	anchor, _ := strconv.ParseInt(c.Query("anchor_seq"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	direction := storagepb.Direction_BACKWARD
+	if c.Query("direction") == "forward" {
+		direction = storagepb.Direction_FORWARD
+	}
+
+	resp, err := service.QueryHistory(c.Request.Context(), &storagepb.QueryBySeqRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		SessionType:    service.SessionType(c.Query("session_type")),
+		AnchorSeq:      anchor,
+		Direction:      direction,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": resp.Messages,
+		"has_more": resp.HasMore,
+		"next_seq": resp.NextSeq,
+	})
+}
+
+// historyByTime
+//
+// 按发送时间区间 [start_time, end_time) 翻页查询会话历史
+// GET /history/time?uid=&conversation_id=&session_type=&start_time=&end_time=&limit=&page_token=
+func historyByTime(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	if uid == "" || conversationId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and conversation_id are required"})
+		return
+	}
+	start, _ := strconv.ParseInt(c.Query("start_time"), 10, 64)
+	end, _ := strconv.ParseInt(c.Query("end_time"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := service.QueryHistoryByTime(c.Request.Context(), &storagepb.QueryByTimeRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		SessionType:    service.SessionType(c.Query("session_type")),
+		StartTime:      start,
+		EndTime:        end,
+		Limit:          int32(limit),
+		PageToken:      c.Query("page_token"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":        resp.Messages,
+		"has_more":        resp.HasMore,
+		"next_page_token": resp.NextPageToken,
+	})
+}
+
+// historyById
+//
+// 按消息id查询, ids 以逗号分隔
+// GET /history/message?uid=&ids=
+func historyById(c *gin.Context) {
+	uid := c.Query("uid")
+	ids := c.Query("ids")
+	if uid == "" || ids == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and ids are required"})
+		return
+	}
+
+	resp, err := service.GetMessages(c.Request.Context(), uid, strings.Split(ids, ","))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": resp.Messages})
+}
+
+// recallMessage
+//
+// 撤回消息, 只有发送者可以撤回
+// POST /history/recall?uid=&message_id=
+func recallMessage(c *gin.Context) {
+	uid := c.Query("uid")
+	messageId := c.Query("message_id")
+	if uid == "" || messageId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and message_id are required"})
+		return
+	}
+
+	resp, err := service.RecallMessage(c.Request.Context(), uid, messageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": resp.Message})
+}
+
+// searchMessages
+//
+// 全文检索, 结果按发送时间从新到旧, conversation_id 为空时搜索全部会话
+// GET /search?uid=&q=&conversation_id=&session_type=&limit=&page_token=
+func searchMessages(c *gin.Context) {
+	uid := c.Query("uid")
+	query := c.Query("q")
+	if uid == "" || query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and q are required"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := service.Search(c.Request.Context(), &storagepb.SearchRequest{
+		Uid:            uid,
+		Query:          query,
+		ConversationId: c.Query("conversation_id"),
+		SessionType:    service.SessionType(c.Query("session_type")),
+		Limit:          int32(limit),
+		PageToken:      c.Query("page_token"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":        resp.Messages,
+		"has_more":        resp.HasMore,
+		"next_page_token": resp.NextPageToken,
+	})
+}
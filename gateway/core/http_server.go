@@ -77,6 +77,32 @@ func StartHttpServer(httpOpts ...HttpParamsOps) {
 	// Please write the route here
 	engine.GET("/health", ping)
 	engine.GET("/ws", websocketServer)
+	engine.GET("/receipt/read", readReceipt)
+	engine.GET("/receipt/delivery", deliveryStatus)
+	engine.GET("/sync/inbox", syncInbox)
+	engine.GET("/sync/messages", syncMessages)
+	engine.POST("/sync/inbox/ack", ackInbox)
+	engine.GET("/history/messages", historyBySeq)
+	engine.GET("/history/time", historyByTime)
+	engine.GET("/history/message", historyById)
+	engine.POST("/history/recall", recallMessage)
+	engine.GET("/search", searchMessages)
+	engine.GET("/disappear/timer", disappearTimer)
+	engine.POST("/disappear/timer", setDisappearTimer)
+
+	engine.POST("/e2ee/devices", registerDevice)
+	engine.GET("/e2ee/devices", devices)
+	engine.DELETE("/e2ee/devices", removeDevice)
+	engine.POST("/e2ee/prekeys", uploadPrekeys)
+	engine.GET("/e2ee/bundles", prekeyBundles)
+	engine.GET("/e2ee/conversations", encryption)
+	engine.POST("/e2ee/conversations", enableEncryption)
+
+	admin := engine.Group("/admin", adminAuth)
+	admin.GET("/users/:uid/export", exportUser)
+	admin.POST("/users/:uid/erase", eraseUser)
+	admin.GET("/archive/segments", archiveSegments)
+	admin.POST("/archive/restore", restoreArchive)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	if err := engine.Run(addr); err != nil {
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"gateway/service"
+	"io"
+	"net/http"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 端到端加密密钥分发
+// 请求体为对应请求消息的json, 公钥与签名为base64, uid 以查询参数为准
+
+// MAX_KEYS_BODY 请求体最大字节数
+const MAX_KEYS_BODY = 256 << 10
+
+// bindKeys 解析请求体, 失败时返回400并返回false
+func bindKeys(c *gin.Context, in proto.Message) bool {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, MAX_KEYS_BODY))
+	if err == nil {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, in)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// registerDevice
+//
+// 注册设备的身份公钥、签名预共享公钥及一次性公钥, 身份公钥变化时旧的一次性公钥作废
+// POST /e2ee/devices?uid=&device_id=
+func registerDevice(c *gin.Context) {
+	uid := c.Query("uid")
+	deviceId := c.Query("device_id")
+	if uid == "" || deviceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and device_id are required"})
+		return
+	}
+	var in pb.RegisterDeviceRequest
+	if !bindKeys(c, &in) {
+		return
+	}
+	in.Uid, in.DeviceId = uid, deviceId
+
+	device, err := service.RegisterDevice(c.Request.Context(), &in)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"device": device})
+}
+
+// uploadPrekeys
+//
+// 补充一次性公钥, 可同时轮换签名预共享公钥
+// POST /e2ee/prekeys?uid=&device_id=
+func uploadPrekeys(c *gin.Context) {
+	uid := c.Query("uid")
+	deviceId := c.Query("device_id")
+	if uid == "" || deviceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and device_id are required"})
+		return
+	}
+	var in pb.UploadPrekeysRequest
+	if !bindKeys(c, &in) {
+		return
+	}
+	in.Uid, in.DeviceId = uid, deviceId
+
+	count, err := service.UploadPrekeys(c.Request.Context(), &in)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prekey_count": count})
+}
+
+// prekeyBundles
+//
+// 发送者获取对方设备的公钥用于建立加密会话, device_id 为空时返回对方所有设备
+// GET /e2ee/bundles?uid=&target=&device_id=
+func prekeyBundles(c *gin.Context) {
+	uid := c.Query("uid")
+	target := c.Query("target")
+	if uid == "" || target == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and target are required"})
+		return
+	}
+
+	bundles, err := service.FetchBundles(c.Request.Context(), &pb.FetchBundlesRequest{
+		Uid:      uid,
+		Target:   target,
+		DeviceId: c.Query("device_id"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// devices
+//
+// 查询用户的设备列表及各设备剩余的一次性公钥数量
+// GET /e2ee/devices?uid=
+func devices(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid is required"})
+		return
+	}
+
+	list, err := service.ListDevices(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": list})
+}
+
+// removeDevice
+//
+// 删除设备, 其他用户之后获取公钥时不再包含该设备
+// DELETE /e2ee/devices?uid=&device_id=
+func removeDevice(c *gin.Context) {
+	uid := c.Query("uid")
+	deviceId := c.Query("device_id")
+	if uid == "" || deviceId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and device_id are required"})
+		return
+	}
+
+	removed, err := service.RemoveDevice(c.Request.Context(), uid, deviceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+// encryption
+//
+// 查询与对方的私聊是否开启了端到端加密
+// GET /e2ee/conversations?uid=&peer=
+func encryption(c *gin.Context) {
+	uid := c.Query("uid")
+	peer := c.Query("peer")
+	if uid == "" || peer == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and peer are required"})
+		return
+	}
+
+	state, err := service.GetEncryption(c.Request.Context(), uid, peer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": state.Enabled, "enabled_by": state.EnabledBy, "enabled_at": state.EnabledAt})
+}
+
+// enableEncryption
+//
+// 为与对方的私聊开启端到端加密, 双方都需要已注册设备, 开启后才能发送 encrypted 消息
+// POST /e2ee/conversations?uid=&peer=
+func enableEncryption(c *gin.Context) {
+	uid := c.Query("uid")
+	peer := c.Query("peer")
+	if uid == "" || peer == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and peer are required"})
+		return
+	}
+
+	state, err := service.EnableEncryption(c.Request.Context(), uid, peer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": state.Enabled, "enabled_by": state.EnabledBy, "enabled_at": state.EnabledAt})
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gateway/dto"
+	"gateway/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/gin-gonic/gin"
+)
+
+// handleRead
+//
+// 处理客户端已读上报
+func handleRead(uid string, msg []byte) error {
+	var read dto.ReadDTO
+
+	if err := json.Unmarshal(msg, &read); err != nil {
+		return err
+	}
+	if err := validate.Struct(&read); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := service.ReportRead(ctx, uid, &read)
+	return err
+}
+
+// handleAck
+//
+// 处理设备ack, 加入批量上报队列
+// device: 设备标识, 取长连接的远程地址
+func handleAck(uid, device string, msg []byte) error {
+	var ack dto.AckDTO
+
+	if err := json.Unmarshal(msg, &ack); err != nil {
+		return err
+	}
+	if err := validate.Struct(&ack); err != nil {
+		return err
+	}
+
+	// 私聊的会话为发送方uid
+	conversationId := ack.ConversationId
+	if ack.SessionType != "group" {
+		conversationId = ack.SenderId
+	}
+	service.GapRepairerTemplate().Observe(device, conversationId, ack.SessionType, ack.Seq)
+
+	ok := service.AckBatcherTemplate().Add(&pb.DeliveryAck{
+		MessageId:      ack.MessageId,
+		SenderId:       ack.SenderId,
+		ReceiverId:     uid,
+		DeviceId:       device,
+		ConversationId: ack.ConversationId,
+		SessionType:    service.SessionType(ack.SessionType),
+		Seq:            ack.Seq,
+		AckTime:        time.Now().UnixMilli(),
+	})
+	if !ok {
+		return errors.New("ack queue is full")
+	}
+	return nil
+}
+
+// readReceipt
+//
+// 查询消息已读情况
+// GET /receipt/read?uid=&conversation_id=&session_type=&seq=&with_members=
+func readReceipt(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	seq, err := strconv.ParseInt(c.Query("seq"), 10, 64)
+	if uid == "" || conversationId == "" || err != nil || seq <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid, conversation_id and seq are required"})
+		return
+	}
+
+	resp, err := service.QueryReadReceipt(c.Request.Context(), &pb.QueryReadReceiptRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		SessionType:    service.SessionType(c.Query("session_type")),
+		Seq:            seq,
+		WithMembers:    c.Query("with_members") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"read":         resp.Read,
+		"read_count":   resp.ReadCount,
+		"unread_count": resp.UnreadCount,
+		"read_uids":    resp.ReadUids,
+		"unread_uids":  resp.UnreadUids,
+	})
+}
+
+// deliveryStatus
+//
+// 查询消息送达状态, 只有发送者可以查询
+// GET /receipt/delivery?uid=&message_id=
+func deliveryStatus(c *gin.Context) {
+	uid := c.Query("uid")
+	messageId := c.Query("message_id")
+	if uid == "" || messageId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and message_id are required"})
+		return
+	}
+
+	resp, err := service.QueryDelivery(c.Request.Context(), uid, messageId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivered":    resp.Delivered,
+		"delivered_at": resp.DeliveredAt,
+		"acked_count":  resp.AckedCount,
+		"acked_uids":   resp.AckedUids,
+	})
+}
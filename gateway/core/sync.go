@@ -0,0 +1,92 @@
+package core
+
+import (
+	"gateway/service"
+	"net/http"
+	"strconv"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// syncInbox
+//
+// 拉取离线收件箱中游标之后的消息
+// GET /sync/inbox?uid=&cursor=&limit=
+func syncInbox(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid is required"})
+		return
+	}
+	cursor, _ := strconv.ParseInt(c.Query("cursor"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := service.SyncInbox(c.Request.Context(), &storagepb.SyncInboxRequest{
+		Uid:    uid,
+		Cursor: cursor,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":     resp.Entries,
+		"next_cursor": resp.NextCursor,
+		"has_more":    resp.HasMore,
+	})
+}
+
+// syncMessages
+//
+// 按会话拉取 from_seq 之后的消息
+// GET /sync/messages?uid=&conversation_id=&from_seq=&limit=
+func syncMessages(c *gin.Context) {
+	uid := c.Query("uid")
+	conversationId := c.Query("conversation_id")
+	if uid == "" || conversationId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and conversation_id are required"})
+		return
+	}
+	fromSeq, _ := strconv.ParseInt(c.Query("from_seq"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := service.SyncMessages(c.Request.Context(), &storagepb.SyncMessagesRequest{
+		Uid:            uid,
+		ConversationId: conversationId,
+		FromSeq:        fromSeq,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": resp.Messages,
+		"has_more": resp.HasMore,
+	})
+}
+
+// ackInbox
+//
+// 确认已收到游标及之前的离线消息
+// POST /sync/inbox/ack?uid=&cursor=
+func ackInbox(c *gin.Context) {
+	uid := c.Query("uid")
+	cursor, err := strconv.ParseInt(c.Query("cursor"), 10, 64)
+	if uid == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid and cursor are required"})
+		return
+	}
+
+	trimmed, err := service.AckInbox(c.Request.Context(), uid, cursor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trimmed": trimmed})
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"gateway/config"
 	"gateway/dto"
 	"gateway/service"
@@ -60,13 +61,25 @@ func websocketServer(c *gin.Context) {
 		return
 	}
 
+	// 登记长连接, 以远程地址作为连接池的key
+	// 数据帧都经由 ws 加锁写入, 控制帧 WriteControl 可以并发调用
+	remoteAddr := conn.RemoteAddr().String()
+	ws, err := utils.PoolsOpsTemplate().AddConnTemplate(remoteAddr, conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	service.GapRepairerTemplate().Register(remoteAddr)
+
 	// 初始化创建状态status
-	utils.StatusTemplate().InitStatus(uid, utils.Meta{
-		UserRemoteAddr: conn.RemoteAddr().Network(),
+	meta := utils.Meta{
+		UserRemoteAddr: remoteAddr,
 		Status:         "online",
 		ServerId:       SERVER_ID,
 		ServerAddr:     config.GatewayCfg.Application.Host,
-	})
+	}
+	utils.StatusTemplate().InitStatus(uid, meta)
 
 	defer func() {
 		_ = conn.WriteControl(
@@ -79,12 +92,14 @@ func websocketServer(c *gin.Context) {
 	}()
 
 	cleanup := func() {
-		// clear connection info
+		// clear connection info, 用户已在其他连接上线时保留其状态
 
-		err := utils.StatusTemplate().ClearStatus(uid)
+		err := utils.StatusTemplate().ClearStatus(uid, meta)
 		if err != nil {
 			log.Printf("clear status failed: %v", err)
 		}
+		utils.PoolsOpsTemplate().ReleaseConnTemplate(remoteAddr)
+		service.GapRepairerTemplate().Release(remoteAddr)
 
 		log.Default().Println("[INFO] 清除断开连接信息")
 	}
@@ -118,11 +133,33 @@ func websocketServer(c *gin.Context) {
 				return
 			}
 
+			switch dto.FrameKind(msg) {
+			case dto.FRAME_EPHEMERAL:
+				// 瞬时信号不回执, 被限流时静默丢弃
+				err := handleEphemeral(uid, msg)
+				if err != nil && !errors.Is(err, service.ErrEphemeralLimited) {
+					ws.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+				}
+				continue
+			case dto.FRAME_READ:
+				if err := handleRead(uid, msg); err != nil {
+					ws.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+				}
+				continue
+			case dto.FRAME_ACK:
+				if err := handleAck(uid, remoteAddr, msg); err != nil {
+					ws.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+				}
+				continue
+			}
+
 			// 处理收到的消息
-			if err := handleMessage(msg); err != nil {
-				conn.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+			sent, err := handleMessage(uid, remoteAddr, msg)
+			if err != nil {
+				ws.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+				continue
 			}
-			conn.WriteMessage(websocket.TextMessage, []byte("message received"))
+			ws.WriteMessage(websocket.TextMessage, sent)
 		}
 	}()
 
@@ -146,56 +183,50 @@ var validate = validator.New()
 // handleMessage
 //
 // 处理消息函数
-// 处理消息转发，若是处理失败则返回消息未能成功发送的结果，希望冲重新投入发送.
-func handleMessage(msg []byte) error {
+// 消息交由center分配id与seq、落库并投递, 返回给发送者的回执
+// 处理失败时返回错误, 希望客户端重新发送.
+// 发送者以鉴权后的uid为准, 忽略客户端上报的sender_id, id与seq由服务端分配
+// 分配的seq记入该连接的投递进度, 对方之后的消息不会把它当作缺失
+func handleMessage(uid, remoteAddr string, msg []byte) ([]byte, error) {
 	var message *dto.MessageDTO
 
 	if err := json.Unmarshal(msg, &message); err != nil {
-		return err
+		return nil, err
 	}
 	if err := validate.Struct(message); err != nil {
-		return err
+		return nil, err
+	}
+	if err := service.ValidatePayload(message); err != nil {
+		return nil, err
 	}
+	message.SenderID = uid
+	message.Id, message.Seq = "", 0
 
-	// service hander
-	data, err := utils.StatusTemplate().GetStatus(message.ReceiverId)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	resp, err := service.SendMessage(ctx, service.ToMessageData(message))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	service.GapRepairerTemplate().Sent(remoteAddr, message.ReceiverId, message.SessionType, resp.Seq)
+	return json.Marshal(&dto.SentDTO{Action: "sent", Id: resp.Id, Seq: resp.Seq})
+}
 
-	// 不同路由处理
-	if data.ServerId != SERVER_ID && data.Status == "online" {
-		// 消息队列发送处理
-		err = service.NewProducer(
-			config.KafkaProducerTemplate(),
-			config.KafkaDLQTemplate(),
-		).SendMessage(
-			context.Background(),
-			message,
-			SERVER_ID,
-		)
-		if err != nil {
-			return err
-		}
-	}
+// handleEphemeral
+//
+// 处理瞬时信号 (正在输入、正在录音 ...)
+// 发送者以鉴权后的uid为准, 忽略客户端上报的sender_id
+func handleEphemeral(uid string, msg []byte) error {
+	var signal dto.EphemeralDTO
 
-	// 同路由在线处理
-	if data.ServerId == SERVER_ID && data.Status == "online" {
-		// 直接发送
-		conn, ok := utils.PoolsOpsTemplate().GetConnTemplate(data.UserRemoteAddr)
-		if !ok {
-			// TODO : 发送至service
-		}
-		err = conn.WriteMessage(websocket.TextMessage, msg)
-		if err != nil {
-			// TODO 发送至service
-			return err
-		}
+	if err := json.Unmarshal(msg, &signal); err != nil {
+		return err
 	}
-
-	if data.Status != "online" {
-		// TODO : 发送至service
+	if err := validate.Struct(&signal); err != nil {
+		return err
 	}
+	signal.SenderID = uid
 
-	return nil
+	return service.EphemeralTemplate().Dispatch(context.Background(), &signal)
 }
@@ -0,0 +1,13 @@
+package dto
+
+// EphemeralDTO 瞬时信号
+//
+// 例如 "正在输入..."、"正在录音" 等状态提示
+// 不落库、不分配seq, 只投递给当前在线的会话参与者, 接收方离线直接丢弃
+type EphemeralDTO struct {
+	Event       string `json:"event" validate:"required,oneof=typing recording_audio uploading_media cancel"`
+	SenderID    string `json:"sender_id"`
+	ReceiverId  string `json:"receiver_id" validate:"required"` // 私聊为对方uid, 群聊为群id
+	SessionType string `json:"session_type" validate:"omitempty,oneof=single group"`
+	Time        int64  `json:"time"`
+}
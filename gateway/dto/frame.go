@@ -0,0 +1,31 @@
+package dto
+
+import "encoding/json"
+
+// 客户端上行帧类型
+const (
+	FRAME_MESSAGE   = "message"
+	FRAME_EPHEMERAL = "ephemeral"
+	FRAME_READ      = "read"
+	FRAME_ACK       = "ack"
+)
+
+// FrameKind 判断一帧上行消息的类型
+//
+// 瞬时信号携带 event 字段, 控制类帧(已读上报等)携带 action 字段, 其余均视为普通消息
+func FrameKind(msg []byte) string {
+	var probe struct {
+		Event  string `json:"event"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(msg, &probe); err != nil {
+		return FRAME_MESSAGE
+	}
+	if probe.Event != "" {
+		return FRAME_EPHEMERAL
+	}
+	if probe.Action != "" {
+		return probe.Action
+	}
+	return FRAME_MESSAGE
+}
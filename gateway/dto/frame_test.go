@@ -0,0 +1,18 @@
+package dto
+
+import "testing"
+
+func TestFrameKind(t *testing.T) {
+	cases := map[string]string{
+		`{"sender_id":"a","receiver_id":"b","content":"hi"}`: FRAME_MESSAGE,
+		`{"event":"typing","receiver_id":"b"}`:               FRAME_EPHEMERAL,
+		`{"action":"read","conversation_id":"b","seq":3}`:    FRAME_READ,
+		`{"event":"typing","action":"read"}`:                 FRAME_EPHEMERAL,
+		`not json`:                                           FRAME_MESSAGE,
+	}
+	for frame, want := range cases {
+		if got := FrameKind([]byte(frame)); got != want {
+			t.Errorf("FrameKind(%s) = %s, want %s", frame, got, want)
+		}
+	}
+}
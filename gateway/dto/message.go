@@ -5,8 +5,29 @@ import "time"
 type MessageDTO struct {
 	SenderID    string        `json:"sender_id"`
 	ReceiverId  string        `json:"receiver_id"`
-	MessageType string        `json:"message_type" validate:"required, oneof=text image file video audio"`
-	Content     string        `json:"content"`
+	MessageType string        `json:"message_type" validate:"required, oneof=text image file video audio encrypted"`
+	Content     string        `json:"content"` // 文本消息为正文, 媒体消息为obs对象引用(MediaPayload的json), 加密消息为base64密文
 	Time        time.Duration `json:"time"`
 	Status      string        `json:"status" validate:"required, oneof=send withdraw"`
+
+	// 以下由服务端投递时填写, 客户端按会话检查seq是否连续
+	Id          string `json:"id,omitempty"`
+	Seq         int64  `json:"seq,omitempty"`
+	SessionType string `json:"session_type,omitempty"` // single group, 为空时为私聊
+}
+
+// GapDTO 会话中缺失的消息过多, 通知客户端自行拉取 seq 在 (from_seq, to_seq] 之间的历史消息
+type GapDTO struct {
+	Action         string `json:"action"`          // 固定为 gap
+	ConversationId string `json:"conversation_id"` // 私聊为对方uid, 群聊为群id
+	SessionType    string `json:"session_type"`
+	FromSeq        int64  `json:"from_seq"`
+	ToSeq          int64  `json:"to_seq"`
+}
+
+// SentDTO 消息发送成功后回复给发送者, 携带服务端分配的id与seq
+type SentDTO struct {
+	Action string `json:"action"` // 固定为 sent
+	Id     string `json:"id"`
+	Seq    int64  `json:"seq"`
 }
@@ -0,0 +1,23 @@
+package dto
+
+// ReadDTO 已读上报
+//
+// 客户端上报在会话中已读到的最大seq
+type ReadDTO struct {
+	Action         string `json:"action"`
+	ConversationId string `json:"conversation_id" validate:"required"` // 私聊为对方uid, 群聊为群id
+	SessionType    string `json:"session_type" validate:"omitempty,oneof=single group"`
+	Seq            int64  `json:"seq" validate:"gt=0"`
+}
+
+// AckDTO 设备收到消息后的ack
+//
+// 任一接收设备ack后, 发送者会收到送达事件
+type AckDTO struct {
+	Action         string `json:"action"`
+	MessageId      string `json:"message_id" validate:"required"`
+	SenderId       string `json:"sender_id" validate:"required"`
+	ConversationId string `json:"conversation_id"` // 群聊时为群id
+	SessionType    string `json:"session_type" validate:"omitempty,oneof=single group"`
+	Seq            int64  `json:"seq"`
+}
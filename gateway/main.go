@@ -22,6 +22,7 @@ func main() {
 	kafkaConf := config.KafkaConf{
 		Brokers:      []string{cfg.Application.Component.Kafka.Brokers},
 		Topic:        cfg.Application.Component.Kafka.Topic,
+		NodeId:       cfg.Application.NodeId,
 		GroupId:      cfg.Application.Component.Kafka.GroupID,
 		RequiredAcks: cfg.Application.Component.Kafka.RequiredAcks,
 		DLQTopic:     dlqTopic,
@@ -65,11 +66,36 @@ func main() {
 		Timeout: time.Duration(10) * time.Second,
 	})
 
+	// 初始化下游gRPC连接池, 实际连接地址由consul服务发现决定
+	_, err = utils.GetgRPCPoolInstance(&utils.GRPCClientConfig{
+		Addr:                addr,
+		MaxConn:             32,
+		Timeout:             3 * time.Second,
+		PermitWithoutStream: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	// 初始化status
-	utils.InitStatus(rc)
+	utils.InitStatus(rc, cfg.Application.NodeId)
 
 	// 初始化工作池
-	utils.NewWsPool(1000)
+	utils.InitWsPool()
+
+	// 初始化瞬时信号投递
+	service.InitEphemeral(
+		rc,
+		config.KafkaProducerTemplate(),
+		cfg.Application.Component.Kafka.Topic,
+		cfg.Application.NodeId,
+		cfg.Application.Ephemeral.Rate,
+		cfg.Application.Ephemeral.Burst,
+	)
+
+	// 初始化设备ack批量上报
+	flushInterval, _ := time.ParseDuration(cfg.Application.Ack.FlushInterval)
+	service.InitAckBatcher(context.Background(), cfg.Application.Ack.BatchSize, flushInterval)
 
 	// 启动协程消费kafka
 	go func() {
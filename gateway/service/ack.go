@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/grpc"
+)
+
+// 设备ack批量上报
+//
+// 设备ack先进入缓冲队列, 攒够 size 条或距上次上报超过 interval 时批量上报至center
+// 队列满时丢弃新的ack, 送达状态以尽力而为的方式维护
+// 接收者为连接上已认证的uid, 发送者、会话与seq由center按storage中的消息重新确定
+
+type ackBatcher struct {
+	acks     chan *pb.DeliveryAck
+	size     int
+	interval time.Duration
+	flush    func(ctx context.Context, acks []*pb.DeliveryAck) error
+}
+
+var (
+	ackBatcherIns  *ackBatcher
+	ackBatcherOnce sync.Once
+)
+
+func newAckBatcher(
+	size int,
+	interval time.Duration,
+	flush func(ctx context.Context, acks []*pb.DeliveryAck) error,
+) *ackBatcher {
+	if size <= 0 {
+		size = 100
+	}
+	if interval <= 0 {
+		interval = 200 * time.Millisecond
+	}
+	return &ackBatcher{
+		acks:     make(chan *pb.DeliveryAck, size*10),
+		size:     size,
+		interval: interval,
+		flush:    flush,
+	}
+}
+
+// InitAckBatcher 初始化ack批量上报并启动上报协程
+func InitAckBatcher(ctx context.Context, size int, interval time.Duration) {
+	ackBatcherOnce.Do(func() {
+		ackBatcherIns = newAckBatcher(size, interval, reportDelivered)
+		go ackBatcherIns.run(ctx)
+	})
+}
+
+func AckBatcherTemplate() *ackBatcher {
+	if ackBatcherIns == nil {
+		panic("ack batcher: call InitAckBatcher first")
+	}
+	return ackBatcherIns
+}
+
+// Add 加入一条ack, 队列已满时返回false
+func (b *ackBatcher) Add(ack *pb.DeliveryAck) bool {
+	select {
+	case b.acks <- ack:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *ackBatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]*pb.DeliveryAck, 0, b.size)
+	report := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.flush(ctx, batch); err != nil {
+			log.Default().Printf("[ERROR] 上报送达ack失败, 丢弃 %d 条: %v", len(batch), err)
+		}
+		batch = make([]*pb.DeliveryAck, 0, b.size)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 退出前尽量上报剩余的ack
+			for {
+				select {
+				case ack := <-b.acks:
+					batch = append(batch, ack)
+				default:
+					report()
+					return
+				}
+			}
+		case ack := <-b.acks:
+			batch = append(batch, ack)
+			if len(batch) >= b.size {
+				report()
+			}
+		case <-ticker.C:
+			report()
+		}
+	}
+}
+
+func reportDelivered(ctx context.Context, acks []*pb.DeliveryAck) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+
+	return Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		_, err := pb.NewReceiptServiceClient(conn).ReportDelivered(ctx, &pb.ReportDeliveredRequest{Acks: acks})
+		return err
+	})
+}
+
+// QueryDelivery 查询消息送达状态
+func QueryDelivery(ctx context.Context, uid, messageId string) (*pb.QueryDeliveryResponse, error) {
+	var resp *pb.QueryDeliveryResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewReceiptServiceClient(conn).QueryDelivery(ctx, &pb.QueryDeliveryRequest{Uid: uid, MessageId: messageId})
+		return err
+	})
+	return resp, err
+}
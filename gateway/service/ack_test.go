@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// 测试ack按数量与时间两种条件批量上报
+func TestAckBatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]*pb.DeliveryAck
+	)
+	flushed := make(chan struct{}, 10)
+	b := newAckBatcher(3, 50*time.Millisecond, func(_ context.Context, acks []*pb.DeliveryAck) error {
+		mu.Lock()
+		batches = append(batches, acks)
+		mu.Unlock()
+		flushed <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.run(ctx)
+		close(done)
+	}()
+
+	// 攒够3条立即上报
+	for i := 0; i < 3; i++ {
+		b.Add(&pb.DeliveryAck{MessageId: "m"})
+	}
+	<-flushed
+
+	// 不足3条时等待定时上报
+	b.Add(&pb.DeliveryAck{MessageId: "n"})
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("pending ack should be flushed by ticker")
+	}
+
+	// 退出时上报剩余ack
+	b.Add(&pb.DeliveryAck{MessageId: "o"})
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := 0
+	for _, batch := range batches {
+		total += len(batch)
+	}
+	if total != 5 {
+		t.Fatalf("expected 5 acks flushed, got %d", total)
+	}
+	if len(batches[0]) != 3 {
+		t.Errorf("first batch should be flushed by size, got %d", len(batches[0]))
+	}
+}
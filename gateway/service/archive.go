@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// 冷热分层存储
+// 归档由storage定期执行, gateway只提供查询分段与恢复的管理接口
+
+// ListSegments 查询会话的归档分段
+func ListSegments(ctx context.Context, conversationId string) ([]*storagepb.Segment, error) {
+	var segs []*storagepb.Segment
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := storagepb.NewArchiveServiceClient(conn).ListSegments(ctx, &storagepb.ListSegmentsRequest{
+			ConversationId: conversationId,
+		})
+		if err != nil {
+			return err
+		}
+		segs = resp.Segments
+		return nil
+	})
+	return segs, err
+}
+
+// RestoreRange 将与seq范围有交集的分段恢复到主存储
+func RestoreRange(ctx context.Context, in *storagepb.RestoreRangeRequest) (*storagepb.RestoreRangeResponse, error) {
+	var resp *storagepb.RestoreRangeResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewArchiveServiceClient(conn).RestoreRange(ctx, in)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/grpc"
+)
+
+// 阅后即焚
+// 计时器由center存储, 到期删除及删除事件的推送也由center完成
+
+// DisappearStart 客户端计时方式转换为协议枚举, 默认已读后计时
+func DisappearStart(start string) pb.DisappearStart {
+	if start == "send" {
+		return pb.DisappearStart_START_ON_SEND
+	}
+	return pb.DisappearStart_START_ON_READ
+}
+
+// SetDisappearTimer 修改会话的计时器, ttl 为0时关闭
+func SetDisappearTimer(ctx context.Context, in *pb.SetDisappearTimerRequest) (*pb.SetDisappearTimerResponse, error) {
+	var resp *pb.SetDisappearTimerResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewDisappearServiceClient(conn).SetTimer(ctx, in)
+		return err
+	})
+	return resp, err
+}
+
+// GetDisappearTimer 查询会话的计时器
+func GetDisappearTimer(ctx context.Context, in *pb.GetDisappearTimerRequest) (*pb.DisappearTimer, error) {
+	var resp *pb.DisappearTimer
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewDisappearServiceClient(conn).GetTimer(ctx, in)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gateway/config"
+	"gateway/dto"
+	"gateway/utils"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+// ephemeral
+//
+// 瞬时信号投递 ("正在输入..."、"正在录音" 等)
+// 1. 不持久化、不分配seq
+// 2. 按发送者uid限流
+// 3. 通过在线状态(status:{uid})只投递给当前在线的会话参与者
+// 4. 接收方离线或投递失败直接丢弃, 不重试也不进入死信队列
+
+// 群成员集合
+// key : groupMember:{groupId}
+// value : set(uid)
+const GROUP_MEMBER_PREFIX = "groupMember:"
+
+var (
+	ErrEphemeralLimited = errors.New("ephemeral signal rate limited")
+	ErrNotParticipant   = errors.New("sender is not a participant of the conversation")
+)
+
+// messageWriter kafka.Writer 实现了该接口
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type ephemeral struct {
+	redis   redis.UniversalClient
+	writer  messageWriter
+	limiter *utils.RateLimiter
+	topic   string // 节点topic的前缀
+	nodeId  string
+
+	status func(uid string) (utils.Meta, error) // 查询在线状态
+	local  func(meta utils.Meta, body []byte)   // 写入本节点的长连接
+}
+
+var (
+	ephemeralIns  *ephemeral
+	ephemeralOnce sync.Once
+)
+
+func NewEphemeral(
+	redis redis.UniversalClient,
+	writer messageWriter,
+	limiter *utils.RateLimiter,
+	topic string,
+	nodeId string,
+) *ephemeral {
+	return &ephemeral{
+		redis:   redis,
+		writer:  writer,
+		limiter: limiter,
+		topic:   topic,
+		nodeId:  nodeId,
+		status: func(uid string) (utils.Meta, error) {
+			return utils.StatusTemplate().GetStatus(uid)
+		},
+		local: deliverOnline,
+	}
+}
+
+// InitEphemeral 初始化瞬时信号投递
+// topic: 节点topic的前缀, 转发写入目标节点的topic
+// rate: 每个用户每秒允许发送的信号数
+// burst: 允许的瞬时突发数
+func InitEphemeral(
+	redis *redis.ClusterClient,
+	writer *kafka.Writer,
+	topic string,
+	nodeId string,
+	rate float64,
+	burst int,
+) {
+	ephemeralOnce.Do(func() {
+		limiter := utils.NewRateLimiter(rate, burst)
+		ephemeralIns = NewEphemeral(redis, writer, limiter, topic, nodeId)
+
+		// 定期回收不活跃用户的令牌桶
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				limiter.Clean(time.Minute)
+			}
+		}()
+	})
+}
+
+func EphemeralTemplate() *ephemeral {
+	if ephemeralIns == nil {
+		panic("ephemeral: call InitEphemeral first")
+	}
+	return ephemeralIns
+}
+
+// Dispatch 投递一条瞬时信号
+//
+// 本节点在线的参与者直接写入长连接
+// 其他节点在线的参与者通过kafka转发至其所在节点的topic
+func (e *ephemeral) Dispatch(ctx context.Context, msg *dto.EphemeralDTO) error {
+	if !e.limiter.Allow(msg.SenderID) {
+		return ErrEphemeralLimited
+	}
+
+	targets, err := e.participants(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	if msg.Time == 0 {
+		msg.Time = time.Now().UnixMilli()
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var forwards []kafka.Message
+	for _, uid := range targets {
+		meta, err := e.status(uid)
+		if err != nil || !meta.Online() {
+			// 离线直接丢弃
+			continue
+		}
+
+		if meta.ServerId == e.nodeId {
+			e.local(meta, body)
+			continue
+		}
+
+		forwards = append(forwards, kafka.Message{
+			Topic: config.NodeTopic(e.topic, meta.ServerId),
+			Value: body,
+			Headers: []kafka.Header{
+				{Key: HEADER_KIND, Value: []byte(KIND_EPHEMERAL)},
+				{Key: HEADER_UID, Value: []byte(uid)},
+			},
+		})
+	}
+
+	if len(forwards) == 0 {
+		return nil
+	}
+
+	// 转发只尝试一次, 失败即丢弃
+	if err := e.writer.WriteMessages(ctx, forwards...); err != nil {
+		log.Default().Printf("[WARN] 瞬时信号转发失败, 已丢弃: %v", err)
+	}
+	return nil
+}
+
+// participants 获取需要接收信号的会话参与者, 不包含发送者本人
+func (e *ephemeral) participants(ctx context.Context, msg *dto.EphemeralDTO) ([]string, error) {
+	if msg.SessionType != "group" {
+		if msg.ReceiverId == msg.SenderID {
+			return nil, nil
+		}
+		return []string{msg.ReceiverId}, nil
+	}
+
+	members, err := e.redis.SMembers(ctx, GROUP_MEMBER_PREFIX+msg.ReceiverId).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0, len(members))
+	joined := false
+	for _, uid := range members {
+		if uid == msg.SenderID {
+			joined = true
+			continue
+		}
+		targets = append(targets, uid)
+	}
+	if !joined {
+		return nil, ErrNotParticipant
+	}
+	return targets, nil
+}
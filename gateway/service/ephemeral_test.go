@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"gateway/dto"
+	"gateway/utils"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter 记录转发到其他节点的消息
+type fakeWriter struct {
+	msgs []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+// newTestEphemeral 本节点为 gw1, bob 在本节点在线, carol 在 gw2 在线, dave 离线
+// alice 与 bob、carol、dave 同在群 g1
+func newTestEphemeral(t *testing.T) (*ephemeral, *fakeWriter, map[string]int) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	rdb.SAdd(context.Background(), GROUP_MEMBER_PREFIX+"g1", "alice", "bob", "carol", "dave")
+
+	online := map[string]utils.Meta{
+		"bob":   {ServerId: "gw1", UserRemoteAddr: "conn-bob", Status: "online"},
+		"carol": {ServerId: "gw2", UserRemoteAddr: "conn-carol", Status: "online"},
+		"dave":  {ServerId: "gw1", UserRemoteAddr: "conn-dave", Status: "deadline"},
+	}
+	writer := &fakeWriter{}
+	local := make(map[string]int)
+	e := NewEphemeral(rdb, writer, utils.NewRateLimiter(100, 100), "im.push", "gw1")
+	e.status = func(uid string) (utils.Meta, error) {
+		meta, ok := online[uid]
+		if !ok {
+			return utils.Meta{}, redis.Nil
+		}
+		return meta, nil
+	}
+	e.local = func(meta utils.Meta, body []byte) {
+		local[meta.UserRemoteAddr]++
+	}
+	return e, writer, local
+}
+
+// 测试本节点在线的参与者直接写入长连接, 其他节点的经由其节点topic转发, 离线的丢弃
+func TestEphemeralDispatchRouting(t *testing.T) {
+	e, writer, local := newTestEphemeral(t)
+
+	err := e.Dispatch(context.Background(), &dto.EphemeralDTO{Event: "typing", SenderID: "alice", ReceiverId: "g1", SessionType: "group"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(local) != 1 || local["conn-bob"] != 1 {
+		t.Fatalf("unexpected local deliveries %v", local)
+	}
+	if len(writer.msgs) != 1 {
+		t.Fatalf("expected 1 forward, got %d", len(writer.msgs))
+	}
+	msg := writer.msgs[0]
+	if msg.Topic != "im.push.gw2" || HeaderValue(msg, HEADER_UID) != "carol" || HeaderValue(msg, HEADER_KIND) != KIND_EPHEMERAL {
+		t.Fatalf("unexpected forward %s %v", msg.Topic, msg.Headers)
+	}
+}
+
+// 测试接收方离线时直接丢弃, 不转发
+func TestEphemeralDropOffline(t *testing.T) {
+	e, writer, local := newTestEphemeral(t)
+
+	for _, receiver := range []string{"dave", "erin"} {
+		err := e.Dispatch(context.Background(), &dto.EphemeralDTO{Event: "typing", SenderID: "alice", ReceiverId: receiver})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(local) != 0 || len(writer.msgs) != 0 {
+		t.Fatalf("signal to offline user delivered: local %v, forwarded %d", local, len(writer.msgs))
+	}
+}
+
+// 测试非群成员不能向群发送瞬时信号
+func TestEphemeralNotParticipant(t *testing.T) {
+	e, writer, local := newTestEphemeral(t)
+
+	err := e.Dispatch(context.Background(), &dto.EphemeralDTO{Event: "typing", SenderID: "mallory", ReceiverId: "g1", SessionType: "group"})
+	if err != ErrNotParticipant {
+		t.Fatalf("expected ErrNotParticipant, got %v", err)
+	}
+	if len(local) != 0 || len(writer.msgs) != 0 {
+		t.Fatal("signal from non-member delivered")
+	}
+}
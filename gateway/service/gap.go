@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gateway/dto"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+)
+
+// 会话seq空洞修复
+//
+// 投递是至少一次的, kafka重投与重试会让客户端看到重复或跳号的seq
+// 每个连接按会话记录已投递的最大seq:
+// 1. seq 不大于已投递的最大seq且不是空洞, 为重复投递, 直接丢弃
+// 2. seq 跳号时先从storage按seq拉取缺失的区间并按序投递, 再投递当前消息
+// 3. storage中也没有的seq (号段释放、恢复时跳号、发送方崩溃) 记为空洞, 不阻塞投递, 空洞中的seq之后到达时照常投递
+// 4. 缺失超过 MAX_GAP_FILL 条时只补最近的部分, 更早的通过 gap 帧通知客户端自行拉取历史
+// 5. 会话的seq由双方共用, 用户自己在该连接上发出的消息通过 Sent 推进进度, 不视为缺失
+//
+// 连接上首次收到某会话的消息时以它为起点, 此前客户端ack过的seq同样可以作为起点
+// 没有seq的消息 (瞬时信号、旧版本节点转发的消息) 不做处理, 直接投递
+//
+// 连接建立时 Register, 断开时 Release; Dispatch、Observe、Sent 忽略未登记或已释放的连接
+// kafka消费循环通过 Dispatch 将消息交给连接的投递协程, 补拉只阻塞该连接后续的投递
+// 补拉与写入连接期间不持有连接的锁, 客户端的ack照常处理
+
+const (
+	MAX_GAP_FILL     = 500             // 单次最多补拉的消息数
+	MAX_GAP_HOLES    = 256             // 每个会话最多记录的空洞数, 超出时丢弃最早的
+	GAP_FILL_TIMEOUT = 2 * time.Second // 单次补拉的超时, 超时后剩余的seq记为空洞
+	gapPageSize      = 200
+	DELIVER_QUEUE    = 256 // 每个连接排队等待投递的消息数
+)
+
+var (
+	ErrDeliverQueueFull = errors.New("deliver queue is full")
+	ErrConnReleased     = errors.New("connection released")
+)
+
+type seqWindow struct {
+	last  int64              // 已投递的最大seq
+	holes map[int64]struct{} // 补拉时storage中不存在的seq
+	sent  map[int64]struct{} // 用户自己发出、尚未连续的seq
+}
+
+// bounded 加入集合, 超过 MAX_GAP_HOLES 时丢弃最早的
+func bounded(set map[int64]struct{}, seq int64) {
+	if len(set) >= MAX_GAP_HOLES {
+		oldest := seq
+		for s := range set {
+			oldest = min(oldest, s)
+		}
+		if oldest == seq {
+			return
+		}
+		delete(set, oldest)
+	}
+	set[seq] = struct{}{}
+}
+
+func (w *seqWindow) addHole(seq int64) {
+	bounded(w.holes, seq)
+}
+
+// advance 推进到 seq, 之后连续的自己发出的seq一并跳过
+func (w *seqWindow) advance(seq int64) {
+	w.last = seq
+	for s := range w.sent {
+		if s <= w.last {
+			delete(w.sent, s)
+		}
+	}
+	for {
+		if _, ok := w.sent[w.last+1]; !ok {
+			return
+		}
+		delete(w.sent, w.last+1)
+		w.last++
+	}
+}
+
+// connSeqs 一个连接上各会话的投递进度
+// deliverMu 使同一连接的投递串行执行, 补拉与写入期间同样持有; mu 只保护 convs, 补拉与写入期间释放
+type connSeqs struct {
+	deliverMu sync.Mutex
+	mu        sync.Mutex
+	convs     map[string]*seqWindow
+
+	queue     chan pending
+	start     sync.Once
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// pending 等待投递协程处理的消息
+type pending struct {
+	uid   string
+	msg   *dto.MessageDTO
+	body  []byte
+	write func([]byte) error
+}
+
+func newConnSeqs() *connSeqs {
+	return &connSeqs{
+		convs: make(map[string]*seqWindow),
+		queue: make(chan pending, DELIVER_QUEUE),
+		done:  make(chan struct{}),
+	}
+}
+
+func (c *connSeqs) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (c *connSeqs) window(conv, typ string) *seqWindow {
+	key := typ + ":" + conv
+	w, ok := c.convs[key]
+	if !ok {
+		w = &seqWindow{holes: make(map[int64]struct{}), sent: make(map[int64]struct{})}
+		c.convs[key] = w
+	}
+	return w
+}
+
+type gapRepairer struct {
+	conns sync.Map // remoteAddr -> *connSeqs
+	fetch func(ctx context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error)
+}
+
+var (
+	gapRepairerIns  *gapRepairer
+	gapRepairerOnce sync.Once
+)
+
+func newGapRepairer(
+	fetch func(ctx context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error),
+) *gapRepairer {
+	return &gapRepairer{fetch: fetch}
+}
+
+// GapRepairerTemplate 从storage消息历史补拉缺失消息
+func GapRepairerTemplate() *gapRepairer {
+	gapRepairerOnce.Do(func() {
+		gapRepairerIns = newGapRepairer(QueryHistory)
+	})
+	return gapRepairerIns
+}
+
+// conversationKey uid视角的会话, 私聊为对方uid, 群聊为群id
+// 会话的seq由双方共用, 自己发出的消息与收到的消息在同一个会话中
+func conversationKey(uid string, msg *dto.MessageDTO) (string, string) {
+	if msg.SessionType == "group" {
+		return msg.ReceiverId, "group"
+	}
+	if msg.SenderID == uid {
+		return msg.ReceiverId, "single"
+	}
+	return msg.SenderID, "single"
+}
+
+// Register 连接建立时登记, 之后才接受投递与ack
+func (g *gapRepairer) Register(remoteAddr string) {
+	g.register(remoteAddr)
+}
+
+func (g *gapRepairer) register(remoteAddr string) *connSeqs {
+	if c, ok := g.conns.Load(remoteAddr); ok {
+		return c.(*connSeqs)
+	}
+	c, _ := g.conns.LoadOrStore(remoteAddr, newConnSeqs())
+	return c.(*connSeqs)
+}
+
+// conn 已登记的连接, 未登记或已释放时返回false, 不创建投递进度
+func (g *gapRepairer) conn(remoteAddr string) (*connSeqs, bool) {
+	c, ok := g.conns.Load(remoteAddr)
+	if !ok {
+		return nil, false
+	}
+	return c.(*connSeqs), true
+}
+
+// Dispatch 交给连接的投递协程按seq顺序投递, 不等待投递完成
+// 排队的消息超过 DELIVER_QUEUE 时返回 ErrDeliverQueueFull, 连接未登记或已释放时返回 ErrConnReleased
+func (g *gapRepairer) Dispatch(
+	uid, remoteAddr string,
+	msg *dto.MessageDTO,
+	body []byte,
+	write func([]byte) error,
+) error {
+	c, ok := g.conn(remoteAddr)
+	if !ok {
+		return ErrConnReleased
+	}
+	c.start.Do(func() { go g.run(remoteAddr, c) })
+
+	select {
+	case c.queue <- pending{uid: uid, msg: msg, body: body, write: write}:
+		return nil
+	default:
+		return ErrDeliverQueueFull
+	}
+}
+
+// run 连接的投递协程, 连接释放或写入失败时退出
+// 退出时排队的消息不再投递, 已落库的消息由客户端重连后按seq同步
+func (g *gapRepairer) run(remoteAddr string, c *connSeqs) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case p := <-c.queue:
+			err := g.deliver(context.Background(), c, p.uid, p.msg, p.body, p.write)
+			if err != nil {
+				log.Default().Printf("[WARN] 投递消息到连接 %s 失败: %v", remoteAddr, err)
+				g.conns.CompareAndDelete(remoteAddr, c)
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// Deliver 按seq顺序投递消息给uid在 remoteAddr 上的连接, 等待投递完成
+// body 为消息原文, write 写一帧到连接, 返回写入失败的错误; 连接未登记时登记
+func (g *gapRepairer) Deliver(
+	ctx context.Context,
+	uid, remoteAddr string,
+	msg *dto.MessageDTO,
+	body []byte,
+	write func([]byte) error,
+) error {
+	return g.deliver(ctx, g.register(remoteAddr), uid, msg, body, write)
+}
+
+func (g *gapRepairer) deliver(
+	ctx context.Context,
+	c *connSeqs,
+	uid string,
+	msg *dto.MessageDTO,
+	body []byte,
+	write func([]byte) error,
+) error {
+	if msg.Seq <= 0 {
+		return write(body)
+	}
+	conv, typ := conversationKey(uid, msg)
+
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+
+	frames, err := g.plan(ctx, c, uid, conv, typ, msg.Seq, body)
+	if err != nil {
+		return err
+	}
+	// 写入期间不持有 mu, 写入失败时连接随之释放, 投递进度不需要回滚
+	for _, frame := range frames {
+		if err := write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plan 更新会话的投递进度, 返回需要按序写入连接的帧
+// 补拉期间不持有 mu, 重新加锁后起点已变化时按新的起点重新补拉
+func (g *gapRepairer) plan(ctx context.Context, c *connSeqs, uid, conv, typ string, seq int64, body []byte) ([][]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.window(conv, typ)
+
+	var fetched []*pb.MessageData
+	for from := int64(-1); w.last > 0 && seq > w.last+1 && w.last != from; {
+		from = w.last
+		c.mu.Unlock()
+		fetched = g.fetchRange(ctx, uid, conv, typ, max(from, seq-MAX_GAP_FILL-1), seq)
+		c.mu.Lock()
+	}
+
+	var frames [][]byte
+	switch {
+	case w.last == 0:
+		// 首条消息, 作为起点
+	case seq <= w.last:
+		if _, ok := w.holes[seq]; !ok {
+			return nil, nil
+		}
+		// 迟到的消息填补了空洞
+		delete(w.holes, seq)
+		return [][]byte{body}, nil
+	case seq > w.last+1:
+		var err error
+		if frames, err = g.fill(w, conv, typ, seq, fetched); err != nil {
+			return nil, err
+		}
+	}
+	w.advance(seq)
+	return append(frames, body), nil
+}
+
+// fetchRange 从storage拉取 (from, to) 之间的消息, 失败或超时时返回已拉取的部分
+func (g *gapRepairer) fetchRange(ctx context.Context, uid, conv, typ string, from, to int64) []*pb.MessageData {
+	ctx, cancel := context.WithTimeout(ctx, GAP_FILL_TIMEOUT)
+	defer cancel()
+
+	var msgs []*pb.MessageData
+	anchor := from
+	for anchor < to-1 {
+		resp, err := g.fetch(ctx, &storagepb.QueryBySeqRequest{
+			Uid:            uid,
+			ConversationId: conv,
+			SessionType:    SessionType(typ),
+			AnchorSeq:      anchor,
+			Direction:      storagepb.Direction_FORWARD,
+			Limit:          int32(min(to-anchor-1, gapPageSize)),
+		})
+		if err != nil {
+			log.Default().Printf("[WARN] 补拉会话 %s 的消息 (%d, %d) 失败: %v", conv, anchor, to, err)
+			break
+		}
+		msgs = append(msgs, resp.Messages...)
+		if !resp.HasMore || resp.NextSeq <= anchor {
+			break
+		}
+		anchor = resp.NextSeq
+	}
+	return msgs
+}
+
+// fill 补齐 (w.last, to) 之间缺失的消息, fetched 为从storage拉取到的消息, 返回需要写入的帧
+func (g *gapRepairer) fill(
+	w *seqWindow,
+	conv, typ string,
+	to int64,
+	fetched []*pb.MessageData,
+) ([][]byte, error) {
+	var frames [][]byte
+	if missing := to - w.last - 1; missing > MAX_GAP_FILL {
+		frame, _ := json.Marshal(&dto.GapDTO{
+			Action:         "gap",
+			ConversationId: conv,
+			SessionType:    typ,
+			FromSeq:        w.last,
+			ToSeq:          to - MAX_GAP_FILL - 1,
+		})
+		frames = append(frames, frame)
+		w.advance(to - MAX_GAP_FILL - 1)
+	}
+
+	for _, m := range fetched {
+		if m.Seq <= w.last || m.Seq >= to {
+			continue
+		}
+		// 自己发出的消息不再投递
+		if _, ok := w.sent[m.Seq]; ok {
+			continue
+		}
+		body, err := json.Marshal(FromMessageData(m))
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, body)
+		g.skip(w, m.Seq)
+		w.advance(m.Seq)
+	}
+
+	// storage中不存在的seq记为空洞
+	g.skip(w, to)
+	return frames, nil
+}
+
+// skip (w.last, to) 之间除自己发出的以外记为空洞
+func (g *gapRepairer) skip(w *seqWindow, to int64) {
+	for s := w.last + 1; s < to; s++ {
+		if _, ok := w.sent[s]; !ok {
+			w.addHole(s)
+		}
+	}
+}
+
+// Observe 客户端确认收到 seq, 连接上还没有该会话的投递记录时以它为起点
+// conversationId 私聊为对方uid, 群聊为群id
+func (g *gapRepairer) Observe(remoteAddr, conversationId, sessionType string, seq int64) {
+	if conversationId == "" || seq <= 0 {
+		return
+	}
+	if sessionType != "group" {
+		sessionType = "single"
+	}
+	c, ok := g.conn(remoteAddr)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := c.window(conversationId, sessionType)
+	if w.last == 0 {
+		w.advance(seq)
+	}
+}
+
+// Sent 用户在该连接上发出了会话中的 seq, 之后收到的消息不再把它当作缺失
+// conversationId 私聊为对方uid, 群聊为群id
+func (g *gapRepairer) Sent(remoteAddr, conversationId, sessionType string, seq int64) {
+	if conversationId == "" || seq <= 0 {
+		return
+	}
+	if sessionType != "group" {
+		sessionType = "single"
+	}
+	c, ok := g.conn(remoteAddr)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := c.window(conversationId, sessionType)
+	switch {
+	case w.last == 0 || seq == w.last+1:
+		w.advance(seq)
+	case seq > w.last+1:
+		bounded(w.sent, seq)
+	default:
+		// 补拉时记为空洞的seq不再等待
+		delete(w.holes, seq)
+	}
+}
+
+// Release 连接断开时清除投递进度并停止投递协程
+func (g *gapRepairer) Release(remoteAddr string) {
+	if c, ok := g.conns.LoadAndDelete(remoteAddr); ok {
+		c.(*connSeqs).close()
+	}
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gateway/dto"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+)
+
+// fakeHistory 按seq保存会话 alice 中的消息, seq 不存在时模拟空洞
+type fakeHistory struct {
+	msgs  map[int64]*pb.MessageData
+	calls int
+	err   error
+}
+
+func (f *fakeHistory) fetch(_ context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	// 与storage一致: 返回锚点之后最早的 limit 条
+	seqs := make([]int64, 0, len(f.msgs))
+	for seq := range f.msgs {
+		if seq > in.AnchorSeq {
+			seqs = append(seqs, seq)
+		}
+	}
+	slices.Sort(seqs)
+	resp := &storagepb.QueryMessagesResponse{NextSeq: in.AnchorSeq}
+	if len(seqs) > int(in.Limit) {
+		seqs, resp.HasMore = seqs[:in.Limit], true
+	}
+	for _, seq := range seqs {
+		resp.Messages = append(resp.Messages, f.msgs[seq])
+		resp.NextSeq = seq
+	}
+	return resp, nil
+}
+
+func history(seqs ...int64) *fakeHistory {
+	f := &fakeHistory{msgs: make(map[int64]*pb.MessageData)}
+	for _, seq := range seqs {
+		f.msgs[seq] = &pb.MessageData{SenderId: "alice", ReceiverId: "bob", Seq: seq, Payload: []byte("hi")}
+	}
+	return f
+}
+
+// recorder 记录写入连接的消息的seq, gap 帧记为 -to_seq
+type recorder struct {
+	mu   sync.Mutex
+	seqs []int64
+}
+
+func (r *recorder) write(body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var frame struct {
+		Action string `json:"action"`
+		Seq    int64  `json:"seq"`
+		ToSeq  int64  `json:"to_seq"`
+	}
+	if err := json.Unmarshal(body, &frame); err != nil {
+		return err
+	}
+	if frame.Action == "gap" {
+		r.seqs = append(r.seqs, -frame.ToSeq)
+		return nil
+	}
+	r.seqs = append(r.seqs, frame.Seq)
+	return nil
+}
+
+func deliver(t *testing.T, g *gapRepairer, r *recorder, seq int64) {
+	t.Helper()
+	msg := &dto.MessageDTO{SenderID: "alice", ReceiverId: "bob", Seq: seq}
+	body, _ := json.Marshal(msg)
+	if err := g.Deliver(context.Background(), "bob", "conn-1", msg, body, r.write); err != nil {
+		t.Fatalf("deliver %d: %v", seq, err)
+	}
+}
+
+func expectSeqs(t *testing.T, r *recorder, want ...int64) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.seqs) != len(want) {
+		t.Fatalf("delivered %v, want %v", r.seqs, want)
+	}
+	for i := range want {
+		if r.seqs[i] != want[i] {
+			t.Fatalf("delivered %v, want %v", r.seqs, want)
+		}
+	}
+}
+
+// 测试跳号时从storage补齐缺失的消息, 重复投递的消息被丢弃
+func TestGapRepair(t *testing.T) {
+	h := history(1, 2, 3, 4, 5)
+	g := newGapRepairer(h.fetch)
+	r := &recorder{}
+
+	deliver(t, g, r, 1)
+	deliver(t, g, r, 4)
+	deliver(t, g, r, 3)
+	deliver(t, g, r, 5)
+	deliver(t, g, r, 5)
+	expectSeqs(t, r, 1, 2, 3, 4, 5)
+	if h.calls != 1 {
+		t.Fatalf("expected 1 fetch, got %d", h.calls)
+	}
+}
+
+// 测试storage中不存在的seq记为空洞, 不阻塞投递, 迟到的消息照常投递
+func TestGapHoles(t *testing.T) {
+	h := history(1, 3, 6)
+	g := newGapRepairer(h.fetch)
+	r := &recorder{}
+
+	deliver(t, g, r, 1)
+	deliver(t, g, r, 6)
+	expectSeqs(t, r, 1, 3, 6)
+
+	// 2 迟到, 4 迟到两次, 3 已投递
+	deliver(t, g, r, 2)
+	deliver(t, g, r, 4)
+	deliver(t, g, r, 4)
+	deliver(t, g, r, 3)
+	expectSeqs(t, r, 1, 3, 6, 2, 4)
+}
+
+// 测试storage不可用时跳过补拉, 缺失的seq之后到达时照常投递
+func TestGapFetchFailed(t *testing.T) {
+	h := history()
+	h.err = errors.New("storage unavailable")
+	g := newGapRepairer(h.fetch)
+	r := &recorder{}
+
+	deliver(t, g, r, 1)
+	deliver(t, g, r, 3)
+	deliver(t, g, r, 2)
+	expectSeqs(t, r, 1, 3, 2)
+}
+
+// 测试缺失过多时只补最近的部分, 更早的通知客户端自行拉取
+func TestGapTooLarge(t *testing.T) {
+	to := int64(MAX_GAP_FILL + 10)
+	h := history(to - 1)
+	g := newGapRepairer(h.fetch)
+	r := &recorder{}
+
+	deliver(t, g, r, 1)
+	deliver(t, g, r, to)
+	expectSeqs(t, r, 1, -(to - MAX_GAP_FILL - 1), to-1, to)
+}
+
+// 测试ack的seq作为起点, 连接断开后重新开始
+func TestGapObserve(t *testing.T) {
+	h := history(1, 2, 3)
+	g := newGapRepairer(h.fetch)
+	r := &recorder{}
+
+	g.Register("conn-1")
+	g.Observe("conn-1", "alice", "single", 1)
+	deliver(t, g, r, 3)
+	expectSeqs(t, r, 2, 3)
+
+	g.Release("conn-1")
+	deliver(t, g, r, 1)
+	expectSeqs(t, r, 2, 3, 1)
+}
+
+// 测试补拉在连接的投递协程中执行, 不阻塞分发与ack, 投递顺序不变
+func TestGapDispatch(t *testing.T) {
+	h := history(1, 2, 3)
+	fetching, release := make(chan struct{}, 1), make(chan struct{})
+	g := newGapRepairer(func(ctx context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error) {
+		fetching <- struct{}{}
+		<-release
+		return h.fetch(ctx, in)
+	})
+	r := &recorder{}
+	g.Register("conn-1")
+	defer g.Release("conn-1")
+
+	dispatch := func(seq int64) {
+		t.Helper()
+		msg := &dto.MessageDTO{SenderID: "alice", ReceiverId: "bob", Seq: seq}
+		body, _ := json.Marshal(msg)
+		if err := g.Dispatch("bob", "conn-1", msg, body, r.write); err != nil {
+			t.Fatalf("dispatch %d: %v", seq, err)
+		}
+	}
+
+	dispatch(1)
+	dispatch(4)
+	dispatch(5)
+	<-fetching
+	observed := make(chan struct{})
+	go func() {
+		g.Observe("conn-1", "carol", "single", 7)
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Fatal("observe blocked by gap fill")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		n := len(r.seqs)
+		r.mu.Unlock()
+		if n >= 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	expectSeqs(t, r, 1, 2, 3, 4, 5)
+}
+
+// 测试未登记或已释放的连接不创建投递进度, 分发时返回 ErrConnReleased
+func TestGapReleased(t *testing.T) {
+	g := newGapRepairer(history(1).fetch)
+	r := &recorder{}
+	msg := &dto.MessageDTO{SenderID: "alice", ReceiverId: "bob", Seq: 1}
+	body, _ := json.Marshal(msg)
+
+	g.Observe("conn-1", "alice", "single", 1)
+	g.Sent("conn-1", "alice", "single", 2)
+	if err := g.Dispatch("bob", "conn-1", msg, body, r.write); !errors.Is(err, ErrConnReleased) {
+		t.Fatalf("expected ErrConnReleased, got %v", err)
+	}
+
+	g.Register("conn-1")
+	g.Release("conn-1")
+	if err := g.Dispatch("bob", "conn-1", msg, body, r.write); !errors.Is(err, ErrConnReleased) {
+		t.Fatalf("expected ErrConnReleased, got %v", err)
+	}
+	g.conns.Range(func(addr, _ any) bool {
+		t.Fatalf("state created for released connection %v", addr)
+		return false
+	})
+	expectSeqs(t, r)
+}
+
+// 测试写入连接期间不持有连接的锁, ack可以同时处理
+func TestGapWriteUnlocked(t *testing.T) {
+	g := newGapRepairer(history(1, 2, 3).fetch)
+	g.Register("conn-1")
+	r := &recorder{}
+	write := func(body []byte) error {
+		// 写入中处理ack, 持有锁时会死锁
+		g.Observe("conn-1", "carol", "single", 1)
+		return r.write(body)
+	}
+
+	for _, seq := range []int64{1, 3} {
+		msg := &dto.MessageDTO{SenderID: "alice", ReceiverId: "bob", Seq: seq}
+		body, _ := json.Marshal(msg)
+		done := make(chan error, 1)
+		go func() { done <- g.Deliver(context.Background(), "bob", "conn-1", msg, body, write) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("write blocked ack handling")
+		}
+	}
+	expectSeqs(t, r, 1, 2, 3)
+}
+
+// 测试私聊双方共用seq, 自己发出的seq推进进度, 不当作缺失补拉
+func TestGapOwnSeqs(t *testing.T) {
+	h := history(1, 2, 3, 4, 5, 6)
+	g := newGapRepairer(h.fetch)
+	g.Register("conn-1")
+	r := &recorder{}
+
+	deliver(t, g, r, 1)
+	g.Sent("conn-1", "alice", "single", 2)
+	deliver(t, g, r, 3)
+	expectSeqs(t, r, 1, 3)
+	if h.calls != 0 {
+		t.Fatalf("own seq fetched as a gap, %d fetches", h.calls)
+	}
+
+	// 先于对方的消息发出, 补拉时跳过自己的消息
+	g.Sent("conn-1", "alice", "single", 5)
+	deliver(t, g, r, 6)
+	expectSeqs(t, r, 1, 3, 4, 6)
+
+	// 自己其他设备发出的消息同样属于与对方的会话
+	msg := &dto.MessageDTO{SenderID: "bob", ReceiverId: "alice", Seq: 7}
+	body, _ := json.Marshal(msg)
+	if err := g.Deliver(context.Background(), "bob", "conn-1", msg, body, r.write); err != nil {
+		t.Fatal(err)
+	}
+	deliver(t, g, r, 8)
+	expectSeqs(t, r, 1, 3, 4, 6, 7, 8)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// 消息历史
+//
+// 历史消息由storage持久化, gateway只负责转发查询, 撤回经由center
+
+// QueryHistory 按seq翻页查询会话历史
+func QueryHistory(ctx context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error) {
+	var resp *storagepb.QueryMessagesResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewHistoryServiceClient(conn).QueryBySeq(ctx, in)
+		return err
+	})
+	return resp, err
+}
+
+// QueryHistoryByTime 按发送时间区间翻页查询会话历史
+func QueryHistoryByTime(ctx context.Context, in *storagepb.QueryByTimeRequest) (*storagepb.QueryMessagesResponse, error) {
+	var resp *storagepb.QueryMessagesResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewHistoryServiceClient(conn).QueryByTime(ctx, in)
+		return err
+	})
+	return resp, err
+}
+
+// GetMessages 按消息id查询
+func GetMessages(ctx context.Context, uid string, ids []string) (*storagepb.GetMessagesResponse, error) {
+	var resp *storagepb.GetMessagesResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewHistoryServiceClient(conn).GetMessages(ctx, &storagepb.GetMessagesRequest{
+			Uid:        uid,
+			MessageIds: ids,
+		})
+		return err
+	})
+	return resp, err
+}
+
+// RecallMessage 撤回消息, 经由center撤回并写入会话成员的时间线
+func RecallMessage(ctx context.Context, uid, messageId string) (*pb.RecallMessageResponse, error) {
+	var resp *pb.RecallMessageResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewMessageServiceClient(conn).RecallMessage(ctx, &pb.RecallMessageRequest{
+			Uid:       uid,
+			MessageId: messageId,
+		})
+		return err
+	})
+	return resp, err
+}
+
+// Search 全文检索uid可见的消息
+func Search(ctx context.Context, in *storagepb.SearchRequest) (*storagepb.SearchResponse, error) {
+	var resp *storagepb.SearchResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewSearchServiceClient(conn).Search(ctx, in)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"gateway/config"
+	"gateway/utils"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// 下游服务调用
+//
+// 通过consul发现服务的健康实例, 轮询选择实例, 连接由gRPC连接池维护
+// 实例列表缓存 DISCOVERY_TTL, 过期后重新从consul拉取
+
+const DISCOVERY_TTL = 10 * time.Second
+
+// 下游服务名称
+const (
+	CENTER_SERVICE = "center"
+)
+
+type discovery struct {
+	mu       sync.Mutex
+	balancer utils.Balancer
+	addrs    []string
+	expireAt time.Time
+}
+
+var discoveries sync.Map // serviceName -> *discovery
+
+// Invoke 选择服务实例并使用其连接执行调用, 调用结束后归还连接
+func Invoke(ctx context.Context, serviceName string, call func(conn *grpc.ClientConn) error) error {
+	addr, err := pickInstance(ctx, serviceName)
+	if err != nil {
+		return err
+	}
+
+	pool, err := utils.GetgRPCPoolInstance(nil)
+	if err != nil {
+		return err
+	}
+	conn, err := pool.GetConnection(addr)
+	if err != nil {
+		return err
+	}
+	defer pool.ReleaseConnection(addr, conn)
+
+	return call(conn)
+}
+
+func pickInstance(ctx context.Context, serviceName string) (string, error) {
+	v, _ := discoveries.LoadOrStore(serviceName, &discovery{})
+	d := v.(*discovery)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.balancer == nil || time.Now().After(d.expireAt) {
+		addrs, err := config.ConsulTemplate().GetServiceInstanceTemplate(ctx, serviceName)
+		if err != nil {
+			return "", err
+		}
+		slices.Sort(addrs)
+
+		// 实例未变化时保留原有轮询位置
+		switch {
+		case d.balancer == nil:
+			b, err := utils.NewRoundRobinBalancer(addrs)
+			if err != nil {
+				return "", err
+			}
+			d.balancer = b
+		case !slices.Equal(d.addrs, addrs):
+			if err := d.balancer.UpdateNodes(addrs); err != nil {
+				return "", err
+			}
+		}
+		d.addrs = addrs
+		d.expireAt = time.Now().Add(DISCOVERY_TTL)
+	}
+
+	return d.balancer.Balance(serviceName)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/grpc"
+)
+
+// 端到端加密密钥分发
+// 设备公钥与一次性公钥由center保存, 网关只做转发
+
+// RegisterDevice 注册或更新设备的身份公钥与预共享公钥
+func RegisterDevice(ctx context.Context, in *pb.RegisterDeviceRequest) (*pb.Device, error) {
+	var resp *pb.RegisterDeviceResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewKeyServiceClient(conn).RegisterDevice(ctx, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Device, nil
+}
+
+// UploadPrekeys 补充一次性公钥, 返回剩余数量
+func UploadPrekeys(ctx context.Context, in *pb.UploadPrekeysRequest) (int64, error) {
+	var count int64
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewKeyServiceClient(conn).UploadPrekeys(ctx, in)
+		if err != nil {
+			return err
+		}
+		count = resp.PrekeyCount
+		return nil
+	})
+	return count, err
+}
+
+// FetchBundles 获取对方设备的公钥, 每个一次性公钥只会返回一次
+func FetchBundles(ctx context.Context, in *pb.FetchBundlesRequest) ([]*pb.PrekeyBundle, error) {
+	var bundles []*pb.PrekeyBundle
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewKeyServiceClient(conn).FetchBundles(ctx, in)
+		if err != nil {
+			return err
+		}
+		bundles = resp.Bundles
+		return nil
+	})
+	return bundles, err
+}
+
+// ListDevices 查询用户的设备列表
+func ListDevices(ctx context.Context, uid string) ([]*pb.Device, error) {
+	var devices []*pb.Device
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewKeyServiceClient(conn).ListDevices(ctx, &pb.ListDevicesRequest{Uid: uid})
+		if err != nil {
+			return err
+		}
+		devices = resp.Devices
+		return nil
+	})
+	return devices, err
+}
+
+// RemoveDevice 删除设备及其剩余的一次性公钥
+func RemoveDevice(ctx context.Context, uid, deviceId string) (bool, error) {
+	var removed bool
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewKeyServiceClient(conn).RemoveDevice(ctx, &pb.RemoveDeviceRequest{Uid: uid, DeviceId: deviceId})
+		if err != nil {
+			return err
+		}
+		removed = resp.Removed
+		return nil
+	})
+	return removed, err
+}
+
+// EnableEncryption 为与 peer 的私聊开启端到端加密
+func EnableEncryption(ctx context.Context, uid, peer string) (*pb.EncryptionState, error) {
+	var state *pb.EncryptionState
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewKeyServiceClient(conn).EnableEncryption(ctx, &pb.EnableEncryptionRequest{Uid: uid, Peer: peer})
+		if err != nil {
+			return err
+		}
+		state = resp.State
+		return nil
+	})
+	return state, err
+}
+
+// GetEncryption 查询与 peer 的私聊是否开启了端到端加密
+func GetEncryption(ctx context.Context, uid, peer string) (*pb.EncryptionState, error) {
+	var state *pb.EncryptionState
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		state, err = pb.NewKeyServiceClient(conn).GetEncryption(ctx, &pb.GetEncryptionRequest{Uid: uid, Peer: peer})
+		return err
+	})
+	return state, err
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"gateway/dto"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// 媒体消息
+//
+// 图片、音频、视频、文件先通过obs分片上传, 消息content只携带对象引用(MediaPayload的json)
+// 接收方凭 object_id 向obs换取短期有效的下载链接
+//
+// 端到端加密消息的content为base64密文, 附件由客户端加密后上传, 对象引用包含在密文中
+
+var (
+	ErrInvalidMedia      = errors.New("media message content must reference an uploaded object")
+	ErrInvalidCiphertext = errors.New("encrypted message content must be base64 ciphertext")
+)
+
+// ValidatePayload 校验媒体消息的content为对象引用, 加密消息的content为base64, 文本消息不校验
+func ValidatePayload(msg *dto.MessageDTO) error {
+	switch msg.MessageType {
+	case "", "text":
+		return nil
+	case "encrypted":
+		if msg.Content == "" {
+			return ErrInvalidCiphertext
+		}
+		if _, err := base64.StdEncoding.DecodeString(msg.Content); err != nil {
+			return ErrInvalidCiphertext
+		}
+		return nil
+	}
+
+	var payload pb.MediaPayload
+	if err := protojson.Unmarshal([]byte(msg.Content), &payload); err != nil {
+		return ErrInvalidMedia
+	}
+	if payload.ObjectId == "" {
+		return ErrInvalidMedia
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"gateway/dto"
+	"testing"
+)
+
+func TestValidatePayload(t *testing.T) {
+	tests := []struct {
+		msg  dto.MessageDTO
+		want error
+	}{
+		{dto.MessageDTO{MessageType: "text", Content: "hello"}, nil},
+		{dto.MessageDTO{MessageType: "image", Content: `{"object_id":"ab12","name":"a.png","size":"10"}`}, nil},
+		{dto.MessageDTO{MessageType: "file", Content: `{"objectId":"ab12"}`}, nil},
+		{dto.MessageDTO{MessageType: "image", Content: "raw bytes"}, ErrInvalidMedia},
+		{dto.MessageDTO{MessageType: "video", Content: `{"name":"a.mp4"}`}, ErrInvalidMedia},
+		{dto.MessageDTO{MessageType: "encrypted", Content: "AAECAw=="}, nil},
+		{dto.MessageDTO{MessageType: "encrypted", Content: "not base64!"}, ErrInvalidCiphertext},
+		{dto.MessageDTO{MessageType: "encrypted"}, ErrInvalidCiphertext},
+	}
+	for _, tt := range tests {
+		if err := ValidatePayload(&tt.msg); err != tt.want {
+			t.Errorf("ValidatePayload(%s, %q) = %v, want %v", tt.msg.MessageType, tt.msg.Content, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/grpc"
+)
+
+// 消息发送
+// 消息经由center校验、分配id与seq并落库后, 由center投递给接收者所在的gateway节点或离线收件箱
+
+// SendMessage 发送消息, 返回center分配的消息id与seq
+func SendMessage(ctx context.Context, msg *pb.MessageData) (*pb.SendMessageResponse, error) {
+	var resp *pb.SendMessageResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewMessageServiceClient(conn).SendMessage(ctx, &pb.SendMessageRequest{Message: msg})
+		return err
+	})
+	return resp, err
+}
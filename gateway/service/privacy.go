@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// 用户数据导出与擦除
+//
+// 数据由storage统一处理并记录审计日志, gateway只提供管理接口
+
+// ExportUser 导出用户数据, storage分块返回的zip依次写入 w
+func ExportUser(ctx context.Context, in *storagepb.ExportUserRequest, w io.Writer) error {
+	return Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		stream, err := storagepb.NewPrivacyServiceClient(conn).ExportUser(ctx, in)
+		if err != nil {
+			return err
+		}
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(chunk.Data); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// EraseUser 擦除用户数据, 返回审计记录
+func EraseUser(ctx context.Context, in *storagepb.EraseUserRequest) (*storagepb.AuditRecord, error) {
+	var resp *storagepb.EraseUserResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewPrivacyServiceClient(conn).EraseUser(ctx, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Audit, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"gateway/utils"
+
+	"github.com/gorilla/websocket"
+	"github.com/segmentio/kafka-go"
+)
+
+// 节点间转发及center推送的事件
+//
+// 通过kafka header区分事件类型与目标用户:
+// kind : 事件类型, 为空时按普通消息处理
+// uid  : 目标用户
+//
+// 此类事件只投递给当前在本节点在线的用户, 离线直接丢弃, 不进入死信队列
+// 事件写入目标节点的topic (见 config.NodeTopic), 用户此后迁移到其他节点时同样丢弃
+
+const (
+	HEADER_KIND = "kind"
+	HEADER_UID  = "uid"
+)
+
+const (
+	KIND_EPHEMERAL = "ephemeral" // 瞬时信号
+	KIND_RECEIPT   = "receipt"   // 已读回执
+	KIND_DELETE    = "delete"    // 消息被删除(阅后即焚到期)
+	KIND_NOTICE    = "notice"    // 会话中的系统通知
+)
+
+// IsOnlineOnly 是否为仅投递在线用户的事件
+func IsOnlineOnly(msg kafka.Message) bool {
+	switch HeaderValue(msg, HEADER_KIND) {
+	case KIND_EPHEMERAL, KIND_RECEIPT, KIND_DELETE, KIND_NOTICE:
+		return true
+	}
+	return false
+}
+
+// ReceiveOnline 投递事件给本节点在线的目标用户
+func ReceiveOnline(msg kafka.Message, nodeId string) {
+	uid := HeaderValue(msg, HEADER_UID)
+	if uid == "" {
+		return
+	}
+	meta, err := utils.StatusTemplate().GetStatus(uid)
+	if err != nil || !meta.Online() || meta.ServerId != nodeId {
+		return
+	}
+	deliverOnline(meta, msg.Value)
+}
+
+func deliverOnline(meta utils.Meta, body []byte) {
+	conn, ok := utils.PoolsOpsTemplate().GetConnTemplate(meta.UserRemoteAddr)
+	if !ok {
+		return
+	}
+	_ = conn.WriteMessage(websocket.TextMessage, body)
+}
+
+// HeaderValue 读取kafka消息头, 不存在时返回空字符串
+func HeaderValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"gateway/dto"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/grpc"
+)
+
+// 已读回执
+// 已读游标由center存储, gateway只负责转发上报与查询
+
+// SessionType 客户端会话类型转换为协议枚举, 默认私聊
+func SessionType(typ string) pb.SesstionType {
+	if typ == "group" {
+		return pb.SesstionType_GROUP
+	}
+	return pb.SesstionType_SINGLE
+}
+
+// ReportRead 上报已读游标, 返回center记录的当前游标
+func ReportRead(ctx context.Context, uid string, read *dto.ReadDTO) (int64, error) {
+	var readSeq int64
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := pb.NewReceiptServiceClient(conn).ReportRead(ctx, &pb.ReportReadRequest{
+			Uid:            uid,
+			ConversationId: read.ConversationId,
+			SessionType:    SessionType(read.SessionType),
+			Seq:            read.Seq,
+		})
+		if err != nil {
+			return err
+		}
+		readSeq = resp.ReadSeq
+		return nil
+	})
+	return readSeq, err
+}
+
+// QueryReadReceipt 查询消息的已读情况
+func QueryReadReceipt(ctx context.Context, in *pb.QueryReadReceiptRequest) (*pb.QueryReadReceiptResponse, error) {
+	var resp *pb.QueryReadReceiptResponse
+	err := Invoke(ctx, CENTER_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = pb.NewReceiptServiceClient(conn).QueryReadReceipt(ctx, in)
+		return err
+	})
+	return resp, err
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -59,39 +60,67 @@ func (r *receviceMessage) StartReadMessage(ctx context.Context, nodeId string) e
 				time.Sleep(100 * time.Millisecond)
 				continue
 			}
-			// 处理消息
-			var message *dto.MessageDTO
-			if err = json.Unmarshal(msg.Value, &message); err != nil {
-				// TODO : 死信队列或者丢回消息队列
-				err = r.dlq.WriteMessages(ctx, msg)
-				if err != nil {
-					// TODO : 消息可能丢失注意
-				}
+			// 瞬时信号、回执只投递给本节点在线用户, 不进入死信队列
+			if IsOnlineOnly(msg) {
+				ReceiveOnline(msg, nodeId)
+				r.reader.CommitMessages(ctx, msg)
+				continue
 			}
 
-			// 发送至正在连接的conn
-			meta, err := utils.StatusTemplate().GetStatus(message.ReceiverId)
-			if err != nil {
-				// 扔进死信队列
-				err = r.dlq.WriteMessages(ctx, msg)
-				if err != nil {
-					// TODO : 消息可能丢失注意
-				}
-			}
-			conn, ok := utils.PoolsOpsTemplate().GetConnTemplate(meta.UserRemoteAddr)
-			if !ok {
-				err = r.dlq.WriteMessages(ctx, msg)
-				if err != nil {
+			// 处理消息
+			if err := r.receive(ctx, msg, nodeId); err != nil {
+				if err = r.dlq.WriteMessages(ctx, msg); err != nil {
 					// TODO : 消息可能丢失注意
+					log.Default().Printf("[ERROR] 写入死信队列失败: %v", err)
 				}
 			}
-			conn.WriteMessage(websocket.TextMessage, msg.Value)
 			r.reader.CommitMessages(ctx, msg) // 手动ACK
 		}
 	}
 
 }
 
+// receive 投递一条消息给本节点在线的接收者, 补拉由连接的投递协程执行, 不阻塞消费循环
+// 接收者已离线、已迁移到其他节点、连接已断开或投递队列已满时写入离线收件箱, 返回错误时消息进入死信队列
+func (r *receviceMessage) receive(ctx context.Context, msg kafka.Message, nodeId string) error {
+	var message *dto.MessageDTO
+	if err := json.Unmarshal(msg.Value, &message); err != nil {
+		return err
+	}
+
+	// center下发的消息以header中的uid为接收者, 群聊时 receiver_id 为群id
+	uid := HeaderValue(msg, HEADER_UID)
+	if uid == "" {
+		uid = message.ReceiverId
+	}
+	meta, err := utils.StatusTemplate().GetStatus(uid)
+	if errors.Is(err, redis.Nil) {
+		// 接收方已离线, 写入离线收件箱
+		return PushOffline(ctx, uid, ToMessageData(message))
+	}
+	if err != nil {
+		return err
+	}
+	if !meta.Online() || meta.ServerId != nodeId {
+		return PushOffline(ctx, uid, ToMessageData(message))
+	}
+
+	conn, ok := utils.PoolsOpsTemplate().GetConnTemplate(meta.UserRemoteAddr)
+	if !ok {
+		// 连接已断开但在线状态尚未清除
+		return PushOffline(ctx, uid, ToMessageData(message))
+	}
+	// 按seq顺序投递, 跳号时先从storage补齐
+	err = GapRepairerTemplate().Dispatch(uid, meta.UserRemoteAddr, message, msg.Value,
+		func(body []byte) error {
+			return conn.WriteMessage(websocket.TextMessage, body)
+		})
+	if errors.Is(err, ErrDeliverQueueFull) || errors.Is(err, ErrConnReleased) {
+		return PushOffline(ctx, uid, ToMessageData(message))
+	}
+	return err
+}
+
 func (r *receviceMessage) Wait() {
 	r.wg.Wait()
 }
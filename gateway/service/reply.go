@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"gateway/config"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -23,15 +24,16 @@ const MAX_SEND_RETRY = 3
 
 // SendMessage
 //
-// 转发消息到对应的gateway节点处理消息
+// 转发消息到对应的gateway节点处理消息, 写入该节点的topic
 func (p *producer) SendMessage(
 	ctx context.Context,
 	msg any,
 	nodeId string,
 ) error {
+	topic := config.NodeTopic(config.GatewayCfg.Application.Component.Kafka.Topic, nodeId)
 
 	err := p.writer.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(nodeId),
+		Topic: topic,
 		Value: msg.([]byte),
 	})
 	if err != nil {
@@ -44,7 +46,7 @@ func (p *producer) SendMessage(
 				return ctx.Err()
 			}
 			err = p.writer.WriteMessages(ctx, kafka.Message{
-				Key:   []byte(nodeId),
+				Topic: topic,
 				Value: msg.([]byte),
 			})
 			if err == nil {
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"gateway/dto"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// 离线消息
+//
+// 接收方离线时消息写入storage中接收方的离线收件箱
+// 客户端重连后通过同步接口按游标拉取, 确认后由storage清理
+
+const STORAGE_SERVICE = "storage"
+
+var messageTypes = map[string]pb.MessageType{
+	"text":  pb.MessageType_TEXT,
+	"image": pb.MessageType_IMAGE,
+	"audio": pb.MessageType_AUDIO,
+	"video": pb.MessageType_VIDEO,
+	"file":  pb.MessageType_FILE,
+	// 端到端加密消息, 服务端只转发和保存密文
+	"encrypted": pb.MessageType_ENCRYPTED,
+}
+
+// ToMessageData 客户端消息转换为协议消息
+// 发送时id与seq为空由center分配, 转发center下发的消息时保留分配的id与seq
+func ToMessageData(msg *dto.MessageDTO) *pb.MessageData {
+	sendTime := msg.Time.Milliseconds()
+	if msg.Id == "" || sendTime <= 0 {
+		sendTime = time.Now().UnixMilli()
+	}
+	return &pb.MessageData{
+		Id:           msg.Id,
+		SenderId:     msg.SenderID,
+		ReceiverId:   msg.ReceiverId,
+		MessageType:  messageTypes[msg.MessageType],
+		SesstionType: SessionType(msg.SessionType),
+		Payload:      []byte(msg.Content),
+		Seq:          msg.Seq,
+		SendTime:     sendTime,
+	}
+}
+
+// MESSAGE_STATUS_RECALLED 与storage中消息的撤回状态一致
+const MESSAGE_STATUS_RECALLED int32 = 1
+
+// FromMessageData 协议消息转换为客户端消息, 用于下发从storage拉取的消息
+func FromMessageData(msg *pb.MessageData) *dto.MessageDTO {
+	out := &dto.MessageDTO{
+		Id:          msg.Id,
+		SenderID:    msg.SenderId,
+		ReceiverId:  msg.ReceiverId,
+		Content:     string(msg.Payload),
+		Time:        time.Duration(msg.SendTime) * time.Millisecond,
+		Status:      "send",
+		Seq:         msg.Seq,
+		SessionType: "single",
+	}
+	for name, typ := range messageTypes {
+		if typ == msg.MessageType {
+			out.MessageType = name
+			break
+		}
+	}
+	if msg.Status == MESSAGE_STATUS_RECALLED {
+		out.Status = "withdraw"
+	}
+	if msg.SesstionType == pb.SesstionType_GROUP {
+		out.SessionType = "group"
+	}
+	return out
+}
+
+// PushOffline 写入离线收件箱
+func PushOffline(ctx context.Context, uid string, msg *pb.MessageData) error {
+	return Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		_, err := storagepb.NewSyncServiceClient(conn).PushOffline(ctx, &storagepb.PushOfflineRequest{
+			Uid:     uid,
+			Message: msg,
+		})
+		return err
+	})
+}
+
+// SyncInbox 按游标拉取离线收件箱
+func SyncInbox(ctx context.Context, in *storagepb.SyncInboxRequest) (*storagepb.SyncInboxResponse, error) {
+	var resp *storagepb.SyncInboxResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewSyncServiceClient(conn).SyncInbox(ctx, in)
+		return err
+	})
+	return resp, err
+}
+
+// SyncMessages 按会话拉取 fromSeq 之后的消息
+func SyncMessages(ctx context.Context, in *storagepb.SyncMessagesRequest) (*storagepb.SyncMessagesResponse, error) {
+	var resp *storagepb.SyncMessagesResponse
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		var err error
+		resp, err = storagepb.NewSyncServiceClient(conn).SyncMessages(ctx, in)
+		return err
+	})
+	return resp, err
+}
+
+// AckInbox 确认已收到游标及之前的离线消息
+func AckInbox(ctx context.Context, uid string, cursor int64) (int64, error) {
+	var trimmed int64
+	err := Invoke(ctx, STORAGE_SERVICE, func(conn *grpc.ClientConn) error {
+		resp, err := storagepb.NewSyncServiceClient(conn).AckInbox(ctx, &storagepb.AckInboxRequest{
+			Uid:    uid,
+			Cursor: cursor,
+		})
+		if err != nil {
+			return err
+		}
+		trimmed = resp.Trimmed
+		return nil
+	})
+	return trimmed, err
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// 限流器
+// 基于令牌桶实现, 每个key(通常为uid)独立一个桶
+// 桶在首次访问时创建, 长时间未访问的桶由 Clean 回收
+
+type RateLimiter struct {
+	rate    float64  // 每秒生成的令牌数
+	burst   float64  // 桶容量, 即允许的瞬时突发数
+	buckets sync.Map // key -> *tokenBucket
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64   // 当前剩余令牌
+	last   time.Time // 上次补充令牌的时间
+}
+
+// NewRateLimiter 创建限流器
+// rate: 每秒生成的令牌数
+// burst: 桶容量
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:  rate,
+		burst: float64(burst),
+	}
+}
+
+// Allow 判断key当前是否允许通过, 允许则消耗一个令牌
+func (l *RateLimiter) Allow(key string) bool {
+	return l.allowAt(key, time.Now())
+}
+
+func (l *RateLimiter) allowAt(key string, now time.Time) bool {
+	v, _ := l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.burst, last: now})
+	b := v.(*tokenBucket)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 按流逝时间补充令牌, 不超过桶容量
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Clean 回收超过idle时间未被访问的桶
+// 被回收的key下次访问时会以满桶重新创建, 因此idle应不小于桶回满所需时间
+func (l *RateLimiter) Clean(idle time.Duration) {
+	l.cleanAt(idle, time.Now())
+}
+
+func (l *RateLimiter) cleanAt(idle time.Duration, now time.Time) {
+	l.buckets.Range(func(k, v any) bool {
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		expired := now.Sub(b.last) > idle
+		b.mu.Unlock()
+		if expired {
+			l.buckets.Delete(k)
+		}
+		return true
+	})
+}
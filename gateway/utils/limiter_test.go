@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试令牌桶的突发容量与按时间补充
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(2, 3)
+	now := time.Now()
+
+	// 满桶时允许burst次
+	for i := 0; i < 3; i++ {
+		if !limiter.allowAt("u1", now) {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if limiter.allowAt("u1", now) {
+		t.Fatal("request beyond burst should be rejected")
+	}
+
+	// 不同用户互不影响
+	if !limiter.allowAt("u2", now) {
+		t.Fatal("another key should have its own bucket")
+	}
+
+	// 500ms 补充一个令牌
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.allowAt("u1", now) {
+		t.Fatal("token should be refilled after 500ms")
+	}
+	if limiter.allowAt("u1", now) {
+		t.Fatal("only one token should be refilled")
+	}
+
+	// 补充不超过桶容量
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !limiter.allowAt("u1", now) {
+			t.Fatalf("request %d should be allowed after refill", i)
+		}
+	}
+	if limiter.allowAt("u1", now) {
+		t.Fatal("refill should be capped at burst")
+	}
+}
+
+// 测试空闲桶回收
+func TestRateLimiterClean(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	now := time.Now()
+
+	limiter.allowAt("idle", now)
+	limiter.allowAt("active", now.Add(time.Minute))
+
+	limiter.cleanAt(30*time.Second, now.Add(time.Minute))
+
+	if _, ok := limiter.buckets.Load("idle"); ok {
+		t.Error("idle bucket should be removed")
+	}
+	if _, ok := limiter.buckets.Load("active"); !ok {
+		t.Error("active bucket should be kept")
+	}
+}
@@ -17,10 +17,20 @@ type Pools struct {
 	cnt    uint32 // 当前连接数
 }
 
+// WsConn 长连接, 写入加锁
+// websocket连接不支持并发写, 消费协程、投递协程与读协程的回复都经由 WsConn 写入
 type WsConn struct {
 	*websocket.Conn
 	clientID string
 	pool     *Pools
+	writeMu  sync.Mutex
+}
+
+// WriteMessage 加锁写入一帧
+func (c *WsConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
 }
 
 func NewWsPool(maxConn uint32) *Pools {
@@ -43,9 +53,10 @@ func InitWsPool() {
 	})
 }
 
-func (p *Pools) AddConnTemplate(clientId string, conn *websocket.Conn) error {
+// AddConnTemplate 登记长连接, 之后对该连接的写入都应通过返回的 WsConn
+func (p *Pools) AddConnTemplate(clientId string, conn *websocket.Conn) (*WsConn, error) {
 	if atomic.LoadUint32(&p.cnt) >= p.max {
-		return errors.New("connection pool full")
+		return nil, errors.New("connection pool full")
 	}
 	c := &WsConn{
 		Conn:     conn,
@@ -54,7 +65,7 @@ func (p *Pools) AddConnTemplate(clientId string, conn *websocket.Conn) error {
 	}
 	p.conns.Store(clientId, c)
 	atomic.AddUint32(&p.cnt, 1)
-	return nil
+	return c, nil
 }
 
 func (p *Pools) ReleaseConnTemplate(clientId string) {
@@ -63,13 +74,12 @@ func (p *Pools) ReleaseConnTemplate(clientId string) {
 	}
 }
 
-func (p *Pools) GetConnTemplate(clientId string) (*websocket.Conn, bool) {
+func (p *Pools) GetConnTemplate(clientId string) (*WsConn, bool) {
 	v, ok := p.conns.Load(clientId)
 	if !ok {
 		return nil, false
 	}
-	wsConn := v.(*WsConn).Conn
-	return wsConn, true
+	return v.(*WsConn), true
 }
 
 func (p *Pools) CloseWsPools() {
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/redis/go-redis/v9"
@@ -15,9 +16,26 @@ import (
 // key : status:{uid}
 //
 // value : Meta
+//
+// 本节点的连接同时缓存在 statusMap 中, 由本节点登记与清除
+// 用户重连到其他连接或节点后状态已被覆盖, 旧连接断开时只清除仍属于自己的状态
+// 其他节点的在线状态每次从redis读取, 不缓存, 避免用户迁移或下线后读到过期的状态
 
 const PERFIX = "status:"
 
+// clearStatus 状态的节点与远程地址都与断开的连接一致时才删除
+var clearStatus = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return 0
+end
+local meta = cjson.decode(data)
+if meta.ServerId ~= ARGV[1] or meta.UserRemoteAddr ~= ARGV[2] then
+	return 0
+end
+return redis.call('DEL', KEYS[1])
+`)
+
 var (
 	statusMap sync.Map
 
@@ -26,7 +44,8 @@ var (
 )
 
 type status struct {
-	redis *redis.ClusterClient
+	redis  redis.UniversalClient
+	nodeId string
 }
 
 type Meta struct {
@@ -36,15 +55,33 @@ type Meta struct {
 	Status         string // 用户状态 : online or deadline
 }
 
-func NewStatus(redis *redis.ClusterClient) *status {
+// MarshalBinary 实现 encoding.BinaryMarshaler
+// 使 Meta 可直接写入 redis, 跨节点以 json 形式共享在线状态
+func (m Meta) MarshalBinary() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (m *Meta) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, m)
+}
+
+// Online 用户是否在线
+func (m Meta) Online() bool {
+	return m.Status == "online"
+}
+
+// nodeId 本节点id, 只缓存本节点连接的状态
+func NewStatus(redis redis.UniversalClient, nodeId string) *status {
 	return &status{
-		redis: redis,
+		redis:  redis,
+		nodeId: nodeId,
 	}
 }
 
-func InitStatus(redis *redis.ClusterClient) {
+func InitStatus(redis *redis.ClusterClient, nodeId string) {
 	statusOnce.Do(func() {
-		statusIns = NewStatus(redis)
+		statusIns = NewStatus(redis, nodeId)
 	})
 }
 
@@ -59,20 +96,23 @@ func (s *status) InitStatus(
 		return err
 	}
 
-	statusMap.Store(uid, meta)
+	if meta.ServerId == s.nodeId {
+		statusMap.Store(uid, meta)
+	}
 
 	return nil
 }
 
-func (s *status) ClearStatus(uid string) error {
+// ClearStatus 清除连接断开的用户状态, meta 为该连接登记时的状态
+func (s *status) ClearStatus(uid string, meta Meta) error {
 
 	key := PERFIX + uid
-	_, err := s.redis.Del(context.Background(), key).Result()
+	_, err := clearStatus.Run(context.Background(), s.redis, []string{key}, meta.ServerId, meta.UserRemoteAddr).Result()
 	if err != nil {
 		return err
 	}
 
-	statusMap.Delete(uid)
+	statusMap.CompareAndDelete(uid, meta)
 
 	return nil
 }
@@ -83,12 +123,11 @@ func (s *status) GetStatus(uid string) (Meta, error) {
 	if ok {
 		return meta.(Meta), nil
 	}
-	meta, err := s.redis.Get(context.Background(), key).Result()
-	if err != nil {
+	var data Meta
+	if err := s.redis.Get(context.Background(), key).Scan(&data); err != nil {
 		return Meta{}, err
 	}
-	statusMap.Store(uid, meta)
-	return meta.(Meta), nil
+	return data, nil
 }
 
 func StatusTemplate() *status {
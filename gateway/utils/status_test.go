@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// 测试旧连接断开时不清除用户在新连接上登记的状态
+func TestClearStatusOwnConnection(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	s := NewStatus(rdb, "gw1")
+
+	old := Meta{ServerId: "gw1", UserRemoteAddr: "conn-1", Status: "online"}
+	if err := s.InitStatus("alice", old); err != nil {
+		t.Fatal(err)
+	}
+	// 重连到 gw2
+	current := Meta{ServerId: "gw2", UserRemoteAddr: "conn-2", Status: "online"}
+	if err := s.InitStatus("alice", current); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ClearStatus("alice", old); err != nil {
+		t.Fatal(err)
+	}
+	var stored Meta
+	if err := rdb.Get(context.Background(), PERFIX+"alice").Scan(&stored); err != nil || stored != current {
+		t.Fatalf("status of new connection cleared: %v, %v", stored, err)
+	}
+
+	if err := s.ClearStatus("alice", current); err != nil {
+		t.Fatal(err)
+	}
+	if rdb.Exists(context.Background(), PERFIX+"alice").Val() != 0 {
+		t.Fatal("status of own connection not cleared")
+	}
+}
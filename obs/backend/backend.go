@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Backend 对象存储后端
+//
+// 对象以内容的sha256作为key, 相同内容只存储一份
+// 本地文件系统实现用于单机部署, 生产环境使用兼容S3协议的对象存储
+
+var ErrNotFound = errors.New("object not found")
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+type Backend interface {
+	// Put 写入对象, size 为 -1 时表示未知长度
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get 读取对象, 调用方负责关闭; 返回值实现 io.ReadSeeker 时支持断点续传下载
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Stat 查询对象信息, 对象不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner 支持生成预签名下载地址的后端, 下载时直接重定向至后端, 不经过obs转发
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
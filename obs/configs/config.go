@@ -0,0 +1,99 @@
+package configs
+
+import (
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Application struct {
+		Name    string `mapstructure:"name"`
+		Host    string `mapstructure:"host"`
+		Port    int    `mapstructure:"port"`
+		NodeId  string `mapstructure:"nodeId"`
+		BaseUrl string `mapstructure:"baseUrl"` // 对外访问地址, 用于生成下载链接
+
+		Upload struct {
+			Dir        string `mapstructure:"dir"`        // 上传会话及分片的临时目录
+			ChunkSize  int64  `mapstructure:"chunkSize"`  // 分片大小
+			MaxSize    int64  `mapstructure:"maxSize"`    // 单个文件最大字节数
+			SessionTTL string `mapstructure:"sessionTTL"` // 未完成会话的保留时间
+			Thumbnails []int  `mapstructure:"thumbnails"` // 图片缩略图的最长边像素, 为空时不生成
+		} `mapstructure:"upload"`
+
+		Sign struct {
+			Secret string `mapstructure:"secret"`
+			TTL    string `mapstructure:"ttl"` // 下载链接有效期
+		} `mapstructure:"sign"`
+
+		Backend struct {
+			Driver string `mapstructure:"driver"` // local | s3
+
+			Local struct {
+				Root string `mapstructure:"root"`
+			} `mapstructure:"local"`
+
+			S3 struct {
+				Endpoint  string `mapstructure:"endpoint"`
+				AccessKey string `mapstructure:"accessKey"`
+				SecretKey string `mapstructure:"secretKey"`
+				Bucket    string `mapstructure:"bucket"`
+				Region    string `mapstructure:"region"`
+				UseSSL    bool   `mapstructure:"useSSL"`
+			} `mapstructure:"s3"`
+		} `mapstructure:"backend"`
+
+		Component struct {
+			Consul struct {
+				Endpoint string `mapstructure:"endpoint"`
+				Port     int    `mapstructure:"port"`
+				Scheme   string `mapstructure:"scheme"`
+				Service  struct {
+					Register bool     `mapstructure:"register"`
+					Tags     []string `mapstructure:"tags"`
+				} `mapstructure:"service"`
+			} `mapstructure:"consul"`
+		} `mapstructure:"component"`
+	} `mapstructure:"application"`
+}
+
+var Cfg atomic.Pointer[Config]
+
+func Get() *Config {
+	return Cfg.Load()
+}
+
+// Load 从工作目录读取 obs.yaml
+func Load() (*Config, error) {
+	v := viper.New()
+
+	v.SetConfigName("obs")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+
+	v.SetDefault("application.port", 8090)
+	v.SetDefault("application.upload.dir", "data/uploads")
+	v.SetDefault("application.upload.chunkSize", 4<<20)
+	v.SetDefault("application.upload.maxSize", 2<<30)
+	v.SetDefault("application.upload.sessionTTL", "24h")
+	v.SetDefault("application.upload.thumbnails", []int{128, 256, 512})
+	v.SetDefault("application.sign.ttl", "10m")
+	v.SetDefault("application.backend.driver", "local")
+	v.SetDefault("application.backend.local.root", "data/objects")
+
+	v.SetEnvPrefix("OBS")
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	Cfg.Store(&c)
+
+	return &c, nil
+}
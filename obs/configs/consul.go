@@ -0,0 +1,54 @@
+package configs
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+)
+
+type ConsulConf struct {
+	Address string
+	Scheme  string
+}
+
+type ConsulClient struct {
+	Client *api.Client
+}
+
+func NewConsulClient(conf *ConsulConf) (*ConsulClient, error) {
+	cfg := api.DefaultConfig()
+	cfg.Address = conf.Address
+	cfg.Scheme = conf.Scheme
+
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsulClient{Client: client}, nil
+}
+
+// RegisterService 注册http服务, 采用 /health 健康检查
+func (c *ConsulClient) RegisterService(serviceID, serviceName, address string,
+	port int, tags []string,
+) error {
+
+	reg := &api.AgentServiceRegistration{
+		ID:      serviceID,
+		Name:    serviceName,
+		Address: address,
+		Port:    port,
+		Tags:    tags,
+		Check: &api.AgentServiceCheck{
+			HTTP:                           fmt.Sprintf("http://%s:%d/health", address, port),
+			Interval:                       "10s",
+			Timeout:                        "2s",
+			DeregisterCriticalServiceAfter: "30s",
+		},
+	}
+
+	return c.Client.Agent().ServiceRegister(reg)
+}
+
+func (c *ConsulClient) DeregisterService(serviceID string) error {
+	return c.Client.Agent().ServiceDeregister(serviceID)
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"obs/backend"
+	"obs/sign"
+	"obs/upload"
+)
+
+// Handler 文件服务的http接口
+//
+// 上传 :
+//   POST   /upload/init                   声明文件, 已存在相同内容时直接返回对象
+//   GET    /upload/:id                    查询已上传的分片, 用于断点续传
+//   PUT    /upload/:id/chunks/:index?uid= 上传分片, body为分片内容
+//   POST   /upload/:id/complete?uid=      合并分片
+//   DELETE /upload/:id?uid=               放弃上传
+// 下载 :
+//   GET    /files/:id/url?uid=            获取短期有效的签名下载链接
+//   GET    /files/:id?expires=&sig=       下载, 支持Range
+// 内部 :
+//   DELETE /files/:id?before=&expires=&sig= 删除过期消息引用的对象, 签名对象为 "delete:" + id
+
+type Handler struct {
+	uploads *upload.Manager
+	backend backend.Backend
+	signer  *sign.Signer
+	baseUrl string
+}
+
+func NewHandler(uploads *upload.Manager, b backend.Backend, signer *sign.Signer, baseUrl string) *Handler {
+	return &Handler{
+		uploads: uploads,
+		backend: b,
+		signer:  signer,
+		baseUrl: baseUrl,
+	}
+}
+
+func (h *Handler) Register(engine *gin.Engine) {
+	engine.POST("/upload/init", h.initUpload)
+	engine.GET("/upload/:id", h.uploadStatus)
+	engine.PUT("/upload/:id/chunks/:index", h.uploadChunk)
+	engine.POST("/upload/:id/complete", h.completeUpload)
+	engine.DELETE("/upload/:id", h.abortUpload)
+	engine.GET("/files/:id/url", h.downloadUrl)
+	engine.GET("/files/:id", h.download)
+	engine.DELETE("/files/:id", h.remove)
+}
+
+type initRequest struct {
+	Uid         string `json:"uid" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+	Sha256      string `json:"sha256" binding:"required"`
+	ContentType string `json:"content_type"`
+}
+
+func (h *Handler) initUpload(c *gin.Context) {
+	var req initRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s, obj, err := h.uploads.Init(c.Request.Context(), &upload.InitRequest{
+		Uid:         req.Uid,
+		Name:        req.Name,
+		Size:        req.Size,
+		Sha256:      req.Sha256,
+		ContentType: req.ContentType,
+	})
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if obj != nil {
+		c.JSON(http.StatusOK, gin.H{"exists": true, "object": obj})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exists": false, "session": s})
+}
+
+func (h *Handler) uploadStatus(c *gin.Context) {
+	s, uploaded, err := h.uploads.Status(c.Param("id"))
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": s, "uploaded": uploaded})
+}
+
+func (h *Handler) uploadChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	if err := h.uploads.WriteChunk(c.Param("id"), c.Query("uid"), index, c.Request.Body); err != nil {
+		abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"index": index})
+}
+
+func (h *Handler) completeUpload(c *gin.Context) {
+	obj, err := h.uploads.Complete(c.Request.Context(), c.Param("id"), c.Query("uid"))
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": obj})
+}
+
+func (h *Handler) abortUpload(c *gin.Context) {
+	if err := h.uploads.Abort(c.Param("id"), c.Query("uid")); err != nil {
+		abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) downloadUrl(c *gin.Context) {
+	id := c.Param("id")
+	if c.Query("uid") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uid is required"})
+		return
+	}
+	if _, err := h.backend.Stat(c.Request.Context(), id); err != nil {
+		abort(c, err)
+		return
+	}
+
+	expires, sig := h.signer.Sign(id, time.Now())
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", sig)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        h.baseUrl + "/files/" + url.PathEscape(id) + "?" + q.Encode(),
+		"expires_at": expires,
+	})
+}
+
+func (h *Handler) download(c *gin.Context) {
+	id := c.Param("id")
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err := h.signer.Verify(id, expires, c.Query("sig"), time.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 后端支持预签名时直接重定向, 有效期不超过本链接的剩余时间
+	if p, ok := h.backend.(backend.Presigner); ok {
+		ttl := time.Until(time.Unix(expires, 0))
+		u, err := p.PresignGet(c.Request.Context(), id, ttl)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, u)
+		return
+	}
+
+	r, info, err := h.backend.Get(c.Request.Context(), id)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	defer r.Close()
+
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	c.Header("Cache-Control", "private, max-age=0")
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", info.ModTime, rs)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, r, nil)
+}
+
+func (h *Handler) remove(c *gin.Context) {
+	id := c.Param("id")
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err := h.signer.Verify("delete:"+id, expires, c.Query("sig"), time.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	before, err := strconv.ParseInt(c.Query("before"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before"})
+		return
+	}
+
+	if err := h.uploads.Remove(c.Request.Context(), id, time.Unix(before, 0)); err != nil {
+		abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func abort(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, upload.ErrSessionNotFound), errors.Is(err, backend.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, upload.ErrInUse):
+		status = http.StatusConflict
+	case errors.Is(err, upload.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, upload.ErrInvalidArgument),
+		errors.Is(err, upload.ErrChunkSize),
+		errors.Is(err, upload.ErrIncomplete),
+		errors.Is(err, upload.ErrChecksum):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HttpConfig struct {
+	Host         string
+	Port         int
+	StopTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// StartHttpServer 启动http服务, 阻塞直到ctx结束后优雅关闭
+func StartHttpServer(ctx context.Context, cfg *HttpConfig, h *Handler) error {
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 5 * time.Second
+	}
+
+	engine := gin.Default()
+	engine.GET("/health", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	h.Register(engine)
+
+	srv := &http.Server{
+		Addr:        fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:     engine,
+		ReadTimeout: cfg.ReadTimeout,
+		// 大文件下载耗时较长, 写超时默认不限制
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.StopTimeout)
+	defer cancel()
+	return srv.Shutdown(stopCtx)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"obs/backend"
+	"obs/configs"
+	"obs/core"
+	"obs/media"
+	"obs/sign"
+	"obs/upload"
+)
+
+func main() {
+	cfg, err := configs.Load()
+	if err != nil {
+		panic(err)
+	}
+	app := cfg.Application
+
+	// 初始化对象存储后端
+	var store backend.Backend
+	switch app.Backend.Driver {
+	case "s3":
+		store, err = backend.NewS3(&backend.S3Config{
+			Endpoint:  app.Backend.S3.Endpoint,
+			AccessKey: app.Backend.S3.AccessKey,
+			SecretKey: app.Backend.S3.SecretKey,
+			Bucket:    app.Backend.S3.Bucket,
+			Region:    app.Backend.S3.Region,
+			UseSSL:    app.Backend.S3.UseSSL,
+		})
+	default:
+		store, err = backend.NewLocal(app.Backend.Local.Root)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// 初始化分片上传
+	sessionTTL, err := time.ParseDuration(app.Upload.SessionTTL)
+	if err != nil {
+		panic(err)
+	}
+	processor := media.NewProcessor(store, app.Upload.Thumbnails)
+	uploads, err := upload.NewManager(app.Upload.Dir, store, processor, app.Upload.ChunkSize, app.Upload.MaxSize, sessionTTL)
+	if err != nil {
+		panic(err)
+	}
+
+	// 初始化下载签名
+	signTTL, err := time.ParseDuration(app.Sign.TTL)
+	if err != nil {
+		panic(err)
+	}
+	signer := sign.NewSigner(app.Sign.Secret, signTTL)
+
+	// 注册consul服务
+	consul, err := configs.NewConsulClient(&configs.ConsulConf{
+		Address: fmt.Sprintf("%s:%d", app.Component.Consul.Endpoint, app.Component.Consul.Port),
+		Scheme:  app.Component.Consul.Scheme,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if app.Component.Consul.Service.Register {
+		err = consul.RegisterService(app.NodeId, app.Name, app.Host, app.Port, app.Component.Consul.Service.Tags)
+		if err != nil {
+			panic(err)
+		}
+		defer consul.DeregisterService(app.NodeId)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 定期清理过期的上传会话
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				uploads.Clean(now)
+			}
+		}
+	}()
+
+	log.Println("[INFO] Starting obs server...")
+	err = core.StartHttpServer(ctx, &core.HttpConfig{
+		Host:        app.Host,
+		Port:        app.Port,
+		ReadTimeout: time.Minute,
+	}, core.NewHandler(uploads, store, signer, app.BaseUrl))
+	if err != nil {
+		log.Printf("[ERROR] obs server exited: %v", err)
+	}
+	log.Println("[INFO] obs server stopped")
+}
@@ -0,0 +1,239 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// 音视频元数据
+//
+// 按文件头识别格式, 只读取必要的头部信息, 不解码音视频数据
+// mp4/mov/m4a : moov/mvhd 中的时长, trak/tkhd 中的画面尺寸
+// wav         : fmt 的码率与 data 的长度
+// mp3         : Xing/Info 头中的帧数, 没有时按首帧码率估算(CBR)
+// 无法识别的格式不返回元数据
+
+type avInfo struct {
+	durationMs int64
+	width      int32
+	height     int32
+}
+
+func probeAV(r io.ReaderAt, size int64) (avInfo, bool) {
+	head := make([]byte, 12)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return avInfo{}, false
+	}
+	switch {
+	case string(head[4:8]) == "ftyp":
+		return probeMP4(r, size)
+	case string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return probeWAV(r, size)
+	case string(head[0:3]) == "ID3" || (head[0] == 0xFF && head[1]&0xE0 == 0xE0):
+		return probeMP3(r, size)
+	}
+	return avInfo{}, false
+}
+
+// box 遍历 [start, end) 内的box, fn 返回false时停止
+func boxes(r io.ReaderAt, start, end int64, fn func(typ string, body, bodyEnd int64) bool) {
+	header := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:8])
+		body := pos + 8
+
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+				return
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			body += 8
+		}
+		if size < body-pos || pos+size > end {
+			return
+		}
+		if !fn(typ, body, pos+size) {
+			return
+		}
+		pos += size
+	}
+}
+
+func probeMP4(r io.ReaderAt, size int64) (avInfo, bool) {
+	var (
+		info  avInfo
+		found bool
+	)
+	boxes(r, 0, size, func(typ string, body, end int64) bool {
+		if typ != "moov" {
+			return true
+		}
+		boxes(r, body, end, func(typ string, body, end int64) bool {
+			switch typ {
+			case "mvhd":
+				if d, ok := readMvhd(r, body); ok {
+					info.durationMs, found = d, true
+				}
+			case "trak":
+				if info.width == 0 {
+					boxes(r, body, end, func(typ string, body, end int64) bool {
+						if typ == "tkhd" {
+							info.width, info.height = readTkhd(r, body)
+							return false
+						}
+						return true
+					})
+				}
+			}
+			return true
+		})
+		return false
+	})
+	return info, found
+}
+
+func readMvhd(r io.ReaderAt, body int64) (int64, bool) {
+	buf := make([]byte, 32)
+	if _, err := r.ReadAt(buf, body); err != nil {
+		return 0, false
+	}
+	var timescale, duration uint64
+	if buf[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(buf[20:]))
+		duration = binary.BigEndian.Uint64(buf[24:])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(buf[12:]))
+		duration = uint64(binary.BigEndian.Uint32(buf[16:]))
+	}
+	if timescale == 0 {
+		return 0, false
+	}
+	return int64(duration * 1000 / timescale), true
+}
+
+// readTkhd 读取轨道的画面尺寸, 音频轨道为0
+func readTkhd(r io.ReaderAt, body int64) (int32, int32) {
+	offset := int64(76) // version 0: 4 + 20 + 52
+	head := make([]byte, 1)
+	if _, err := r.ReadAt(head, body); err != nil {
+		return 0, 0
+	}
+	if head[0] == 1 {
+		offset = 88 // version 1: 4 + 32 + 52
+	}
+	buf := make([]byte, 8)
+	if _, err := r.ReadAt(buf, body+offset); err != nil {
+		return 0, 0
+	}
+	// 16.16 定点数
+	return int32(binary.BigEndian.Uint32(buf) >> 16), int32(binary.BigEndian.Uint32(buf[4:]) >> 16)
+}
+
+func probeWAV(r io.ReaderAt, size int64) (avInfo, bool) {
+	var byteRate, dataSize uint32
+	header := make([]byte, 8)
+	for pos := int64(12); pos+8 <= size; {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			break
+		}
+		chunk := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch chunk {
+		case "fmt ":
+			buf := make([]byte, 4)
+			if _, err := r.ReadAt(buf, pos+8+8); err == nil {
+				byteRate = binary.LittleEndian.Uint32(buf)
+			}
+		case "data":
+			dataSize = uint32(min(length, size-pos-8))
+		}
+		// chunk按偶数字节对齐
+		pos += 8 + length + length%2
+	}
+	if byteRate == 0 || dataSize == 0 {
+		return avInfo{}, false
+	}
+	return avInfo{durationMs: int64(dataSize) * 1000 / int64(byteRate)}, true
+}
+
+var (
+	// [version][bitrate index], version 0 为 MPEG1, 1 为 MPEG2/2.5, 单位kbps
+	mp3Bitrates = [2][16]int64{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	// [version bits][sample rate index], version bits: 0 MPEG2.5, 2 MPEG2, 3 MPEG1
+	mp3SampleRates = [4][3]int64{
+		{11025, 12000, 8000},
+		{0, 0, 0},
+		{22050, 24000, 16000},
+		{44100, 48000, 32000},
+	}
+)
+
+func probeMP3(r io.ReaderAt, size int64) (avInfo, bool) {
+	start := int64(0)
+	id3 := make([]byte, 10)
+	if _, err := r.ReadAt(id3, 0); err != nil {
+		return avInfo{}, false
+	}
+	if string(id3[:3]) == "ID3" {
+		// 标签长度为 syncsafe 整数
+		start = 10 + int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+	}
+
+	frame := make([]byte, 4+32+12) // 帧头 + 最长的side info + Xing头(标识、flags、帧数)
+	if _, err := r.ReadAt(frame, start); err != nil && err != io.EOF {
+		return avInfo{}, false
+	}
+	if frame[0] != 0xFF || frame[1]&0xE0 != 0xE0 {
+		return avInfo{}, false
+	}
+
+	versionBits := (frame[1] >> 3) & 0x03
+	layer := (frame[1] >> 1) & 0x03
+	bitrateIndex := frame[2] >> 4
+	rateIndex := (frame[2] >> 2) & 0x03
+	mono := frame[3]>>6 == 0x03
+
+	// 只支持 Layer III
+	if versionBits == 1 || layer != 1 || rateIndex == 3 {
+		return avInfo{}, false
+	}
+	mpeg1 := versionBits == 3
+	sampleRate := mp3SampleRates[versionBits][rateIndex]
+
+	v, samples, side := 1, int64(576), 17
+	if mpeg1 {
+		v, samples, side = 0, 1152, 32
+		if mono {
+			side = 17
+		}
+	} else if mono {
+		side = 9
+	}
+
+	// VBR文件首帧为 Xing/Info 头, 记录总帧数
+	xing := frame[4+side:]
+	if bytes.HasPrefix(xing, []byte("Xing")) || bytes.HasPrefix(xing, []byte("Info")) {
+		if binary.BigEndian.Uint32(xing[4:])&0x01 != 0 {
+			frames := int64(binary.BigEndian.Uint32(xing[8:]))
+			return avInfo{durationMs: frames * samples * 1000 / sampleRate}, true
+		}
+	}
+
+	bitrate := mp3Bitrates[v][bitrateIndex]
+	if bitrate == 0 {
+		return avInfo{}, false
+	}
+	return avInfo{durationMs: (size - start) * 8 / bitrate}, true
+}
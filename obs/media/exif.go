@@ -0,0 +1,122 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// StripGPS 原地清除jpeg中EXIF的GPS信息, 返回是否修改
+//
+// EXIF位于APP1段, 内部为TIFF结构, IFD0中的 GPSInfo(0x8825) 指向GPS IFD
+// 清零GPS IFD的全部条目及其引用的数据, 并将条目数置0
+// 文件长度和其余EXIF信息(拍摄时间、方向等)保持不变
+
+const tagGPSInfo = 0x8825
+
+// TIFF字段类型对应的字节数
+var typeSize = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+func StripGPS(data []byte) bool {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return false
+	}
+
+	stripped := false
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return stripped
+		}
+		marker := data[pos+1]
+		// SOS之后为图像数据, 不再有元数据段
+		if marker == 0xDA || marker == 0xD9 {
+			return stripped
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return stripped
+		}
+
+		seg := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			if stripTIFF(seg[6:]) {
+				stripped = true
+			}
+		}
+		pos = end
+	}
+	return stripped
+}
+
+func stripTIFF(tiff []byte) bool {
+	if len(tiff) < 8 {
+		return false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return false
+	}
+
+	ifd0 := order.Uint32(tiff[4:])
+	gps, ok := findTag(tiff, order, ifd0, tagGPSInfo)
+	if !ok {
+		return false
+	}
+	return clearIFD(tiff, order, gps)
+}
+
+// findTag 在IFD中查找tag, 返回其4字节的值
+func findTag(tiff []byte, order binary.ByteOrder, ifd uint32, tag uint16) (uint32, bool) {
+	if uint64(ifd)+2 > uint64(len(tiff)) {
+		return 0, false
+	}
+	n := uint32(order.Uint16(tiff[ifd:]))
+	for i := uint32(0); i < n; i++ {
+		entry := uint64(ifd) + 2 + uint64(i)*12
+		if entry+12 > uint64(len(tiff)) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) == tag {
+			return order.Uint32(tiff[entry+8:]), true
+		}
+	}
+	return 0, false
+}
+
+func clearIFD(tiff []byte, order binary.ByteOrder, ifd uint32) bool {
+	if uint64(ifd)+2 > uint64(len(tiff)) {
+		return false
+	}
+	n := uint32(order.Uint16(tiff[ifd:]))
+	if n == 0 {
+		return false
+	}
+
+	for i := uint32(0); i < n; i++ {
+		entry := uint64(ifd) + 2 + uint64(i)*12
+		if entry+12 > uint64(len(tiff)) {
+			break
+		}
+		typ := order.Uint16(tiff[entry+2:])
+		count := order.Uint32(tiff[entry+4:])
+
+		// 超过4字节的值存放在偏移处, 一并清零
+		total := uint64(typeSize[typ]) * uint64(count)
+		if total > 4 {
+			off := uint64(order.Uint32(tiff[entry+8:]))
+			if off+total <= uint64(len(tiff)) {
+				clear(tiff[off : off+total])
+			}
+		}
+		clear(tiff[entry : entry+12])
+	}
+	order.PutUint16(tiff[ifd:], 0)
+	return true
+}
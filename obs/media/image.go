@@ -0,0 +1,78 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// 缩略图
+//
+// 按最长边缩放到各个尺寸, 不放大; jpeg源图输出jpeg, 其余输出png以保留透明通道
+// 解码前先检查像素数, 超过 MAX_PIXELS 的图片只记录尺寸不生成缩略图
+
+const (
+	MAX_PIXELS   = 64 << 20
+	JPEG_QUALITY = 80
+)
+
+type thumb struct {
+	data        []byte
+	width       int
+	height      int
+	contentType string
+}
+
+// probe 读取图片尺寸与格式
+func probe(data []byte) (image.Config, string, error) {
+	return image.DecodeConfig(bytes.NewReader(data))
+}
+
+func thumbnails(data []byte, sizes []int) ([]thumb, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	longest := max(bounds.Dx(), bounds.Dy())
+
+	var thumbs []thumb
+	for _, size := range sizes {
+		if size <= 0 || size >= longest {
+			continue
+		}
+		w, h := scale(bounds.Dx(), bounds.Dy(), size)
+
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if format == "jpeg" {
+			contentType = "image/jpeg"
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: JPEG_QUALITY})
+		} else {
+			err = png.Encode(&buf, dst)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumbs = append(thumbs, thumb{data: buf.Bytes(), width: w, height: h, contentType: contentType})
+	}
+	return thumbs, nil
+}
+
+// scale 按最长边缩放到 size, 保持宽高比
+func scale(w, h, size int) (int, int) {
+	if w >= h {
+		return size, max(1, h*size/w)
+	}
+	return max(1, w*size/h), size
+}
@@ -0,0 +1,175 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG 构造带有GPS信息的jpeg, 返回文件与GPS纬度数据在文件中的偏移
+func exifJPEG(t *testing.T) ([]byte, int) {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 小端TIFF: IFD0 只有 GPSInfo 一项, GPS IFD 包含 GPSLatitudeRef 与 GPSLatitude(3个RATIONAL)
+	le := binary.LittleEndian
+	tiff := make([]byte, 8+2+12+4+2+24+4+24)
+	copy(tiff, "II")
+	le.PutUint16(tiff[2:], 42)
+	le.PutUint32(tiff[4:], 8)
+
+	le.PutUint16(tiff[8:], 1)
+	le.PutUint16(tiff[10:], tagGPSInfo)
+	le.PutUint16(tiff[12:], 4)
+	le.PutUint32(tiff[14:], 1)
+	le.PutUint32(tiff[18:], 26) // GPS IFD 偏移
+
+	gps := 26
+	le.PutUint16(tiff[gps:], 2)
+	le.PutUint16(tiff[gps+2:], 1) // GPSLatitudeRef 'N', 内联
+	le.PutUint16(tiff[gps+4:], 2)
+	le.PutUint32(tiff[gps+6:], 2)
+	copy(tiff[gps+10:], "N")
+	le.PutUint16(tiff[gps+14:], 2) // GPSLatitude
+	le.PutUint16(tiff[gps+16:], 5)
+	le.PutUint32(tiff[gps+18:], 3)
+	le.PutUint32(tiff[gps+22:], uint32(gps+30))
+	lat := gps + 30
+	for i := 0; i < 6; i++ {
+		le.PutUint32(tiff[lat+i*4:], uint32(i+1))
+	}
+
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+
+	data := append([]byte{0xFF, 0xD8}, app1...)
+	data = append(data, seg...)
+	data = append(data, img.Bytes()[2:]...)
+	return data, 2 + 4 + 6 + lat
+}
+
+func TestStripGPS(t *testing.T) {
+	data, lat := exifJPEG(t)
+	size := len(data)
+
+	if !StripGPS(data) {
+		t.Fatal("expected gps to be stripped")
+	}
+	if len(data) != size {
+		t.Fatalf("length changed %d -> %d", size, len(data))
+	}
+	if !bytes.Equal(data[lat:lat+24], make([]byte, 24)) {
+		t.Fatalf("latitude not cleared: %v", data[lat:lat+24])
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("image broken: %v", err)
+	}
+	if StripGPS(data) {
+		t.Fatal("expected nothing left to strip")
+	}
+}
+
+func TestThumbnails(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbs, err := thumbnails(buf.Bytes(), []int{64, 128, 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不放大, 512 被跳过
+	if len(thumbs) != 2 {
+		t.Fatalf("expected 2 thumbnails, got %d", len(thumbs))
+	}
+	if thumbs[0].width != 64 || thumbs[0].height != 21 || thumbs[0].contentType != "image/jpeg" {
+		t.Fatalf("unexpected thumbnail %+v", thumbs[0])
+	}
+	cfg, _, err := probe(thumbs[1].data)
+	if err != nil || cfg.Width != 128 || cfg.Height != 42 {
+		t.Fatalf("unexpected encoded thumbnail %+v, %v", cfg, err)
+	}
+}
+
+func TestProbeWAV(t *testing.T) {
+	// 16kHz 单声道 16bit, 1.5秒
+	le := binary.LittleEndian
+	data := make([]byte, 44+48000)
+	copy(data, "RIFF")
+	le.PutUint32(data[4:], uint32(len(data)-8))
+	copy(data[8:], "WAVEfmt ")
+	le.PutUint32(data[16:], 16)
+	le.PutUint16(data[20:], 1)
+	le.PutUint16(data[22:], 1)
+	le.PutUint32(data[24:], 16000)
+	le.PutUint32(data[28:], 32000)
+	copy(data[36:], "data")
+	le.PutUint32(data[40:], 48000)
+
+	info, ok := probeAV(bytes.NewReader(data), int64(len(data)))
+	if !ok || info.durationMs != 1500 {
+		t.Fatalf("unexpected %+v, %v", info, ok)
+	}
+}
+
+func box(typ string, body ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], typ)
+	for _, p := range body {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func TestProbeMP4(t *testing.T) {
+	be := binary.BigEndian
+
+	mvhd := make([]byte, 100)
+	be.PutUint32(mvhd[12:], 600)   // timescale
+	be.PutUint32(mvhd[16:], 12345) // duration
+
+	audio := make([]byte, 84)
+	video := make([]byte, 84)
+	be.PutUint32(video[76:], 1280<<16)
+	be.PutUint32(video[80:], 720<<16)
+
+	data := append(box("ftyp", []byte("isom")), box("moov",
+		box("mvhd", mvhd),
+		box("trak", box("tkhd", audio)),
+		box("trak", box("tkhd", video)),
+	)...)
+	data = append(data, box("mdat", make([]byte, 16))...)
+
+	info, ok := probeAV(bytes.NewReader(data), int64(len(data)))
+	if !ok || info.durationMs != 20575 || info.width != 1280 || info.height != 720 {
+		t.Fatalf("unexpected %+v, %v", info, ok)
+	}
+}
+
+func TestProbeMP3(t *testing.T) {
+	// MPEG1 Layer III 128kbps 44.1kHz 立体声, 无Xing头时按码率估算
+	data := make([]byte, 16000)
+	copy(data, []byte{0xFF, 0xFB, 0x90, 0x00})
+
+	info, ok := probeAV(bytes.NewReader(data), int64(len(data)))
+	if !ok || info.durationMs != 1000 {
+		t.Fatalf("unexpected %+v, %v", info, ok)
+	}
+
+	// Xing头记录 100 帧
+	copy(data[4+32:], "Xing")
+	binary.BigEndian.PutUint32(data[4+32+4:], 1)
+	binary.BigEndian.PutUint32(data[4+32+8:], 100)
+	info, ok = probeAV(bytes.NewReader(data), int64(len(data)))
+	if !ok || info.durationMs != 100*1152*1000/44100 {
+		t.Fatalf("unexpected %+v, %v", info, ok)
+	}
+}
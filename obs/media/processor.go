@@ -0,0 +1,132 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+	"obs/backend"
+)
+
+// Processor 上传完成后解析媒体文件, 补充 MediaPayload 中的元数据
+//
+// 图片 : 清除EXIF中的GPS信息, 记录宽高, 按 sizes 生成缩略图并写入后端
+// 音视频 : 记录时长, 视频记录画面宽高
+//
+// 解析失败只记录日志, 文件仍按原样保存, 不影响上传
+
+const (
+	// 超过该大小的图片不读入内存处理
+	MAX_IMAGE_BYTES = 32 << 20
+)
+
+type Processor struct {
+	backend backend.Backend
+	sizes   []int
+}
+
+func NewProcessor(b backend.Backend, sizes []int) *Processor {
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	return &Processor{backend: b, sizes: sizes}
+}
+
+// Process 解析 path 处的文件并填充 payload
+//
+// 文件内容被修改(如清除了GPS信息)时返回新文件的路径, 并同步更新 payload 的对象id与大小,
+// 新文件与 path 在同一目录, 由调用方删除; 未修改时返回 path
+func (p *Processor) Process(ctx context.Context, path string, payload *pb.MediaPayload) (string, error) {
+	switch {
+	case strings.HasPrefix(payload.ContentType, "image/"):
+		return p.image(ctx, path, payload)
+	case strings.HasPrefix(payload.ContentType, "audio/"),
+		strings.HasPrefix(payload.ContentType, "video/"):
+		return path, p.av(path, payload)
+	}
+	return path, nil
+}
+
+func (p *Processor) image(ctx context.Context, path string, payload *pb.MediaPayload) (string, error) {
+	if payload.Size > MAX_IMAGE_BYTES {
+		return path, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	out := path
+	if payload.ContentType == "image/jpeg" && StripGPS(data) {
+		f, err := os.CreateTemp(filepath.Dir(path), "clean-*")
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		sum := sha256.Sum256(data)
+		payload.ObjectId = hex.EncodeToString(sum[:])
+		payload.Size = int64(len(data))
+		out = f.Name()
+	}
+
+	cfg, _, err := probe(data)
+	if err != nil {
+		log.Printf("[WARN] probe image %s: %v", payload.ObjectId, err)
+		return out, nil
+	}
+	payload.Width, payload.Height = int32(cfg.Width), int32(cfg.Height)
+	if cfg.Width*cfg.Height > MAX_PIXELS || len(p.sizes) == 0 {
+		return out, nil
+	}
+
+	thumbs, err := thumbnails(data, p.sizes)
+	if err != nil {
+		log.Printf("[WARN] thumbnail %s: %v", payload.ObjectId, err)
+		return out, nil
+	}
+	for _, t := range thumbs {
+		sum := sha256.Sum256(t.data)
+		id := hex.EncodeToString(sum[:])
+		if err := p.backend.Put(ctx, id, bytes.NewReader(t.data), int64(len(t.data)), t.contentType); err != nil {
+			return "", err
+		}
+		payload.Thumbnails = append(payload.Thumbnails, &pb.Thumbnail{
+			ObjectId:    id,
+			Width:       int32(t.width),
+			Height:      int32(t.height),
+			Size:        int64(len(t.data)),
+			ContentType: t.contentType,
+		})
+	}
+	return out, nil
+}
+
+func (p *Processor) av(path string, payload *pb.MediaPayload) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, ok := probeAV(f, payload.Size)
+	if !ok {
+		log.Printf("[WARN] unrecognized %s %s", payload.ContentType, payload.ObjectId)
+		return nil
+	}
+	payload.DurationMs = info.durationMs
+	payload.Width, payload.Height = info.width, info.height
+	return nil
+}
@@ -0,0 +1,64 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Signer 下载链接签名
+//
+// sig = hex(hmac_sha256(secret, objectId + ":" + expires))
+// expires 为过期时间的unix秒, 链接过期或被篡改后无法使用
+
+var (
+	ErrExpired          = errors.New("signed url expired")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewSigner(secret string, ttl time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &Signer{secret: []byte(secret), ttl: ttl}
+}
+
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign 生成签名, 返回过期时间及签名
+func (s *Signer) Sign(objectId string, now time.Time) (int64, string) {
+	expires := now.Add(s.ttl).Unix()
+	return expires, s.sum(objectId, expires)
+}
+
+// Verify 校验签名及有效期
+func (s *Signer) Verify(objectId string, expires int64, sig string, now time.Time) error {
+	want, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(want, s.mac(objectId, expires)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) sum(objectId string, expires int64) string {
+	return hex.EncodeToString(s.mac(objectId, expires))
+}
+
+func (s *Signer) mac(objectId string, expires int64) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(objectId + ":" + strconv.FormatInt(expires, 10)))
+	return h.Sum(nil)
+}
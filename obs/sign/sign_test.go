@@ -0,0 +1,31 @@
+package sign
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	s := NewSigner("secret", time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	expires, sig := s.Sign("abc", now)
+	if expires != now.Add(time.Minute).Unix() {
+		t.Fatalf("unexpected expires %d", expires)
+	}
+	if err := s.Verify("abc", expires, sig, now.Add(30*time.Second)); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := s.Verify("abc", expires, sig, now.Add(2*time.Minute)); err != ErrExpired {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if err := s.Verify("abd", expires, sig, now); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature for other object, got %v", err)
+	}
+	if err := s.Verify("abc", expires+3600, sig, now); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature for extended expiry, got %v", err)
+	}
+	if err := NewSigner("other", time.Minute).Verify("abc", expires, sig, now); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature for other secret, got %v", err)
+	}
+}
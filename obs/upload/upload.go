@@ -0,0 +1,485 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"obs/backend"
+	"obs/media"
+)
+
+// Manager 断点续传的分片上传
+//
+// 1. Init      声明文件的大小与sha256, 对象已存在时直接返回(秒传), 否则创建上传会话
+// 2. WriteChunk 按序号上传分片, 分片可以乱序、并发、重复上传
+// 3. Status    查询已上传的分片, 客户端断线后据此续传
+// 4. Complete  合并分片并校验大小与sha256, 解析媒体元数据, 写入后端后删除会话
+//
+// 解析出的 MediaPayload 以 meta-{sha256} 保存在后端, 秒传时直接返回, 无需重新解析
+// 图片清除GPS信息后内容会变化, 对象id为清除后的sha256, 元数据同时按客户端声明的sha256保存
+// 每次秒传都会重写元数据, 元数据的修改时间即对象最近一次被引用的时间, 用于 Remove 判断对象是否仍在使用
+//
+// 会话保存在本地目录 {dir}/{uploadId}, 同一会话的请求需路由到同一个obs节点
+// 超过 ttl 未完成的会话由 Clean 清理
+
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrTooLarge        = errors.New("file too large")
+	ErrSessionNotFound = errors.New("upload session not found")
+	ErrChunkSize       = errors.New("unexpected chunk size")
+	ErrIncomplete      = errors.New("upload incomplete")
+	ErrChecksum        = errors.New("sha256 mismatch")
+	ErrInUse           = errors.New("object referenced recently")
+)
+
+const (
+	sessionFile = "session.json"
+	chunkPrefix = "chunk-"
+	metaPrefix  = "meta-"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type Session struct {
+	UploadId    string `json:"upload_id"`
+	Uid         string `json:"uid"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	Sha256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+	ChunkSize   int64  `json:"chunk_size"`
+	Chunks      int    `json:"chunks"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// chunkLen 第 index 个分片的长度, 最后一个分片可能不足 ChunkSize
+func (s *Session) chunkLen(index int) int64 {
+	if index == s.Chunks-1 {
+		return s.Size - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+type InitRequest struct {
+	Uid         string
+	Name        string
+	Size        int64
+	Sha256      string
+	ContentType string
+}
+
+type Manager struct {
+	dir       string
+	backend   backend.Backend
+	chunkSize int64
+	maxSize   int64
+	ttl       time.Duration
+	processor *media.Processor // 为nil时不解析媒体元数据
+
+	locks sync.Map // uploadId -> *sync.Mutex, 串行化同一会话的合并
+}
+
+func NewManager(dir string, b backend.Backend, processor *media.Processor, chunkSize, maxSize int64, ttl time.Duration) (*Manager, error) {
+	if dir == "" {
+		dir = "data/uploads"
+	}
+	if chunkSize <= 0 {
+		chunkSize = 4 << 20
+	}
+	if maxSize <= 0 {
+		maxSize = 2 << 30
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		dir:       dir,
+		backend:   b,
+		chunkSize: chunkSize,
+		maxSize:   maxSize,
+		ttl:       ttl,
+		processor: processor,
+	}, nil
+}
+
+// Init 创建上传会话
+// 相同内容的对象已存在时返回对象引用, 会话为nil
+func (m *Manager) Init(ctx context.Context, req *InitRequest) (*Session, *pb.MediaPayload, error) {
+	req.Sha256 = strings.ToLower(req.Sha256)
+	if req.Uid == "" || req.Name == "" || req.Size <= 0 || !sha256Pattern.MatchString(req.Sha256) {
+		return nil, nil, ErrInvalidArgument
+	}
+	if req.Size > m.maxSize {
+		return nil, nil, ErrTooLarge
+	}
+
+	payload, err := m.loadMeta(ctx, req.Sha256)
+	if err == nil {
+		// 对象可能已被过期清理, 此时重新上传
+		if _, err = m.backend.Stat(ctx, payload.ObjectId); err == nil {
+			if err := m.saveMeta(ctx, req.Sha256, payload); err != nil {
+				return nil, nil, err
+			}
+			payload.Name = req.Name
+			return nil, payload, nil
+		}
+	}
+	if err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	// 没有元数据的历史对象
+	info, err := m.backend.Stat(ctx, req.Sha256)
+	if err == nil && info.Size == req.Size {
+		return nil, payloadOf(req.Name, info), nil
+	}
+	if err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, err
+	}
+	s := &Session{
+		UploadId:    hex.EncodeToString(id),
+		Uid:         req.Uid,
+		Name:        req.Name,
+		Size:        req.Size,
+		Sha256:      req.Sha256,
+		ContentType: req.ContentType,
+		ChunkSize:   m.chunkSize,
+		Chunks:      int((req.Size + m.chunkSize - 1) / m.chunkSize),
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	dir := filepath.Join(m.dir, s.UploadId)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, sessionFile), data, 0o644); err != nil {
+		return nil, nil, err
+	}
+	return s, nil, nil
+}
+
+// Status 查询会话及已上传的分片序号
+func (m *Manager) Status(uploadId string) (*Session, []int, error) {
+	s, err := m.load(uploadId)
+	if err != nil {
+		return nil, nil, err
+	}
+	uploaded, err := m.uploaded(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, uploaded, nil
+}
+
+// WriteChunk 写入一个分片, 分片长度必须与会话声明一致
+func (m *Manager) WriteChunk(uploadId, uid string, index int, r io.Reader) error {
+	s, err := m.load(uploadId)
+	if err != nil {
+		return err
+	}
+	if s.Uid != uid || index < 0 || index >= s.Chunks {
+		return ErrInvalidArgument
+	}
+
+	dir := filepath.Join(m.dir, uploadId)
+	tmp, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	want := s.chunkLen(index)
+	n, err := io.Copy(tmp, io.LimitReader(r, want+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n != want {
+		return ErrChunkSize
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, chunkName(index)))
+}
+
+// Complete 合并分片并写入后端, 返回对象引用
+func (m *Manager) Complete(ctx context.Context, uploadId, uid string) (*pb.MediaPayload, error) {
+	mu, _ := m.locks.LoadOrStore(uploadId, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer func() {
+		mu.(*sync.Mutex).Unlock()
+		m.locks.Delete(uploadId)
+	}()
+
+	s, err := m.load(uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if s.Uid != uid {
+		return nil, ErrInvalidArgument
+	}
+	uploaded, err := m.uploaded(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(uploaded) != s.Chunks {
+		return nil, ErrIncomplete
+	}
+
+	dir := filepath.Join(m.dir, uploadId)
+	merged, err := os.CreateTemp(dir, "merged-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		merged.Close()
+		os.Remove(merged.Name())
+	}()
+
+	h := sha256.New()
+	for i := 0; i < s.Chunks; i++ {
+		if err := appendChunk(io.MultiWriter(merged, h), filepath.Join(dir, chunkName(i))); err != nil {
+			return nil, err
+		}
+	}
+	if hex.EncodeToString(h.Sum(nil)) != s.Sha256 {
+		// 分片内容有误, 清理后由客户端重新上传
+		os.RemoveAll(dir)
+		return nil, ErrChecksum
+	}
+
+	if _, err := merged.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	contentType := s.ContentType
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(merged, head)
+		contentType = http.DetectContentType(head[:n])
+	}
+
+	payload := &pb.MediaPayload{
+		ObjectId:    s.Sha256,
+		Name:        s.Name,
+		Size:        s.Size,
+		ContentType: contentType,
+	}
+	path := merged.Name()
+	if m.processor != nil {
+		if path, err = m.processor.Process(ctx, path, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.put(ctx, payload.ObjectId, path, payload.Size, contentType); err != nil {
+		return nil, err
+	}
+	if err := m.saveMeta(ctx, s.Sha256, payload); err != nil {
+		return nil, err
+	}
+	os.RemoveAll(dir)
+
+	return payload, nil
+}
+
+// Remove 删除对象及其缩略图, 用于消息过期后清理媒体
+// 对象在 before 之后被上传或秒传过时返回 ErrInUse, 对象可能仍被其他消息引用
+func (m *Manager) Remove(ctx context.Context, objectId string, before time.Time) error {
+	if !sha256Pattern.MatchString(objectId) {
+		return ErrInvalidArgument
+	}
+	info, err := m.backend.Stat(ctx, metaPrefix+objectId)
+	if errors.Is(err, backend.ErrNotFound) {
+		// 没有元数据的历史对象按对象本身的修改时间判断
+		info, err = m.backend.Stat(ctx, objectId)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.ModTime.Before(before) {
+		return ErrInUse
+	}
+
+	var thumbnails []*pb.Thumbnail
+	if payload, err := m.loadMeta(ctx, objectId); err == nil {
+		thumbnails = payload.Thumbnails
+	}
+	for _, t := range thumbnails {
+		if err := m.backend.Delete(ctx, t.ObjectId); err != nil && !errors.Is(err, backend.ErrNotFound) {
+			return err
+		}
+	}
+	if err := m.backend.Delete(ctx, objectId); err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return err
+	}
+	if err := m.backend.Delete(ctx, metaPrefix+objectId); err != nil && !errors.Is(err, backend.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Abort 放弃上传
+func (m *Manager) Abort(uploadId, uid string) error {
+	s, err := m.load(uploadId)
+	if err != nil {
+		return err
+	}
+	if s.Uid != uid {
+		return ErrInvalidArgument
+	}
+	return os.RemoveAll(filepath.Join(m.dir, uploadId))
+}
+
+// Clean 清理超过 ttl 未完成的会话
+func (m *Manager) Clean(now time.Time) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+	expireBefore := now.Add(-m.ttl).Unix()
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		s, err := m.load(e.Name())
+		if err != nil || s.CreatedAt < expireBefore {
+			os.RemoveAll(filepath.Join(m.dir, e.Name()))
+		}
+	}
+}
+
+func (m *Manager) load(uploadId string) (*Session, error) {
+	if uploadId == "" || strings.ContainsAny(uploadId, `/\.`) {
+		return nil, ErrSessionNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(m.dir, uploadId, sessionFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (m *Manager) uploaded(s *Session) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dir, s.UploadId))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, chunkPrefix) {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(name, chunkPrefix))
+		if err != nil || i < 0 || i >= s.Chunks {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// put 将 path 处的文件写入后端
+func (m *Manager) put(ctx context.Context, key, path string, size int64, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.backend.Put(ctx, key, f, size, contentType)
+}
+
+func (m *Manager) loadMeta(ctx context.Context, sha string) (*pb.MediaPayload, error) {
+	r, _, err := m.backend.Get(ctx, metaPrefix+sha)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var payload pb.MediaPayload
+	if err := protojson.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// saveMeta 按 sha 及对象id保存元数据
+func (m *Manager) saveMeta(ctx context.Context, sha string, payload *pb.MediaPayload) error {
+	data, err := protojson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{sha, payload.ObjectId} {
+		if err := m.backend.Put(ctx, metaPrefix+key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+			return err
+		}
+		if sha == payload.ObjectId {
+			break
+		}
+	}
+	return nil
+}
+
+func appendChunk(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func chunkName(index int) string {
+	return fmt.Sprintf("%s%06d", chunkPrefix, index)
+}
+
+func payloadOf(name string, info *backend.ObjectInfo) *pb.MediaPayload {
+	return &pb.MediaPayload{
+		ObjectId:    info.Key,
+		Name:        name,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+	}
+}
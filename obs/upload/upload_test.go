@@ -0,0 +1,215 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"obs/backend"
+	"obs/media"
+)
+
+func newManager(t *testing.T) (*Manager, backend.Backend) {
+	t.Helper()
+	dir := t.TempDir()
+	b, err := backend.NewLocal(filepath.Join(dir, "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(filepath.Join(dir, "uploads"), b, media.NewProcessor(b, []int{8}), 4, 1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, b
+}
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func TestResumableUpload(t *testing.T) {
+	ctx := context.Background()
+	m, b := newManager(t)
+	data := []byte("hello, world")
+
+	s, obj, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a.txt", Size: int64(len(data)), Sha256: sum(data)})
+	if err != nil || obj != nil {
+		t.Fatalf("init: %v, %v", obj, err)
+	}
+	if s.Chunks != 3 {
+		t.Fatalf("expected 3 chunks, got %d", s.Chunks)
+	}
+
+	// 乱序上传部分分片后中断
+	if err := m.WriteChunk(s.UploadId, "u1", 2, bytes.NewReader(data[8:])); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u1", 0, bytes.NewReader(data[:4])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Complete(ctx, s.UploadId, "u1"); err != ErrIncomplete {
+		t.Fatalf("expected ErrIncomplete, got %v", err)
+	}
+
+	// 续传
+	_, uploaded, err := m.Status(s.UploadId)
+	if err != nil || !reflect.DeepEqual(uploaded, []int{0, 2}) {
+		t.Fatalf("unexpected status %v, %v", uploaded, err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u1", 1, bytes.NewReader(data[4:9])); err != ErrChunkSize {
+		t.Fatalf("expected ErrChunkSize, got %v", err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u1", 1, bytes.NewReader(data[4:8])); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err = m.Complete(ctx, s.UploadId, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.ObjectId != sum(data) || obj.Size != int64(len(data)) || obj.Name != "a.txt" || obj.ContentType == "" {
+		t.Fatalf("unexpected object %v", obj)
+	}
+
+	r, _, err := b.Get(ctx, obj.ObjectId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stored, _ := io.ReadAll(r)
+	if !bytes.Equal(stored, data) {
+		t.Fatalf("stored %q", stored)
+	}
+
+	if _, _, err := m.Status(s.UploadId); err != ErrSessionNotFound {
+		t.Fatalf("expected session to be removed, got %v", err)
+	}
+
+	// 相同内容秒传
+	s, obj, err = m.Init(ctx, &InitRequest{Uid: "u2", Name: "b.txt", Size: int64(len(data)), Sha256: sum(data)})
+	if err != nil || s != nil || obj.ObjectId != sum(data) || obj.Name != "b.txt" {
+		t.Fatalf("expected dedup, got %v, %v, %v", s, obj, err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newManager(t)
+
+	s, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4, Sha256: sum([]byte("abcd"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u1", 0, bytes.NewReader([]byte("abce"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Complete(ctx, s.UploadId, "u1"); err != ErrChecksum {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestInitValidation(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newManager(t)
+
+	if _, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4, Sha256: "xyz"}); err != ErrInvalidArgument {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+	if _, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4096, Sha256: sum(nil)}); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+
+	s, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4, Sha256: sum([]byte("abcd"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u2", 0, bytes.NewReader([]byte("abcd"))); err != ErrInvalidArgument {
+		t.Fatalf("expected other user to be rejected, got %v", err)
+	}
+
+	m.Clean(time.Now().Add(2 * time.Hour))
+	if _, _, err := m.Status(s.UploadId); err != ErrSessionNotFound {
+		t.Fatalf("expected expired session to be cleaned, got %v", err)
+	}
+}
+
+func TestImageMetadata(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newManager(t)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 16))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	s, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a.png", Size: int64(len(data)), Sha256: sum(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < s.Chunks; i++ {
+		chunk := data[i*4 : min(len(data), (i+1)*4)]
+		if err := m.WriteChunk(s.UploadId, "u1", i, bytes.NewReader(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	obj, err := m.Complete(ctx, s.UploadId, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Width != 32 || obj.Height != 16 || len(obj.Thumbnails) != 1 {
+		t.Fatalf("unexpected metadata %v", obj)
+	}
+	if th := obj.Thumbnails[0]; th.Width != 8 || th.Height != 4 || th.ContentType != "image/png" {
+		t.Fatalf("unexpected thumbnail %v", th)
+	}
+
+	// 秒传返回已解析的元数据
+	_, dup, err := m.Init(ctx, &InitRequest{Uid: "u2", Name: "b.png", Size: int64(len(data)), Sha256: sum(data)})
+	if err != nil || dup.Name != "b.png" || dup.Width != 32 || len(dup.Thumbnails) != 1 {
+		t.Fatalf("expected dedup with metadata, got %v, %v", dup, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	m, b := newManager(t)
+	data := []byte("abcd")
+
+	s, _, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4, Sha256: sum(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteChunk(s.UploadId, "u1", 0, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Complete(ctx, s.UploadId, "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 最近上传过的对象仍可能被引用
+	if err := m.Remove(ctx, sum(data), time.Now().Add(-time.Hour)); err != ErrInUse {
+		t.Fatalf("expected ErrInUse, got %v", err)
+	}
+	if err := m.Remove(ctx, sum(data), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Stat(ctx, sum(data)); err != backend.ErrNotFound {
+		t.Fatalf("expected object to be removed, got %v", err)
+	}
+
+	// 删除后需要重新上传
+	s, obj, err := m.Init(ctx, &InitRequest{Uid: "u1", Name: "a", Size: 4, Sha256: sum(data)})
+	if err != nil || s == nil || obj != nil {
+		t.Fatalf("expected new session, got %v, %v, %v", s, obj, err)
+	}
+}
@@ -0,0 +1,520 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v6.31.1
+// source: disappear.proto
+
+package message
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DisappearStart int32
+
+const (
+	DisappearStart_START_ON_READ DisappearStart = 0 // 接收者已读后开始计时, 群聊中任一成员已读即开始
+	DisappearStart_START_ON_SEND DisappearStart = 1 // 发送后开始计时
+)
+
+// Enum value maps for DisappearStart.
+var (
+	DisappearStart_name = map[int32]string{
+		0: "START_ON_READ",
+		1: "START_ON_SEND",
+	}
+	DisappearStart_value = map[string]int32{
+		"START_ON_READ": 0,
+		"START_ON_SEND": 1,
+	}
+)
+
+func (x DisappearStart) Enum() *DisappearStart {
+	p := new(DisappearStart)
+	*p = x
+	return p
+}
+
+func (x DisappearStart) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DisappearStart) Descriptor() protoreflect.EnumDescriptor {
+	return file_disappear_proto_enumTypes[0].Descriptor()
+}
+
+func (DisappearStart) Type() protoreflect.EnumType {
+	return &file_disappear_proto_enumTypes[0]
+}
+
+func (x DisappearStart) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DisappearStart.Descriptor instead.
+func (DisappearStart) EnumDescriptor() ([]byte, []int) {
+	return file_disappear_proto_rawDescGZIP(), []int{0}
+}
+
+type DisappearTimer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TtlSeconds int64          `protobuf:"varint,1,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"` // 为0表示关闭
+	Start      DisappearStart `protobuf:"varint,2,opt,name=start,proto3,enum=message.v1.DisappearStart" json:"start,omitempty"`
+	UpdatedBy  string         `protobuf:"bytes,3,opt,name=updated_by,json=updatedBy,proto3" json:"updated_by,omitempty"`
+	UpdatedAt  int64          `protobuf:"varint,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+}
+
+func (x *DisappearTimer) Reset() {
+	*x = DisappearTimer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_disappear_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DisappearTimer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisappearTimer) ProtoMessage() {}
+
+func (x *DisappearTimer) ProtoReflect() protoreflect.Message {
+	mi := &file_disappear_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisappearTimer.ProtoReflect.Descriptor instead.
+func (*DisappearTimer) Descriptor() ([]byte, []int) {
+	return file_disappear_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DisappearTimer) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *DisappearTimer) GetStart() DisappearStart {
+	if x != nil {
+		return x.Start
+	}
+	return DisappearStart_START_ON_READ
+}
+
+func (x *DisappearTimer) GetUpdatedBy() string {
+	if x != nil {
+		return x.UpdatedBy
+	}
+	return ""
+}
+
+func (x *DisappearTimer) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+type SetDisappearTimerRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uid            string         `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	ConversationId string         `protobuf:"bytes,2,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"` // 私聊为对方uid, 群聊为群id
+	SessionType    SesstionType   `protobuf:"varint,3,opt,name=session_type,json=sessionType,proto3,enum=message.v1.SesstionType" json:"session_type,omitempty"`
+	TtlSeconds     int64          `protobuf:"varint,4,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	Start          DisappearStart `protobuf:"varint,5,opt,name=start,proto3,enum=message.v1.DisappearStart" json:"start,omitempty"`
+}
+
+func (x *SetDisappearTimerRequest) Reset() {
+	*x = SetDisappearTimerRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_disappear_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetDisappearTimerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetDisappearTimerRequest) ProtoMessage() {}
+
+func (x *SetDisappearTimerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_disappear_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetDisappearTimerRequest.ProtoReflect.Descriptor instead.
+func (*SetDisappearTimerRequest) Descriptor() ([]byte, []int) {
+	return file_disappear_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SetDisappearTimerRequest) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *SetDisappearTimerRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *SetDisappearTimerRequest) GetSessionType() SesstionType {
+	if x != nil {
+		return x.SessionType
+	}
+	return SesstionType_SESSION_TYPE_UNSPECIFIED
+}
+
+func (x *SetDisappearTimerRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *SetDisappearTimerRequest) GetStart() DisappearStart {
+	if x != nil {
+		return x.Start
+	}
+	return DisappearStart_START_ON_READ
+}
+
+type SetDisappearTimerResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timer  *DisappearTimer `protobuf:"bytes,1,opt,name=timer,proto3" json:"timer,omitempty"`
+	Notice *MessageData    `protobuf:"bytes,2,opt,name=notice,proto3" json:"notice,omitempty"` // 会话中生成的通知消息
+}
+
+func (x *SetDisappearTimerResponse) Reset() {
+	*x = SetDisappearTimerResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_disappear_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetDisappearTimerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetDisappearTimerResponse) ProtoMessage() {}
+
+func (x *SetDisappearTimerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_disappear_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetDisappearTimerResponse.ProtoReflect.Descriptor instead.
+func (*SetDisappearTimerResponse) Descriptor() ([]byte, []int) {
+	return file_disappear_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SetDisappearTimerResponse) GetTimer() *DisappearTimer {
+	if x != nil {
+		return x.Timer
+	}
+	return nil
+}
+
+func (x *SetDisappearTimerResponse) GetNotice() *MessageData {
+	if x != nil {
+		return x.Notice
+	}
+	return nil
+}
+
+type GetDisappearTimerRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uid            string       `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	ConversationId string       `protobuf:"bytes,2,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	SessionType    SesstionType `protobuf:"varint,3,opt,name=session_type,json=sessionType,proto3,enum=message.v1.SesstionType" json:"session_type,omitempty"`
+}
+
+func (x *GetDisappearTimerRequest) Reset() {
+	*x = GetDisappearTimerRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_disappear_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDisappearTimerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDisappearTimerRequest) ProtoMessage() {}
+
+func (x *GetDisappearTimerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_disappear_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDisappearTimerRequest.ProtoReflect.Descriptor instead.
+func (*GetDisappearTimerRequest) Descriptor() ([]byte, []int) {
+	return file_disappear_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetDisappearTimerRequest) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *GetDisappearTimerRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *GetDisappearTimerRequest) GetSessionType() SesstionType {
+	if x != nil {
+		return x.SessionType
+	}
+	return SesstionType_SESSION_TYPE_UNSPECIFIED
+}
+
+var File_disappear_proto protoreflect.FileDescriptor
+
+var file_disappear_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x64, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x0a, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x0d, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa1, 0x01, 0x0a,
+	0x0e, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x12,
+	0x1f, 0x0a, 0x0b, 0x74, 0x74, 0x6c, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x74, 0x74, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73,
+	0x12, 0x30, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x1a, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73,
+	0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x53, 0x74, 0x61, 0x72, 0x74, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x72, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x62, 0x79,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x42,
+	0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74,
+	0x22, 0xe5, 0x01, 0x0a, 0x18, 0x53, 0x65, 0x74, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61,
+	0x72, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a,
+	0x03, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12,
+	0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72,
+	0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x3b, 0x0a, 0x0c, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18,
+	0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x73, 0x73,
+	0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x74, 0x6c, 0x5f, 0x73, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x74, 0x74, 0x6c, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x30, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x53, 0x74, 0x61, 0x72,
+	0x74, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x22, 0x7e, 0x0a, 0x19, 0x53, 0x65, 0x74, 0x44,
+	0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x30, 0x0a, 0x05, 0x74, 0x69, 0x6d, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72,
+	0x52, 0x05, 0x74, 0x69, 0x6d, 0x65, 0x72, 0x12, 0x2f, 0x0a, 0x06, 0x6e, 0x6f, 0x74, 0x69, 0x63,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x44, 0x61, 0x74, 0x61,
+	0x52, 0x06, 0x6e, 0x6f, 0x74, 0x69, 0x63, 0x65, 0x22, 0x92, 0x01, 0x0a, 0x18, 0x47, 0x65, 0x74,
+	0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65,
+	0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64,
+	0x12, 0x3b, 0x0a, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x73, 0x73, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65,
+	0x52, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x2a, 0x36, 0x0a,
+	0x0e, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x53, 0x74, 0x61, 0x72, 0x74, 0x12,
+	0x11, 0x0a, 0x0d, 0x53, 0x54, 0x41, 0x52, 0x54, 0x5f, 0x4f, 0x4e, 0x5f, 0x52, 0x45, 0x41, 0x44,
+	0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x54, 0x41, 0x52, 0x54, 0x5f, 0x4f, 0x4e, 0x5f, 0x53,
+	0x45, 0x4e, 0x44, 0x10, 0x01, 0x32, 0xbd, 0x01, 0x0a, 0x10, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70,
+	0x65, 0x61, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x59, 0x0a, 0x08, 0x53, 0x65,
+	0x74, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x12, 0x24, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x74, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72,
+	0x54, 0x69, 0x6d, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x74, 0x44, 0x69, 0x73,
+	0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4e, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x54, 0x69, 0x6d, 0x65,
+	0x72, 0x12, 0x24, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x65, 0x74, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x61, 0x70, 0x70, 0x65, 0x61, 0x72, 0x54, 0x69,
+	0x6d, 0x65, 0x72, 0x22, 0x00, 0x42, 0x0b, 0x5a, 0x09, 0x2e, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_disappear_proto_rawDescOnce sync.Once
+	file_disappear_proto_rawDescData = file_disappear_proto_rawDesc
+)
+
+func file_disappear_proto_rawDescGZIP() []byte {
+	file_disappear_proto_rawDescOnce.Do(func() {
+		file_disappear_proto_rawDescData = protoimpl.X.CompressGZIP(file_disappear_proto_rawDescData)
+	})
+	return file_disappear_proto_rawDescData
+}
+
+var file_disappear_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_disappear_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_disappear_proto_goTypes = []interface{}{
+	(DisappearStart)(0),               // 0: message.v1.DisappearStart
+	(*DisappearTimer)(nil),            // 1: message.v1.DisappearTimer
+	(*SetDisappearTimerRequest)(nil),  // 2: message.v1.SetDisappearTimerRequest
+	(*SetDisappearTimerResponse)(nil), // 3: message.v1.SetDisappearTimerResponse
+	(*GetDisappearTimerRequest)(nil),  // 4: message.v1.GetDisappearTimerRequest
+	(SesstionType)(0),                 // 5: message.v1.SesstionType
+	(*MessageData)(nil),               // 6: message.v1.MessageData
+}
+var file_disappear_proto_depIdxs = []int32{
+	0, // 0: message.v1.DisappearTimer.start:type_name -> message.v1.DisappearStart
+	5, // 1: message.v1.SetDisappearTimerRequest.session_type:type_name -> message.v1.SesstionType
+	0, // 2: message.v1.SetDisappearTimerRequest.start:type_name -> message.v1.DisappearStart
+	1, // 3: message.v1.SetDisappearTimerResponse.timer:type_name -> message.v1.DisappearTimer
+	6, // 4: message.v1.SetDisappearTimerResponse.notice:type_name -> message.v1.MessageData
+	5, // 5: message.v1.GetDisappearTimerRequest.session_type:type_name -> message.v1.SesstionType
+	2, // 6: message.v1.DisappearService.SetTimer:input_type -> message.v1.SetDisappearTimerRequest
+	4, // 7: message.v1.DisappearService.GetTimer:input_type -> message.v1.GetDisappearTimerRequest
+	3, // 8: message.v1.DisappearService.SetTimer:output_type -> message.v1.SetDisappearTimerResponse
+	1, // 9: message.v1.DisappearService.GetTimer:output_type -> message.v1.DisappearTimer
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_disappear_proto_init() }
+func file_disappear_proto_init() {
+	if File_disappear_proto != nil {
+		return
+	}
+	file_message_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_disappear_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DisappearTimer); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_disappear_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetDisappearTimerRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_disappear_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetDisappearTimerResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_disappear_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDisappearTimerRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_disappear_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_disappear_proto_goTypes,
+		DependencyIndexes: file_disappear_proto_depIdxs,
+		EnumInfos:         file_disappear_proto_enumTypes,
+		MessageInfos:      file_disappear_proto_msgTypes,
+	}.Build()
+	File_disappear_proto = out.File
+	file_disappear_proto_rawDesc = nil
+	file_disappear_proto_goTypes = nil
+	file_disappear_proto_depIdxs = nil
+}
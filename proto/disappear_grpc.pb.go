@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v6.31.1
+// source: disappear.proto
+
+package message
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DisappearServiceClient is the client API for DisappearService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DisappearServiceClient interface {
+	SetTimer(ctx context.Context, in *SetDisappearTimerRequest, opts ...grpc.CallOption) (*SetDisappearTimerResponse, error)
+	GetTimer(ctx context.Context, in *GetDisappearTimerRequest, opts ...grpc.CallOption) (*DisappearTimer, error)
+}
+
+type disappearServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDisappearServiceClient(cc grpc.ClientConnInterface) DisappearServiceClient {
+	return &disappearServiceClient{cc}
+}
+
+func (c *disappearServiceClient) SetTimer(ctx context.Context, in *SetDisappearTimerRequest, opts ...grpc.CallOption) (*SetDisappearTimerResponse, error) {
+	out := new(SetDisappearTimerResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.DisappearService/SetTimer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *disappearServiceClient) GetTimer(ctx context.Context, in *GetDisappearTimerRequest, opts ...grpc.CallOption) (*DisappearTimer, error) {
+	out := new(DisappearTimer)
+	err := c.cc.Invoke(ctx, "/message.v1.DisappearService/GetTimer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DisappearServiceServer is the server API for DisappearService service.
+// All implementations must embed UnimplementedDisappearServiceServer
+// for forward compatibility
+type DisappearServiceServer interface {
+	SetTimer(context.Context, *SetDisappearTimerRequest) (*SetDisappearTimerResponse, error)
+	GetTimer(context.Context, *GetDisappearTimerRequest) (*DisappearTimer, error)
+	mustEmbedUnimplementedDisappearServiceServer()
+}
+
+// UnimplementedDisappearServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDisappearServiceServer struct {
+}
+
+func (UnimplementedDisappearServiceServer) SetTimer(context.Context, *SetDisappearTimerRequest) (*SetDisappearTimerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTimer not implemented")
+}
+func (UnimplementedDisappearServiceServer) GetTimer(context.Context, *GetDisappearTimerRequest) (*DisappearTimer, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTimer not implemented")
+}
+func (UnimplementedDisappearServiceServer) mustEmbedUnimplementedDisappearServiceServer() {}
+
+// UnsafeDisappearServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DisappearServiceServer will
+// result in compilation errors.
+type UnsafeDisappearServiceServer interface {
+	mustEmbedUnimplementedDisappearServiceServer()
+}
+
+func RegisterDisappearServiceServer(s grpc.ServiceRegistrar, srv DisappearServiceServer) {
+	s.RegisterService(&DisappearService_ServiceDesc, srv)
+}
+
+func _DisappearService_SetTimer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDisappearTimerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DisappearServiceServer).SetTimer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.DisappearService/SetTimer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DisappearServiceServer).SetTimer(ctx, req.(*SetDisappearTimerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DisappearService_GetTimer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDisappearTimerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DisappearServiceServer).GetTimer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.DisappearService/GetTimer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DisappearServiceServer).GetTimer(ctx, req.(*GetDisappearTimerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DisappearService_ServiceDesc is the grpc.ServiceDesc for DisappearService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DisappearService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "message.v1.DisappearService",
+	HandlerType: (*DisappearServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetTimer",
+			Handler:    _DisappearService_SetTimer_Handler,
+		},
+		{
+			MethodName: "GetTimer",
+			Handler:    _DisappearService_GetTimer_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "disappear.proto",
+}
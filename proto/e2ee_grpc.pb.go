@@ -0,0 +1,321 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v6.31.1
+// source: e2ee.proto
+
+package message
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// KeyServiceClient is the client API for KeyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KeyServiceClient interface {
+	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error)
+	UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*UploadPrekeysResponse, error)
+	FetchBundles(ctx context.Context, in *FetchBundlesRequest, opts ...grpc.CallOption) (*FetchBundlesResponse, error)
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	RemoveDevice(ctx context.Context, in *RemoveDeviceRequest, opts ...grpc.CallOption) (*RemoveDeviceResponse, error)
+	EnableEncryption(ctx context.Context, in *EnableEncryptionRequest, opts ...grpc.CallOption) (*EnableEncryptionResponse, error)
+	GetEncryption(ctx context.Context, in *GetEncryptionRequest, opts ...grpc.CallOption) (*EncryptionState, error)
+}
+
+type keyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKeyServiceClient(cc grpc.ClientConnInterface) KeyServiceClient {
+	return &keyServiceClient{cc}
+}
+
+func (c *keyServiceClient) RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error) {
+	out := new(RegisterDeviceResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/RegisterDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*UploadPrekeysResponse, error) {
+	out := new(UploadPrekeysResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/UploadPrekeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) FetchBundles(ctx context.Context, in *FetchBundlesRequest, opts ...grpc.CallOption) (*FetchBundlesResponse, error) {
+	out := new(FetchBundlesResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/FetchBundles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/ListDevices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) RemoveDevice(ctx context.Context, in *RemoveDeviceRequest, opts ...grpc.CallOption) (*RemoveDeviceResponse, error) {
+	out := new(RemoveDeviceResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/RemoveDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) EnableEncryption(ctx context.Context, in *EnableEncryptionRequest, opts ...grpc.CallOption) (*EnableEncryptionResponse, error) {
+	out := new(EnableEncryptionResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/EnableEncryption", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyServiceClient) GetEncryption(ctx context.Context, in *GetEncryptionRequest, opts ...grpc.CallOption) (*EncryptionState, error) {
+	out := new(EncryptionState)
+	err := c.cc.Invoke(ctx, "/message.v1.KeyService/GetEncryption", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// KeyServiceServer is the server API for KeyService service.
+// All implementations must embed UnimplementedKeyServiceServer
+// for forward compatibility
+type KeyServiceServer interface {
+	RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error)
+	UploadPrekeys(context.Context, *UploadPrekeysRequest) (*UploadPrekeysResponse, error)
+	FetchBundles(context.Context, *FetchBundlesRequest) (*FetchBundlesResponse, error)
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	RemoveDevice(context.Context, *RemoveDeviceRequest) (*RemoveDeviceResponse, error)
+	EnableEncryption(context.Context, *EnableEncryptionRequest) (*EnableEncryptionResponse, error)
+	GetEncryption(context.Context, *GetEncryptionRequest) (*EncryptionState, error)
+	mustEmbedUnimplementedKeyServiceServer()
+}
+
+// UnimplementedKeyServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedKeyServiceServer struct {
+}
+
+func (UnimplementedKeyServiceServer) RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDevice not implemented")
+}
+func (UnimplementedKeyServiceServer) UploadPrekeys(context.Context, *UploadPrekeysRequest) (*UploadPrekeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadPrekeys not implemented")
+}
+func (UnimplementedKeyServiceServer) FetchBundles(context.Context, *FetchBundlesRequest) (*FetchBundlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchBundles not implemented")
+}
+func (UnimplementedKeyServiceServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedKeyServiceServer) RemoveDevice(context.Context, *RemoveDeviceRequest) (*RemoveDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveDevice not implemented")
+}
+func (UnimplementedKeyServiceServer) EnableEncryption(context.Context, *EnableEncryptionRequest) (*EnableEncryptionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableEncryption not implemented")
+}
+func (UnimplementedKeyServiceServer) GetEncryption(context.Context, *GetEncryptionRequest) (*EncryptionState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEncryption not implemented")
+}
+func (UnimplementedKeyServiceServer) mustEmbedUnimplementedKeyServiceServer() {}
+
+// UnsafeKeyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KeyServiceServer will
+// result in compilation errors.
+type UnsafeKeyServiceServer interface {
+	mustEmbedUnimplementedKeyServiceServer()
+}
+
+func RegisterKeyServiceServer(s grpc.ServiceRegistrar, srv KeyServiceServer) {
+	s.RegisterService(&KeyService_ServiceDesc, srv)
+}
+
+func _KeyService_RegisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).RegisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/RegisterDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).RegisterDevice(ctx, req.(*RegisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_UploadPrekeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadPrekeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).UploadPrekeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/UploadPrekeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).UploadPrekeys(ctx, req.(*UploadPrekeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_FetchBundles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchBundlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).FetchBundles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/FetchBundles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).FetchBundles(ctx, req.(*FetchBundlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/ListDevices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_RemoveDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).RemoveDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/RemoveDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).RemoveDevice(ctx, req.(*RemoveDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_EnableEncryption_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableEncryptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).EnableEncryption(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/EnableEncryption",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).EnableEncryption(ctx, req.(*EnableEncryptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyService_GetEncryption_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEncryptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyServiceServer).GetEncryption(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.KeyService/GetEncryption",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyServiceServer).GetEncryption(ctx, req.(*GetEncryptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// KeyService_ServiceDesc is the grpc.ServiceDesc for KeyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KeyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "message.v1.KeyService",
+	HandlerType: (*KeyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterDevice",
+			Handler:    _KeyService_RegisterDevice_Handler,
+		},
+		{
+			MethodName: "UploadPrekeys",
+			Handler:    _KeyService_UploadPrekeys_Handler,
+		},
+		{
+			MethodName: "FetchBundles",
+			Handler:    _KeyService_FetchBundles_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _KeyService_ListDevices_Handler,
+		},
+		{
+			MethodName: "RemoveDevice",
+			Handler:    _KeyService_RemoveDevice_Handler,
+		},
+		{
+			MethodName: "EnableEncryption",
+			Handler:    _KeyService_EnableEncryption_Handler,
+		},
+		{
+			MethodName: "GetEncryption",
+			Handler:    _KeyService_GetEncryption_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "e2ee.proto",
+}
@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v6.31.1
+// source: receipt.proto
+
+package message
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ReceiptServiceClient is the client API for ReceiptService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReceiptServiceClient interface {
+	ReportRead(ctx context.Context, in *ReportReadRequest, opts ...grpc.CallOption) (*ReportReadResponse, error)
+	QueryReadReceipt(ctx context.Context, in *QueryReadReceiptRequest, opts ...grpc.CallOption) (*QueryReadReceiptResponse, error)
+	ReportDelivered(ctx context.Context, in *ReportDeliveredRequest, opts ...grpc.CallOption) (*ReportDeliveredResponse, error)
+	QueryDelivery(ctx context.Context, in *QueryDeliveryRequest, opts ...grpc.CallOption) (*QueryDeliveryResponse, error)
+}
+
+type receiptServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReceiptServiceClient(cc grpc.ClientConnInterface) ReceiptServiceClient {
+	return &receiptServiceClient{cc}
+}
+
+func (c *receiptServiceClient) ReportRead(ctx context.Context, in *ReportReadRequest, opts ...grpc.CallOption) (*ReportReadResponse, error) {
+	out := new(ReportReadResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.ReceiptService/ReportRead", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *receiptServiceClient) QueryReadReceipt(ctx context.Context, in *QueryReadReceiptRequest, opts ...grpc.CallOption) (*QueryReadReceiptResponse, error) {
+	out := new(QueryReadReceiptResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.ReceiptService/QueryReadReceipt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *receiptServiceClient) ReportDelivered(ctx context.Context, in *ReportDeliveredRequest, opts ...grpc.CallOption) (*ReportDeliveredResponse, error) {
+	out := new(ReportDeliveredResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.ReceiptService/ReportDelivered", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *receiptServiceClient) QueryDelivery(ctx context.Context, in *QueryDeliveryRequest, opts ...grpc.CallOption) (*QueryDeliveryResponse, error) {
+	out := new(QueryDeliveryResponse)
+	err := c.cc.Invoke(ctx, "/message.v1.ReceiptService/QueryDelivery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReceiptServiceServer is the server API for ReceiptService service.
+// All implementations must embed UnimplementedReceiptServiceServer
+// for forward compatibility
+type ReceiptServiceServer interface {
+	ReportRead(context.Context, *ReportReadRequest) (*ReportReadResponse, error)
+	QueryReadReceipt(context.Context, *QueryReadReceiptRequest) (*QueryReadReceiptResponse, error)
+	ReportDelivered(context.Context, *ReportDeliveredRequest) (*ReportDeliveredResponse, error)
+	QueryDelivery(context.Context, *QueryDeliveryRequest) (*QueryDeliveryResponse, error)
+	mustEmbedUnimplementedReceiptServiceServer()
+}
+
+// UnimplementedReceiptServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedReceiptServiceServer struct {
+}
+
+func (UnimplementedReceiptServiceServer) ReportRead(context.Context, *ReportReadRequest) (*ReportReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportRead not implemented")
+}
+func (UnimplementedReceiptServiceServer) QueryReadReceipt(context.Context, *QueryReadReceiptRequest) (*QueryReadReceiptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryReadReceipt not implemented")
+}
+func (UnimplementedReceiptServiceServer) ReportDelivered(context.Context, *ReportDeliveredRequest) (*ReportDeliveredResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportDelivered not implemented")
+}
+func (UnimplementedReceiptServiceServer) QueryDelivery(context.Context, *QueryDeliveryRequest) (*QueryDeliveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryDelivery not implemented")
+}
+func (UnimplementedReceiptServiceServer) mustEmbedUnimplementedReceiptServiceServer() {}
+
+// UnsafeReceiptServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReceiptServiceServer will
+// result in compilation errors.
+type UnsafeReceiptServiceServer interface {
+	mustEmbedUnimplementedReceiptServiceServer()
+}
+
+func RegisterReceiptServiceServer(s grpc.ServiceRegistrar, srv ReceiptServiceServer) {
+	s.RegisterService(&ReceiptService_ServiceDesc, srv)
+}
+
+func _ReceiptService_ReportRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiptServiceServer).ReportRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.ReceiptService/ReportRead",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiptServiceServer).ReportRead(ctx, req.(*ReportReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReceiptService_QueryReadReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryReadReceiptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiptServiceServer).QueryReadReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.ReceiptService/QueryReadReceipt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiptServiceServer).QueryReadReceipt(ctx, req.(*QueryReadReceiptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReceiptService_ReportDelivered_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportDeliveredRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiptServiceServer).ReportDelivered(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.ReceiptService/ReportDelivered",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiptServiceServer).ReportDelivered(ctx, req.(*ReportDeliveredRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReceiptService_QueryDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryDeliveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiptServiceServer).QueryDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/message.v1.ReceiptService/QueryDelivery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiptServiceServer).QueryDelivery(ctx, req.(*QueryDeliveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ReceiptService_ServiceDesc is the grpc.ServiceDesc for ReceiptService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReceiptService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "message.v1.ReceiptService",
+	HandlerType: (*ReceiptServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportRead",
+			Handler:    _ReceiptService_ReportRead_Handler,
+		},
+		{
+			MethodName: "QueryReadReceipt",
+			Handler:    _ReceiptService_QueryReadReceipt_Handler,
+		},
+		{
+			MethodName: "ReportDelivered",
+			Handler:    _ReceiptService_ReportDelivered_Handler,
+		},
+		{
+			MethodName: "QueryDelivery",
+			Handler:    _ReceiptService_QueryDelivery_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "receipt.proto",
+}
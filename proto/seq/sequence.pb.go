@@ -7,6 +7,7 @@
 package seq
 
 import (
+	proto "github.com/atoncooper/im/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -20,29 +21,641 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// SNOWFLAKE : signal生成的消息id, 41位时间戳 | 10位机器id | 12位序列号
+// CENTER    : center生成的id, 41位时间戳 | 4位业务 | 3位数据中心 | 6位机器id | 9位序列号
+type IdLayout int32
+
+const (
+	IdLayout_SNOWFLAKE IdLayout = 0
+	IdLayout_CENTER    IdLayout = 1
+)
+
+// Enum value maps for IdLayout.
+var (
+	IdLayout_name = map[int32]string{
+		0: "SNOWFLAKE",
+		1: "CENTER",
+	}
+	IdLayout_value = map[string]int32{
+		"SNOWFLAKE": 0,
+		"CENTER":    1,
+	}
+)
+
+func (x IdLayout) Enum() *IdLayout {
+	p := new(IdLayout)
+	*p = x
+	return p
+}
+
+func (x IdLayout) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (IdLayout) Descriptor() protoreflect.EnumDescriptor {
+	return file_sequence_proto_enumTypes[0].Descriptor()
+}
+
+func (IdLayout) Type() protoreflect.EnumType {
+	return &file_sequence_proto_enumTypes[0]
+}
+
+func (x IdLayout) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use IdLayout.Descriptor instead.
+func (IdLayout) EnumDescriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{0}
+}
+
 type Empty struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 }
 
-func (x *Empty) Reset() {
-	*x = Empty{}
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{0}
+}
+
+type MessageIdResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *MessageIdResponse) Reset() {
+	*x = MessageIdResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageIdResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageIdResponse) ProtoMessage() {}
+
+func (x *MessageIdResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageIdResponse.ProtoReflect.Descriptor instead.
+func (*MessageIdResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MessageIdResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// seq 按会话分配, 会话内所有参与者看到同一个顺序
+// 私聊会话为双方uid排序拼接 s:{较小uid}:{较大uid}, 群聊会话为 g:{receiver_id}
+type MessageSeqRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SenderId    string             `protobuf:"bytes,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	ReceiverId  string             `protobuf:"bytes,2,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"` // 私聊为对方uid, 群聊为群id
+	MachineId   string             `protobuf:"bytes,3,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	SessionType proto.SesstionType `protobuf:"varint,4,opt,name=session_type,json=sessionType,proto3,enum=message.v1.SesstionType" json:"session_type,omitempty"` // 未指定时按私聊处理
+}
+
+func (x *MessageSeqRequest) Reset() {
+	*x = MessageSeqRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageSeqRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageSeqRequest) ProtoMessage() {}
+
+func (x *MessageSeqRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageSeqRequest.ProtoReflect.Descriptor instead.
+func (*MessageSeqRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MessageSeqRequest) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *MessageSeqRequest) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *MessageSeqRequest) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+func (x *MessageSeqRequest) GetSessionType() proto.SesstionType {
+	if x != nil {
+		return x.SessionType
+	}
+	return proto.SesstionType(0)
+}
+
+type MessageResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq            int64  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	ConversationId string `protobuf:"bytes,2,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+}
+
+func (x *MessageResponse) Reset() {
+	*x = MessageResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageResponse) ProtoMessage() {}
+
+func (x *MessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageResponse.ProtoReflect.Descriptor instead.
+func (*MessageResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MessageResponse) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *MessageResponse) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+type MessageIdsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count int32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *MessageIdsRequest) Reset() {
+	*x = MessageIdsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageIdsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageIdsRequest) ProtoMessage() {}
+
+func (x *MessageIdsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageIdsRequest.ProtoReflect.Descriptor instead.
+func (*MessageIdsRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MessageIdsRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type MessageIdsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ids []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+}
+
+func (x *MessageIdsResponse) Reset() {
+	*x = MessageIdsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageIdsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageIdsResponse) ProtoMessage() {}
+
+func (x *MessageIdsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageIdsResponse.ProtoReflect.Descriptor instead.
+func (*MessageIdsResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MessageIdsResponse) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+// 一次为会话分配 count 个seq, 结果递增, 同一批次中的seq之间不会插入其他消息
+type MessageSeqsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SenderId    string             `protobuf:"bytes,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	ReceiverId  string             `protobuf:"bytes,2,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	SessionType proto.SesstionType `protobuf:"varint,3,opt,name=session_type,json=sessionType,proto3,enum=message.v1.SesstionType" json:"session_type,omitempty"`
+	Count       int32              `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *MessageSeqsRequest) Reset() {
+	*x = MessageSeqsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageSeqsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageSeqsRequest) ProtoMessage() {}
+
+func (x *MessageSeqsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageSeqsRequest.ProtoReflect.Descriptor instead.
+func (*MessageSeqsRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MessageSeqsRequest) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *MessageSeqsRequest) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *MessageSeqsRequest) GetSessionType() proto.SesstionType {
+	if x != nil {
+		return x.SessionType
+	}
+	return proto.SesstionType(0)
+}
+
+func (x *MessageSeqsRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type MessageSeqsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seqs           []int64 `protobuf:"varint,1,rep,packed,name=seqs,proto3" json:"seqs,omitempty"`
+	ConversationId string  `protobuf:"bytes,2,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+}
+
+func (x *MessageSeqsResponse) Reset() {
+	*x = MessageSeqsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageSeqsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageSeqsResponse) ProtoMessage() {}
+
+func (x *MessageSeqsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageSeqsResponse.ProtoReflect.Descriptor instead.
+func (*MessageSeqsResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MessageSeqsResponse) GetSeqs() []int64 {
+	if x != nil {
+		return x.Seqs
+	}
+	return nil
+}
+
+func (x *MessageSeqsResponse) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+// conversation 不为空时同时为该会话分配seq, 与id一一对应
+type StreamMessageIdsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count        int64              `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	BatchSize    int32              `protobuf:"varint,2,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"` // 每批的数量, 不超过服务端的单次上限
+	Conversation *MessageSeqRequest `protobuf:"bytes,3,opt,name=conversation,proto3" json:"conversation,omitempty"`
+}
+
+func (x *StreamMessageIdsRequest) Reset() {
+	*x = StreamMessageIdsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamMessageIdsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMessageIdsRequest) ProtoMessage() {}
+
+func (x *StreamMessageIdsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMessageIdsRequest.ProtoReflect.Descriptor instead.
+func (*StreamMessageIdsRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamMessageIdsRequest) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *StreamMessageIdsRequest) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *StreamMessageIdsRequest) GetConversation() *MessageSeqRequest {
+	if x != nil {
+		return x.Conversation
+	}
+	return nil
+}
+
+type MessageIdsBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ids            []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+	Seqs           []int64  `protobuf:"varint,2,rep,packed,name=seqs,proto3" json:"seqs,omitempty"`
+	ConversationId string   `protobuf:"bytes,3,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+}
+
+func (x *MessageIdsBatch) Reset() {
+	*x = MessageIdsBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageIdsBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageIdsBatch) ProtoMessage() {}
+
+func (x *MessageIdsBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageIdsBatch.ProtoReflect.Descriptor instead.
+func (*MessageIdsBatch) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MessageIdsBatch) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *MessageIdsBatch) GetSeqs() []int64 {
+	if x != nil {
+		return x.Seqs
+	}
+	return nil
+}
+
+func (x *MessageIdsBatch) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+// 一个事件投递给多个用户时, 一次请求为每个用户分配一个inbox seq
+type InboxSeqsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uids []string `protobuf:"bytes,1,rep,name=uids,proto3" json:"uids,omitempty"`
+}
+
+func (x *InboxSeqsRequest) Reset() {
+	*x = InboxSeqsRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sequence_proto_msgTypes[0]
+		mi := &file_sequence_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Empty) String() string {
+func (x *InboxSeqsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Empty) ProtoMessage() {}
+func (*InboxSeqsRequest) ProtoMessage() {}
 
-func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_sequence_proto_msgTypes[0]
+func (x *InboxSeqsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -53,36 +666,44 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
-func (*Empty) Descriptor() ([]byte, []int) {
-	return file_sequence_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use InboxSeqsRequest.ProtoReflect.Descriptor instead.
+func (*InboxSeqsRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{10}
 }
 
-type MessageIdResponse struct {
+func (x *InboxSeqsRequest) GetUids() []string {
+	if x != nil {
+		return x.Uids
+	}
+	return nil
+}
+
+type InboxSeq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Uid string `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Seq int64  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
 }
 
-func (x *MessageIdResponse) Reset() {
-	*x = MessageIdResponse{}
+func (x *InboxSeq) Reset() {
+	*x = InboxSeq{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sequence_proto_msgTypes[1]
+		mi := &file_sequence_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *MessageIdResponse) String() string {
+func (x *InboxSeq) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageIdResponse) ProtoMessage() {}
+func (*InboxSeq) ProtoMessage() {}
 
-func (x *MessageIdResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sequence_proto_msgTypes[1]
+func (x *InboxSeq) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -93,45 +714,51 @@ func (x *MessageIdResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageIdResponse.ProtoReflect.Descriptor instead.
-func (*MessageIdResponse) Descriptor() ([]byte, []int) {
-	return file_sequence_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use InboxSeq.ProtoReflect.Descriptor instead.
+func (*InboxSeq) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *MessageIdResponse) GetId() string {
+func (x *InboxSeq) GetUid() string {
 	if x != nil {
-		return x.Id
+		return x.Uid
 	}
 	return ""
 }
 
-type MessageSeqRequest struct {
+func (x *InboxSeq) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+// 与请求中的uid一一对应
+type InboxSeqsResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	SenderId   string `protobuf:"bytes,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
-	ReceiverId string `protobuf:"bytes,2,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
-	MachineId  string `protobuf:"bytes,3,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Seqs []*InboxSeq `protobuf:"bytes,1,rep,name=seqs,proto3" json:"seqs,omitempty"`
 }
 
-func (x *MessageSeqRequest) Reset() {
-	*x = MessageSeqRequest{}
+func (x *InboxSeqsResponse) Reset() {
+	*x = InboxSeqsResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sequence_proto_msgTypes[2]
+		mi := &file_sequence_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *MessageSeqRequest) String() string {
+func (x *InboxSeqsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageSeqRequest) ProtoMessage() {}
+func (*InboxSeqsResponse) ProtoMessage() {}
 
-func (x *MessageSeqRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sequence_proto_msgTypes[2]
+func (x *InboxSeqsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -142,57 +769,108 @@ func (x *MessageSeqRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageSeqRequest.ProtoReflect.Descriptor instead.
-func (*MessageSeqRequest) Descriptor() ([]byte, []int) {
-	return file_sequence_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use InboxSeqsResponse.ProtoReflect.Descriptor instead.
+func (*InboxSeqsResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *MessageSeqRequest) GetSenderId() string {
+func (x *InboxSeqsResponse) GetSeqs() []*InboxSeq {
 	if x != nil {
-		return x.SenderId
+		return x.Seqs
 	}
-	return ""
+	return nil
 }
 
-func (x *MessageSeqRequest) GetReceiverId() string {
+type DecodeMessageIdRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Layout IdLayout `protobuf:"varint,2,opt,name=layout,proto3,enum=seq.v1.IdLayout" json:"layout,omitempty"`
+}
+
+func (x *DecodeMessageIdRequest) Reset() {
+	*x = DecodeMessageIdRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sequence_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DecodeMessageIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeMessageIdRequest) ProtoMessage() {}
+
+func (x *DecodeMessageIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeMessageIdRequest.ProtoReflect.Descriptor instead.
+func (*DecodeMessageIdRequest) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DecodeMessageIdRequest) GetId() string {
 	if x != nil {
-		return x.ReceiverId
+		return x.Id
 	}
 	return ""
 }
 
-func (x *MessageSeqRequest) GetMachineId() string {
+func (x *DecodeMessageIdRequest) GetLayout() IdLayout {
 	if x != nil {
-		return x.MachineId
+		return x.Layout
 	}
-	return ""
+	return IdLayout_SNOWFLAKE
 }
 
-type MessageResponse struct {
+// 布局中没有的部分为0, borrowed 表示时钟回拨时从预留的序列号空间分配
+// 雪花布局预留借用空间之前生成的id, 序列号 >= 2048 时 borrowed 同样为 true
+type DecodeMessageIdResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Seq int64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Id         string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Layout     IdLayout `protobuf:"varint,2,opt,name=layout,proto3,enum=seq.v1.IdLayout" json:"layout,omitempty"`
+	Timestamp  int64    `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // 毫秒
+	Time       string   `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`            // RFC3339, UTC
+	Biz        int64    `protobuf:"varint,5,opt,name=biz,proto3" json:"biz,omitempty"`
+	Datacenter int64    `protobuf:"varint,6,opt,name=datacenter,proto3" json:"datacenter,omitempty"`
+	MachineId  int64    `protobuf:"varint,7,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`
+	Sequence   int64    `protobuf:"varint,8,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Borrowed   bool     `protobuf:"varint,9,opt,name=borrowed,proto3" json:"borrowed,omitempty"`
 }
 
-func (x *MessageResponse) Reset() {
-	*x = MessageResponse{}
+func (x *DecodeMessageIdResponse) Reset() {
+	*x = DecodeMessageIdResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sequence_proto_msgTypes[3]
+		mi := &file_sequence_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *MessageResponse) String() string {
+func (x *DecodeMessageIdResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageResponse) ProtoMessage() {}
+func (*DecodeMessageIdResponse) ProtoMessage() {}
 
-func (x *MessageResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sequence_proto_msgTypes[3]
+func (x *DecodeMessageIdResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sequence_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -203,46 +881,204 @@ func (x *MessageResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageResponse.ProtoReflect.Descriptor instead.
-func (*MessageResponse) Descriptor() ([]byte, []int) {
-	return file_sequence_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use DecodeMessageIdResponse.ProtoReflect.Descriptor instead.
+func (*DecodeMessageIdResponse) Descriptor() ([]byte, []int) {
+	return file_sequence_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *MessageResponse) GetSeq() int64 {
+func (x *DecodeMessageIdResponse) GetId() string {
 	if x != nil {
-		return x.Seq
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DecodeMessageIdResponse) GetLayout() IdLayout {
+	if x != nil {
+		return x.Layout
+	}
+	return IdLayout_SNOWFLAKE
+}
+
+func (x *DecodeMessageIdResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *DecodeMessageIdResponse) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *DecodeMessageIdResponse) GetBiz() int64 {
+	if x != nil {
+		return x.Biz
+	}
+	return 0
+}
+
+func (x *DecodeMessageIdResponse) GetDatacenter() int64 {
+	if x != nil {
+		return x.Datacenter
+	}
+	return 0
+}
+
+func (x *DecodeMessageIdResponse) GetMachineId() int64 {
+	if x != nil {
+		return x.MachineId
+	}
+	return 0
+}
+
+func (x *DecodeMessageIdResponse) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
 	}
 	return 0
 }
 
+func (x *DecodeMessageIdResponse) GetBorrowed() bool {
+	if x != nil {
+		return x.Borrowed
+	}
+	return false
+}
+
 var File_sequence_proto protoreflect.FileDescriptor
 
 var file_sequence_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x06, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x22, 0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74,
-	0x79, 0x22, 0x23, 0x0a, 0x11, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x70, 0x0a, 0x11, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x12, 0x06, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x1a, 0x0d, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74, 0x79,
+	0x22, 0x23, 0x0a, 0x11, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0xad, 0x01, 0x0a, 0x11, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
 	0x65, 0x53, 0x65, 0x71, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73,
 	0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
 	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x63, 0x65,
 	0x69, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72,
 	0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63,
 	0x68, 0x69, 0x6e, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6d,
-	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64, 0x22, 0x23, 0x0a, 0x0f, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73,
-	0x65, 0x71, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x32, 0x9a, 0x01,
-	0x0a, 0x0f, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
-	0x65, 0x12, 0x3d, 0x0a, 0x11, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x0d, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e,
-	0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x19, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x12, 0x48, 0x0a, 0x12, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x12, 0x19, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x17, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x07, 0x5a, 0x05, 0x2e, 0x2f,
-	0x73, 0x65, 0x71, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x49, 0x64, 0x12, 0x3b, 0x0a, 0x0c, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18,
+	0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x73, 0x73,
+	0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x54, 0x79, 0x70, 0x65, 0x22, 0x4c, 0x0a, 0x0f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f,
+	0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x49, 0x64, 0x22, 0x29, 0x0a, 0x11, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x26,
+	0x0a, 0x12, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x03, 0x69, 0x64, 0x73, 0x22, 0xa5, 0x01, 0x0a, 0x12, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a,
+	0x09, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65,
+	0x63, 0x65, 0x69, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x72, 0x49, 0x64, 0x12, 0x3b, 0x0a, 0x0c, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x18, 0x2e, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53,
+	0x65, 0x73, 0x73, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x73, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x52,
+	0x0a, 0x13, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x71, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x03, 0x52, 0x04, 0x73, 0x65, 0x71, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e,
+	0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x49, 0x64, 0x22, 0x8d, 0x01, 0x0a, 0x17, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x3d, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x73, 0x65, 0x71, 0x2e,
+	0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x52, 0x0c, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x22, 0x60, 0x0a, 0x0f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x73,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x03, 0x69, 0x64, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x71, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x03, 0x52, 0x04, 0x73, 0x65, 0x71, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x63,
+	0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x49, 0x64, 0x22, 0x26, 0x0a, 0x10, 0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65, 0x71,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x69, 0x64, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x75, 0x69, 0x64, 0x73, 0x22, 0x2e, 0x0a, 0x08,
+	0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65, 0x71, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65,
+	0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x73, 0x65, 0x71, 0x22, 0x39, 0x0a, 0x11,
+	0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x24, 0x0a, 0x04, 0x73, 0x65, 0x71, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65,
+	0x71, 0x52, 0x04, 0x73, 0x65, 0x71, 0x73, 0x22, 0x52, 0x0a, 0x16, 0x44, 0x65, 0x63, 0x6f, 0x64,
+	0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x28, 0x0a, 0x06, 0x6c, 0x61, 0x79, 0x6f, 0x75, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x10, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x4c, 0x61, 0x79,
+	0x6f, 0x75, 0x74, 0x52, 0x06, 0x6c, 0x61, 0x79, 0x6f, 0x75, 0x74, 0x22, 0x8e, 0x02, 0x0a, 0x17,
+	0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x28, 0x0a, 0x06, 0x6c, 0x61, 0x79, 0x6f, 0x75,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31,
+	0x2e, 0x49, 0x64, 0x4c, 0x61, 0x79, 0x6f, 0x75, 0x74, 0x52, 0x06, 0x6c, 0x61, 0x79, 0x6f, 0x75,
+	0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x62, 0x69, 0x7a, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x03, 0x62, 0x69, 0x7a, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x61, 0x74, 0x61, 0x63, 0x65, 0x6e,
+	0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x64, 0x61, 0x74, 0x61, 0x63,
+	0x65, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6d, 0x61, 0x63, 0x68, 0x69,
+	0x6e, 0x65, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x12, 0x1a, 0x0a, 0x08, 0x62, 0x6f, 0x72, 0x72, 0x6f, 0x77, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x08, 0x62, 0x6f, 0x72, 0x72, 0x6f, 0x77, 0x65, 0x64, 0x2a, 0x25, 0x0a, 0x08,
+	0x49, 0x64, 0x4c, 0x61, 0x79, 0x6f, 0x75, 0x74, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x4e, 0x4f, 0x57,
+	0x46, 0x4c, 0x41, 0x4b, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x45, 0x4e, 0x54, 0x45,
+	0x52, 0x10, 0x01, 0x32, 0xa5, 0x04, 0x0a, 0x0f, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3d, 0x0a, 0x11, 0x47, 0x65, 0x6e, 0x65, 0x72,
+	0x61, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x0d, 0x2e, 0x73,
+	0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x19, 0x2e, 0x73, 0x65,
+	0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x48, 0x0a, 0x12, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61,
+	0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x12, 0x19, 0x2e, 0x73,
+	0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31,
+	0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x4b, 0x0a, 0x12, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x12, 0x19, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1a, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x49, 0x64, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4e, 0x0a,
+	0x13, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x53, 0x65, 0x71, 0x73, 0x12, 0x1a, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1b, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4e, 0x0a,
+	0x10, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64,
+	0x73, 0x12, 0x1f, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x17, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x49, 0x64, 0x73, 0x42, 0x61, 0x74, 0x63, 0x68, 0x30, 0x01, 0x12, 0x48, 0x0a,
+	0x11, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65,
+	0x71, 0x73, 0x12, 0x18, 0x2e, 0x73, 0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x62, 0x6f,
+	0x78, 0x53, 0x65, 0x71, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x73,
+	0x65, 0x71, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x62, 0x6f, 0x78, 0x53, 0x65, 0x71, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x52, 0x0a, 0x0f, 0x44, 0x65, 0x63, 0x6f, 0x64,
+	0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x1e, 0x2e, 0x73, 0x65, 0x71,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x49, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x73, 0x65, 0x71,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x49, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x07, 0x5a, 0x05, 0x2e,
+	0x2f, 0x73, 0x65, 0x71, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -257,23 +1093,53 @@ func file_sequence_proto_rawDescGZIP() []byte {
 	return file_sequence_proto_rawDescData
 }
 
-var file_sequence_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sequence_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sequence_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_sequence_proto_goTypes = []interface{}{
-	(*Empty)(nil),             // 0: seq.v1.Empty
-	(*MessageIdResponse)(nil), // 1: seq.v1.MessageIdResponse
-	(*MessageSeqRequest)(nil), // 2: seq.v1.MessageSeqRequest
-	(*MessageResponse)(nil),   // 3: seq.v1.MessageResponse
+	(IdLayout)(0),                   // 0: seq.v1.IdLayout
+	(*Empty)(nil),                   // 1: seq.v1.Empty
+	(*MessageIdResponse)(nil),       // 2: seq.v1.MessageIdResponse
+	(*MessageSeqRequest)(nil),       // 3: seq.v1.MessageSeqRequest
+	(*MessageResponse)(nil),         // 4: seq.v1.MessageResponse
+	(*MessageIdsRequest)(nil),       // 5: seq.v1.MessageIdsRequest
+	(*MessageIdsResponse)(nil),      // 6: seq.v1.MessageIdsResponse
+	(*MessageSeqsRequest)(nil),      // 7: seq.v1.MessageSeqsRequest
+	(*MessageSeqsResponse)(nil),     // 8: seq.v1.MessageSeqsResponse
+	(*StreamMessageIdsRequest)(nil), // 9: seq.v1.StreamMessageIdsRequest
+	(*MessageIdsBatch)(nil),         // 10: seq.v1.MessageIdsBatch
+	(*InboxSeqsRequest)(nil),        // 11: seq.v1.InboxSeqsRequest
+	(*InboxSeq)(nil),                // 12: seq.v1.InboxSeq
+	(*InboxSeqsResponse)(nil),       // 13: seq.v1.InboxSeqsResponse
+	(*DecodeMessageIdRequest)(nil),  // 14: seq.v1.DecodeMessageIdRequest
+	(*DecodeMessageIdResponse)(nil), // 15: seq.v1.DecodeMessageIdResponse
+	(proto.SesstionType)(0),         // 16: message.v1.SesstionType
 }
 var file_sequence_proto_depIdxs = []int32{
-	0, // 0: seq.v1.SequenceService.GenerateMessageId:input_type -> seq.v1.Empty
-	2, // 1: seq.v1.SequenceService.GenerateMessageSeq:input_type -> seq.v1.MessageSeqRequest
-	1, // 2: seq.v1.SequenceService.GenerateMessageId:output_type -> seq.v1.MessageIdResponse
-	3, // 3: seq.v1.SequenceService.GenerateMessageSeq:output_type -> seq.v1.MessageResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	16, // 0: seq.v1.MessageSeqRequest.session_type:type_name -> message.v1.SesstionType
+	16, // 1: seq.v1.MessageSeqsRequest.session_type:type_name -> message.v1.SesstionType
+	3,  // 2: seq.v1.StreamMessageIdsRequest.conversation:type_name -> seq.v1.MessageSeqRequest
+	12, // 3: seq.v1.InboxSeqsResponse.seqs:type_name -> seq.v1.InboxSeq
+	0,  // 4: seq.v1.DecodeMessageIdRequest.layout:type_name -> seq.v1.IdLayout
+	0,  // 5: seq.v1.DecodeMessageIdResponse.layout:type_name -> seq.v1.IdLayout
+	1,  // 6: seq.v1.SequenceService.GenerateMessageId:input_type -> seq.v1.Empty
+	3,  // 7: seq.v1.SequenceService.GenerateMessageSeq:input_type -> seq.v1.MessageSeqRequest
+	5,  // 8: seq.v1.SequenceService.GenerateMessageIds:input_type -> seq.v1.MessageIdsRequest
+	7,  // 9: seq.v1.SequenceService.GenerateMessageSeqs:input_type -> seq.v1.MessageSeqsRequest
+	9,  // 10: seq.v1.SequenceService.StreamMessageIds:input_type -> seq.v1.StreamMessageIdsRequest
+	11, // 11: seq.v1.SequenceService.GenerateInboxSeqs:input_type -> seq.v1.InboxSeqsRequest
+	14, // 12: seq.v1.SequenceService.DecodeMessageId:input_type -> seq.v1.DecodeMessageIdRequest
+	2,  // 13: seq.v1.SequenceService.GenerateMessageId:output_type -> seq.v1.MessageIdResponse
+	4,  // 14: seq.v1.SequenceService.GenerateMessageSeq:output_type -> seq.v1.MessageResponse
+	6,  // 15: seq.v1.SequenceService.GenerateMessageIds:output_type -> seq.v1.MessageIdsResponse
+	8,  // 16: seq.v1.SequenceService.GenerateMessageSeqs:output_type -> seq.v1.MessageSeqsResponse
+	10, // 17: seq.v1.SequenceService.StreamMessageIds:output_type -> seq.v1.MessageIdsBatch
+	13, // 18: seq.v1.SequenceService.GenerateInboxSeqs:output_type -> seq.v1.InboxSeqsResponse
+	15, // 19: seq.v1.SequenceService.DecodeMessageId:output_type -> seq.v1.DecodeMessageIdResponse
+	13, // [13:20] is the sub-list for method output_type
+	6,  // [6:13] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_sequence_proto_init() }
@@ -330,19 +1196,152 @@ func file_sequence_proto_init() {
 				return nil
 			}
 		}
+		file_sequence_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageIdsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageIdsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageSeqsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageSeqsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StreamMessageIdsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageIdsBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InboxSeqsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InboxSeq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InboxSeqsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DecodeMessageIdRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sequence_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DecodeMessageIdResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sequence_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_sequence_proto_goTypes,
 		DependencyIndexes: file_sequence_proto_depIdxs,
+		EnumInfos:         file_sequence_proto_enumTypes,
 		MessageInfos:      file_sequence_proto_msgTypes,
 	}.Build()
 	File_sequence_proto = out.File
@@ -24,6 +24,14 @@ const _ = grpc.SupportPackageIsVersion7
 type SequenceServiceClient interface {
 	GenerateMessageId(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*MessageIdResponse, error)
 	GenerateMessageSeq(ctx context.Context, in *MessageSeqRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	GenerateMessageIds(ctx context.Context, in *MessageIdsRequest, opts ...grpc.CallOption) (*MessageIdsResponse, error)
+	GenerateMessageSeqs(ctx context.Context, in *MessageSeqsRequest, opts ...grpc.CallOption) (*MessageSeqsResponse, error)
+	// 按批次持续返回, 适合批量导入, 超过调用方限额时放慢速度而不是失败
+	StreamMessageIds(ctx context.Context, in *StreamMessageIdsRequest, opts ...grpc.CallOption) (SequenceService_StreamMessageIdsClient, error)
+	// 为投递给用户的事件分配用户的inbox seq, 同一用户的inbox seq单调递增
+	GenerateInboxSeqs(ctx context.Context, in *InboxSeqsRequest, opts ...grpc.CallOption) (*InboxSeqsResponse, error)
+	// 解析消息id, 用于排查问题
+	DecodeMessageId(ctx context.Context, in *DecodeMessageIdRequest, opts ...grpc.CallOption) (*DecodeMessageIdResponse, error)
 }
 
 type sequenceServiceClient struct {
@@ -52,12 +60,88 @@ func (c *sequenceServiceClient) GenerateMessageSeq(ctx context.Context, in *Mess
 	return out, nil
 }
 
+func (c *sequenceServiceClient) GenerateMessageIds(ctx context.Context, in *MessageIdsRequest, opts ...grpc.CallOption) (*MessageIdsResponse, error) {
+	out := new(MessageIdsResponse)
+	err := c.cc.Invoke(ctx, "/seq.v1.SequenceService/GenerateMessageIds", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sequenceServiceClient) GenerateMessageSeqs(ctx context.Context, in *MessageSeqsRequest, opts ...grpc.CallOption) (*MessageSeqsResponse, error) {
+	out := new(MessageSeqsResponse)
+	err := c.cc.Invoke(ctx, "/seq.v1.SequenceService/GenerateMessageSeqs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sequenceServiceClient) StreamMessageIds(ctx context.Context, in *StreamMessageIdsRequest, opts ...grpc.CallOption) (SequenceService_StreamMessageIdsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SequenceService_ServiceDesc.Streams[0], "/seq.v1.SequenceService/StreamMessageIds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sequenceServiceStreamMessageIdsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SequenceService_StreamMessageIdsClient interface {
+	Recv() (*MessageIdsBatch, error)
+	grpc.ClientStream
+}
+
+type sequenceServiceStreamMessageIdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *sequenceServiceStreamMessageIdsClient) Recv() (*MessageIdsBatch, error) {
+	m := new(MessageIdsBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sequenceServiceClient) GenerateInboxSeqs(ctx context.Context, in *InboxSeqsRequest, opts ...grpc.CallOption) (*InboxSeqsResponse, error) {
+	out := new(InboxSeqsResponse)
+	err := c.cc.Invoke(ctx, "/seq.v1.SequenceService/GenerateInboxSeqs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sequenceServiceClient) DecodeMessageId(ctx context.Context, in *DecodeMessageIdRequest, opts ...grpc.CallOption) (*DecodeMessageIdResponse, error) {
+	out := new(DecodeMessageIdResponse)
+	err := c.cc.Invoke(ctx, "/seq.v1.SequenceService/DecodeMessageId", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SequenceServiceServer is the server API for SequenceService service.
 // All implementations must embed UnimplementedSequenceServiceServer
 // for forward compatibility
 type SequenceServiceServer interface {
 	GenerateMessageId(context.Context, *Empty) (*MessageIdResponse, error)
 	GenerateMessageSeq(context.Context, *MessageSeqRequest) (*MessageResponse, error)
+	GenerateMessageIds(context.Context, *MessageIdsRequest) (*MessageIdsResponse, error)
+	GenerateMessageSeqs(context.Context, *MessageSeqsRequest) (*MessageSeqsResponse, error)
+	// 按批次持续返回, 适合批量导入, 超过调用方限额时放慢速度而不是失败
+	StreamMessageIds(*StreamMessageIdsRequest, SequenceService_StreamMessageIdsServer) error
+	// 为投递给用户的事件分配用户的inbox seq, 同一用户的inbox seq单调递增
+	GenerateInboxSeqs(context.Context, *InboxSeqsRequest) (*InboxSeqsResponse, error)
+	// 解析消息id, 用于排查问题
+	DecodeMessageId(context.Context, *DecodeMessageIdRequest) (*DecodeMessageIdResponse, error)
 	mustEmbedUnimplementedSequenceServiceServer()
 }
 
@@ -71,6 +155,21 @@ func (UnimplementedSequenceServiceServer) GenerateMessageId(context.Context, *Em
 func (UnimplementedSequenceServiceServer) GenerateMessageSeq(context.Context, *MessageSeqRequest) (*MessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GenerateMessageSeq not implemented")
 }
+func (UnimplementedSequenceServiceServer) GenerateMessageIds(context.Context, *MessageIdsRequest) (*MessageIdsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateMessageIds not implemented")
+}
+func (UnimplementedSequenceServiceServer) GenerateMessageSeqs(context.Context, *MessageSeqsRequest) (*MessageSeqsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateMessageSeqs not implemented")
+}
+func (UnimplementedSequenceServiceServer) StreamMessageIds(*StreamMessageIdsRequest, SequenceService_StreamMessageIdsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMessageIds not implemented")
+}
+func (UnimplementedSequenceServiceServer) GenerateInboxSeqs(context.Context, *InboxSeqsRequest) (*InboxSeqsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateInboxSeqs not implemented")
+}
+func (UnimplementedSequenceServiceServer) DecodeMessageId(context.Context, *DecodeMessageIdRequest) (*DecodeMessageIdResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecodeMessageId not implemented")
+}
 func (UnimplementedSequenceServiceServer) mustEmbedUnimplementedSequenceServiceServer() {}
 
 // UnsafeSequenceServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +219,99 @@ func _SequenceService_GenerateMessageSeq_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SequenceService_GenerateMessageIds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageIdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServiceServer).GenerateMessageIds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/seq.v1.SequenceService/GenerateMessageIds",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServiceServer).GenerateMessageIds(ctx, req.(*MessageIdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SequenceService_GenerateMessageSeqs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageSeqsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServiceServer).GenerateMessageSeqs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/seq.v1.SequenceService/GenerateMessageSeqs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServiceServer).GenerateMessageSeqs(ctx, req.(*MessageSeqsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SequenceService_StreamMessageIds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMessageIdsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SequenceServiceServer).StreamMessageIds(m, &sequenceServiceStreamMessageIdsServer{stream})
+}
+
+type SequenceService_StreamMessageIdsServer interface {
+	Send(*MessageIdsBatch) error
+	grpc.ServerStream
+}
+
+type sequenceServiceStreamMessageIdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *sequenceServiceStreamMessageIdsServer) Send(m *MessageIdsBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SequenceService_GenerateInboxSeqs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InboxSeqsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServiceServer).GenerateInboxSeqs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/seq.v1.SequenceService/GenerateInboxSeqs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServiceServer).GenerateInboxSeqs(ctx, req.(*InboxSeqsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SequenceService_DecodeMessageId_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeMessageIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SequenceServiceServer).DecodeMessageId(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/seq.v1.SequenceService/DecodeMessageId",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SequenceServiceServer).DecodeMessageId(ctx, req.(*DecodeMessageIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SequenceService_ServiceDesc is the grpc.ServiceDesc for SequenceService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,7 +327,29 @@ var SequenceService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GenerateMessageSeq",
 			Handler:    _SequenceService_GenerateMessageSeq_Handler,
 		},
+		{
+			MethodName: "GenerateMessageIds",
+			Handler:    _SequenceService_GenerateMessageIds_Handler,
+		},
+		{
+			MethodName: "GenerateMessageSeqs",
+			Handler:    _SequenceService_GenerateMessageSeqs_Handler,
+		},
+		{
+			MethodName: "GenerateInboxSeqs",
+			Handler:    _SequenceService_GenerateInboxSeqs_Handler,
+		},
+		{
+			MethodName: "DecodeMessageId",
+			Handler:    _SequenceService_DecodeMessageId_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMessageIds",
+			Handler:       _SequenceService_StreamMessageIds_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "sequence.proto",
 }
@@ -0,0 +1,1121 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v6.31.1
+// source: storage.proto
+
+package storage
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SyncServiceClient is the client API for SyncService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SyncServiceClient interface {
+	PushOffline(ctx context.Context, in *PushOfflineRequest, opts ...grpc.CallOption) (*PushOfflineResponse, error)
+	SyncMessages(ctx context.Context, in *SyncMessagesRequest, opts ...grpc.CallOption) (*SyncMessagesResponse, error)
+	SyncInbox(ctx context.Context, in *SyncInboxRequest, opts ...grpc.CallOption) (*SyncInboxResponse, error)
+	AckInbox(ctx context.Context, in *AckInboxRequest, opts ...grpc.CallOption) (*AckInboxResponse, error)
+}
+
+type syncServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSyncServiceClient(cc grpc.ClientConnInterface) SyncServiceClient {
+	return &syncServiceClient{cc}
+}
+
+func (c *syncServiceClient) PushOffline(ctx context.Context, in *PushOfflineRequest, opts ...grpc.CallOption) (*PushOfflineResponse, error) {
+	out := new(PushOfflineResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.SyncService/PushOffline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *syncServiceClient) SyncMessages(ctx context.Context, in *SyncMessagesRequest, opts ...grpc.CallOption) (*SyncMessagesResponse, error) {
+	out := new(SyncMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.SyncService/SyncMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *syncServiceClient) SyncInbox(ctx context.Context, in *SyncInboxRequest, opts ...grpc.CallOption) (*SyncInboxResponse, error) {
+	out := new(SyncInboxResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.SyncService/SyncInbox", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *syncServiceClient) AckInbox(ctx context.Context, in *AckInboxRequest, opts ...grpc.CallOption) (*AckInboxResponse, error) {
+	out := new(AckInboxResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.SyncService/AckInbox", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SyncServiceServer is the server API for SyncService service.
+// All implementations must embed UnimplementedSyncServiceServer
+// for forward compatibility
+type SyncServiceServer interface {
+	PushOffline(context.Context, *PushOfflineRequest) (*PushOfflineResponse, error)
+	SyncMessages(context.Context, *SyncMessagesRequest) (*SyncMessagesResponse, error)
+	SyncInbox(context.Context, *SyncInboxRequest) (*SyncInboxResponse, error)
+	AckInbox(context.Context, *AckInboxRequest) (*AckInboxResponse, error)
+	mustEmbedUnimplementedSyncServiceServer()
+}
+
+// UnimplementedSyncServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSyncServiceServer struct {
+}
+
+func (UnimplementedSyncServiceServer) PushOffline(context.Context, *PushOfflineRequest) (*PushOfflineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushOffline not implemented")
+}
+func (UnimplementedSyncServiceServer) SyncMessages(context.Context, *SyncMessagesRequest) (*SyncMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncMessages not implemented")
+}
+func (UnimplementedSyncServiceServer) SyncInbox(context.Context, *SyncInboxRequest) (*SyncInboxResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncInbox not implemented")
+}
+func (UnimplementedSyncServiceServer) AckInbox(context.Context, *AckInboxRequest) (*AckInboxResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AckInbox not implemented")
+}
+func (UnimplementedSyncServiceServer) mustEmbedUnimplementedSyncServiceServer() {}
+
+// UnsafeSyncServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SyncServiceServer will
+// result in compilation errors.
+type UnsafeSyncServiceServer interface {
+	mustEmbedUnimplementedSyncServiceServer()
+}
+
+func RegisterSyncServiceServer(s grpc.ServiceRegistrar, srv SyncServiceServer) {
+	s.RegisterService(&SyncService_ServiceDesc, srv)
+}
+
+func _SyncService_PushOffline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushOfflineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).PushOffline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.SyncService/PushOffline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).PushOffline(ctx, req.(*PushOfflineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SyncService_SyncMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).SyncMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.SyncService/SyncMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).SyncMessages(ctx, req.(*SyncMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SyncService_SyncInbox_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncInboxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).SyncInbox(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.SyncService/SyncInbox",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).SyncInbox(ctx, req.(*SyncInboxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SyncService_AckInbox_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckInboxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).AckInbox(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.SyncService/AckInbox",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).AckInbox(ctx, req.(*AckInboxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SyncService_ServiceDesc is the grpc.ServiceDesc for SyncService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SyncService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.SyncService",
+	HandlerType: (*SyncServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PushOffline",
+			Handler:    _SyncService_PushOffline_Handler,
+		},
+		{
+			MethodName: "SyncMessages",
+			Handler:    _SyncService_SyncMessages_Handler,
+		},
+		{
+			MethodName: "SyncInbox",
+			Handler:    _SyncService_SyncInbox_Handler,
+		},
+		{
+			MethodName: "AckInbox",
+			Handler:    _SyncService_AckInbox_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
+
+// HistoryServiceClient is the client API for HistoryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HistoryServiceClient interface {
+	SaveMessages(ctx context.Context, in *SaveMessagesRequest, opts ...grpc.CallOption) (*SaveMessagesResponse, error)
+	QueryBySeq(ctx context.Context, in *QueryBySeqRequest, opts ...grpc.CallOption) (*QueryMessagesResponse, error)
+	QueryByTime(ctx context.Context, in *QueryByTimeRequest, opts ...grpc.CallOption) (*QueryMessagesResponse, error)
+	GetMessages(ctx context.Context, in *GetMessagesRequest, opts ...grpc.CallOption) (*GetMessagesResponse, error)
+	RecallMessage(ctx context.Context, in *RecallMessageRequest, opts ...grpc.CallOption) (*RecallMessageResponse, error)
+	// DeleteMessages 物理删除消息, 供center等内部服务调用, 不校验用户权限
+	DeleteMessages(ctx context.Context, in *DeleteMessagesRequest, opts ...grpc.CallOption) (*DeleteMessagesResponse, error)
+}
+
+type historyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHistoryServiceClient(cc grpc.ClientConnInterface) HistoryServiceClient {
+	return &historyServiceClient{cc}
+}
+
+func (c *historyServiceClient) SaveMessages(ctx context.Context, in *SaveMessagesRequest, opts ...grpc.CallOption) (*SaveMessagesResponse, error) {
+	out := new(SaveMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/SaveMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *historyServiceClient) QueryBySeq(ctx context.Context, in *QueryBySeqRequest, opts ...grpc.CallOption) (*QueryMessagesResponse, error) {
+	out := new(QueryMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/QueryBySeq", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *historyServiceClient) QueryByTime(ctx context.Context, in *QueryByTimeRequest, opts ...grpc.CallOption) (*QueryMessagesResponse, error) {
+	out := new(QueryMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/QueryByTime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *historyServiceClient) GetMessages(ctx context.Context, in *GetMessagesRequest, opts ...grpc.CallOption) (*GetMessagesResponse, error) {
+	out := new(GetMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/GetMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *historyServiceClient) RecallMessage(ctx context.Context, in *RecallMessageRequest, opts ...grpc.CallOption) (*RecallMessageResponse, error) {
+	out := new(RecallMessageResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/RecallMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *historyServiceClient) DeleteMessages(ctx context.Context, in *DeleteMessagesRequest, opts ...grpc.CallOption) (*DeleteMessagesResponse, error) {
+	out := new(DeleteMessagesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.HistoryService/DeleteMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HistoryServiceServer is the server API for HistoryService service.
+// All implementations must embed UnimplementedHistoryServiceServer
+// for forward compatibility
+type HistoryServiceServer interface {
+	SaveMessages(context.Context, *SaveMessagesRequest) (*SaveMessagesResponse, error)
+	QueryBySeq(context.Context, *QueryBySeqRequest) (*QueryMessagesResponse, error)
+	QueryByTime(context.Context, *QueryByTimeRequest) (*QueryMessagesResponse, error)
+	GetMessages(context.Context, *GetMessagesRequest) (*GetMessagesResponse, error)
+	RecallMessage(context.Context, *RecallMessageRequest) (*RecallMessageResponse, error)
+	// DeleteMessages 物理删除消息, 供center等内部服务调用, 不校验用户权限
+	DeleteMessages(context.Context, *DeleteMessagesRequest) (*DeleteMessagesResponse, error)
+	mustEmbedUnimplementedHistoryServiceServer()
+}
+
+// UnimplementedHistoryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedHistoryServiceServer struct {
+}
+
+func (UnimplementedHistoryServiceServer) SaveMessages(context.Context, *SaveMessagesRequest) (*SaveMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveMessages not implemented")
+}
+func (UnimplementedHistoryServiceServer) QueryBySeq(context.Context, *QueryBySeqRequest) (*QueryMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryBySeq not implemented")
+}
+func (UnimplementedHistoryServiceServer) QueryByTime(context.Context, *QueryByTimeRequest) (*QueryMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryByTime not implemented")
+}
+func (UnimplementedHistoryServiceServer) GetMessages(context.Context, *GetMessagesRequest) (*GetMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessages not implemented")
+}
+func (UnimplementedHistoryServiceServer) RecallMessage(context.Context, *RecallMessageRequest) (*RecallMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecallMessage not implemented")
+}
+func (UnimplementedHistoryServiceServer) DeleteMessages(context.Context, *DeleteMessagesRequest) (*DeleteMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMessages not implemented")
+}
+func (UnimplementedHistoryServiceServer) mustEmbedUnimplementedHistoryServiceServer() {}
+
+// UnsafeHistoryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HistoryServiceServer will
+// result in compilation errors.
+type UnsafeHistoryServiceServer interface {
+	mustEmbedUnimplementedHistoryServiceServer()
+}
+
+func RegisterHistoryServiceServer(s grpc.ServiceRegistrar, srv HistoryServiceServer) {
+	s.RegisterService(&HistoryService_ServiceDesc, srv)
+}
+
+func _HistoryService_SaveMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).SaveMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/SaveMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).SaveMessages(ctx, req.(*SaveMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HistoryService_QueryBySeq_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryBySeqRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).QueryBySeq(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/QueryBySeq",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).QueryBySeq(ctx, req.(*QueryBySeqRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HistoryService_QueryByTime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryByTimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).QueryByTime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/QueryByTime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).QueryByTime(ctx, req.(*QueryByTimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HistoryService_GetMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).GetMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/GetMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).GetMessages(ctx, req.(*GetMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HistoryService_RecallMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecallMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).RecallMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/RecallMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).RecallMessage(ctx, req.(*RecallMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HistoryService_DeleteMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HistoryServiceServer).DeleteMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.HistoryService/DeleteMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HistoryServiceServer).DeleteMessages(ctx, req.(*DeleteMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HistoryService_ServiceDesc is the grpc.ServiceDesc for HistoryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HistoryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.HistoryService",
+	HandlerType: (*HistoryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SaveMessages",
+			Handler:    _HistoryService_SaveMessages_Handler,
+		},
+		{
+			MethodName: "QueryBySeq",
+			Handler:    _HistoryService_QueryBySeq_Handler,
+		},
+		{
+			MethodName: "QueryByTime",
+			Handler:    _HistoryService_QueryByTime_Handler,
+		},
+		{
+			MethodName: "GetMessages",
+			Handler:    _HistoryService_GetMessages_Handler,
+		},
+		{
+			MethodName: "RecallMessage",
+			Handler:    _HistoryService_RecallMessage_Handler,
+		},
+		{
+			MethodName: "DeleteMessages",
+			Handler:    _HistoryService_DeleteMessages_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
+
+// SearchServiceClient is the client API for SearchService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SearchServiceClient interface {
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+}
+
+type searchServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSearchServiceClient(cc grpc.ClientConnInterface) SearchServiceClient {
+	return &searchServiceClient{cc}
+}
+
+func (c *searchServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.SearchService/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchServiceServer is the server API for SearchService service.
+// All implementations must embed UnimplementedSearchServiceServer
+// for forward compatibility
+type SearchServiceServer interface {
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	mustEmbedUnimplementedSearchServiceServer()
+}
+
+// UnimplementedSearchServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSearchServiceServer struct {
+}
+
+func (UnimplementedSearchServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSearchServiceServer) mustEmbedUnimplementedSearchServiceServer() {}
+
+// UnsafeSearchServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SearchServiceServer will
+// result in compilation errors.
+type UnsafeSearchServiceServer interface {
+	mustEmbedUnimplementedSearchServiceServer()
+}
+
+func RegisterSearchServiceServer(s grpc.ServiceRegistrar, srv SearchServiceServer) {
+	s.RegisterService(&SearchService_ServiceDesc, srv)
+}
+
+func _SearchService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.SearchService/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SearchService_ServiceDesc is the grpc.ServiceDesc for SearchService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SearchService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.SearchService",
+	HandlerType: (*SearchServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _SearchService_Search_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
+
+// PrivacyServiceClient is the client API for PrivacyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PrivacyServiceClient interface {
+	// 导出用户的全部数据, 以zip分块返回
+	ExportUser(ctx context.Context, in *ExportUserRequest, opts ...grpc.CallOption) (PrivacyService_ExportUserClient, error)
+	EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*EraseUserResponse, error)
+}
+
+type privacyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrivacyServiceClient(cc grpc.ClientConnInterface) PrivacyServiceClient {
+	return &privacyServiceClient{cc}
+}
+
+func (c *privacyServiceClient) ExportUser(ctx context.Context, in *ExportUserRequest, opts ...grpc.CallOption) (PrivacyService_ExportUserClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PrivacyService_ServiceDesc.Streams[0], "/storage.v1.PrivacyService/ExportUser", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &privacyServiceExportUserClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PrivacyService_ExportUserClient interface {
+	Recv() (*ExportChunk, error)
+	grpc.ClientStream
+}
+
+type privacyServiceExportUserClient struct {
+	grpc.ClientStream
+}
+
+func (x *privacyServiceExportUserClient) Recv() (*ExportChunk, error) {
+	m := new(ExportChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *privacyServiceClient) EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*EraseUserResponse, error) {
+	out := new(EraseUserResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.PrivacyService/EraseUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PrivacyServiceServer is the server API for PrivacyService service.
+// All implementations must embed UnimplementedPrivacyServiceServer
+// for forward compatibility
+type PrivacyServiceServer interface {
+	// 导出用户的全部数据, 以zip分块返回
+	ExportUser(*ExportUserRequest, PrivacyService_ExportUserServer) error
+	EraseUser(context.Context, *EraseUserRequest) (*EraseUserResponse, error)
+	mustEmbedUnimplementedPrivacyServiceServer()
+}
+
+// UnimplementedPrivacyServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPrivacyServiceServer struct {
+}
+
+func (UnimplementedPrivacyServiceServer) ExportUser(*ExportUserRequest, PrivacyService_ExportUserServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportUser not implemented")
+}
+func (UnimplementedPrivacyServiceServer) EraseUser(context.Context, *EraseUserRequest) (*EraseUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EraseUser not implemented")
+}
+func (UnimplementedPrivacyServiceServer) mustEmbedUnimplementedPrivacyServiceServer() {}
+
+// UnsafePrivacyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrivacyServiceServer will
+// result in compilation errors.
+type UnsafePrivacyServiceServer interface {
+	mustEmbedUnimplementedPrivacyServiceServer()
+}
+
+func RegisterPrivacyServiceServer(s grpc.ServiceRegistrar, srv PrivacyServiceServer) {
+	s.RegisterService(&PrivacyService_ServiceDesc, srv)
+}
+
+func _PrivacyService_ExportUser_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportUserRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PrivacyServiceServer).ExportUser(m, &privacyServiceExportUserServer{stream})
+}
+
+type PrivacyService_ExportUserServer interface {
+	Send(*ExportChunk) error
+	grpc.ServerStream
+}
+
+type privacyServiceExportUserServer struct {
+	grpc.ServerStream
+}
+
+func (x *privacyServiceExportUserServer) Send(m *ExportChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PrivacyService_EraseUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EraseUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrivacyServiceServer).EraseUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.PrivacyService/EraseUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrivacyServiceServer).EraseUser(ctx, req.(*EraseUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PrivacyService_ServiceDesc is the grpc.ServiceDesc for PrivacyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PrivacyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.PrivacyService",
+	HandlerType: (*PrivacyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "EraseUser",
+			Handler:    _PrivacyService_EraseUser_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportUser",
+			Handler:       _PrivacyService_ExportUser_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "storage.proto",
+}
+
+// ArchiveServiceClient is the client API for ArchiveService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ArchiveServiceClient interface {
+	ListSegments(ctx context.Context, in *ListSegmentsRequest, opts ...grpc.CallOption) (*ListSegmentsResponse, error)
+	RestoreRange(ctx context.Context, in *RestoreRangeRequest, opts ...grpc.CallOption) (*RestoreRangeResponse, error)
+}
+
+type archiveServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewArchiveServiceClient(cc grpc.ClientConnInterface) ArchiveServiceClient {
+	return &archiveServiceClient{cc}
+}
+
+func (c *archiveServiceClient) ListSegments(ctx context.Context, in *ListSegmentsRequest, opts ...grpc.CallOption) (*ListSegmentsResponse, error) {
+	out := new(ListSegmentsResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.ArchiveService/ListSegments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *archiveServiceClient) RestoreRange(ctx context.Context, in *RestoreRangeRequest, opts ...grpc.CallOption) (*RestoreRangeResponse, error) {
+	out := new(RestoreRangeResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.ArchiveService/RestoreRange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ArchiveServiceServer is the server API for ArchiveService service.
+// All implementations must embed UnimplementedArchiveServiceServer
+// for forward compatibility
+type ArchiveServiceServer interface {
+	ListSegments(context.Context, *ListSegmentsRequest) (*ListSegmentsResponse, error)
+	RestoreRange(context.Context, *RestoreRangeRequest) (*RestoreRangeResponse, error)
+	mustEmbedUnimplementedArchiveServiceServer()
+}
+
+// UnimplementedArchiveServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedArchiveServiceServer struct {
+}
+
+func (UnimplementedArchiveServiceServer) ListSegments(context.Context, *ListSegmentsRequest) (*ListSegmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSegments not implemented")
+}
+func (UnimplementedArchiveServiceServer) RestoreRange(context.Context, *RestoreRangeRequest) (*RestoreRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreRange not implemented")
+}
+func (UnimplementedArchiveServiceServer) mustEmbedUnimplementedArchiveServiceServer() {}
+
+// UnsafeArchiveServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ArchiveServiceServer will
+// result in compilation errors.
+type UnsafeArchiveServiceServer interface {
+	mustEmbedUnimplementedArchiveServiceServer()
+}
+
+func RegisterArchiveServiceServer(s grpc.ServiceRegistrar, srv ArchiveServiceServer) {
+	s.RegisterService(&ArchiveService_ServiceDesc, srv)
+}
+
+func _ArchiveService_ListSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSegmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArchiveServiceServer).ListSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.ArchiveService/ListSegments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArchiveServiceServer).ListSegments(ctx, req.(*ListSegmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ArchiveService_RestoreRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArchiveServiceServer).RestoreRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.ArchiveService/RestoreRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArchiveServiceServer).RestoreRange(ctx, req.(*RestoreRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ArchiveService_ServiceDesc is the grpc.ServiceDesc for ArchiveService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ArchiveService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.ArchiveService",
+	HandlerType: (*ArchiveServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSegments",
+			Handler:    _ArchiveService_ListSegments_Handler,
+		},
+		{
+			MethodName: "RestoreRange",
+			Handler:    _ArchiveService_RestoreRange_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
+
+// CheckpointServiceClient is the client API for CheckpointService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CheckpointServiceClient interface {
+	SaveCheckpoints(ctx context.Context, in *SaveCheckpointsRequest, opts ...grpc.CallOption) (*SaveCheckpointsResponse, error)
+	GetSeqStates(ctx context.Context, in *GetSeqStatesRequest, opts ...grpc.CallOption) (*GetSeqStatesResponse, error)
+	ListCheckpoints(ctx context.Context, in *ListCheckpointsRequest, opts ...grpc.CallOption) (*ListCheckpointsResponse, error)
+}
+
+type checkpointServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCheckpointServiceClient(cc grpc.ClientConnInterface) CheckpointServiceClient {
+	return &checkpointServiceClient{cc}
+}
+
+func (c *checkpointServiceClient) SaveCheckpoints(ctx context.Context, in *SaveCheckpointsRequest, opts ...grpc.CallOption) (*SaveCheckpointsResponse, error) {
+	out := new(SaveCheckpointsResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.CheckpointService/SaveCheckpoints", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *checkpointServiceClient) GetSeqStates(ctx context.Context, in *GetSeqStatesRequest, opts ...grpc.CallOption) (*GetSeqStatesResponse, error) {
+	out := new(GetSeqStatesResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.CheckpointService/GetSeqStates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *checkpointServiceClient) ListCheckpoints(ctx context.Context, in *ListCheckpointsRequest, opts ...grpc.CallOption) (*ListCheckpointsResponse, error) {
+	out := new(ListCheckpointsResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.CheckpointService/ListCheckpoints", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckpointServiceServer is the server API for CheckpointService service.
+// All implementations must embed UnimplementedCheckpointServiceServer
+// for forward compatibility
+type CheckpointServiceServer interface {
+	SaveCheckpoints(context.Context, *SaveCheckpointsRequest) (*SaveCheckpointsResponse, error)
+	GetSeqStates(context.Context, *GetSeqStatesRequest) (*GetSeqStatesResponse, error)
+	ListCheckpoints(context.Context, *ListCheckpointsRequest) (*ListCheckpointsResponse, error)
+	mustEmbedUnimplementedCheckpointServiceServer()
+}
+
+// UnimplementedCheckpointServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCheckpointServiceServer struct {
+}
+
+func (UnimplementedCheckpointServiceServer) SaveCheckpoints(context.Context, *SaveCheckpointsRequest) (*SaveCheckpointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveCheckpoints not implemented")
+}
+func (UnimplementedCheckpointServiceServer) GetSeqStates(context.Context, *GetSeqStatesRequest) (*GetSeqStatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSeqStates not implemented")
+}
+func (UnimplementedCheckpointServiceServer) ListCheckpoints(context.Context, *ListCheckpointsRequest) (*ListCheckpointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCheckpoints not implemented")
+}
+func (UnimplementedCheckpointServiceServer) mustEmbedUnimplementedCheckpointServiceServer() {}
+
+// UnsafeCheckpointServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CheckpointServiceServer will
+// result in compilation errors.
+type UnsafeCheckpointServiceServer interface {
+	mustEmbedUnimplementedCheckpointServiceServer()
+}
+
+func RegisterCheckpointServiceServer(s grpc.ServiceRegistrar, srv CheckpointServiceServer) {
+	s.RegisterService(&CheckpointService_ServiceDesc, srv)
+}
+
+func _CheckpointService_SaveCheckpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveCheckpointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CheckpointServiceServer).SaveCheckpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.CheckpointService/SaveCheckpoints",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CheckpointServiceServer).SaveCheckpoints(ctx, req.(*SaveCheckpointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CheckpointService_GetSeqStates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSeqStatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CheckpointServiceServer).GetSeqStates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.CheckpointService/GetSeqStates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CheckpointServiceServer).GetSeqStates(ctx, req.(*GetSeqStatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CheckpointService_ListCheckpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCheckpointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CheckpointServiceServer).ListCheckpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.CheckpointService/ListCheckpoints",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CheckpointServiceServer).ListCheckpoints(ctx, req.(*ListCheckpointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CheckpointService_ServiceDesc is the grpc.ServiceDesc for CheckpointService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CheckpointService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.CheckpointService",
+	HandlerType: (*CheckpointServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SaveCheckpoints",
+			Handler:    _CheckpointService_SaveCheckpoints_Handler,
+		},
+		{
+			MethodName: "GetSeqStates",
+			Handler:    _CheckpointService_GetSeqStates_Handler,
+		},
+		{
+			MethodName: "ListCheckpoints",
+			Handler:    _CheckpointService_ListCheckpoints_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
+
+// TimelineServiceClient is the client API for TimelineService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TimelineServiceClient interface {
+	AppendEvents(ctx context.Context, in *AppendEventsRequest, opts ...grpc.CallOption) (*AppendEventsResponse, error)
+	SyncTimeline(ctx context.Context, in *SyncTimelineRequest, opts ...grpc.CallOption) (*SyncTimelineResponse, error)
+}
+
+type timelineServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTimelineServiceClient(cc grpc.ClientConnInterface) TimelineServiceClient {
+	return &timelineServiceClient{cc}
+}
+
+func (c *timelineServiceClient) AppendEvents(ctx context.Context, in *AppendEventsRequest, opts ...grpc.CallOption) (*AppendEventsResponse, error) {
+	out := new(AppendEventsResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.TimelineService/AppendEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timelineServiceClient) SyncTimeline(ctx context.Context, in *SyncTimelineRequest, opts ...grpc.CallOption) (*SyncTimelineResponse, error) {
+	out := new(SyncTimelineResponse)
+	err := c.cc.Invoke(ctx, "/storage.v1.TimelineService/SyncTimeline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TimelineServiceServer is the server API for TimelineService service.
+// All implementations must embed UnimplementedTimelineServiceServer
+// for forward compatibility
+type TimelineServiceServer interface {
+	AppendEvents(context.Context, *AppendEventsRequest) (*AppendEventsResponse, error)
+	SyncTimeline(context.Context, *SyncTimelineRequest) (*SyncTimelineResponse, error)
+	mustEmbedUnimplementedTimelineServiceServer()
+}
+
+// UnimplementedTimelineServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTimelineServiceServer struct {
+}
+
+func (UnimplementedTimelineServiceServer) AppendEvents(context.Context, *AppendEventsRequest) (*AppendEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AppendEvents not implemented")
+}
+func (UnimplementedTimelineServiceServer) SyncTimeline(context.Context, *SyncTimelineRequest) (*SyncTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncTimeline not implemented")
+}
+func (UnimplementedTimelineServiceServer) mustEmbedUnimplementedTimelineServiceServer() {}
+
+// UnsafeTimelineServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TimelineServiceServer will
+// result in compilation errors.
+type UnsafeTimelineServiceServer interface {
+	mustEmbedUnimplementedTimelineServiceServer()
+}
+
+func RegisterTimelineServiceServer(s grpc.ServiceRegistrar, srv TimelineServiceServer) {
+	s.RegisterService(&TimelineService_ServiceDesc, srv)
+}
+
+func _TimelineService_AppendEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimelineServiceServer).AppendEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.TimelineService/AppendEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimelineServiceServer).AppendEvents(ctx, req.(*AppendEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimelineService_SyncTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimelineServiceServer).SyncTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/storage.v1.TimelineService/SyncTimeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimelineServiceServer).SyncTimeline(ctx, req.(*SyncTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TimelineService_ServiceDesc is the grpc.ServiceDesc for TimelineService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TimelineService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.TimelineService",
+	HandlerType: (*TimelineServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AppendEvents",
+			Handler:    _TimelineService_AppendEvents_Handler,
+		},
+		{
+			MethodName: "SyncTimeline",
+			Handler:    _TimelineService_SyncTimeline_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
@@ -2,19 +2,34 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
+	"log"
+	"net"
+	"net/http"
 	"signal/configs"
 	"signal/services"
+	"strconv"
 	"time"
 
 	pb "github.com/atoncooper/im/proto/seq"
+	storagepb "github.com/atoncooper/im/proto/storage"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 )
 
-func init() {
+const (
+	SERVICE_NAME    = "signal"
+	STORAGE_SERVICE = "storage"
+)
+
+// 默认的优雅关闭等待时间
+const DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// loadConfig 读取 signal.yaml, 文件变化时重新加载
+func loadConfig() (*configs.Config, error) {
 	v := viper.New()
 
 	v.SetConfigName("signal")
@@ -28,28 +43,58 @@ func init() {
 	v.AutomaticEnv()
 
 	if err := v.ReadInConfig(); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	var c configs.Config
 
 	if err := v.Unmarshal(&c); err != nil {
-		panic(err)
+		return nil, err
 	}
 	configs.Cfg.Store(&c)
 
 	v.OnConfigChange(func(e fsnotify.Event) {
 		var newC configs.Config
 		if err := v.Unmarshal(&newC); err != nil {
-			panic(err)
+			log.Printf("[WARN] reload config: %v", err)
+			return
 		}
 		configs.Cfg.Store(&newC)
 	})
 	v.WatchConfig()
 
+	return &c, nil
+}
+
+// Run 初始化并启动signal, 阻塞直到ctx结束或gRPC服务异常退出
+//
+// 启动: 读取配置 -> redis -> consul -> 租用机器id -> 时钟 -> 从storage恢复计数器 -> 号段 -> gRPC服务 -> 注册consul
+// 停止: 从consul注销 -> 健康检查置为 NOT_SERVING 并优雅关闭gRPC服务 -> 持久化时间戳、释放号段、写入检查点 -> 停止续约 -> 释放机器id
+// 机器id被其他进程取得时健康检查立即置为 NOT_SERVING 并返回 ErrMachineLease, 由进程管理重启后租用新的机器id
+func Run(ctx context.Context) error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	app := &c.Application
+	if app.Name == "" {
+		app.Name = SERVICE_NAME
+	}
+	if app.NodeId == "" {
+		app.NodeId = fmt.Sprintf("%s-%s-%d", app.Name, app.Server.Host, app.Server.Port)
+	}
+	shutdownTimeout := app.Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
+
+	// 后台任务在服务完全停止后才结束, 不随ctx提前退出
+	bg, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+
 	// 初始化redis
 	redisCfg := configs.RedisConfig{
-		Addrs:    c.Application.Component.Redis.Nodes,
+		Addrs:    app.Component.Redis.Nodes,
 		Timeout:  10 * time.Second,
 		Password: "",
 		PoolSize: 10,
@@ -58,54 +103,189 @@ func init() {
 	redisCil := configs.NewRedisClient(&redisCfg)
 	configs.Client.Store(redisCil)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
+	pingCtx, pingCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer pingCancel()
 
-	if err := redisCil.Ping(ctx).Err(); err != nil {
-		panic(err)
+	if err := redisCil.Ping(pingCtx).Err(); err != nil {
+		return err
 	}
 
-	// 注册consul服务
-
-	client, err := configs.NewEnterpriseClient([]string{"127.0.0.1"}, "", "")
+	// 连接consul
+	consul := app.Component.Consul
+	consulClient, err := configs.NewConsulClient(&configs.ConsulConf{
+		Address: net.JoinHostPort(consul.Endpoint, strconv.Itoa(consul.Port)),
+		Scheme:  consul.Scheme,
+		Token:   consul.Token,
+		CAFile:  consul.CAFile,
+	})
 	if err != nil {
-		panic(err)
+		return err
 	}
-	consulClient := &configs.ConsulClient{Client: client}
 
-	// TODO : 注册服务
 	// 获取机器id
 	nodeID, err := consulClient.GetNodeID()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	var machineID int64
-	_, err = fmt.Sscanf(nodeID, "%x", &machineID)
+	// 机器id从redis租用, 同一节点优先使用由NodeID计算的id, 没有可用的id时拒绝启动
+	node := net.JoinHostPort(app.Server.Host, strconv.Itoa(app.Server.Port))
+	leaseCtx, leaseCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer leaseCancel()
+	lease, err := services.AcquireMachineId(leaseCtx, redisCil, node, int64(crc32.ChecksumIEEE([]byte(nodeID))), app.Machine.TTL)
 	if err != nil {
-		machineID = int64(crc32.ChecksumIEEE([]byte(nodeID))) % 1024
+		return err
 	}
+	log.Printf("[INFO] leased machine id %d", lease.Id())
+	renewCtx, stopRenew := context.WithCancel(context.Background())
+	renewDone := make(chan struct{})
+	go func() {
+		lease.Run(renewCtx)
+		close(renewDone)
+	}()
 
-	// 初始化生成器
+	// 之后启动失败时同样需要释放机器id, 先停止续约再释放
+	defer func() {
+		stopRenew()
+		<-renewDone
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := lease.Release(ctx); err != nil {
+			log.Printf("[WARN] release machine id %d: %v", lease.Id(), err)
+		}
+	}()
+
+	clockCtx, clockCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer clockCancel()
+	clock, err := services.NewClock(clockCtx, redisCil, lease, &services.ClockConfig{
+		Policy:          app.Clock.Policy,
+		MaxWait:         app.Clock.MaxWait,
+		MaxBorrow:       app.Clock.MaxBorrow,
+		PersistInterval: app.Clock.PersistInterval,
+	})
+	if err != nil {
+		return err
+	}
 
-	err = services.InitGenerator(machineID, redisCil)
+	// 监控指标, expvar 注册在 http.DefaultServeMux 的 /debug/vars
+	if app.Server.MetricsPort > 0 {
+		metrics := &http.Server{Addr: net.JoinHostPort(app.Server.Host, strconv.Itoa(app.Server.MetricsPort))}
+		go func() {
+			if err := metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("[WARN] metrics server: %v", err)
+			}
+		}()
+		defer metrics.Close()
+	}
+
+	// 连接storage, 恢复redis中丢失或回退的计数器后再对外服务
+	storageConn, err := services.DialService(bg, consulClient.Client, STORAGE_SERVICE)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer storageConn.Close()
+
+	checkpoints := services.NewCheckpointer(storagepb.NewCheckpointServiceClient(storageConn), redisCil, &services.CheckpointConfig{
+		Gap:      app.Seq.Gap,
+		Interval: app.Seq.CheckpointInterval,
+	})
+	recoverCtx, recoverCancel := context.WithTimeout(ctx, time.Minute)
+	defer recoverCancel()
+	if err := checkpoints.Recover(recoverCtx); err != nil {
+		return err
+	}
+	go checkpoints.Run(bg)
+
+	segments := services.NewSegmentAllocator(redisCil, &services.SegmentConfig{
+		Node:     node,
+		Step:     app.Seq.Step,
+		Lease:    app.Seq.Lease,
+		Prefetch: app.Seq.Prefetch,
+	})
+	go segments.Run(bg)
+
+	// 初始化生成器
+	if err := services.InitGenerator(lease, clock, redisCil, segments, checkpoints); err != nil {
+		return err
 	}
+	go services.GeneratorFactory().Run(bg)
+
+	// 停止时持久化消息id的时间戳, 释放会话的号段, 写入剩余的检查点
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		services.GeneratorFactory().Close(ctx)
+	}()
+
+	limiter := services.NewCallerLimiter(&services.LimitConfig{
+		MaxBatch: app.Limit.MaxBatch,
+		Rate:     app.Limit.Rate,
+		Burst:    app.Limit.Burst,
+	})
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-bg.Done():
+				return
+			case <-ticker.C:
+				limiter.Clean(10 * time.Minute)
+			}
+		}
+	}()
 
 	// 初始化grpc服务
 	gRPCcfg := services.GRPCConfig{
-		Host: c.Application.Server.Host,
-		Port: c.Application.Server.Port,
+		Host: app.Server.Host,
+		Port: app.Server.Port,
 	}
 
 	// 注册register服务
 	register := func(s *grpc.Server) {
 		pb.RegisterSequenceServiceServer(s, &services.ServerHandle{
-			Redis: configs.RedisTmeplate(),
+			Redis:   configs.RedisTmeplate(),
+			Limiter: limiter,
 		})
 	}
 
-	services.NewGRPCServer(&gRPCcfg, register)
+	if err := services.NewGRPCServer(&gRPCcfg, register); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		services.GRPCstop(ctx)
+	}()
+
+	// 注册consul服务, 停止时先注销, 让调用方不再发现本实例
+	if consul.Service.Register {
+		err = consulClient.RegisterService(app.NodeId, app.Name, app.Server.Host, app.Server.Port,
+			consul.Service.Tags, map[string]string{"machineId": strconv.FormatInt(lease.Id(), 10)},
+			consul.Service.DeregisterAfter)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := consulClient.DeregisterService(app.NodeId); err != nil {
+				log.Printf("[WARN] deregister %s: %v", app.NodeId, err)
+			}
+		}()
+	}
+	log.Printf("[INFO] signal %s serving on %s", app.NodeId, node)
 
+	select {
+	case <-ctx.Done():
+		log.Println("[INFO] signal server stopping...")
+		return nil
+	case err := <-services.GRPCErr():
+		if err == nil || errors.Is(err, grpc.ErrServerStopped) {
+			return nil
+		}
+		return err
+	case <-lease.Lost():
+		log.Printf("[ERROR] machine id %d leased by another process, stop serving", lease.Id())
+		services.GRPCNotServing()
+		return services.ErrMachineLease
+	}
 }
@@ -2,21 +2,75 @@ package configs
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 type Config struct {
 	Application struct {
+		// 注册到consul的服务名与实例id, nodeId 为空时取 name-host-port
+		Name   string `mapstructure:"name"`
+		NodeId string `mapstructure:"nodeId"`
+
 		Server struct {
 			Host    string `mapstructure:"host"`
 			Port    int    `mapstructure:"port"`
 			Timeout int    `mapstructure:"timeout"`
+			// 优雅关闭的最长等待时间, 超时后强制关闭
+			ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+			// 监控指标(expvar /debug/vars)的http端口, 0 时不开启
+			MetricsPort int `mapstructure:"metricsPort"`
 		} `mapstructure:"server"`
 
 		Component struct {
 			Redis struct {
 				Nodes []string `mapstructure:"nodes"`
 			} `mapstructure:"redis"`
+
+			Consul struct {
+				Endpoint string `mapstructure:"endpoint"`
+				Port     int    `mapstructure:"port"`
+				Scheme   string `mapstructure:"scheme"`
+				Token    string `mapstructure:"token"`
+				CAFile   string `mapstructure:"caFile"`
+				Service  struct {
+					Register bool     `mapstructure:"register"`
+					Tags     []string `mapstructure:"tags"`
+					// 健康检查失败超过该时间后consul自动注销实例
+					DeregisterAfter time.Duration `mapstructure:"deregisterAfter"`
+				} `mapstructure:"service"`
+			} `mapstructure:"consul"`
 		} `mapstructure:"component"`
+
+		// 会话seq号段
+		Seq struct {
+			Step     int64         `mapstructure:"step"`
+			Lease    time.Duration `mapstructure:"lease"`
+			Prefetch float64       `mapstructure:"prefetch"`
+
+			// 检查点, 计数器丢失或回退时以 检查点 + gap 恢复
+			Gap                int64         `mapstructure:"gap"`
+			CheckpointInterval time.Duration `mapstructure:"checkpointInterval"`
+		} `mapstructure:"seq"`
+
+		// 雪花算法的机器id从redis租用, 按 ttl 续约
+		Machine struct {
+			TTL time.Duration `mapstructure:"ttl"`
+		} `mapstructure:"machine"`
+
+		// 消息id的时钟回拨处理, 已使用的最大时间戳按 persistInterval 持久化
+		Clock struct {
+			Policy          string        `mapstructure:"policy"`
+			MaxWait         time.Duration `mapstructure:"maxWait"`
+			MaxBorrow       time.Duration `mapstructure:"maxBorrow"`
+			PersistInterval time.Duration `mapstructure:"persistInterval"`
+		} `mapstructure:"clock"`
+
+		// 调用方限额, 按分配的id/seq数量计算
+		Limit struct {
+			MaxBatch int     `mapstructure:"maxBatch"`
+			Rate     float64 `mapstructure:"rate"`
+			Burst    int     `mapstructure:"burst"`
+		} `mapstructure:"limit"`
 	} `mapstructure:"application"`
 }
 
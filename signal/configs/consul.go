@@ -8,37 +8,58 @@ import (
 	"github.com/hashicorp/consul/api"
 )
 
+type ConsulConf struct {
+	Address string
+	Scheme  string
+	Token   string
+	CAFile  string
+}
+
 type ConsulClient struct {
 	Client *api.Client
 }
 
-func NewEnterpriseClient(addrs []string, token, caFile string) (*api.Client, error) {
-	if len(addrs) == 0 {
-		return nil, errors.New("empty consul address list")
+func NewConsulClient(conf *ConsulConf) (*ConsulClient, error) {
+	if conf == nil || conf.Address == "" {
+		return nil, errors.New("empty consul address")
 	}
 
-	conf := api.DefaultConfig()
-	conf.Token = token
-	if caFile != "" {
-		conf.TLSConfig = api.TLSConfig{
-			CAFile:             caFile,
+	cfg := api.DefaultConfig()
+	cfg.Address = conf.Address
+	if conf.Scheme != "" {
+		cfg.Scheme = conf.Scheme
+	}
+	cfg.Token = conf.Token
+	if conf.CAFile != "" {
+		cfg.TLSConfig = api.TLSConfig{
+			CAFile:             conf.CAFile,
 			InsecureSkipVerify: false,
 		}
 	}
-	conf.HttpClient.Timeout = 5 * time.Second
+	cfg.HttpClient.Timeout = 5 * time.Second
 
-	var lastErr error
-	for _, addr := range addrs {
-		conf.Address = addr
-		return api.NewClient(conf)
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("all consul addrs failed: %w", lastErr)
+	return &ConsulClient{Client: client}, nil
 }
 
+// RegisterService 注册gRPC服务, 采用gRPC健康检查 (grpc.health.v1)
+// 健康检查失败超过 deregisterAfter 后consul自动注销实例
 func (c *ConsulClient) RegisterService(serviceID, serviceName, address string,
-	port int, tags []string, meta map[string]string,
+	port int, tags []string, meta map[string]string, deregisterAfter time.Duration,
 ) error {
 
+	check := &api.AgentServiceCheck{
+		GRPC:     fmt.Sprintf("%s:%d", address, port),
+		Interval: "10s",
+		Timeout:  "2s",
+	}
+	if deregisterAfter > 0 {
+		check.DeregisterCriticalServiceAfter = deregisterAfter.String()
+	}
+
 	cfg := &api.AgentServiceRegistration{
 		ID:      serviceID,
 		Name:    serviceName,
@@ -46,16 +67,16 @@ func (c *ConsulClient) RegisterService(serviceID, serviceName, address string,
 		Port:    port,
 		Tags:    tags,
 		Meta:    meta,
-		Check: &api.AgentServiceCheck{
-			TCP:      fmt.Sprintf("%s:%d", address, port),
-			Interval: "10s",
-			Timeout:  "2s",
-		},
+		Check:   check,
 	}
 
 	return c.Client.Agent().ServiceRegister(cfg)
 }
 
+func (c *ConsulClient) DeregisterService(serviceID string) error {
+	return c.Client.Agent().ServiceDeregister(serviceID)
+}
+
 func (c *ConsulClient) GetNodeID() (string, error) {
 	self, err := c.Client.Agent().Self()
 	if err != nil {
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os/signal"
+	"signal/bootstrap"
+	"syscall"
 )
 
 func main() {
+	// SIGINT/SIGTERM 时从consul注销并优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	log.Println("[INFO] Starting signal server...")
-	select {}
+	if err := bootstrap.Run(ctx); err != nil {
+		log.Fatalf("[ERROR] signal server exited: %v", err)
+	}
+	log.Println("[INFO] signal server stopped")
 }
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	pb "github.com/atoncooper/im/proto/seq"
+)
+
+// 批量分配
+// 群聊扇出、批量导入等场景一次请求分配多个id/seq, 按分配数量计入调用方限额
+
+// MAX_STREAM_COUNT 流式接口一次请求最多分配的数量
+const MAX_STREAM_COUNT = 1000000
+
+func (s *ServerHandle) GenerateMessageIds(ctx context.Context, in *pb.MessageIdsRequest) (*pb.MessageIdsResponse, error) {
+	if in.Count <= 0 {
+		return nil, ErrInvalidArgument
+	}
+	if err := s.allow(ctx, int(in.Count)); err != nil {
+		return nil, err
+	}
+	ids, err := GeneratorFactory().GenerateMessageIDs(int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MessageIdsResponse{Ids: formatIds(ids)}, nil
+}
+
+func (s *ServerHandle) GenerateMessageSeqs(ctx context.Context, in *pb.MessageSeqsRequest) (*pb.MessageSeqsResponse, error) {
+	if in.SenderId == "" || in.ReceiverId == "" || in.Count <= 0 {
+		return nil, ErrInvalidArgument
+	}
+	if err := s.allow(ctx, int(in.Count)); err != nil {
+		return nil, err
+	}
+	return s.seqs(ctx, in)
+}
+
+// seqs 在本节点分配, 会话由其他节点负责时转发
+func (s *ServerHandle) seqs(ctx context.Context, in *pb.MessageSeqsRequest) (*pb.MessageSeqsResponse, error) {
+	seqs, err := GeneratorFactory().GenerateSeqs(ctx, in.SenderId, in.ReceiverId, in.SessionType, int(in.Count))
+	var notOwner *NotOwnerError
+	if errors.As(err, &notOwner) {
+		return s.forwardSeqs(ctx, notOwner, in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MessageSeqsResponse{
+		Seqs:           seqs,
+		ConversationId: ConversationKey(in.SenderId, in.ReceiverId, in.SessionType),
+	}, nil
+}
+
+// StreamMessageIds 按批次返回, 令牌不足时等待而不是失败
+func (s *ServerHandle) StreamMessageIds(in *pb.StreamMessageIdsRequest, stream pb.SequenceService_StreamMessageIdsServer) error {
+	if in.Count <= 0 || in.Count > MAX_STREAM_COUNT || in.BatchSize < 0 {
+		return ErrInvalidArgument
+	}
+	conv := in.Conversation
+	if conv != nil && (conv.SenderId == "" || conv.ReceiverId == "") {
+		return ErrInvalidArgument
+	}
+	batch := int(in.BatchSize)
+	if batch == 0 {
+		batch = DEFAULT_MAX_BATCH
+		if s.Limiter != nil {
+			batch = s.Limiter.maxBatch
+		}
+	}
+
+	ctx := stream.Context()
+	for remaining := int(in.Count); remaining > 0; {
+		n := min(remaining, batch)
+		if s.Limiter != nil {
+			if err := s.Limiter.Wait(ctx, n); err != nil {
+				return err
+			}
+		}
+
+		ids, err := GeneratorFactory().GenerateMessageIDs(n)
+		if err != nil {
+			return err
+		}
+		resp := &pb.MessageIdsBatch{Ids: formatIds(ids)}
+		if conv != nil {
+			seqs, err := s.seqs(ctx, &pb.MessageSeqsRequest{
+				SenderId:    conv.SenderId,
+				ReceiverId:  conv.ReceiverId,
+				SessionType: conv.SessionType,
+				Count:       int32(n),
+			})
+			if err != nil {
+				return err
+			}
+			resp.Seqs = seqs.Seqs
+			resp.ConversationId = seqs.ConversationId
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+func formatIds(ids []int64) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return s
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// 检查点
+//
+// redis中的计数器不是持久化的, 故障切换到落后的副本或数据丢失后计数器会回退, 已分配过的seq被再次分配
+// 1. 每次租用号段后记录会话已租出的最大seq, 定期批量写入storage的检查点
+// 2. 计数器不存在时以 max(检查点, 已存储消息的最大seq) + Gap 初始化
+// 3. 启动时先遍历全部检查点, 把不存在或小于检查点的计数器提升到检查点 + Gap, 之后再对外服务
+//
+// 检查点的写入有延迟, Gap 需要大于一个写入周期内同一会话可能租出的seq数量(号段长度 × 租用次数)
+
+const (
+	DEFAULT_CHECKPOINT_GAP      = 10000
+	DEFAULT_CHECKPOINT_INTERVAL = time.Second
+
+	CHECKPOINT_BATCH = 200
+)
+
+// raise 计数器不存在或小于 ARGV[1] 时设置为 ARGV[2], 返回设置后的值
+var raise = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur and tonumber(cur) >= tonumber(ARGV[1]) then
+	return tonumber(cur)
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return tonumber(ARGV[2])
+`)
+
+type CheckpointConfig struct {
+	Gap      int64         // 恢复时在检查点之上跳过的seq数量
+	Interval time.Duration // 写入检查点的间隔
+}
+
+type Checkpointer struct {
+	client storagepb.CheckpointServiceClient
+	redis  redis.UniversalClient
+	conf   *CheckpointConfig
+
+	mu    sync.Mutex
+	dirty map[string]int64 // 会话id -> 尚未写入的最大seq
+}
+
+func NewCheckpointer(client storagepb.CheckpointServiceClient, redis redis.UniversalClient, conf *CheckpointConfig) *Checkpointer {
+	if conf.Gap <= 0 {
+		conf.Gap = DEFAULT_CHECKPOINT_GAP
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = DEFAULT_CHECKPOINT_INTERVAL
+	}
+	return &Checkpointer{
+		client: client,
+		redis:  redis,
+		conf:   conf,
+		dirty:  make(map[string]int64),
+	}
+}
+
+// Mark 记录会话已租出的最大seq, 由 Run 异步写入
+func (c *Checkpointer) Mark(conversationId string, seq int64) {
+	c.mu.Lock()
+	c.dirty[conversationId] = max(c.dirty[conversationId], seq)
+	c.mu.Unlock()
+}
+
+// Run 定期写入检查点, ctx结束时写入剩余的检查点
+func (c *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := c.Flush(ctx); err != nil {
+				log.Printf("[ERROR] flush seq checkpoints: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				log.Printf("[WARN] flush seq checkpoints: %v", err)
+			}
+		}
+	}
+}
+
+// Flush 写入全部尚未写入的检查点, 失败的部分保留到下次写入
+func (c *Checkpointer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	dirty := c.dirty
+	c.dirty = make(map[string]int64)
+	c.mu.Unlock()
+
+	batch := make([]*storagepb.SeqCheckpoint, 0, CHECKPOINT_BATCH)
+	var err error
+	for id, seq := range dirty {
+		batch = append(batch, &storagepb.SeqCheckpoint{ConversationId: id, Seq: seq})
+		if len(batch) == CHECKPOINT_BATCH {
+			if err = c.save(ctx, batch); err != nil {
+				break
+			}
+			batch = batch[:0]
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		err = c.save(ctx, batch)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// 检查点只增不减, 已写入的部分重复写入不影响结果
+	c.mu.Lock()
+	for id, seq := range dirty {
+		c.dirty[id] = max(c.dirty[id], seq)
+	}
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Checkpointer) save(ctx context.Context, batch []*storagepb.SeqCheckpoint) error {
+	_, err := c.client.SaveCheckpoints(ctx, &storagepb.SaveCheckpointsRequest{Checkpoints: batch})
+	return err
+}
+
+// Floor 会话计数器恢复时的最小值, 会话没有检查点也没有消息时为0
+func (c *Checkpointer) Floor(ctx context.Context, conversationId string) (int64, error) {
+	resp, err := c.client.GetSeqStates(ctx, &storagepb.GetSeqStatesRequest{ConversationIds: []string{conversationId}})
+	if err != nil {
+		return 0, err
+	}
+	var floor int64
+	for _, state := range resp.States {
+		floor = max(floor, state.Checkpoint, state.MaxSeq)
+	}
+	if floor == 0 {
+		return 0, nil
+	}
+	return floor + c.conf.Gap, nil
+}
+
+// Raise 计数器不存在或小于 floor 时提升到 floor, 返回计数器的值
+func (c *Checkpointer) Raise(ctx context.Context, key string, floor int64) (int64, error) {
+	return raise.Run(ctx, c.redis, []string{key}, floor, floor).Int64()
+}
+
+// Recover 启动时遍历全部检查点, 把不存在或回退的计数器提升到检查点 + Gap
+func (c *Checkpointer) Recover(ctx context.Context) error {
+	var (
+		after           string
+		scanned, raised int
+	)
+	for {
+		resp, err := c.client.ListCheckpoints(ctx, &storagepb.ListCheckpointsRequest{After: after, Limit: CHECKPOINT_BATCH})
+		if err != nil {
+			return err
+		}
+
+		pipe := c.redis.Pipeline()
+		cmds := make([]*redis.Cmd, len(resp.Checkpoints))
+		for i, cp := range resp.Checkpoints {
+			cmds[i] = raise.Eval(ctx, pipe, []string{SEQ_PREFIX + cp.ConversationId}, cp.Seq, cp.Seq+c.conf.Gap)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		for i, cp := range resp.Checkpoints {
+			if v, _ := cmds[i].Int64(); v == cp.Seq+c.conf.Gap {
+				raised++
+			}
+		}
+		scanned += len(resp.Checkpoints)
+
+		if !resp.HasMore || len(resp.Checkpoints) == 0 {
+			break
+		}
+		after = resp.Checkpoints[len(resp.Checkpoints)-1].ConversationId
+	}
+	if raised > 0 {
+		log.Printf("[WARN] recovered %d of %d seq counters from checkpoints", raised, scanned)
+	}
+	return nil
+}
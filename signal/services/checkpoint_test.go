@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	msgpb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+// fakeCheckpoints storage中的检查点与已存储消息的最大seq
+type fakeCheckpoints struct {
+	storagepb.CheckpointServiceClient
+	checkpoints map[string]int64
+	maxSeqs     map[string]int64
+}
+
+func (f *fakeCheckpoints) SaveCheckpoints(_ context.Context, in *storagepb.SaveCheckpointsRequest, _ ...grpc.CallOption) (*storagepb.SaveCheckpointsResponse, error) {
+	for _, cp := range in.Checkpoints {
+		f.checkpoints[cp.ConversationId] = max(f.checkpoints[cp.ConversationId], cp.Seq)
+	}
+	return &storagepb.SaveCheckpointsResponse{}, nil
+}
+
+func (f *fakeCheckpoints) GetSeqStates(_ context.Context, in *storagepb.GetSeqStatesRequest, _ ...grpc.CallOption) (*storagepb.GetSeqStatesResponse, error) {
+	resp := &storagepb.GetSeqStatesResponse{}
+	for _, id := range in.ConversationIds {
+		resp.States = append(resp.States, &storagepb.SeqState{ConversationId: id, Checkpoint: f.checkpoints[id], MaxSeq: f.maxSeqs[id]})
+	}
+	return resp, nil
+}
+
+func (f *fakeCheckpoints) ListCheckpoints(_ context.Context, in *storagepb.ListCheckpointsRequest, _ ...grpc.CallOption) (*storagepb.ListCheckpointsResponse, error) {
+	ids := make([]string, 0, len(f.checkpoints))
+	for id := range f.checkpoints {
+		if id > in.After {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	resp := &storagepb.ListCheckpointsResponse{}
+	if len(ids) > int(in.Limit) {
+		ids, resp.HasMore = ids[:in.Limit], true
+	}
+	for _, id := range ids {
+		resp.Checkpoints = append(resp.Checkpoints, &storagepb.SeqCheckpoint{ConversationId: id, Seq: f.checkpoints[id]})
+	}
+	return resp, nil
+}
+
+func newFakeCheckpoints() *fakeCheckpoints {
+	return &fakeCheckpoints{checkpoints: make(map[string]int64), maxSeqs: make(map[string]int64)}
+}
+
+// 测试启动恢复: 不存在的计数器提升到检查点 + Gap, 领先于检查点的计数器保持不变
+func TestCheckpointRecover(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	client := newFakeCheckpoints()
+	client.checkpoints["g:1"] = 50
+	client.checkpoints["s:a:b"] = 100
+	client.checkpoints["s:c:d"] = 300
+	mr.Set(SEQ_PREFIX+"g:1", "20000")
+	mr.Set(SEQ_PREFIX+"s:c:d", "120")
+
+	c := NewCheckpointer(client, rdb, &CheckpointConfig{Gap: 1000, Interval: time.Second})
+	if err := c.Recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]int64{"g:1": 20000, "s:a:b": 1100, "s:c:d": 1300} {
+		if got, _ := rdb.Get(ctx, SEQ_PREFIX+key).Int64(); got != want {
+			t.Fatalf("counter of %s = %d, want %d", key, got, want)
+		}
+	}
+}
+
+// 测试恢复的下限取检查点与已存储消息最大seq中较大的值再加上 Gap, 计数器只增不减
+func TestCheckpointFloor(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	client := newFakeCheckpoints()
+	client.checkpoints["s:a:b"] = 30
+	client.maxSeqs["s:a:b"] = 45
+	c := NewCheckpointer(client, rdb, &CheckpointConfig{Gap: 1000})
+
+	floor, err := c.Floor(ctx, "s:a:b")
+	if err != nil || floor != 1045 {
+		t.Fatalf("floor %d, %v, want 1045", floor, err)
+	}
+	// 没有检查点也没有消息的会话从0开始
+	if floor, err := c.Floor(ctx, "s:x:y"); err != nil || floor != 0 {
+		t.Fatalf("floor of new conversation %d, %v", floor, err)
+	}
+
+	key := SEQ_PREFIX + "s:a:b"
+	if seq, err := c.Raise(ctx, key, floor); err != nil || seq != 1045 {
+		t.Fatalf("raise missing counter %d, %v", seq, err)
+	}
+	mr.Set(key, "5000")
+	if seq, err := c.Raise(ctx, key, floor); err != nil || seq != 5000 {
+		t.Fatalf("raise must not lower the counter: %d, %v", seq, err)
+	}
+}
+
+// 测试计数器丢失后分配seq时恢复, 没有检查点时从旧版本两个方向的计数器中较大的值继续
+func TestGeneratorRecoverLegacy(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	mr.Set(SEQ_PREFIX+"alice:bob", "7")
+	mr.Set(SEQ_PREFIX+"bob:alice", "12")
+	client := newFakeCheckpoints()
+	checkpoints := NewCheckpointer(client, rdb, &CheckpointConfig{Gap: 1000})
+	segments := NewSegmentAllocator(rdb, &SegmentConfig{Node: "node-1", Step: 10, Lease: time.Minute})
+	g, err := NewIDGenerator(nil, nil, rdb, segments, checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seq, err := g.GenerateSeq(ctx, "bob", "alice", msgpb.SesstionType_SINGLE)
+	if err != nil || seq != 13 {
+		t.Fatalf("expected seq 13 after legacy counters, got %d, %v", seq, err)
+	}
+
+	// 有检查点的会话以检查点 + Gap 为起点, 群聊没有旧版本计数器
+	client.checkpoints["g:1"] = 40
+	seq, err = g.GenerateSeq(ctx, "alice", "1", msgpb.SesstionType_GROUP)
+	if err != nil || seq != 1041 {
+		t.Fatalf("expected seq 1041 after checkpoint, got %d, %v", seq, err)
+	}
+
+	// 恢复后的值与租出的号段写入检查点
+	if err := checkpoints.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cp := client.checkpoints["s:alice:bob"]; cp < 22 {
+		t.Fatalf("checkpoint of s:alice:bob = %d, want at least 22", cp)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/atoncooper/im/utils/idgen"
+	"github.com/redis/go-redis/v9"
+)
+
+// 消息id的时间戳持久化
+//
+// 机器id可能在进程间转移, 已使用的最大时间戳按机器id保存在redis中, 而不是本地文件
+// key : machineTs:{id}
+// 新进程租用机器id后以 保存的值 + persistInterval 为起点, 即使时钟回拨也不会与之前的进程重复
+
+const MACHINE_TS_PREFIX = "machineTs:"
+
+// raiseTimestamp 只增不减
+var raiseTimestamp = redis.NewScript(`
+local cur = tonumber(redis.call('GET', KEYS[1]) or '0')
+if cur < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+type TimestampStore struct {
+	redis *redis.ClusterClient
+	key   string
+}
+
+func NewTimestampStore(redis *redis.ClusterClient, machineId int64) *TimestampStore {
+	return &TimestampStore{redis: redis, key: fmt.Sprintf("%s%d", MACHINE_TS_PREFIX, machineId)}
+}
+
+func (s *TimestampStore) Load(ctx context.Context) (int64, error) {
+	ts, err := s.redis.Get(ctx, s.key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return ts, err
+}
+
+func (s *TimestampStore) Save(ctx context.Context, ts int64) error {
+	return raiseTimestamp.Run(ctx, s.redis, []string{s.key}, ts).Err()
+}
+
+// ClockConfig 时钟回拨的处理, 见 idgen.Config
+type ClockConfig struct {
+	Policy          string
+	MaxWait         time.Duration
+	MaxBorrow       time.Duration
+	PersistInterval time.Duration
+}
+
+// 当前的消息id生成器, 回拨、等待、借用的统计以 idgen 导出到 expvar (/debug/vars)
+var currentClock atomic.Pointer[idgen.Generator]
+
+func init() {
+	expvar.Publish("idgen", expvar.Func(func() any {
+		if g := currentClock.Load(); g != nil {
+			return g.Stats()
+		}
+		return nil
+	}))
+}
+
+// NewClock 以租用的机器id创建消息id生成器, 起点从redis中恢复
+func NewClock(ctx context.Context, redis *redis.ClusterClient, lease *MachineLease, conf *ClockConfig) (*idgen.Generator, error) {
+	policy, err := idgen.ParsePolicy(conf.Policy)
+	if err != nil {
+		return nil, err
+	}
+	g, err := idgen.New(ctx, &idgen.Config{
+		Layout:          idgen.SnowflakeLayout,
+		Worker:          lease.Id(),
+		Policy:          policy,
+		MaxWait:         conf.MaxWait,
+		MaxBorrow:       conf.MaxBorrow,
+		Store:           NewTimestampStore(redis, lease.Id()),
+		PersistInterval: conf.PersistInterval,
+		OnRollback:      alertRollback,
+	})
+	if err != nil {
+		return nil, err
+	}
+	currentClock.Store(g)
+	return g, nil
+}
+
+// alertRollback 回拨开始及每次回拨中第一次生成失败时告警
+func alertRollback(r idgen.Rollback) {
+	if r.Err != nil {
+		log.Printf("[ALERT] machine id %d clock moved backwards by %v, policy %s, generate message id failed: %v", r.Worker, r.Drift, r.Policy, r.Err)
+		return
+	}
+	log.Printf("[ALERT] machine id %d clock moved backwards by %v, policy %s", r.Worker, r.Drift, r.Policy)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto/seq"
+	"github.com/atoncooper/im/utils/idgen"
+)
+
+// 消息id解析, 用于排查问题, 命令行工具见 utils/cmd/idinfo
+//
+// 雪花布局改为预留借用空间之前, 由 bwmarrin/snowflake 生成的id使用全部12位序列号,
+// 这些id的序列号 >= 2048 时 borrowed 同样为 true, 并不表示发生过时钟回拨
+
+var layouts = map[pb.IdLayout]idgen.Layout{
+	pb.IdLayout_SNOWFLAKE: idgen.SnowflakeLayout,
+	pb.IdLayout_CENTER:    idgen.CenterLayout,
+}
+
+func (s *ServerHandle) DecodeMessageId(ctx context.Context, in *pb.DecodeMessageIdRequest) (*pb.DecodeMessageIdResponse, error) {
+	layout, ok := layouts[in.Layout]
+	if !ok {
+		return nil, ErrInvalidArgument
+	}
+	id, err := strconv.ParseInt(in.Id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidArgument
+	}
+	if err := s.allow(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	d, err := layout.Decode(id)
+	if err != nil {
+		return nil, ErrInvalidArgument
+	}
+	return &pb.DecodeMessageIdResponse{
+		Id:         in.Id,
+		Layout:     in.Layout,
+		Timestamp:  d.Timestamp,
+		Time:       d.Time.UTC().Format(time.RFC3339Nano),
+		Biz:        d.Biz,
+		Datacenter: d.Datacenter,
+		MachineId:  d.Machine,
+		Sequence:   d.Sequence,
+		Borrowed:   d.Borrowed,
+	}, nil
+}
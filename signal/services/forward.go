@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	pb "github.com/atoncooper/im/proto/seq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 转发
+//
+// 会话的seq只由所有者分配, 请求落到其他节点时转发给所有者
+// 转发的请求带有 FORWARDED_HEADER, 所有者变更导致再次落空时不再转发, 由调用方重试
+
+const FORWARDED_HEADER = "x-seq-forwarded"
+
+// peers 到其他signal节点的连接, 按地址缓存
+type peers struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func (p *peers) get(addr string) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn, ok := p.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	if p.conns == nil {
+		p.conns = make(map[string]*grpc.ClientConn)
+	}
+	p.conns[addr] = conn
+	return conn, nil
+}
+
+func (p *peers) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, addr)
+	}
+}
+
+// forward 将分配请求转发给会话的所有者
+func (s *ServerHandle) forward(ctx context.Context, owner *NotOwnerError, in *pb.MessageSeqRequest) (*pb.MessageResponse, error) {
+	client, ctx, err := s.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return client.GenerateMessageSeq(ctx, in)
+}
+
+// forwardSeqs 将批量分配请求转发给会话的所有者
+func (s *ServerHandle) forwardSeqs(ctx context.Context, owner *NotOwnerError, in *pb.MessageSeqsRequest) (*pb.MessageSeqsResponse, error) {
+	client, ctx, err := s.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return client.GenerateMessageSeqs(ctx, in)
+}
+
+// forwardInbox 将inbox seq分配请求转发给用户的所有者, 请求中的用户都由该节点负责
+func (s *ServerHandle) forwardInbox(ctx context.Context, owner *NotOwnerError, in *pb.InboxSeqsRequest) (*pb.InboxSeqsResponse, error) {
+	client, ctx, err := s.owner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return client.GenerateInboxSeqs(ctx, in)
+}
+
+func (s *ServerHandle) owner(ctx context.Context, owner *NotOwnerError) (pb.SequenceServiceClient, context.Context, error) {
+	if forwarded(ctx) {
+		return nil, nil, status.Error(codes.Unavailable, owner.Error())
+	}
+	conn, err := s.peers.get(owner.Owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewSequenceServiceClient(conn), metadata.AppendToOutgoingContext(ctx, FORWARDED_HEADER, "1"), nil
+}
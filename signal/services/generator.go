@@ -2,48 +2,165 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/bwmarrin/snowflake"
+	msgpb "github.com/atoncooper/im/proto"
+	"github.com/atoncooper/im/utils/idgen"
 	"github.com/redis/go-redis/v9"
 )
 
 type IDGenerator struct {
-	snowflakeNode *snowflake.Node
-	redisClient   *redis.ClusterClient
-	redisTimeout  time.Duration
+	clock        *idgen.Generator
+	lease        *MachineLease
+	redisClient  redis.UniversalClient
+	redisTimeout time.Duration
+	segments     *SegmentAllocator
+	checkpoints  *Checkpointer
 }
 
-func NewIDGenerator(machineId int64, redis *redis.ClusterClient) (*IDGenerator, error) {
-	node, err := snowflake.NewNode(machineId)
-	if err != nil {
-		return nil, err
+func NewIDGenerator(lease *MachineLease, clock *idgen.Generator, redis redis.UniversalClient, segments *SegmentAllocator, checkpoints *Checkpointer) (*IDGenerator, error) {
+	g := &IDGenerator{
+		clock:        clock,
+		lease:        lease,
+		redisClient:  redis,
+		redisTimeout: 5 * time.Second,
+		segments:     segments,
+		checkpoints:  checkpoints,
+	}
+	segments.recover = g.recover
+	segments.leased = func(key string, end int64) {
+		checkpoints.Mark(strings.TrimPrefix(key, SEQ_PREFIX), end)
+	}
+	return g, nil
+}
+
+// 会话seq
+//
+// 每个会话一个计数器, 会话内所有参与者共用同一个递增序列
+// key : seq:{conversation}
+// 私聊会话为双方uid排序拼接 s:{a}:{b}, 群聊会话为 g:{groupId}, 与center、storage的会话id一致
+//
+// 旧版本按发送方向计数 seq:{sender}:{receiver}, 私聊会话首次分配时以两个方向中较大的值为起点
+// 保证升级后同一会话的seq不会变小
+//
+// seq由号段分配器在内存中分配, 见 segment.go
+// 计数器不存在或回退时由检查点恢复, 见 checkpoint.go
+
+const SEQ_PREFIX = "seq:"
+
+// ConversationKey 由发送者视角的会话得到会话id, 未指定会话类型时按私聊处理
+func ConversationKey(senderId, receiverId string, typ msgpb.SesstionType) string {
+	if typ == msgpb.SesstionType_GROUP {
+		return "g:" + receiverId
 	}
-	return &IDGenerator{
-		snowflakeNode: node,
-		redisClient:   redis,
-		redisTimeout:  5 * time.Second,
-	}, nil
+	a, b := senderId, receiverId
+	if a > b {
+		a, b = b, a
+	}
+	return "s:" + a + ":" + b
+}
+
+// GenerateSeq 为会话分配下一个seq, 会话由其他节点负责时返回 *NotOwnerError
+func (g *IDGenerator) GenerateSeq(ctx context.Context, senderId, receiverId string, typ msgpb.SesstionType) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.redisTimeout)
+	defer cancel()
+
+	return g.segments.Next(ctx, SEQ_PREFIX+ConversationKey(senderId, receiverId, typ))
+}
+
+// GenerateSeqs 为会话连续分配 n 个seq
+func (g *IDGenerator) GenerateSeqs(ctx context.Context, senderId, receiverId string, typ msgpb.SesstionType, n int) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.redisTimeout)
+	defer cancel()
+
+	return g.segments.NextN(ctx, SEQ_PREFIX+ConversationKey(senderId, receiverId, typ), n)
 }
 
-func (g *IDGenerator) GenerateSeq(senderID, receiverID string) (int64, error) {
-	key := fmt.Sprintf("seq:%s:%s", senderID, receiverID)
-	ctx, cancel := context.WithTimeout(context.Background(), g.redisTimeout)
+// GenerateInboxSeq 为用户分配下一个inbox seq, 用户由其他节点负责时返回 *NotOwnerError
+func (g *IDGenerator) GenerateInboxSeq(ctx context.Context, uid string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.redisTimeout)
 	defer cancel()
 
-	seq, err := g.redisClient.Incr(ctx, key).Result()
+	return g.segments.Next(ctx, SEQ_PREFIX+InboxKey(uid))
+}
+
+// recover 计数器不存在或回退时恢复, 取以下各值中最大的:
+// floor(本地已租出的最大seq)、旧版本两个方向的计数器、检查点与已存储消息的最大seq加上安全间隔
+// 多个节点同时恢复时计数器只会被提升, 不会回退
+func (g *IDGenerator) recover(ctx context.Context, key string, floor int64) error {
+	conversationId := strings.TrimPrefix(key, SEQ_PREFIX)
+	legacy, err := g.legacy(ctx, conversationId)
+	if err != nil {
+		return err
+	}
+	durable, err := g.checkpoints.Floor(ctx, conversationId)
+	if err != nil {
+		return err
+	}
+	seq, err := g.checkpoints.Raise(ctx, key, max(floor, legacy, durable))
+	if err != nil {
+		return err
+	}
+	g.checkpoints.Mark(conversationId, seq)
+	return nil
+}
+
+// legacy 旧版本按发送方向计数 seq:{sender}:{receiver}, 返回私聊会话两个方向中较大的值
+// 旧key与新key不在同一slot, 无法在脚本中原子完成, 由 raise 保证计数器只增不减
+func (g *IDGenerator) legacy(ctx context.Context, conversationId string) (int64, error) {
+	parts := strings.SplitN(conversationId, ":", 3)
+	if len(parts) != 3 || parts[0] != "s" {
+		return 0, nil
+	}
+	var start int64
+	for _, key := range []string{SEQ_PREFIX + parts[1] + ":" + parts[2], SEQ_PREFIX + parts[2] + ":" + parts[1]} {
+		v, err := g.redisClient.Get(ctx, key).Int64()
+		if err != nil && err != redis.Nil {
+			return 0, err
+		}
+		start = max(start, v)
+	}
+	return start, nil
+}
+
+// GenerateMessageID 机器id租约失效时返回 ErrMachineLease, 时钟回拨无法处理时返回 idgen.ErrClockRollback
+func (g *IDGenerator) GenerateMessageID() (int64, error) {
+	if !g.lease.Valid() {
+		return 0, ErrMachineLease
+	}
+	return g.clock.Next()
+}
+
+// GenerateMessageIDs 连续生成 n 个id, 失败时不返回已生成的部分
+func (g *IDGenerator) GenerateMessageIDs(n int) ([]int64, error) {
+	if !g.lease.Valid() {
+		return nil, ErrMachineLease
+	}
+	ids, err := g.clock.NextN(n)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return ids, nil
+}
 
-	return seq, nil
+// Run 定期持久化消息id已使用的最大时间戳, ctx结束时再持久化一次
+func (g *IDGenerator) Run(ctx context.Context) {
+	g.clock.Run(ctx)
 }
 
-func (g *IDGenerator) GenerateMessageID() int64 {
-	return g.snowflakeNode.Generate().Int64()
+// Close 持久化消息id的时间戳, 释放会话的号段并写入剩余的检查点
+func (g *IDGenerator) Close(ctx context.Context) {
+	if err := g.clock.Persist(ctx); err != nil {
+		log.Printf("[WARN] persist message id timestamp: %v", err)
+	}
+	g.segments.Close(ctx)
+	if err := g.checkpoints.Flush(ctx); err != nil {
+		log.Printf("[WARN] flush seq checkpoints: %v", err)
+	}
 }
 
 var (
@@ -51,10 +168,11 @@ var (
 	once sync.Once
 )
 
-func InitGenerator(machineId int64, redis *redis.ClusterClient) error {
+func InitGenerator(lease *MachineLease, clock *idgen.Generator, redis redis.UniversalClient, segments *SegmentAllocator, checkpoints *Checkpointer) error {
 	var err error
 	once.Do(func() {
-		generator, err := NewIDGenerator(machineId, redis)
+		var generator *IDGenerator
+		generator, err = NewIDGenerator(lease, clock, redis, segments, checkpoints)
 		if err != nil {
 			return
 		}
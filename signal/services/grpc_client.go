@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// 服务发现刷新间隔
+const DISCOVERY_TTL = 10 * time.Second
+
+// DialService 通过consul发现服务并建立连接
+//
+// 连接内部按健康实例做 round_robin 负载均衡
+// 后台定期刷新实例列表直到ctx结束
+func DialService(ctx context.Context, consul *api.Client, serviceName string) (*grpc.ClientConn, error) {
+	r := manual.NewBuilderWithScheme("consul-" + serviceName)
+
+	state, err := discover(consul, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	r.InitialState(state)
+
+	conn, err := grpc.NewClient(r.Scheme()+":///"+serviceName,
+		grpc.WithResolvers(r),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(DISCOVERY_TTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				state, err := discover(consul, serviceName)
+				if err != nil {
+					log.Printf("[WARN] 刷新服务 %s 实例失败: %v", serviceName, err)
+					continue
+				}
+				r.UpdateState(state)
+			}
+		}
+	}()
+
+	return conn, nil
+}
+
+// discover 查询consul中健康的服务实例
+func discover(consul *api.Client, serviceName string) (resolver.State, error) {
+	entries, _, err := consul.Health().Service(serviceName, "", true, nil)
+	if err != nil {
+		return resolver.State{}, err
+	}
+
+	addrs := make([]resolver.Address, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		addrs = append(addrs, resolver.Address{Addr: fmt.Sprintf("%s:%d", host, e.Service.Port)})
+	}
+	return resolver.State{Addresses: addrs}, nil
+}
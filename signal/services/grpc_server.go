@@ -1,12 +1,15 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -37,15 +40,26 @@ func newDafaultgRPCCfg() *GRPCConfig {
 
 var (
 	srv     *grpc.Server
+	hs      *health.Server
+	srvErr  = make(chan error, 1)
 	srvOnce sync.Once
 )
 
-func NewGRPCServer(cfg *GRPCConfig, register func(*grpc.Server)) {
+// NewGRPCServer 启动gRPC服务, 同时提供标准的健康检查服务 grpc.health.v1
+// 监听失败时返回错误, 服务运行中退出的错误见 GRPCErr
+func NewGRPCServer(cfg *GRPCConfig, register func(*grpc.Server)) error {
+	var err error
 	srvOnce.Do(func() {
 		if cfg == nil {
 			cfg = newDafaultgRPCCfg()
 		}
 
+		lis, e := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
+		if e != nil {
+			err = e
+			return
+		}
+
 		kp := keepalive.ServerParameters{
 			MaxConnectionIdle:     cfg.MaxConnIdle,
 			MaxConnectionAge:      cfg.MaxConnAge,
@@ -61,15 +75,23 @@ func NewGRPCServer(cfg *GRPCConfig, register func(*grpc.Server)) {
 
 		register(srv)
 
+		// 整体及每个已注册服务的健康状态, 关闭时置为 NOT_SERVING
+		hs = health.NewServer()
+		for name := range srv.GetServiceInfo() {
+			hs.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+		}
+		healthpb.RegisterHealthServer(srv, hs)
+
 		go func() {
-			lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
-			if err != nil {
-				panic(err)
-			}
-			_ = srv.Serve(lis)
+			srvErr <- srv.Serve(lis)
 		}()
 	})
+	return err
+}
 
+// GRPCErr gRPC服务退出时返回 Serve 的错误
+func GRPCErr() <-chan error {
+	return srvErr
 }
 
 func GRPCTemplate() *grpc.Server {
@@ -79,8 +101,29 @@ func GRPCTemplate() *grpc.Server {
 	return srv
 }
 
-func GRPCstop() {
-	if srv != nil {
+// GRPCNotServing 健康检查置为 NOT_SERVING, 调用方不再把请求发往本节点, 之后不能再恢复
+func GRPCNotServing() {
+	if hs != nil {
+		hs.Shutdown()
+	}
+}
+
+// GRPCstop 健康检查置为 NOT_SERVING 后优雅关闭, ctx 结束时仍未完成则强制关闭
+func GRPCstop(ctx context.Context) {
+	if srv == nil {
+		return
+	}
+	GRPCNotServing()
+
+	stopped := make(chan struct{})
+	go func() {
 		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+		<-stopped
 	}
 }
@@ -9,15 +9,23 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+var ErrInvalidArgument = errors.New("invalid argument")
+
 type ServerHandle struct {
 	pb.UnimplementedSequenceServiceServer
-	Redis *redis.ClusterClient
+	Redis   *redis.ClusterClient
+	Limiter *CallerLimiter
+
+	peers peers
 }
 
 func (s *ServerHandle) GenerateMessageId(ctx context.Context, in *pb.Empty) (*pb.MessageIdResponse, error) {
-	msgId := GeneratorFactory().GenerateMessageID()
-	if msgId == 0 {
-		return nil, errors.New("generate message id failed")
+	if err := s.allow(ctx, 1); err != nil {
+		return nil, err
+	}
+	msgId, err := GeneratorFactory().GenerateMessageID()
+	if err != nil {
+		return nil, err
 	}
 
 	resp := &pb.MessageIdResponse{Id: strconv.FormatInt(msgId, 10)}
@@ -26,11 +34,33 @@ func (s *ServerHandle) GenerateMessageId(ctx context.Context, in *pb.Empty) (*pb
 }
 
 func (s *ServerHandle) GenerateMessageSeq(ctx context.Context, in *pb.MessageSeqRequest) (*pb.MessageResponse, error) {
-	msgId, err := GeneratorFactory().GenerateSeq(in.SenderId, in.ReceiverId)
+	if in.SenderId == "" || in.ReceiverId == "" {
+		return nil, ErrInvalidArgument
+	}
+	if err := s.allow(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	seq, err := GeneratorFactory().GenerateSeq(ctx, in.SenderId, in.ReceiverId, in.SessionType)
+	var notOwner *NotOwnerError
+	if errors.As(err, &notOwner) {
+		return s.forward(ctx, notOwner, in)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	resp := &pb.MessageResponse{Seq: msgId}
+	resp := &pb.MessageResponse{
+		Seq:            seq,
+		ConversationId: ConversationKey(in.SenderId, in.ReceiverId, in.SessionType),
+	}
 	return resp, nil
 }
+
+// allow 未配置限额时不限制
+func (s *ServerHandle) allow(ctx context.Context, n int) error {
+	if s.Limiter == nil {
+		return nil
+	}
+	return s.Limiter.Allow(ctx, n)
+}
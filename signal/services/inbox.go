@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/atoncooper/im/proto/seq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 用户inbox seq
+//
+// 投递给用户的每个事件(消息、撤回、编辑、表情回应、已读)分配一个该用户的inbox seq, storage按它索引用户的时间线
+// 客户端以一个游标同步全部会话, 见 storage 的 TimelineService
+// key : seq:u:{uid}
+// 与会话seq共用号段分配、所有者转发及检查点恢复, 检查点中的id为 u:{uid}
+
+const INBOX_PREFIX = "u:"
+
+// InboxKey 用户inbox seq在检查点中的id
+func InboxKey(uid string) string {
+	return INBOX_PREFIX + uid
+}
+
+func (s *ServerHandle) GenerateInboxSeqs(ctx context.Context, in *pb.InboxSeqsRequest) (*pb.InboxSeqsResponse, error) {
+	if len(in.Uids) == 0 {
+		return nil, ErrInvalidArgument
+	}
+	for _, uid := range in.Uids {
+		if uid == "" {
+			return nil, ErrInvalidArgument
+		}
+	}
+	if err := s.allow(ctx, len(in.Uids)); err != nil {
+		return nil, err
+	}
+
+	// 本节点负责的用户直接分配, 其余按所有者分组转发, 结果与请求中的uid一一对应
+	seqs := make([]*pb.InboxSeq, len(in.Uids))
+	remote := make(map[string][]int)
+	owners := make(map[string]*NotOwnerError)
+	for i, uid := range in.Uids {
+		seq, err := GeneratorFactory().GenerateInboxSeq(ctx, uid)
+		var notOwner *NotOwnerError
+		if errors.As(err, &notOwner) {
+			remote[notOwner.Owner] = append(remote[notOwner.Owner], i)
+			owners[notOwner.Owner] = notOwner
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		seqs[i] = &pb.InboxSeq{Uid: uid, Seq: seq}
+	}
+
+	for addr, idx := range remote {
+		uids := make([]string, len(idx))
+		for j, i := range idx {
+			uids[j] = in.Uids[i]
+		}
+		resp, err := s.forwardInbox(ctx, owners[addr], &pb.InboxSeqsRequest{Uids: uids})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Seqs) != len(idx) {
+			return nil, status.Errorf(codes.Internal, "owner %s returned %d inbox seqs, want %d", addr, len(resp.Seqs), len(idx))
+		}
+		for j, i := range idx {
+			seqs[i] = resp.Seqs[j]
+		}
+	}
+	return &pb.InboxSeqsResponse{Seqs: seqs}, nil
+}
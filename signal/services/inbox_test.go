@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto/seq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeOwner 其他signal节点, 记录收到的转发请求, 按请求顺序返回 base+1, base+2 ...
+type fakeOwner struct {
+	pb.UnimplementedSequenceServiceServer
+	base int64
+
+	mu        sync.Mutex
+	requests  [][]string
+	forwarded bool
+}
+
+func (f *fakeOwner) GenerateInboxSeqs(ctx context.Context, in *pb.InboxSeqsRequest) (*pb.InboxSeqsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, in.Uids)
+	f.forwarded = forwarded(ctx)
+	resp := &pb.InboxSeqsResponse{}
+	for i, uid := range in.Uids {
+		resp.Seqs = append(resp.Seqs, &pb.InboxSeq{Uid: uid, Seq: f.base + int64(i) + 1})
+	}
+	return resp, nil
+}
+
+// startOwner 在本地端口启动其他节点, 返回其地址
+func startOwner(t *testing.T, owner *fakeOwner) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterSequenceServiceServer(s, owner)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// 测试inbox seq按所有者分组转发, 每个所有者只转发一次, 结果与请求中的uid一一对应
+func TestGenerateInboxSeqsForward(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	segments := NewSegmentAllocator(rdb, &SegmentConfig{Node: "node-1", Step: 10, Lease: time.Minute})
+	g, err := NewIDGenerator(nil, nil, rdb, segments, NewCheckpointer(newFakeCheckpoints(), rdb, &CheckpointConfig{Gap: 1000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := inst.Load()
+	inst.Store(g)
+	t.Cleanup(func() { inst.Store(prev) })
+
+	a, b := &fakeOwner{base: 100}, &fakeOwner{base: 200}
+	addrA, addrB := startOwner(t, a), startOwner(t, b)
+	for uid, addr := range map[string]string{"carol": addrA, "dave": addrA, "erin": addrB} {
+		mr.Set(ownerKey(SEQ_PREFIX+InboxKey(uid)), addr)
+	}
+
+	s := &ServerHandle{}
+	t.Cleanup(s.peers.Close)
+	uids := []string{"alice", "carol", "erin", "dave", "bob"}
+	resp, err := s.GenerateInboxSeqs(ctx, &pb.InboxSeqsRequest{Uids: uids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Seqs) != len(uids) {
+		t.Fatalf("expected %d seqs, got %v", len(uids), resp.Seqs)
+	}
+	for i, s := range resp.Seqs {
+		if s.Uid != uids[i] || s.Seq <= 0 {
+			t.Fatalf("unexpected seq %v for %s", s, uids[i])
+		}
+	}
+	want := map[string]int64{"carol": 101, "dave": 102, "erin": 201}
+	for _, s := range resp.Seqs {
+		if seq, ok := want[s.Uid]; ok && s.Seq != seq {
+			t.Fatalf("expected %s seq %d from owner, got %d", s.Uid, seq, s.Seq)
+		}
+	}
+
+	if len(a.requests) != 1 || !slices.Equal(a.requests[0], []string{"carol", "dave"}) || !a.forwarded {
+		t.Fatalf("unexpected requests to owner a %v, forwarded %v", a.requests, a.forwarded)
+	}
+	if len(b.requests) != 1 || !slices.Equal(b.requests[0], []string{"erin"}) || !b.forwarded {
+		t.Fatalf("unexpected requests to owner b %v, forwarded %v", b.requests, b.forwarded)
+	}
+
+	// 转发来的请求再次落空时不再转发
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(FORWARDED_HEADER, "1"))
+	if _, err := s.GenerateInboxSeqs(ctx, &pb.InboxSeqsRequest{Uids: []string{"alice", "carol"}}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if len(a.requests) != 1 {
+		t.Fatalf("forwarded request forwarded again: %v", a.requests)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// 调用方限额
+// 单次请求的数量不超过 MaxBatch, 每个调用方按分配的id/seq数量限流
+// 调用方由请求头 CALLER_HEADER 标识, 未设置时取对端ip
+// 基于令牌桶实现, 与gateway的限流器一致, 每个调用方独立一个桶
+
+const (
+	CALLER_HEADER = "x-caller"
+
+	DEFAULT_MAX_BATCH = 1000
+	DEFAULT_RATE      = 10000
+)
+
+var (
+	ErrBatchTooLarge = status.Error(codes.InvalidArgument, "batch too large")
+	ErrRateLimited   = status.Error(codes.ResourceExhausted, "rate limited")
+)
+
+type LimitConfig struct {
+	MaxBatch int     // 单次请求最多分配的数量
+	Rate     float64 // 每个调用方每秒可以分配的数量
+	Burst    int     // 桶容量, 不小于 MaxBatch
+}
+
+type CallerLimiter struct {
+	maxBatch int
+	rate     float64
+	burst    float64
+	buckets  sync.Map // 调用方 -> *tokenBucket
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewCallerLimiter(conf *LimitConfig) *CallerLimiter {
+	if conf.MaxBatch <= 0 {
+		conf.MaxBatch = DEFAULT_MAX_BATCH
+	}
+	if conf.Rate <= 0 {
+		conf.Rate = DEFAULT_RATE
+	}
+	if conf.Burst < conf.MaxBatch {
+		conf.Burst = conf.MaxBatch
+	}
+	return &CallerLimiter{
+		maxBatch: conf.MaxBatch,
+		rate:     conf.Rate,
+		burst:    float64(conf.Burst),
+	}
+}
+
+// Allow 消耗 n 个令牌, 超过单次上限或令牌不足时返回错误
+// 转发的请求已在第一个节点计数, 不再限制
+func (l *CallerLimiter) Allow(ctx context.Context, n int) error {
+	if n > l.maxBatch {
+		return ErrBatchTooLarge
+	}
+	if forwarded(ctx) {
+		return nil
+	}
+	if l.reserve(caller(ctx), n, time.Now()) > 0 {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Wait 消耗 n 个令牌, 令牌不足时等待
+func (l *CallerLimiter) Wait(ctx context.Context, n int) error {
+	if n > l.maxBatch {
+		return ErrBatchTooLarge
+	}
+	key := caller(ctx)
+	for {
+		wait := l.reserve(key, n, time.Now())
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve 令牌足够时消耗并返回0, 否则返回补足所需的时间
+func (l *CallerLimiter) reserve(key string, n int, now time.Time) time.Duration {
+	v, _ := l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.burst, last: now})
+	b := v.(*tokenBucket)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*l.rate, l.burst)
+		b.last = now
+	}
+	if lack := float64(n) - b.tokens; lack > 0 {
+		return time.Duration(lack / l.rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	return 0
+}
+
+// Clean 回收超过idle时间未被访问的桶
+func (l *CallerLimiter) Clean(idle time.Duration) {
+	now := time.Now()
+	l.buckets.Range(func(k, v any) bool {
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		expired := now.Sub(b.last) > idle
+		b.mu.Unlock()
+		if expired {
+			l.buckets.Delete(k)
+		}
+		return true
+	})
+}
+
+func caller(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(CALLER_HEADER); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func forwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(FORWARDED_HEADER)) > 0
+}
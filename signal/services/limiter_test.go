@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func callerContext(name string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(CALLER_HEADER, name))
+}
+
+// 测试单次请求超过 MaxBatch 时拒绝, 转发的请求同样受限
+func TestLimiterMaxBatch(t *testing.T) {
+	l := NewCallerLimiter(&LimitConfig{MaxBatch: 10, Rate: 100, Burst: 100})
+
+	if err := l.Allow(callerContext("center"), 11); err != ErrBatchTooLarge {
+		t.Fatalf("expected ErrBatchTooLarge, got %v", err)
+	}
+	forwardedCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(FORWARDED_HEADER, "1"))
+	if err := l.Allow(forwardedCtx, 11); err != ErrBatchTooLarge {
+		t.Fatalf("expected ErrBatchTooLarge for forwarded request, got %v", err)
+	}
+	if err := l.Wait(callerContext("center"), 11); err != ErrBatchTooLarge {
+		t.Fatalf("expected ErrBatchTooLarge from Wait, got %v", err)
+	}
+	if err := l.Allow(callerContext("center"), 10); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试桶容量用完后限流, 各调用方独立计数, 转发的请求不计数
+func TestLimiterBurst(t *testing.T) {
+	l := NewCallerLimiter(&LimitConfig{MaxBatch: 10, Rate: 1, Burst: 25})
+
+	for i := 0; i < 2; i++ {
+		if err := l.Allow(callerContext("center"), 10); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := l.Allow(callerContext("center"), 10); err != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if err := l.Allow(callerContext("center"), 5); err != nil {
+		t.Fatalf("remaining tokens rejected: %v", err)
+	}
+	if err := l.Allow(callerContext("gateway"), 10); err != nil {
+		t.Fatalf("other caller limited: %v", err)
+	}
+	forwardedCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(CALLER_HEADER, "center", FORWARDED_HEADER, "1"))
+	if err := l.Allow(forwardedCtx, 10); err != nil {
+		t.Fatalf("forwarded request limited: %v", err)
+	}
+
+	// 令牌不足时 Wait 在ctx结束前返回
+	ctx, cancel := context.WithTimeout(callerContext("center"), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, 10); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+// 测试令牌按速率补充, 不超过桶容量
+func TestLimiterRate(t *testing.T) {
+	l := NewCallerLimiter(&LimitConfig{MaxBatch: 100, Rate: 10, Burst: 100})
+	now := time.Now()
+
+	if wait := l.reserve("center", 100, now); wait != 0 {
+		t.Fatalf("expected full bucket, wait %v", wait)
+	}
+	if wait := l.reserve("center", 5, now); wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got %v", wait)
+	}
+	if wait := l.reserve("center", 5, now.Add(500*time.Millisecond)); wait != 0 {
+		t.Fatalf("tokens not refilled, wait %v", wait)
+	}
+
+	// 空闲很久后最多补满桶容量
+	later := now.Add(time.Hour)
+	if wait := l.reserve("center", 100, later); wait != 0 {
+		t.Fatalf("expected full bucket, wait %v", wait)
+	}
+	if wait := l.reserve("center", 1, later); wait == 0 {
+		t.Fatal("bucket refilled beyond burst")
+	}
+}
+
+// 测试回收空闲的桶, 回收后重新从满桶开始
+func TestLimiterClean(t *testing.T) {
+	l := NewCallerLimiter(&LimitConfig{MaxBatch: 10, Rate: 1, Burst: 10})
+	now := time.Now()
+
+	l.reserve("idle", 10, now.Add(-time.Hour))
+	l.reserve("active", 10, now)
+	l.Clean(10 * time.Minute)
+
+	if _, ok := l.buckets.Load("idle"); ok {
+		t.Fatal("idle bucket not cleaned")
+	}
+	if _, ok := l.buckets.Load("active"); !ok {
+		t.Fatal("active bucket cleaned")
+	}
+	if wait := l.reserve("idle", 10, now); wait != 0 {
+		t.Fatalf("expected full bucket after clean, wait %v", wait)
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/atoncooper/im/utils/idgen"
+	"github.com/redis/go-redis/v9"
+)
+
+// 机器id租约
+//
+// 雪花算法要求同一时刻每个机器id只被一个进程使用, 机器id从redis中租用
+// key : machineId:{id}    持有者标识, 带过期时间
+//
+// 1. 启动时从 preferred 开始依次尝试 SET NX, 全部被占用时拒绝启动
+// 2. 每隔 TTL/3 续约, 续约失败且机器id已被其他进程取得时租约失效, 之后不再生成id, 由 Lost 通知调用方
+// 3. 本地认为租约有效的时间比redis中的过期时间短, 租约过期前一定已停止生成id
+// 4. 停止时不直接删除, 而是缩短为 MACHINE_COOLDOWN 后过期, 避免新进程在时钟回拨时与本进程最后生成的id重复
+
+const (
+	MACHINE_PREFIX   = "machineId:"
+	MACHINE_COOLDOWN = 2 * time.Second
+
+	DEFAULT_MACHINE_TTL = 30 * time.Second
+)
+
+var (
+	ErrNoMachineId  = errors.New("no machine id available")
+	ErrMachineLease = errors.New("machine id lease lost")
+)
+
+// renewMachine 持有者一致时续约, 持有者为空时重新取得, 返回 1 表示租约有效
+var renewMachine = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// releaseMachine 持有者一致时缩短过期时间
+var releaseMachine = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+`)
+
+type MachineLease struct {
+	redis redis.UniversalClient
+	ttl   time.Duration
+	id    int64
+	token string
+
+	// 续约与释放互斥, 释放之后不会再续约
+	renewing sync.Mutex
+
+	mu         sync.Mutex
+	validUntil time.Time
+	lost       bool
+	lostCh     chan struct{} // 机器id被其他进程取得时关闭
+}
+
+// AcquireMachineId 租用一个机器id, preferred 为优先尝试的id, 通常由节点标识计算得到
+// node 为本节点地址, 与进程号一起作为持有者标识
+func AcquireMachineId(ctx context.Context, redis redis.UniversalClient, node string, preferred int64, ttl time.Duration) (*MachineLease, error) {
+	if ttl <= 0 {
+		ttl = DEFAULT_MACHINE_TTL
+	}
+	l := &MachineLease{
+		redis:  redis,
+		ttl:    ttl,
+		token:  fmt.Sprintf("%s/%d/%d", node, os.Getpid(), time.Now().UnixNano()),
+		lostCh: make(chan struct{}),
+	}
+
+	mask := int64(1)<<idgen.SnowflakeLayout.WorkerBits - 1
+	preferred &= mask
+	for i := int64(0); i <= mask; i++ {
+		id := (preferred + i) & mask
+		start := time.Now()
+		ok, err := redis.SetNX(ctx, machineKey(id), l.token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			l.id = id
+			l.validUntil = start.Add(ttl * 2 / 3)
+			return l, nil
+		}
+	}
+	return nil, ErrNoMachineId
+}
+
+func (l *MachineLease) Id() int64 {
+	return l.id
+}
+
+// Valid 租约是否有效, 无效时不能再生成id
+func (l *MachineLease) Valid() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.lost && time.Now().Before(l.validUntil)
+}
+
+// Lost 机器id被其他进程取得时关闭, 主动释放时不关闭
+func (l *MachineLease) Lost() <-chan struct{} {
+	return l.lostCh
+}
+
+// Run 定期续约直到ctx结束, 租约失效后停止续约
+func (l *MachineLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.renew(ctx); err != nil {
+				log.Printf("[ERROR] renew machine id %d: %v", l.id, err)
+				if err == ErrMachineLease {
+					return
+				}
+			}
+		}
+	}
+}
+
+// renew 续约, 租约已失效或已释放时不再续约, 否则会把已缩短为冷却时间的租约重新延长
+func (l *MachineLease) renew(ctx context.Context) error {
+	l.renewing.Lock()
+	defer l.renewing.Unlock()
+
+	l.mu.Lock()
+	lost := l.lost
+	l.mu.Unlock()
+	if lost {
+		return ErrMachineLease
+	}
+
+	start := time.Now()
+	ok, err := renewMachine.Run(ctx, l.redis, []string{machineKey(l.id)}, l.token, l.ttl.Milliseconds()).Bool()
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !ok {
+		l.lost = true
+		close(l.lostCh)
+		return ErrMachineLease
+	}
+	l.validUntil = start.Add(l.ttl * 2 / 3)
+	return nil
+}
+
+// Release 停止生成id并释放租约, 等待进行中的续约完成
+func (l *MachineLease) Release(ctx context.Context) error {
+	l.renewing.Lock()
+	defer l.renewing.Unlock()
+
+	l.mu.Lock()
+	l.lost = true
+	l.mu.Unlock()
+	return releaseMachine.Run(ctx, l.redis, []string{machineKey(l.id)}, l.token, MACHINE_COOLDOWN.Milliseconds()).Err()
+}
+
+func machineKey(id int64) string {
+	return fmt.Sprintf("%s%d", MACHINE_PREFIX, id)
+}
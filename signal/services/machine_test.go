@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atoncooper/im/utils/idgen"
+)
+
+// 测试已租出的机器id不能被其他节点取得, 释放后缩短为冷却时间
+func TestMachineLeased(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+
+	a, err := AcquireMachineId(ctx, rdb, "node-1:8080", 5, time.Minute)
+	if err != nil || a.Id() != 5 || !a.Valid() {
+		t.Fatalf("expected id 5, got %v, %v", a, err)
+	}
+	b, err := AcquireMachineId(ctx, rdb, "node-2:8080", 5, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Id() == a.Id() {
+		t.Fatalf("machine id %d leased twice", a.Id())
+	}
+
+	// 其他节点续约不会取得已租出的id
+	if ok, _ := renewMachine.Run(ctx, rdb, []string{machineKey(a.Id())}, b.token, time.Minute.Milliseconds()).Bool(); ok {
+		t.Fatal("renewed a machine id leased by another node")
+	}
+	if owner, _ := mr.Get(machineKey(a.Id())); owner != a.token {
+		t.Fatalf("owner changed to %s", owner)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.Valid() {
+		t.Fatal("lease still valid after release")
+	}
+	if ttl := mr.TTL(machineKey(a.Id())); ttl <= 0 || ttl > MACHINE_COOLDOWN {
+		t.Fatalf("expected cooldown ttl, got %v", ttl)
+	}
+	mr.FastForward(MACHINE_COOLDOWN)
+	c, err := AcquireMachineId(ctx, rdb, "node-3:8080", 5, time.Minute)
+	if err != nil || c.Id() != 5 {
+		t.Fatalf("expected released id 5, got %v, %v", c, err)
+	}
+}
+
+// 测试优先的id被占用时依次尝试之后的id, 超过最大值时从0开始
+func TestMachineFallback(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+
+	mask := int64(1)<<idgen.SnowflakeLayout.WorkerBits - 1
+	mr.Set(machineKey(mask), "other")
+	mr.Set(machineKey(0), "other")
+
+	l, err := AcquireMachineId(ctx, rdb, "node-1:8080", mask, time.Minute)
+	if err != nil || l.Id() != 1 {
+		t.Fatalf("expected id 1, got %v, %v", l, err)
+	}
+}
+
+// 测试全部id被占用时拒绝启动
+func TestMachineExhausted(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+
+	for id := int64(0); id < 1<<idgen.SnowflakeLayout.WorkerBits; id++ {
+		mr.Set(machineKey(id), "other")
+	}
+	if _, err := AcquireMachineId(ctx, rdb, "node-1:8080", 0, time.Minute); err != ErrNoMachineId {
+		t.Fatalf("expected ErrNoMachineId, got %v", err)
+	}
+}
+
+// 测试续约时发现id已被其他进程取得, 租约失效并停止续约
+func TestMachineLost(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+
+	l, err := AcquireMachineId(ctx, rdb, "node-1:8080", 0, 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.renew(ctx); err != nil || !l.Valid() {
+		t.Fatalf("renew: %v", err)
+	}
+
+	mr.Set(machineKey(l.Id()), "other")
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("renewal not stopped after lease lost")
+	}
+	if l.Valid() {
+		t.Fatal("lease still valid after lost")
+	}
+	if err := l.renew(ctx); err != ErrMachineLease {
+		t.Fatalf("expected ErrMachineLease, got %v", err)
+	}
+	select {
+	case <-l.Lost():
+	default:
+		t.Fatal("lost not notified")
+	}
+}
+
+// 测试释放后不再续约, 冷却时间不会被重新延长
+func TestMachineRenewAfterRelease(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+
+	l, err := AcquireMachineId(ctx, rdb, "node-1:8080", 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.renew(ctx); err != ErrMachineLease {
+		t.Fatalf("expected ErrMachineLease, got %v", err)
+	}
+	if ttl := mr.TTL(machineKey(l.Id())); ttl <= 0 || ttl > MACHINE_COOLDOWN {
+		t.Fatalf("lease renewed after release, ttl %v", ttl)
+	}
+	select {
+	case <-l.Lost():
+		t.Fatal("release must not be reported as lost")
+	default:
+	}
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 号段分配
+//
+// 每个会话由一个signal节点负责分配seq, 所有者一次从redis租用一段连续的seq(号段), 之后在内存中逐个分配
+// key : seq:{conversation}            已租出的最大seq
+// key : {seq:{conversation}}:owner    当前所有者的地址, 带过期时间, 与计数器在同一slot
+//
+// 1. 租用号段与续约所有权在同一个脚本中完成, 其他节点持有所有权时返回对方地址, 由调用方转发
+// 2. 当前号段剩余不足 prefetch 比例时异步租用下一段(双缓冲), 当前号段用完时直接切换
+// 3. 所有权到期前不再分配, 需要重新确认所有权; 计数器仍等于本地已租出的最大seq时号段继续有效
+// 4. 会话空闲超过租约时间后释放所有权, 计数器未变化时归还未分配的seq, 避免出现空洞
+// 5. 计数器不存在或小于本地已租出的最大seq时, 说明redis数据丢失或回退, 先恢复计数器(见 checkpoint.go)
+//
+// 只有所有者会增加计数器, 两个节点不会分配出同一个seq, 同一会话的seq也严格按分配顺序递增
+
+const (
+	SEQ_OWNER_SUFFIX = "}:owner"
+
+	DEFAULT_SEQ_STEP     = 100
+	DEFAULT_SEQ_LEASE    = 10 * time.Second
+	DEFAULT_SEQ_PREFETCH = 0.2
+)
+
+// NotOwnerError 会话由其他节点负责分配
+type NotOwnerError struct {
+	ConversationId string
+	Owner          string
+}
+
+func (e *NotOwnerError) Error() string {
+	return fmt.Sprintf("conversation %s is owned by %s", e.ConversationId, e.Owner)
+}
+
+var ErrCounterMissing = errors.New("seq counter missing")
+
+// acquire 确认或取得所有权并续约, ARGV[3] 大于0时同时租用号段
+// 返回 {计数器的值, 所有者}, 其他节点持有所有权时计数器的值为 -1, 计数器不存在时为 -2
+var acquire = redis.NewScript(`
+local owner = redis.call('GET', KEYS[2])
+if owner and owner ~= ARGV[1] then
+	return {-1, owner}
+end
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+	return {-2, ARGV[1]}
+end
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+if tonumber(ARGV[3]) == 0 then
+	return {tonumber(cur), ARGV[1]}
+end
+return {redis.call('INCRBY', KEYS[1], ARGV[3]), ARGV[1]}
+`)
+
+// release 释放所有权, 计数器仍等于 ARGV[2] 时回退到 ARGV[3], 归还未分配的seq
+var release = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(redis.call('GET', KEYS[1]) or '0') == tonumber(ARGV[2]) then
+	redis.call('SET', KEYS[1], ARGV[3])
+end
+redis.call('DEL', KEYS[2])
+return 1
+`)
+
+type SegmentConfig struct {
+	Node     string        // 本节点地址 host:port, 其他节点据此转发
+	Step     int64         // 每个号段的长度
+	Lease    time.Duration // 所有权的租约时间
+	Prefetch float64       // 当前号段剩余比例低于该值时预取下一段
+}
+
+// segment 号段 [next, end]
+type segment struct {
+	next, end int64
+}
+
+func (s *segment) remaining() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.end - s.next + 1
+}
+
+type conversation struct {
+	// 串行化同一会话的分配, 等待预取时 mu 会被释放, 由 alloc 保证批次之间不交错
+	alloc    sync.Mutex
+	mu       sync.Mutex
+	cur, nxt *segment
+	// 预取中的号段, 完成后关闭
+	loading chan struct{}
+	// 本地认为所有权有效的截止时间, 比redis中的过期时间提前, 留出时钟误差与网络延迟
+	validUntil time.Time
+	lastUsed   time.Time
+	// 已从分配器中移除, 持有旧指针的调用方需要重新获取
+	removed bool
+}
+
+// leased 本地已租出的最大seq, 没有号段时为0
+func (c *conversation) leased() int64 {
+	if c.nxt != nil {
+		return c.nxt.end
+	}
+	if c.cur != nil {
+		return c.cur.end
+	}
+	return 0
+}
+
+func (c *conversation) reset() {
+	c.cur, c.nxt = nil, nil
+	c.validUntil = time.Time{}
+}
+
+type SegmentAllocator struct {
+	redis redis.UniversalClient
+	conf  *SegmentConfig
+	// recover 计数器不存在或回退时调用, 把计数器恢复到不小于 floor 的值
+	recover func(ctx context.Context, key string, floor int64) error
+	// leased 租用号段后调用, end 为已租出的最大seq
+	leased func(key string, end int64)
+
+	mu    sync.Mutex
+	convs map[string]*conversation
+}
+
+func NewSegmentAllocator(redis redis.UniversalClient, conf *SegmentConfig) *SegmentAllocator {
+	if conf.Step <= 0 {
+		conf.Step = DEFAULT_SEQ_STEP
+	}
+	if conf.Lease <= 0 {
+		conf.Lease = DEFAULT_SEQ_LEASE
+	}
+	if conf.Prefetch <= 0 || conf.Prefetch >= 1 {
+		conf.Prefetch = DEFAULT_SEQ_PREFETCH
+	}
+	return &SegmentAllocator{
+		redis: redis,
+		conf:  conf,
+		convs: make(map[string]*conversation),
+	}
+}
+
+// Next 分配会话的下一个seq, key 为会话计数器
+// 会话由其他节点负责时返回 *NotOwnerError
+func (a *SegmentAllocator) Next(ctx context.Context, key string) (int64, error) {
+	seqs, err := a.NextN(ctx, key, 1)
+	if err != nil {
+		return 0, err
+	}
+	return seqs[0], nil
+}
+
+// NextN 连续分配会话的 n 个seq, 分配期间持有会话的锁, 同一批次之间不会插入其他seq
+func (a *SegmentAllocator) NextN(ctx context.Context, key string, n int) ([]int64, error) {
+	c := a.conversation(key)
+	c.alloc.Lock()
+	c.mu.Lock()
+	for c.removed {
+		c.mu.Unlock()
+		c.alloc.Unlock()
+		c = a.conversation(key)
+		c.alloc.Lock()
+		c.mu.Lock()
+	}
+	defer c.alloc.Unlock()
+	defer c.mu.Unlock()
+
+	seqs := make([]int64, 0, n)
+	for len(seqs) < n {
+		seq, err := a.next(ctx, key, c)
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+// next 分配一个seq, 调用方持有 c.mu
+func (a *SegmentAllocator) next(ctx context.Context, key string, c *conversation) (int64, error) {
+	c.lastUsed = time.Now()
+	if c.lastUsed.After(c.validUntil) {
+		if err := a.confirm(ctx, key, c); err != nil {
+			return 0, err
+		}
+	}
+
+	for c.cur.remaining() == 0 {
+		if c.nxt != nil {
+			c.cur, c.nxt = c.nxt, nil
+			break
+		}
+		// 预取尚未完成时等待, 不能再同时租用, 否则两个号段的先后顺序无法确定
+		if ready := c.loading; ready != nil {
+			c.mu.Unlock()
+			select {
+			case <-ready:
+				c.mu.Lock()
+				continue
+			case <-ctx.Done():
+				c.mu.Lock()
+				return 0, ctx.Err()
+			}
+		}
+		end, err := a.acquire(ctx, key, c, a.conf.Step)
+		if err != nil {
+			return 0, err
+		}
+		c.cur = &segment{next: end - a.conf.Step + 1, end: end}
+		a.notify(key, end)
+	}
+
+	seq := c.cur.next
+	c.cur.next++
+
+	if c.nxt == nil && c.loading == nil && float64(c.cur.remaining()) < float64(a.conf.Step)*a.conf.Prefetch {
+		c.loading = make(chan struct{})
+		go a.prefetch(key, c, c.cur.end)
+	}
+	return seq, nil
+}
+
+// confirm 重新确认所有权, 计数器已被其他节点修改时丢弃本地号段
+// 没有号段时不需要确认, 由租用号段时取得所有权, 调用方持有 c.mu
+func (a *SegmentAllocator) confirm(ctx context.Context, key string, c *conversation) error {
+	if c.leased() == 0 {
+		return nil
+	}
+	counter, err := a.acquire(ctx, key, c, 0)
+	if err != nil {
+		return err
+	}
+	if counter < c.leased() {
+		log.Printf("[WARN] seq counter of %s went back: %d, leased %d", key, counter, c.leased())
+		if err := a.recover(ctx, key, c.leased()); err != nil {
+			return err
+		}
+		c.cur, c.nxt = nil, nil
+	} else if counter != c.leased() {
+		log.Printf("[WARN] seq counter of %s changed: %d, leased %d", key, counter, c.leased())
+		c.cur, c.nxt = nil, nil
+	}
+	return nil
+}
+
+// acquire 执行 acquire 脚本并更新本地的所有权截止时间, 返回计数器的值, 调用方持有 c.mu
+// 计数器不存在时先恢复再重试一次
+func (a *SegmentAllocator) acquire(ctx context.Context, key string, c *conversation, step int64) (int64, error) {
+	start := time.Now()
+	counter, err := a.run(ctx, key, step)
+	if err == ErrCounterMissing {
+		if err := a.recover(ctx, key, c.leased()); err != nil {
+			return 0, err
+		}
+		c.cur, c.nxt = nil, nil
+		start = time.Now()
+		counter, err = a.run(ctx, key, step)
+	}
+	if err != nil {
+		if _, ok := err.(*NotOwnerError); ok {
+			c.reset()
+		}
+		return 0, err
+	}
+	// 以发出请求的时间计算, 本地截止时间只会早于redis中的过期时间
+	c.validUntil = start.Add(a.conf.Lease * 2 / 3)
+	return counter, nil
+}
+
+func (a *SegmentAllocator) run(ctx context.Context, key string, step int64) (int64, error) {
+	res, err := acquire.Run(ctx, a.redis, []string{key, ownerKey(key)}, a.conf.Node, a.conf.Lease.Milliseconds(), step).Slice()
+	if err != nil {
+		return 0, err
+	}
+	counter, _ := res[0].(int64)
+	switch counter {
+	case -1:
+		owner, _ := res[1].(string)
+		return 0, &NotOwnerError{ConversationId: key, Owner: owner}
+	case -2:
+		return 0, ErrCounterMissing
+	}
+	return counter, nil
+}
+
+func (a *SegmentAllocator) notify(key string, end int64) {
+	if a.leased != nil {
+		a.leased(key, end)
+	}
+}
+
+// prefetch 异步租用紧接 end 的下一个号段, 请求redis期间不持有锁
+func (a *SegmentAllocator) prefetch(key string, c *conversation, end int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.conf.Lease/2)
+	defer cancel()
+
+	start := time.Now()
+	last, err := a.run(ctx, key, a.conf.Step)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.loading)
+	c.loading = nil
+	if err != nil {
+		log.Printf("[WARN] prefetch seq segment of %s: %v", key, err)
+		if _, ok := err.(*NotOwnerError); ok {
+			c.reset()
+		}
+		return
+	}
+	c.validUntil = start.Add(a.conf.Lease * 2 / 3)
+	a.notify(key, last)
+	seg := &segment{next: last - a.conf.Step + 1, end: last}
+	// 只有所有者会增加计数器, 新号段应当紧接当前号段
+	// 否则期间所有权曾被其他节点取得, 当前号段中剩余的seq已不能保证递增, 直接丢弃
+	if seg.next != end+1 || c.leased() != end {
+		log.Printf("[WARN] seq segment of %s is not contiguous: %d after %d", key, seg.next, end)
+		c.cur, c.nxt = seg, nil
+		return
+	}
+	c.nxt = seg
+}
+
+func (a *SegmentAllocator) conversation(key string) *conversation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.convs[key]
+	if !ok {
+		c = &conversation{}
+		a.convs[key] = c
+	}
+	return c
+}
+
+// Run 定期为活跃的会话续约, 释放空闲的会话
+func (a *SegmentAllocator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.conf.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.renew(ctx, now)
+		}
+	}
+}
+
+func (a *SegmentAllocator) renew(ctx context.Context, now time.Time) {
+	a.mu.Lock()
+	convs := make(map[string]*conversation, len(a.convs))
+	for key, c := range a.convs {
+		convs[key] = c
+	}
+	a.mu.Unlock()
+
+	for key, c := range convs {
+		c.mu.Lock()
+		switch {
+		case c.loading != nil:
+		case c.leased() == 0:
+			if now.Sub(c.lastUsed) > a.conf.Lease {
+				a.forget(key, c)
+			}
+		case now.Sub(c.lastUsed) > a.conf.Lease:
+			if err := a.release(ctx, key, c); err != nil {
+				log.Printf("[WARN] release seq segment of %s: %v", key, err)
+			}
+			a.forget(key, c)
+		default:
+			if err := a.confirm(ctx, key, c); err != nil {
+				log.Printf("[WARN] renew seq segment of %s: %v", key, err)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// forget 从本地移除会话, 调用方持有 c.mu
+func (a *SegmentAllocator) forget(key string, c *conversation) {
+	a.mu.Lock()
+	if a.convs[key] == c {
+		delete(a.convs, key)
+	}
+	a.mu.Unlock()
+	c.reset()
+	c.removed = true
+}
+
+// release 释放所有权并归还未分配的seq, 调用方持有 c.mu
+func (a *SegmentAllocator) release(ctx context.Context, key string, c *conversation) error {
+	leased := c.leased()
+	if leased == 0 {
+		return nil
+	}
+	used := c.cur.next - 1
+	if c.cur.remaining() == 0 && c.nxt != nil {
+		used = c.nxt.next - 1
+	}
+	return release.Run(ctx, a.redis, []string{key, ownerKey(key)}, a.conf.Node, leased, used).Err()
+}
+
+// Close 释放全部会话的所有权, 停止服务前调用
+func (a *SegmentAllocator) Close(ctx context.Context) {
+	a.mu.Lock()
+	convs := a.convs
+	a.convs = make(map[string]*conversation)
+	a.mu.Unlock()
+
+	for key, c := range convs {
+		c.mu.Lock()
+		if err := a.release(ctx, key, c); err != nil {
+			log.Printf("[WARN] release seq segment of %s: %v", key, err)
+		}
+		c.reset()
+		c.removed = true
+		c.mu.Unlock()
+	}
+}
+
+// ownerKey 以计数器的key为hash tag, 保证两者在同一slot
+func ownerKey(key string) string {
+	return "{" + key + SEQ_OWNER_SUFFIX
+}
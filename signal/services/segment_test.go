@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testSeqKey = "seq:s:alice:bob"
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+// newTestAllocator 计数器从0开始, 号段长度为 step
+func newTestAllocator(t *testing.T, rdb redis.UniversalClient, node string, step int64, prefetch float64) *SegmentAllocator {
+	t.Helper()
+	if err := rdb.SetNX(context.Background(), testSeqKey, 0, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	return NewSegmentAllocator(rdb, &SegmentConfig{Node: node, Step: step, Lease: time.Minute, Prefetch: prefetch})
+}
+
+// 测试跨越多个号段时seq连续分配, 计数器只租出用到的号段
+func TestSegmentContiguous(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	a := newTestAllocator(t, rdb, "node-1", 10, 0.3)
+
+	seqs, err := a.NextN(ctx, testSeqKey, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 15; i++ {
+		seq, err := a.Next(ctx, testSeqKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, seq)
+	}
+	for i, seq := range seqs {
+		if seq != int64(i+1) {
+			t.Fatalf("seq %d at %d, want %d: %v", seq, i, i+1, seqs)
+		}
+	}
+
+	// 等待预取完成, 最多预租一个号段
+	waitLoaded(t, a, testSeqKey)
+	if counter, _ := rdb.Get(ctx, testSeqKey).Int64(); counter != 40 && counter != 50 {
+		t.Fatalf("unexpected counter %d", counter)
+	}
+}
+
+// 测试其他节点持有所有权时拒绝分配, 并返回所有者
+func TestSegmentNotOwner(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	a := newTestAllocator(t, rdb, "node-1", 10, 0.2)
+	b := newTestAllocator(t, rdb, "node-2", 10, 0.2)
+
+	if _, err := a.Next(ctx, testSeqKey); err != nil {
+		t.Fatal(err)
+	}
+	_, err := b.Next(ctx, testSeqKey)
+	var notOwner *NotOwnerError
+	if !errors.As(err, &notOwner) || notOwner.Owner != "node-1" {
+		t.Fatalf("expected NotOwnerError from node-1, got %v", err)
+	}
+	if counter, _ := rdb.Get(ctx, testSeqKey).Int64(); counter != 10 {
+		t.Fatalf("non-owner must not lease, counter %d", counter)
+	}
+}
+
+// 测试释放所有权时归还未分配的seq, 其他节点从已分配的seq之后继续
+func TestSegmentRelease(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	a := newTestAllocator(t, rdb, "node-1", 10, 0.2)
+	b := newTestAllocator(t, rdb, "node-2", 10, 0.2)
+
+	if _, err := a.NextN(ctx, testSeqKey, 3); err != nil {
+		t.Fatal(err)
+	}
+	a.Close(ctx)
+	if counter, _ := rdb.Get(ctx, testSeqKey).Int64(); counter != 3 {
+		t.Fatalf("expected counter rolled back to 3, got %d", counter)
+	}
+	if n := rdb.Exists(ctx, ownerKey(testSeqKey)).Val(); n != 0 {
+		t.Fatal("owner not released")
+	}
+
+	seq, err := b.Next(ctx, testSeqKey)
+	if err != nil || seq != 4 {
+		t.Fatalf("expected 4 from new owner, got %d, %v", seq, err)
+	}
+
+	// 计数器已被其他节点修改时不回退
+	a = newTestAllocator(t, rdb, "node-1", 10, 0.2)
+	b.Close(ctx)
+	rdb.Set(ctx, testSeqKey, 100, 0)
+	rdb.Set(ctx, ownerKey(testSeqKey), "node-2", 0)
+	if err := a.release(ctx, testSeqKey, &conversation{cur: &segment{next: 4, end: 13}}); err != nil {
+		t.Fatal(err)
+	}
+	if counter, _ := rdb.Get(ctx, testSeqKey).Int64(); counter != 100 {
+		t.Fatalf("counter of other owner rolled back to %d", counter)
+	}
+}
+
+// 测试并发分配与预取同时进行时不会分配出重复的seq
+func TestSegmentPrefetchUnique(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	a := newTestAllocator(t, rdb, "node-1", 5, 0.8)
+
+	const workers, each = 8, 50
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				n := 1 + j%3
+				seqs, err := a.NextN(ctx, testSeqKey, n)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				for k, seq := range seqs {
+					if seen[seq] {
+						t.Errorf("seq %d allocated twice", seq)
+					}
+					seen[seq] = true
+					if k > 0 && seq != seqs[k-1]+1 {
+						t.Errorf("batch not contiguous: %v", seqs)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for seq := int64(1); seq <= int64(len(seen)); seq++ {
+		if !seen[seq] {
+			t.Fatalf("seq %d skipped, allocated %d", seq, len(seen))
+		}
+	}
+}
+
+func waitLoaded(t *testing.T, a *SegmentAllocator, key string) {
+	t.Helper()
+	c := a.conversation(key)
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		loading := c.loading
+		c.mu.Unlock()
+		if loading == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("prefetch not finished")
+}
@@ -0,0 +1,239 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+	"storage/store"
+)
+
+// 会话seq检查点
+//
+// signal在redis中为每个会话维护seq计数器, 并定期把已租出的最大seq写入检查点
+// redis数据丢失或故障切换导致计数器回退时, 以 max(检查点, 已存储消息的最大seq) 加上安全间隔恢复
+// 检查点只增不减, 多个signal节点并发写入同一会话时保留较大的值
+
+type Checkpoint struct {
+	ConversationId string
+	Seq            int64
+}
+
+type Store interface {
+	// Save 批量写入检查点, 小于已有值的忽略
+	Save(ctx context.Context, checkpoints []Checkpoint) error
+	// Get 批量查询, 不存在的会话不在结果中
+	Get(ctx context.Context, conversationIds []string) (map[string]int64, error)
+	// Scan 按会话id升序遍历 after 之后的检查点, 最多返回 limit 个
+	Scan(ctx context.Context, after string, limit int) ([]Checkpoint, bool, error)
+	Close() error
+}
+
+// BoltStore 与 BoltStore 消息存储配合的嵌入式检查点存储
+//
+// bucket checkpoints : 会话id -> seq(8)
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var bucketCheckpoints = []byte("checkpoints")
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		path = "data/checkpoints.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketCheckpoints)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Save(ctx context.Context, checkpoints []Checkpoint) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketCheckpoints)
+		for _, cp := range checkpoints {
+			if cp.ConversationId == "" {
+				return store.ErrInvalidArgument
+			}
+			k := []byte(cp.ConversationId)
+			if v := bucket.Get(k); v != nil && int64(binary.BigEndian.Uint64(v)) >= cp.Seq {
+				continue
+			}
+			if err := bucket.Put(k, binary.BigEndian.AppendUint64(nil, uint64(cp.Seq))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) Get(ctx context.Context, conversationIds []string) (map[string]int64, error) {
+	found := make(map[string]int64, len(conversationIds))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketCheckpoints)
+		for _, id := range conversationIds {
+			if v := bucket.Get([]byte(id)); v != nil {
+				found[id] = int64(binary.BigEndian.Uint64(v))
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (b *BoltStore) Scan(ctx context.Context, after string, limit int) ([]Checkpoint, bool, error) {
+	var (
+		checkpoints []Checkpoint
+		hasMore     bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketCheckpoints).Cursor()
+		for k, v := c.Seek([]byte(after)); k != nil; k, v = c.Next() {
+			if bytes.Equal(k, []byte(after)) {
+				continue
+			}
+			if len(checkpoints) == limit {
+				hasMore = true
+				break
+			}
+			checkpoints = append(checkpoints, Checkpoint{ConversationId: string(k), Seq: int64(binary.BigEndian.Uint64(v))})
+		}
+		return nil
+	})
+	return checkpoints, hasMore, err
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// SQLStore 与 SQLStore 消息存储配合的检查点存储, 多个storage节点共享同一张表
+// 每个会话一行, 不分片, 固定存储在第一个分片
+type SQLStore struct {
+	dialect *store.Dialect
+	db      *sql.DB
+}
+
+// conversation_id 与 messages 表一致按字节比较, s:Alice:bob 与 s:alice:bob 是两个会话
+const schema = `
+CREATE TABLE IF NOT EXISTS seq_checkpoints (
+	conversation_id VARCHAR(160) COLLATE %s NOT NULL PRIMARY KEY,
+	seq             BIGINT       NOT NULL
+) %s`
+
+func NewSQLStore(ctx context.Context, dialect *store.Dialect, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(schema, dialect.Collate, dialect.Options)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLStore{dialect: dialect, db: db}, nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, checkpoints []Checkpoint) error {
+	query := "INSERT INTO seq_checkpoints (conversation_id, seq) VALUES (?, ?) " +
+		"ON DUPLICATE KEY UPDATE seq = GREATEST(seq, VALUES(seq))"
+	if s.dialect == store.Postgres {
+		query = "INSERT INTO seq_checkpoints (conversation_id, seq) VALUES (?, ?) " +
+			"ON CONFLICT (conversation_id) DO UPDATE SET seq = GREATEST(seq_checkpoints.seq, EXCLUDED.seq)"
+	}
+	query = s.dialect.Rebind(query)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, cp := range checkpoints {
+		if cp.ConversationId == "" {
+			return store.ErrInvalidArgument
+		}
+		if _, err := tx.ExecContext(ctx, query, cp.ConversationId, cp.Seq); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Get(ctx context.Context, conversationIds []string) (map[string]int64, error) {
+	found := make(map[string]int64, len(conversationIds))
+	if len(conversationIds) == 0 {
+		return found, nil
+	}
+	args := make([]any, len(conversationIds))
+	marks := make([]byte, 0, 2*len(conversationIds))
+	for i, id := range conversationIds {
+		args[i] = id
+		if i > 0 {
+			marks = append(marks, ',')
+		}
+		marks = append(marks, '?')
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		"SELECT conversation_id, seq FROM seq_checkpoints WHERE conversation_id IN ("+string(marks)+")"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id  string
+			seq int64
+		)
+		if err := rows.Scan(&id, &seq); err != nil {
+			return nil, err
+		}
+		found[id] = seq
+	}
+	return found, rows.Err()
+}
+
+func (s *SQLStore) Scan(ctx context.Context, after string, limit int) ([]Checkpoint, bool, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		"SELECT conversation_id, seq FROM seq_checkpoints WHERE conversation_id > ? ORDER BY conversation_id LIMIT ?"),
+		after, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		if err := rows.Scan(&cp.ConversationId, &cp.Seq); err != nil {
+			return nil, false, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(checkpoints) > limit {
+		return checkpoints[:limit], true, nil
+	}
+	return checkpoints, false, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,53 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "checkpoints.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	err = s.Save(ctx, []Checkpoint{{"s:u1:u2", 100}, {"g:1", 300}, {"s:u1:u3", 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 检查点只增不减
+	if err := s.Save(ctx, []Checkpoint{{"s:u1:u2", 50}, {"g:1", 400}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Get(ctx, []string{"s:u1:u2", "g:1", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["s:u1:u2"] != 100 || got["g:1"] != 400 {
+		t.Fatalf("unexpected checkpoints %v", got)
+	}
+
+	var all []string
+	after := ""
+	for {
+		page, hasMore, err := s.Scan(ctx, after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cp := range page {
+			all = append(all, fmt.Sprintf("%s=%d", cp.ConversationId, cp.Seq))
+		}
+		if !hasMore {
+			break
+		}
+		after = page[len(page)-1].ConversationId
+	}
+	if fmt.Sprint(all) != "[g:1=400 s:u1:u2=100 s:u1:u3=5]" {
+		t.Fatalf("unexpected scan %v", all)
+	}
+}
@@ -0,0 +1,184 @@
+package configs
+
+import (
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Application struct {
+		Name   string `mapstructure:"name"`
+		Host   string `mapstructure:"host"`
+		Port   int    `mapstructure:"port"`
+		NodeId string `mapstructure:"nodeId"`
+
+		Inbox struct {
+			MaxSize int    `mapstructure:"maxSize"` // 每个用户收件箱最多保留的消息数
+			TTL     string `mapstructure:"ttl"`     // 收件箱消息保留时间
+		} `mapstructure:"inbox"`
+
+		// 消息历史存储
+		Store struct {
+			Driver string `mapstructure:"driver"` // bolt | mysql | postgres
+			Path   string `mapstructure:"path"`   // bolt 数据文件
+
+			// mysql / postgres 按会话id分片, 每个分片一个dsn
+			Shards       []string `mapstructure:"shards"`
+			MaxOpenConns int      `mapstructure:"maxOpenConns"`
+			MaxIdleConns int      `mapstructure:"maxIdleConns"`
+			MaxLifetime  string   `mapstructure:"maxLifetime"`
+		} `mapstructure:"store"`
+
+		// 消息payload静态加密, 每个会话一个数据密钥, 由主密钥加密后保存
+		Encryption struct {
+			Enabled        bool     `mapstructure:"enabled"`
+			KeyFile        string   `mapstructure:"keyFile"`        // 主密钥文件
+			KeyPath        string   `mapstructure:"keyPath"`        // bolt 驱动时数据密钥的存储文件, sql 驱动时存储在第一个分片
+			DataKeyTTL     string   `mapstructure:"dataKeyTTL"`     // 数据密钥轮换周期, 为0时不轮换
+			RotateInterval string   `mapstructure:"rotateInterval"` // 重新加载主密钥文件并重新加密旧数据密钥的间隔
+			Index          []string `mapstructure:"index"`          // 加密后仍建立全文索引的会话类型, * 表示全部
+		} `mapstructure:"encryption"`
+
+		// 冷热分层存储, 早于 age 的消息按会话分段压缩后归档到对象存储, 查询时透明读取
+		// 分段索引为本地文件, 多个storage节点共用同一个主存储时只能在一个节点上开启
+		Tiered struct {
+			Enabled     bool   `mapstructure:"enabled"`
+			IndexPath   string `mapstructure:"indexPath"`   // 分段索引文件
+			ObjectDir   string `mapstructure:"objectDir"`   // 本地目录模拟的对象存储
+			Age         string `mapstructure:"age"`         // 早于该时间的消息归档
+			Interval    string `mapstructure:"interval"`    // 归档间隔
+			SegmentSize int    `mapstructure:"segmentSize"` // 每个分段最多的消息数
+			RestoreTTL  string `mapstructure:"restoreTTL"`  // 恢复后多长时间内不再归档
+		} `mapstructure:"tiered"`
+
+		// 会话seq检查点, bolt 驱动时存储在独立的文件, sql 驱动时存储在第一个分片
+		Checkpoint struct {
+			Path string `mapstructure:"path"`
+		} `mapstructure:"checkpoint"`
+
+		// 用户时间线, bolt 驱动时存储在独立的文件, sql 驱动时按uid分片存储在消息存储的分片中
+		Timeline struct {
+			Path     string `mapstructure:"path"`
+			TTL      string `mapstructure:"ttl"`      // 事件的保留时间, 为空或0时永久保留
+			Interval string `mapstructure:"interval"` // 清理间隔
+		} `mapstructure:"timeline"`
+
+		// 全文检索
+		Search struct {
+			Path string `mapstructure:"path"` // 倒排索引数据文件
+		} `mapstructure:"search"`
+
+		// 消息保留策略, 按顺序匹配, 第一条匹配的策略生效
+		Retention struct {
+			Enabled    bool              `mapstructure:"enabled"`
+			Interval   string            `mapstructure:"interval"`   // 清理间隔
+			DryRun     bool              `mapstructure:"dryRun"`     // 只生成报告, 不删除
+			BatchSize  int               `mapstructure:"batchSize"`  // 每批遍历的消息数
+			ReportDir  string            `mapstructure:"reportDir"`  // 清理报告目录
+			ArchiveDir string            `mapstructure:"archiveDir"` // archive 策略的归档目录
+			Policies   []RetentionPolicy `mapstructure:"policies"`
+
+			// 删除过期媒体消息在obs中的对象
+			Media struct {
+				Enabled bool   `mapstructure:"enabled"`
+				ObsUrl  string `mapstructure:"obsUrl"`
+				Secret  string `mapstructure:"secret"` // 与obs的签名密钥一致
+			} `mapstructure:"media"`
+		} `mapstructure:"retention"`
+
+		// 用户数据导出与擦除
+		Privacy struct {
+			AuditDir string `mapstructure:"auditDir"` // 审计日志目录
+
+			// 导出及删除用户在obs中的媒体
+			Media struct {
+				Enabled bool   `mapstructure:"enabled"`
+				ObsUrl  string `mapstructure:"obsUrl"`
+				Secret  string `mapstructure:"secret"` // 与obs的签名密钥一致
+			} `mapstructure:"media"`
+		} `mapstructure:"privacy"`
+
+		Component struct {
+			Consul struct {
+				Endpoint string `mapstructure:"endpoint"`
+				Port     int    `mapstructure:"port"`
+				Scheme   string `mapstructure:"scheme"`
+				Service  struct {
+					Register bool     `mapstructure:"register"`
+					Tags     []string `mapstructure:"tags"`
+				} `mapstructure:"service"`
+			} `mapstructure:"consul"`
+
+			Redis struct {
+				Nodes    []string `mapstructure:"nodes"`
+				Password string   `mapstructure:"password"`
+			} `mapstructure:"redis"`
+		} `mapstructure:"component"`
+	} `mapstructure:"application"`
+}
+
+type RetentionPolicy struct {
+	Name        string `mapstructure:"name"`
+	Tenant      string `mapstructure:"tenant"`      // 为空或 * 表示不限
+	SessionType string `mapstructure:"sessionType"` // single | group | system, 为空表示不限
+	MessageType string `mapstructure:"messageType"` // text | image | audio | video | file | custom | encrypted, 为空表示不限
+	TTL         string `mapstructure:"ttl"`         // 为0时永久保留
+	Action      string `mapstructure:"action"`      // delete | archive
+}
+
+var Cfg atomic.Pointer[Config]
+
+func Get() *Config {
+	return Cfg.Load()
+}
+
+// Load 从工作目录读取 storage.yaml
+func Load() (*Config, error) {
+	v := viper.New()
+
+	v.SetConfigName("storage")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+
+	v.SetDefault("application.port", 50061)
+	v.SetDefault("application.inbox.maxSize", 2000)
+	v.SetDefault("application.inbox.ttl", "168h")
+	v.SetDefault("application.store.driver", "bolt")
+	v.SetDefault("application.store.path", "data/messages.db")
+	v.SetDefault("application.store.maxOpenConns", 50)
+	v.SetDefault("application.store.maxIdleConns", 10)
+	v.SetDefault("application.store.maxLifetime", "30m")
+	v.SetDefault("application.search.path", "data/search.db")
+	v.SetDefault("application.checkpoint.path", "data/checkpoints.db")
+	v.SetDefault("application.encryption.keyFile", "data/master.json")
+	v.SetDefault("application.encryption.keyPath", "data/keys.db")
+	v.SetDefault("application.encryption.dataKeyTTL", "720h")
+	v.SetDefault("application.encryption.rotateInterval", "1m")
+	v.SetDefault("application.tiered.indexPath", "data/segments.db")
+	v.SetDefault("application.tiered.objectDir", "data/cold")
+	v.SetDefault("application.tiered.age", "2160h")
+	v.SetDefault("application.tiered.interval", "1h")
+	v.SetDefault("application.tiered.segmentSize", 1000)
+	v.SetDefault("application.tiered.restoreTTL", "168h")
+	v.SetDefault("application.retention.interval", "1h")
+	v.SetDefault("application.retention.batchSize", 200)
+	v.SetDefault("application.retention.reportDir", "data/retention")
+	v.SetDefault("application.retention.archiveDir", "data/archive")
+	v.SetDefault("application.privacy.auditDir", "data/privacy")
+
+	v.SetEnvPrefix("STORAGE")
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	Cfg.Store(&c)
+
+	return &c, nil
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type GrpcConfig struct {
+	Host           string
+	Port           int
+	Network        string
+	MaxRecvMsgSize int
+	StopTimeout    time.Duration
+}
+
+func newDefaultGrpcConfig() *GrpcConfig {
+	return &GrpcConfig{
+		Host:           "0.0.0.0",
+		Port:           50061,
+		Network:        "tcp",
+		MaxRecvMsgSize: 16 << 20,
+		StopTimeout:    5 * time.Second,
+	}
+}
+
+// StartgRPCServer 启动gRPC服务, 阻塞直到ctx结束后优雅关闭
+// register: 注册业务服务
+func StartgRPCServer(ctx context.Context, cfg *GrpcConfig, register func(*grpc.Server)) error {
+	def := newDefaultGrpcConfig()
+	if cfg == nil {
+		cfg = def
+	}
+	if cfg.Network == "" {
+		cfg.Network = def.Network
+	}
+	if cfg.MaxRecvMsgSize <= 0 {
+		cfg.MaxRecvMsgSize = def.MaxRecvMsgSize
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = def.StopTimeout
+	}
+
+	lis, err := net.Listen(cfg.Network, fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	if register != nil {
+		register(srv)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	// 超时未完成优雅关闭则强制关闭
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(cfg.StopTimeout):
+		srv.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/store"
+	"storage/store/storetest"
+)
+
+// writeKeyFile 写入主密钥文件, keys 为 id -> base64 密钥
+func writeKeyFile(t *testing.T, path, primary string, keys map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"primary": primary, "keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readKeyFile 读取主密钥文件中的全部密钥
+func readKeyFile(t *testing.T, path string) map[string]string {
+	t.Helper()
+	var file struct {
+		Keys map[string]string `json:"keys"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file.Keys
+}
+
+// newTestKMS 主密钥文件只有一个主密钥 k1, 返回文件路径用于轮换
+func newTestKMS(t *testing.T) (*FileKMS, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "master.json")
+	writeKeyFile(t, path, "k1", map[string]string{"k1": GenerateKey()})
+	kms, err := NewFileKMS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kms, path
+}
+
+func newTestKeyStore(t *testing.T) *BoltKeyStore {
+	t.Helper()
+	keys, err := NewBoltKeyStore(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+	return keys
+}
+
+func message(id string, seq int64, payload string) *pb.MessageData {
+	return &pb.MessageData{
+		Id:           id,
+		SenderId:     "u1",
+		ReceiverId:   "u2",
+		SesstionType: pb.SesstionType_SINGLE,
+		MessageType:  pb.MessageType_TEXT,
+		Payload:      []byte(payload),
+		Seq:          seq,
+		SendTime:     time.Now().UnixMilli() + seq,
+	}
+}
+
+// 加密后的存储需满足与其他后端相同的查询语义
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.MessageStore {
+		kms, _ := newTestKMS(t)
+		return NewStore(storetest.OpenBolt(t), NewKeyring(kms, newTestKeyStore(t), 0))
+	})
+}
+
+func TestEncryptedAtRest(t *testing.T) {
+	ctx := context.Background()
+	inner := storetest.OpenBolt(t)
+	kms, _ := newTestKMS(t)
+	s := NewStore(inner, NewKeyring(kms, newTestKeyStore(t), 0))
+
+	msg := message("m1", 1, "top secret")
+	if _, err := s.Save(ctx, []*pb.MessageData{msg}); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Payload) != "top secret" {
+		t.Fatal("Save must not modify the caller's message")
+	}
+
+	raw, err := inner.Get(ctx, []string{"m1"})
+	if err != nil || len(raw) != 1 {
+		t.Fatalf("unexpected raw result %v, %v", raw, err)
+	}
+	if !Encrypted(raw[0].Payload) {
+		t.Fatalf("payload stored in plaintext: %q", raw[0].Payload)
+	}
+
+	msgs, err := s.Get(ctx, []string{"m1"})
+	if err != nil || len(msgs) != 1 || string(msgs[0].Payload) != "top secret" {
+		t.Fatalf("unexpected decrypted result %v, %v", msgs, err)
+	}
+
+	// 开启加密前写入的明文原样返回
+	if _, err := inner.Save(ctx, []*pb.MessageData{message("m0", 0, "legacy")}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _, err = s.QueryBySeq(ctx, store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE), -1, false, 10)
+	if err != nil || len(msgs) != 2 || string(msgs[0].Payload) != "legacy" || string(msgs[1].Payload) != "top secret" {
+		t.Fatalf("unexpected query result %v, %v", msgs, err)
+	}
+}
+
+func TestDataKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := storetest.OpenBolt(t)
+	kms, _ := newTestKMS(t)
+	keys := newTestKeyStore(t)
+	ring := NewKeyring(kms, keys, time.Hour)
+	now := time.Now()
+	ring.now = func() time.Time { return now }
+	s := NewStore(inner, ring)
+
+	if _, err := s.Save(ctx, []*pb.MessageData{message("m1", 1, "before")}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := s.Save(ctx, []*pb.MessageData{message("m2", 2, "after")}); err != nil {
+		t.Fatal(err)
+	}
+
+	conv := store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE)
+	latest, err := keys.Latest(ctx, conv)
+	if err != nil || latest.Version != 2 {
+		t.Fatalf("expected data key version 2, got %v, %v", latest, err)
+	}
+
+	// 新的keyring没有缓存, 两个版本的密钥都从 KeyStore 加载
+	s = NewStore(inner, NewKeyring(kms, keys, time.Hour))
+	msgs, err := s.Get(ctx, []string{"m1", "m2"})
+	if err != nil || len(msgs) != 2 || string(msgs[0].Payload) != "before" || string(msgs[1].Payload) != "after" {
+		t.Fatalf("unexpected result %v, %v", msgs, err)
+	}
+}
+
+func TestMasterKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := storetest.OpenBolt(t)
+	kms, keyFile := newTestKMS(t)
+	keys := newTestKeyStore(t)
+	ring := NewKeyring(kms, keys, 0)
+	s := NewStore(inner, ring)
+
+	if _, err := s.Save(ctx, []*pb.MessageData{message("m1", 1, "hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新增主密钥 k2 并设为 primary, 重新加密数据密钥
+	writeKeyFile(t, keyFile, "k2", map[string]string{"k1": readKeyFile(t, keyFile)["k1"], "k2": GenerateKey()})
+	if err := kms.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := ring.Rewrap(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 rewrapped key, got %d, %v", n, err)
+	}
+	if n, err := ring.Rewrap(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing left to rewrap, got %d, %v", n, err)
+	}
+
+	// 删除旧主密钥后仍可解密
+	writeKeyFile(t, keyFile, "k2", map[string]string{"k2": readKeyFile(t, keyFile)["k2"]})
+	if err := kms.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = NewStore(inner, NewKeyring(kms, keys, 0))
+	msgs, err := s.Get(ctx, []string{"m1"})
+	if err != nil || len(msgs) != 1 || string(msgs[0].Payload) != "hello" {
+		t.Fatalf("unexpected result %v, %v", msgs, err)
+	}
+}
+
+func TestInvalidKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.json")
+	writeKeyFile(t, path, "k2", map[string]string{"k1": GenerateKey()})
+	if _, err := NewFileKMS(path); err == nil {
+		t.Fatal("expected error for missing primary key")
+	}
+	writeKeyFile(t, path, "k1", map[string]string{"k1": "c2hvcnQ="})
+	if _, err := NewFileKMS(path); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
@@ -0,0 +1,206 @@
+package envelope
+
+import (
+	"context"
+	"crypto/cipher"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Keyring 会话数据密钥的创建、轮换与缓存
+//
+// 1. 每个会话一个数据密钥, 由主密钥加密后存入 KeyStore, 明文只缓存在内存中
+// 2. 数据密钥超过 ttl 后创建新版本, 新消息使用新版本, 旧消息仍可用写入时的版本解密
+// 3. 主密钥轮换后由 Rewrap 使用新主密钥重新加密全部数据密钥, 消息本身无需重新加密
+// 轮换过程中新旧密钥同时可用, 不需要停机
+
+const (
+	KEY_SIZE = 32 // AES-256-GCM
+
+	// 内存中最多缓存的数据密钥数, 超出后清空重新加载
+	MAX_CACHED_KEYS = 100000
+	// Rewrap 每批处理的数据密钥数
+	REWRAP_BATCH = 100
+)
+
+var ErrCorrupted = errors.New("encrypted data corrupted")
+
+// Reloader 可以在运行时重新加载主密钥的KMS, 例如 FileKMS
+type Reloader interface {
+	Reload() error
+}
+
+type current struct {
+	version  uint32
+	aead     cipher.AEAD
+	expireAt time.Time
+}
+
+type Keyring struct {
+	kms   KMS
+	store KeyStore
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD // {会话id}#{version}
+	current map[string]*current
+}
+
+// NewKeyring ttl 为数据密钥的使用期限, 为0时不轮换
+func NewKeyring(kms KMS, store KeyStore, ttl time.Duration) *Keyring {
+	return &Keyring{
+		kms:     kms,
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		keys:    make(map[string]cipher.AEAD),
+		current: make(map[string]*current),
+	}
+}
+
+// Current 会话当前用于加密的数据密钥, 不存在或已过期时创建新版本
+func (k *Keyring) Current(ctx context.Context, conversationId string) (uint32, cipher.AEAD, error) {
+	now := k.now()
+	k.mu.RLock()
+	c := k.current[conversationId]
+	k.mu.RUnlock()
+	if c != nil && now.Before(c.expireAt) {
+		return c.version, c.aead, nil
+	}
+
+	latest, err := k.store.Latest(ctx, conversationId)
+	switch {
+	case err == ErrNoKey:
+		latest, err = k.create(ctx, conversationId, 1)
+	case err != nil:
+	case k.ttl > 0 && now.Sub(time.UnixMilli(latest.CreatedAt)) >= k.ttl:
+		latest, err = k.create(ctx, conversationId, latest.Version+1)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	aead, err := k.Key(ctx, conversationId, latest.Version)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	expireAt := time.UnixMilli(latest.CreatedAt).Add(k.ttl)
+	if k.ttl == 0 {
+		expireAt = time.UnixMilli(1<<62 - 1)
+	}
+	k.mu.Lock()
+	if len(k.current) >= MAX_CACHED_KEYS {
+		k.current = make(map[string]*current)
+	}
+	k.current[conversationId] = &current{version: latest.Version, aead: aead, expireAt: expireAt}
+	k.mu.Unlock()
+	return latest.Version, aead, nil
+}
+
+// Key 会话指定版本的数据密钥, 用于解密
+func (k *Keyring) Key(ctx context.Context, conversationId string, version uint32) (cipher.AEAD, error) {
+	name := conversationId + "#" + strconv.FormatUint(uint64(version), 10)
+	k.mu.RLock()
+	aead, ok := k.keys[name]
+	k.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+
+	key, err := k.store.Get(ctx, conversationId, version)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := k.kms.Unwrap(ctx, key.MasterKeyId, key.Wrapped, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	if len(k.keys) >= MAX_CACHED_KEYS {
+		k.keys = make(map[string]cipher.AEAD)
+	}
+	k.keys[name] = aead
+	k.mu.Unlock()
+	return aead, nil
+}
+
+// create 生成新版本的数据密钥, 其他节点已创建同一版本时使用已有的
+func (k *Keyring) create(ctx context.Context, conversationId string, version uint32) (*WrappedKey, error) {
+	name := conversationId + "#" + strconv.FormatUint(uint64(version), 10)
+	masterKeyId, wrapped, err := k.kms.Wrap(ctx, randomKey(), []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return k.store.Add(ctx, &WrappedKey{
+		ConversationId: conversationId,
+		Version:        version,
+		MasterKeyId:    masterKeyId,
+		Wrapped:        wrapped,
+		CreatedAt:      k.now().UnixMilli(),
+	})
+}
+
+// Rewrap 使用当前主密钥重新加密由旧主密钥加密的数据密钥, 返回处理的数量
+// 完成后旧主密钥即可从KMS中删除
+func (k *Keyring) Rewrap(ctx context.Context) (int, error) {
+	primary := k.kms.Primary()
+	n := 0
+	for {
+		stale, err := k.store.Stale(ctx, primary, REWRAP_BATCH)
+		if err != nil || len(stale) == 0 {
+			return n, err
+		}
+		for _, key := range stale {
+			name := []byte(key.ConversationId + "#" + strconv.FormatUint(uint64(key.Version), 10))
+			raw, err := k.kms.Unwrap(ctx, key.MasterKeyId, key.Wrapped, name)
+			if err != nil {
+				return n, err
+			}
+			if key.MasterKeyId, key.Wrapped, err = k.kms.Wrap(ctx, raw, name); err != nil {
+				return n, err
+			}
+			if err := k.store.Update(ctx, key); err != nil {
+				return n, err
+			}
+			n++
+		}
+		// 轮换过程中主密钥再次变化, 下次运行时继续
+		if k.kms.Primary() != primary {
+			return n, nil
+		}
+	}
+}
+
+// Run 定期重新加载主密钥并重新加密旧的数据密钥, 阻塞直到ctx结束
+func (k *Keyring) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r, ok := k.kms.(Reloader); ok {
+				if err := r.Reload(); err != nil {
+					log.Printf("[ERROR] reload master keys: %v", err)
+					continue
+				}
+			}
+			n, err := k.Rewrap(ctx)
+			if err != nil {
+				log.Printf("[ERROR] rewrap data keys: %v", err)
+			}
+			if n > 0 {
+				log.Printf("[INFO] rewrapped %d data keys with master key %s", n, k.kms.Primary())
+			}
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+	"storage/store"
+)
+
+// WrappedKey 被主密钥加密的会话数据密钥
+// 同一会话的数据密钥按版本递增, 新消息总是使用最新版本, 旧消息使用写入时的版本解密
+type WrappedKey struct {
+	ConversationId string `json:"conversation_id"`
+	Version        uint32 `json:"version"`
+	MasterKeyId    string `json:"master_key_id"`
+	Wrapped        []byte `json:"wrapped"`
+	CreatedAt      int64  `json:"created_at"` // 毫秒
+}
+
+var ErrNoKey = errors.New("data key not found")
+
+// KeyStore 数据密钥的持久化, 丢失数据密钥等同于丢失对应的消息
+type KeyStore interface {
+	// Latest 会话最新版本的数据密钥, 不存在时返回 ErrNoKey
+	Latest(ctx context.Context, conversationId string) (*WrappedKey, error)
+	// Get 指定版本的数据密钥, 不存在时返回 ErrNoKey
+	Get(ctx context.Context, conversationId string, version uint32) (*WrappedKey, error)
+	// Add 写入新版本, 该版本已被其他节点写入时返回已有的密钥
+	Add(ctx context.Context, key *WrappedKey) (*WrappedKey, error)
+	// Stale 最多返回 limit 个不是由 primary 主密钥加密的数据密钥
+	Stale(ctx context.Context, primary string, limit int) ([]*WrappedKey, error)
+	// Update 替换数据密钥的密文及主密钥id
+	Update(ctx context.Context, key *WrappedKey) error
+	Close() error
+}
+
+// BoltKeyStore 与 BoltStore 配合的嵌入式密钥存储
+//
+// bucket keys : 会话id + 0x00 + version(4) -> json(WrappedKey)
+type BoltKeyStore struct {
+	db *bolt.DB
+}
+
+var bucketKeys = []byte("keys")
+
+func NewBoltKeyStore(path string) (*BoltKeyStore, error) {
+	if path == "" {
+		path = "data/keys.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketKeys)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltKeyStore{db: db}, nil
+}
+
+func (b *BoltKeyStore) Latest(ctx context.Context, conversationId string) (*WrappedKey, error) {
+	var key *WrappedKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := keyPrefix(conversationId)
+		c := tx.Bucket(bucketKeys).Cursor()
+		k, v := c.Seek(boltKey(conversationId, ^uint32(0)))
+		if k == nil {
+			k, v = c.Last()
+		} else if !bytes.Equal(k, boltKey(conversationId, ^uint32(0))) {
+			k, v = c.Prev()
+		}
+		if k == nil || !bytes.HasPrefix(k, prefix) {
+			return ErrNoKey
+		}
+		key = &WrappedKey{}
+		return json.Unmarshal(v, key)
+	})
+	return key, err
+}
+
+func (b *BoltKeyStore) Get(ctx context.Context, conversationId string, version uint32) (*WrappedKey, error) {
+	var key *WrappedKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketKeys).Get(boltKey(conversationId, version))
+		if v == nil {
+			return ErrNoKey
+		}
+		key = &WrappedKey{}
+		return json.Unmarshal(v, key)
+	})
+	return key, err
+}
+
+func (b *BoltKeyStore) Add(ctx context.Context, key *WrappedKey) (*WrappedKey, error) {
+	existing := key
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(bucketKeys)
+		k := boltKey(key.ConversationId, key.Version)
+		if v := keys.Get(k); v != nil {
+			existing = &WrappedKey{}
+			return json.Unmarshal(v, existing)
+		}
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		return keys.Put(k, data)
+	})
+	return existing, err
+}
+
+func (b *BoltKeyStore) Stale(ctx context.Context, primary string, limit int) ([]*WrappedKey, error) {
+	var stale []*WrappedKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketKeys).ForEach(func(k, v []byte) error {
+			if len(stale) == limit {
+				return nil
+			}
+			var key WrappedKey
+			if err := json.Unmarshal(v, &key); err != nil {
+				return err
+			}
+			if key.MasterKeyId != primary {
+				stale = append(stale, &key)
+			}
+			return nil
+		})
+	})
+	return stale, err
+}
+
+func (b *BoltKeyStore) Update(ctx context.Context, key *WrappedKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketKeys).Put(boltKey(key.ConversationId, key.Version), data)
+	})
+}
+
+func (b *BoltKeyStore) Close() error {
+	return b.db.Close()
+}
+
+func keyPrefix(conversationId string) []byte {
+	return append([]byte(conversationId), 0)
+}
+
+func boltKey(conversationId string, version uint32) []byte {
+	return binary.BigEndian.AppendUint32(keyPrefix(conversationId), version)
+}
+
+// SQLKeyStore 与 SQLStore 配合的密钥存储, 多个storage节点共享同一张表
+// 数据密钥量很小, 不分片, 固定存储在第一个分片
+type SQLKeyStore struct {
+	dialect *store.Dialect
+	db      *sql.DB
+}
+
+// conversation_id 与 messages 表一致按字节比较
+const keySchema = `
+CREATE TABLE IF NOT EXISTS data_keys (
+	conversation_id VARCHAR(160) COLLATE %s NOT NULL,
+	version         BIGINT       NOT NULL,
+	master_key_id   VARCHAR(64)  NOT NULL,
+	wrapped         %s           NOT NULL,
+	created_at      BIGINT       NOT NULL,
+	PRIMARY KEY (conversation_id, version)
+) %s`
+
+func NewSQLKeyStore(ctx context.Context, dialect *store.Dialect, dsn string) (*SQLKeyStore, error) {
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	blob := "VARBINARY(256)"
+	if dialect == store.Postgres {
+		blob = "BYTEA"
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(keySchema, dialect.Collate, blob, dialect.Options)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLKeyStore{dialect: dialect, db: db}, nil
+}
+
+func (s *SQLKeyStore) Latest(ctx context.Context, conversationId string) (*WrappedKey, error) {
+	return s.one(ctx, "SELECT conversation_id, version, master_key_id, wrapped, created_at FROM data_keys "+
+		"WHERE conversation_id = ? ORDER BY version DESC LIMIT 1", conversationId)
+}
+
+func (s *SQLKeyStore) Get(ctx context.Context, conversationId string, version uint32) (*WrappedKey, error) {
+	return s.one(ctx, "SELECT conversation_id, version, master_key_id, wrapped, created_at FROM data_keys "+
+		"WHERE conversation_id = ? AND version = ?", conversationId, version)
+}
+
+func (s *SQLKeyStore) Add(ctx context.Context, key *WrappedKey) (*WrappedKey, error) {
+	query := "INSERT IGNORE INTO data_keys (conversation_id, version, master_key_id, wrapped, created_at) VALUES (?, ?, ?, ?, ?)"
+	if s.dialect == store.Postgres {
+		query = "INSERT INTO data_keys (conversation_id, version, master_key_id, wrapped, created_at) VALUES (?, ?, ?, ?, ?) " +
+			"ON CONFLICT (conversation_id, version) DO NOTHING"
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(query),
+		key.ConversationId, key.Version, key.MasterKeyId, key.Wrapped, key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// 并发写入同一版本时以先写入的为准
+	return s.Get(ctx, key.ConversationId, key.Version)
+}
+
+func (s *SQLKeyStore) Stale(ctx context.Context, primary string, limit int) ([]*WrappedKey, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		"SELECT conversation_id, version, master_key_id, wrapped, created_at FROM data_keys "+
+			"WHERE master_key_id <> ? LIMIT ?"), primary, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []*WrappedKey
+	for rows.Next() {
+		var key WrappedKey
+		if err := rows.Scan(&key.ConversationId, &key.Version, &key.MasterKeyId, &key.Wrapped, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		stale = append(stale, &key)
+	}
+	return stale, rows.Err()
+}
+
+func (s *SQLKeyStore) Update(ctx context.Context, key *WrappedKey) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		"UPDATE data_keys SET master_key_id = ?, wrapped = ? WHERE conversation_id = ? AND version = ?"),
+		key.MasterKeyId, key.Wrapped, key.ConversationId, key.Version)
+	return err
+}
+
+func (s *SQLKeyStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLKeyStore) one(ctx context.Context, query string, args ...any) (*WrappedKey, error) {
+	var key WrappedKey
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(query), args...).
+		Scan(&key.ConversationId, &key.Version, &key.MasterKeyId, &key.Wrapped, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
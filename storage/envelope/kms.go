@@ -0,0 +1,154 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// KMS 主密钥服务, 只负责加解密数据密钥, 主密钥本身不离开KMS
+// aad 为附加认证数据, 解密时必须一致, 用于把数据密钥绑定到所属会话
+type KMS interface {
+	// Wrap 使用当前主密钥加密, 返回主密钥id及密文
+	Wrap(ctx context.Context, plaintext, aad []byte) (string, []byte, error)
+	// Unwrap 使用 keyId 对应的主密钥解密
+	Unwrap(ctx context.Context, keyId string, wrapped, aad []byte) ([]byte, error)
+	// Primary 当前用于加密的主密钥id
+	Primary() string
+}
+
+var (
+	ErrUnknownMasterKey = errors.New("unknown master key")
+	ErrInvalidKeyFile   = errors.New("invalid master key file")
+)
+
+// FileKMS 从本地文件读取主密钥, 适用于单机部署及测试
+//
+// 文件格式:
+//
+//	{
+//	  "primary": "k2",
+//	  "keys": {"k1": "base64(32字节)", "k2": "base64(32字节)"}
+//	}
+//
+// 轮换主密钥时新增一个密钥并修改 primary, Reload 后新的数据密钥使用新主密钥,
+// 旧主密钥需保留到 Keyring.Rewrap 将全部数据密钥重新加密之后才能删除
+type FileKMS struct {
+	path    string
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func NewFileKMS(path string) (*FileKMS, error) {
+	k := &FileKMS{path: path}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload 重新读取密钥文件, 失败时保留原有密钥
+func (k *FileKMS) Reload() error {
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Primary string            `json:"primary"`
+		Keys    map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+
+	keys := make(map[string]cipher.AEAD, len(file.Keys))
+	for id, encoded := range file.Keys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != KEY_SIZE {
+			return fmt.Errorf("%w: key %s must be %d bytes base64", ErrInvalidKeyFile, id, KEY_SIZE)
+		}
+		if keys[id], err = newAEAD(raw); err != nil {
+			return err
+		}
+	}
+	if _, ok := keys[file.Primary]; !ok {
+		return fmt.Errorf("%w: primary key %q not found", ErrInvalidKeyFile, file.Primary)
+	}
+
+	k.mu.Lock()
+	k.primary, k.keys = file.Primary, keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *FileKMS) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+func (k *FileKMS) Wrap(ctx context.Context, plaintext, aad []byte) (string, []byte, error) {
+	k.mu.RLock()
+	id, aead := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+	return id, seal(aead, plaintext, aad), nil
+}
+
+func (k *FileKMS) Unwrap(ctx context.Context, keyId string, wrapped, aad []byte) ([]byte, error) {
+	k.mu.RLock()
+	aead, ok := k.keys[keyId]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyId)
+	}
+	return open(aead, wrapped, aad)
+}
+
+// GenerateKey 生成一个主密钥, 用于编写密钥文件
+func GenerateKey() string {
+	return base64.StdEncoding.EncodeToString(randomKey())
+}
+
+func randomKey() []byte {
+	key := make([]byte, KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 输出 nonce + 密文
+func seal(aead cipher.AEAD, plaintext, aad []byte) []byte {
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(out); err != nil {
+		panic(err)
+	}
+	return aead.Seal(out, out, plaintext, aad)
+}
+
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
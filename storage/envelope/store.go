@@ -0,0 +1,137 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/proto"
+	"storage/store"
+)
+
+// Store 对 MessageData.payload 加密的消息存储, 包装任意 store.MessageStore
+//
+// 加密后的payload : magic(5) + 数据密钥版本(4) + nonce(12) + 密文
+// 附加认证数据为 会话id + 消息id, 密文无法被挪用到其他消息
+// 查询结果透明解密; 不以 magic 开头的payload为开启加密前写入的明文, 原样返回
+//
+// 只加密payload, seq、发送时间、收发双方等索引字段保持明文
+
+var magic = []byte{0, 'e', 'n', 'v', 1}
+
+type Store struct {
+	store.MessageStore
+	keys *Keyring
+}
+
+func NewStore(s store.MessageStore, keys *Keyring) *Store {
+	return &Store{MessageStore: s, keys: keys}
+}
+
+// Encrypted payload是否已加密
+func Encrypted(payload []byte) bool {
+	return bytes.HasPrefix(payload, magic)
+}
+
+func (s *Store) Save(ctx context.Context, msgs []*pb.MessageData) (int, error) {
+	sealed := make([]*pb.MessageData, len(msgs))
+	for i, msg := range msgs {
+		if msg == nil || len(msg.Payload) == 0 || Encrypted(msg.Payload) {
+			sealed[i] = msg
+			continue
+		}
+		// 不修改调用方的消息, 调用方还会用明文建立索引
+		sealed[i] = proto.Clone(msg).(*pb.MessageData)
+		if err := s.encrypt(ctx, sealed[i]); err != nil {
+			return 0, err
+		}
+	}
+	return s.MessageStore.Save(ctx, sealed)
+}
+
+func (s *Store) QueryBySeq(ctx context.Context, conversationId string, anchor int64, backward bool, limit int) ([]*pb.MessageData, bool, error) {
+	msgs, hasMore, err := s.MessageStore.QueryBySeq(ctx, conversationId, anchor, backward, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, s.decrypt(ctx, msgs...)
+}
+
+func (s *Store) QueryByTime(ctx context.Context, conversationId string, start, end int64, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	msgs, hasMore, err := s.MessageStore.QueryByTime(ctx, conversationId, start, end, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, s.decrypt(ctx, msgs...)
+}
+
+func (s *Store) Get(ctx context.Context, ids []string) ([]*pb.MessageData, error) {
+	msgs, err := s.MessageStore.Get(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, s.decrypt(ctx, msgs...)
+}
+
+func (s *Store) SetStatus(ctx context.Context, id string, status int32) (*pb.MessageData, error) {
+	msg, err := s.MessageStore.SetStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	return msg, s.decrypt(ctx, msg)
+}
+
+func (s *Store) ScanBefore(ctx context.Context, before int64, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	msgs, hasMore, err := s.MessageStore.ScanBefore(ctx, before, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, s.decrypt(ctx, msgs...)
+}
+
+func (s *Store) ScanSender(ctx context.Context, senderId string, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	msgs, hasMore, err := s.MessageStore.ScanSender(ctx, senderId, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, s.decrypt(ctx, msgs...)
+}
+
+func (s *Store) encrypt(ctx context.Context, msg *pb.MessageData) error {
+	conversationId := store.ConversationOf(msg)
+	version, aead, err := s.keys.Current(ctx, conversationId)
+	if err != nil {
+		return err
+	}
+	payload := binary.BigEndian.AppendUint32(append([]byte(nil), magic...), version)
+	msg.Payload = append(payload, seal(aead, msg.Payload, aad(conversationId, msg.Id))...)
+	return nil
+}
+
+func (s *Store) decrypt(ctx context.Context, msgs ...*pb.MessageData) error {
+	for _, msg := range msgs {
+		if !Encrypted(msg.Payload) {
+			continue
+		}
+		if len(msg.Payload) < len(magic)+4 {
+			return ErrCorrupted
+		}
+		conversationId := store.ConversationOf(msg)
+		version := binary.BigEndian.Uint32(msg.Payload[len(magic):])
+		aead, err := s.keys.Key(ctx, conversationId, version)
+		if err != nil {
+			return err
+		}
+		plaintext, err := open(aead, msg.Payload[len(magic)+4:], aad(conversationId, msg.Id))
+		if err != nil {
+			return err
+		}
+		msg.Payload = plaintext
+	}
+	return nil
+}
+
+func aad(conversationId, id string) []byte {
+	return []byte(conversationId + "|" + id)
+}
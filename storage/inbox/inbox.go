@@ -0,0 +1,228 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+// Inbox 用户离线收件箱
+//
+// key : inbox:{uid}        zset, score 为游标, member 为序列化后的消息引用
+// key : inboxCursor:{uid}  游标计数器, 单调递增, 不过期
+// 两个key使用相同的hash tag, 保证在集群中位于同一slot
+//
+// 1. 每个用户最多保留 maxSize 条, 超出时丢弃最旧的消息
+// 2. 收件箱在 ttl 内没有新消息写入时整体过期, 拉取时跳过发送时间超过 ttl 的消息
+// 3. 客户端ack后删除游标及之前的消息
+// 4. 只保存消息的引用(不含 payload 与 ext), 内容由调用方从消息历史中读取, 不绕过消息历史的静态加密
+
+const (
+	INBOX_PREFIX        = "inbox:"
+	INBOX_CURSOR_PREFIX = "inboxCursor:"
+
+	DEFAULT_LIMIT = 100
+	MAX_LIMIT     = 500
+)
+
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// push 写入消息并裁剪收件箱, 返回消息的游标
+var push = redis.NewScript(`
+local cursor = redis.call('INCR', KEYS[2])
+redis.call('ZADD', KEYS[1], cursor, ARGV[1])
+local size = redis.call('ZCARD', KEYS[1])
+local max = tonumber(ARGV[2])
+if size > max then
+	redis.call('ZREMRANGEBYRANK', KEYS[1], 0, size - max - 1)
+end
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return cursor
+`)
+
+type Entry struct {
+	Cursor  int64
+	Message *pb.MessageData
+}
+
+type Inbox struct {
+	redis   redis.UniversalClient
+	maxSize int
+	ttl     time.Duration
+}
+
+func NewInbox(redis redis.UniversalClient, maxSize int, ttl time.Duration) *Inbox {
+	if maxSize <= 0 {
+		maxSize = 2000
+	}
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &Inbox{
+		redis:   redis,
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+func inboxKey(uid string) string {
+	return INBOX_PREFIX + "{" + uid + "}"
+}
+
+func cursorKey(uid string) string {
+	return INBOX_CURSOR_PREFIX + "{" + uid + "}"
+}
+
+// ConversationOf 消息在接收者视角下所属的会话
+// 私聊为发送者uid, 群聊为群id
+func ConversationOf(msg *pb.MessageData) string {
+	if msg.SesstionType == pb.SesstionType_GROUP {
+		return msg.ReceiverId
+	}
+	return msg.SenderId
+}
+
+// reference 消息的引用, 保留id、seq、收发双方等定位信息, 不含消息内容
+func reference(msg *pb.MessageData) *pb.MessageData {
+	ref := proto.Clone(msg).(*pb.MessageData)
+	ref.Payload = nil
+	ref.Ext = nil
+	return ref
+}
+
+// Push 将消息的引用写入uid的收件箱
+func (i *Inbox) Push(ctx context.Context, uid string, msg *pb.MessageData) (int64, error) {
+	if uid == "" || msg == nil {
+		return 0, ErrInvalidArgument
+	}
+	data, err := proto.Marshal(reference(msg))
+	if err != nil {
+		return 0, err
+	}
+
+	return push.Run(ctx, i.redis,
+		[]string{inboxKey(uid), cursorKey(uid)},
+		data, i.maxSize, int64(i.ttl.Seconds()),
+	).Int64()
+}
+
+// Sync 拉取游标之后的消息, 按游标升序
+func (i *Inbox) Sync(ctx context.Context, uid string, cursor int64, limit int) ([]Entry, bool, error) {
+	limit = normalizeLimit(limit)
+
+	zs, err := i.redis.ZRangeByScoreWithScores(ctx, inboxKey(uid), &redis.ZRangeBy{
+		Min:   "(" + strconv.FormatInt(cursor, 10),
+		Max:   "+inf",
+		Count: int64(limit + 1),
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(zs) > limit
+	if hasMore {
+		zs = zs[:limit]
+	}
+
+	return i.decode(zs), hasMore, nil
+}
+
+// SyncConversation 拉取某个会话中 fromSeq 之后的消息, 按seq升序
+// 收件箱有容量上限, 因此直接在收件箱内过滤
+func (i *Inbox) SyncConversation(ctx context.Context, uid, conversationId string, fromSeq int64, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+
+	zs, err := i.redis.ZRangeWithScores(ctx, inboxKey(uid), 0, -1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var msgs []*pb.MessageData
+	for _, e := range i.decode(zs) {
+		if e.Message.Seq > fromSeq && ConversationOf(e.Message) == conversationId {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	sort.Slice(msgs, func(a, b int) bool {
+		return msgs[a].Seq < msgs[b].Seq
+	})
+
+	hasMore := len(msgs) > limit
+	if hasMore {
+		msgs = msgs[:limit]
+	}
+	return msgs, hasMore, nil
+}
+
+// Ack 删除游标及之前的消息, 返回删除的条数
+func (i *Inbox) Ack(ctx context.Context, uid string, cursor int64) (int64, error) {
+	if cursor <= 0 {
+		return 0, nil
+	}
+	return i.redis.ZRemRangeByScore(ctx, inboxKey(uid), "-inf", strconv.FormatInt(cursor, 10)).Result()
+}
+
+// Clear 删除uid的收件箱及游标, 用于擦除用户数据
+func (i *Inbox) Clear(ctx context.Context, uid string) error {
+	return i.redis.Del(ctx, inboxKey(uid), cursorKey(uid)).Err()
+}
+
+// RemoveSender 删除uid收件箱中 senderId 发送的消息, 返回删除的条数, 用于擦除发送者的数据
+func (i *Inbox) RemoveSender(ctx context.Context, uid, senderId string) (int64, error) {
+	members, err := i.redis.ZRange(ctx, inboxKey(uid), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	var removed []any
+	for _, member := range members {
+		var msg pb.MessageData
+		if err := proto.Unmarshal([]byte(member), &msg); err != nil {
+			continue
+		}
+		if msg.SenderId == senderId {
+			removed = append(removed, member)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	return i.redis.ZRem(ctx, inboxKey(uid), removed...).Result()
+}
+
+// decode 反序列化消息, 跳过损坏及超过保留时间的消息
+func (i *Inbox) decode(zs []redis.Z) []Entry {
+	expireBefore := time.Now().Add(-i.ttl).UnixMilli()
+
+	entries := make([]Entry, 0, len(zs))
+	for _, z := range zs {
+		member, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		var msg pb.MessageData
+		if err := proto.Unmarshal([]byte(member), &msg); err != nil {
+			continue
+		}
+		if msg.SendTime > 0 && msg.SendTime < expireBefore {
+			continue
+		}
+		entries = append(entries, Entry{Cursor: int64(z.Score), Message: &msg})
+	}
+	return entries
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DEFAULT_LIMIT
+	}
+	if limit > MAX_LIMIT {
+		return MAX_LIMIT
+	}
+	return limit
+}
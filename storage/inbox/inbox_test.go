@@ -0,0 +1,164 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pb "github.com/atoncooper/im/proto"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestInbox(t *testing.T, maxSize int) *Inbox {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewInbox(rdb, maxSize, time.Hour)
+}
+
+func message(sender string, seq int64) *pb.MessageData {
+	return &pb.MessageData{
+		Id:           fmt.Sprintf("%s-%d", sender, seq),
+		SenderId:     sender,
+		ReceiverId:   "bob",
+		SesstionType: pb.SesstionType_SINGLE,
+		Seq:          seq,
+		SendTime:     time.Now().UnixMilli(),
+	}
+}
+
+// 测试按游标拉取与ack后清理
+func TestInboxSyncAndAck(t *testing.T) {
+	ctx := context.Background()
+	box := newTestInbox(t, 100)
+
+	for i := int64(1); i <= 5; i++ {
+		cursor, err := box.Push(ctx, "bob", message("alice", i))
+		if err != nil {
+			t.Fatalf("push: %v", err)
+		}
+		if cursor != i {
+			t.Fatalf("expected cursor %d, got %d", i, cursor)
+		}
+	}
+
+	entries, hasMore, err := box.Sync(ctx, "bob", 0, 3)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(entries) != 3 || !hasMore {
+		t.Fatalf("expected 3 entries with more, got %d more=%v", len(entries), hasMore)
+	}
+	if entries[2].Cursor != 3 || entries[2].Message.Seq != 3 {
+		t.Errorf("unexpected third entry: %+v", entries[2])
+	}
+
+	trimmed, err := box.Ack(ctx, "bob", 3)
+	if err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if trimmed != 3 {
+		t.Errorf("expected 3 trimmed, got %d", trimmed)
+	}
+
+	entries, hasMore, err = box.Sync(ctx, "bob", 0, 10)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(entries) != 2 || hasMore || entries[0].Cursor != 4 {
+		t.Fatalf("unexpected entries after ack: %d more=%v", len(entries), hasMore)
+	}
+}
+
+// 测试收件箱容量上限
+func TestInboxBounded(t *testing.T) {
+	ctx := context.Background()
+	box := newTestInbox(t, 3)
+
+	for i := int64(1); i <= 5; i++ {
+		if _, err := box.Push(ctx, "bob", message("alice", i)); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	entries, _, err := box.Sync(ctx, "bob", 0, 10)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Message.Seq != 3 {
+		t.Fatalf("expected the newest 3 messages, got %d starting at seq %d", len(entries), entries[0].Message.Seq)
+	}
+}
+
+// 测试按会话拉取且按seq排序
+func TestInboxSyncConversation(t *testing.T) {
+	ctx := context.Background()
+	box := newTestInbox(t, 100)
+
+	box.Push(ctx, "bob", message("alice", 2))
+	box.Push(ctx, "bob", message("carol", 1))
+	box.Push(ctx, "bob", message("alice", 1))
+	box.Push(ctx, "bob", message("alice", 3))
+
+	msgs, hasMore, err := box.SyncConversation(ctx, "bob", "alice", 1, 10)
+	if err != nil {
+		t.Fatalf("sync conversation: %v", err)
+	}
+	if hasMore || len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Seq != 2 || msgs[1].Seq != 3 {
+		t.Errorf("messages should be ordered by seq, got %d, %d", msgs[0].Seq, msgs[1].Seq)
+	}
+}
+
+// 测试超过保留时间的消息不会被拉取
+func TestInboxSkipExpired(t *testing.T) {
+	ctx := context.Background()
+	box := newTestInbox(t, 100)
+
+	old := message("alice", 1)
+	old.SendTime = time.Now().Add(-2 * time.Hour).UnixMilli()
+	box.Push(ctx, "bob", old)
+	box.Push(ctx, "bob", message("alice", 2))
+
+	entries, _, err := box.Sync(ctx, "bob", 0, 10)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Message.Seq != 2 {
+		t.Fatalf("expired message should be skipped, got %d entries", len(entries))
+	}
+}
+
+// 测试收件箱只保存消息引用, 不保存消息内容
+func TestInboxStoresReference(t *testing.T) {
+	ctx := context.Background()
+	box := newTestInbox(t, 100)
+
+	msg := message("alice", 1)
+	msg.Payload = []byte("secret")
+	msg.Ext = map[string]string{"k": "v"}
+	if _, err := box.Push(ctx, "bob", msg); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if len(msg.Payload) == 0 {
+		t.Fatal("push must not modify the caller's message")
+	}
+
+	members := box.redis.ZRange(ctx, inboxKey("bob"), 0, -1).Val()
+	if len(members) != 1 || strings.Contains(members[0], "secret") {
+		t.Fatalf("payload stored in inbox: %q", members)
+	}
+	entries, _, err := box.Sync(ctx, "bob", 0, 10)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Message.Id != msg.Id || entries[0].Message.Payload != nil || entries[0].Message.Ext != nil {
+		t.Fatalf("unexpected reference %+v", entries)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"storage/checkpoint"
+	"storage/configs"
+	"storage/core"
+	"storage/envelope"
+	"storage/inbox"
+	"storage/privacy"
+	"storage/retention"
+	"storage/search"
+	"storage/service"
+	"storage/store"
+	"storage/tiered"
+	"storage/timeline"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	cfg, err := configs.Load()
+	if err != nil {
+		panic(err)
+	}
+	app := cfg.Application
+
+	// 初始化redis
+	rdb := configs.NewRedisClient(&configs.RedisConfig{
+		Addrs:        app.Component.Redis.Nodes,
+		Password:     app.Component.Redis.Password,
+		PoolSize:     100,
+		MinIdle:      10,
+		DialTimeout:  3 * time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 2 * time.Second,
+		MaxRetries:   3,
+	})
+	configs.Client.Store(rdb)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		panic(err)
+	}
+
+	// 初始化离线收件箱
+	ttl, err := time.ParseDuration(app.Inbox.TTL)
+	if err != nil {
+		panic(err)
+	}
+	box := inbox.NewInbox(rdb, app.Inbox.MaxSize, ttl)
+
+	// 初始化消息历史存储
+	lifetime, err := time.ParseDuration(app.Store.MaxLifetime)
+	if err != nil {
+		panic(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	history, err := store.New(ctx, &store.Config{
+		Driver:       app.Store.Driver,
+		Path:         app.Store.Path,
+		Shards:       app.Store.Shards,
+		MaxOpenConns: app.Store.MaxOpenConns,
+		MaxIdleConns: app.Store.MaxIdleConns,
+		MaxLifetime:  lifetime,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer history.Close()
+
+	// 冷热分层, 位于加密层之下, 归档文件中的payload与主存储一样为密文
+	var cold *tiered.Store
+	if app.Tiered.Enabled {
+		var segments *tiered.Index
+		cold, segments, err = newTieredStore(cfg, history)
+		if err != nil {
+			panic(err)
+		}
+		defer segments.Close()
+		history = cold
+	}
+
+	// 会话seq检查点
+	checkpoints, err := newCheckpointStore(ctx, cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer checkpoints.Close()
+
+	// 用户时间线
+	events, err := newTimelineStore(ctx, cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer events.Close()
+
+	// 初始化全文索引
+	index, err := search.NewIndex(app.Search.Path)
+	if err != nil {
+		panic(err)
+	}
+	defer index.Close()
+
+	// 静态加密, 存储中的payload为密文, 查询时透明解密
+	var keyring *envelope.Keyring
+	if app.Encryption.Enabled {
+		var keys envelope.KeyStore
+		keyring, keys, err = newKeyring(ctx, cfg)
+		if err != nil {
+			panic(err)
+		}
+		defer keys.Close()
+		history = envelope.NewStore(history, keyring)
+
+		policy, err := search.ParsePolicy(app.Encryption.Index)
+		if err != nil {
+			panic(err)
+		}
+		index.SetPolicy(policy)
+	}
+
+	// 注册consul服务
+	consul, err := configs.NewConsulClient(&configs.ConsulConf{
+		Address: fmt.Sprintf("%s:%d", app.Component.Consul.Endpoint, app.Component.Consul.Port),
+		Scheme:  app.Component.Consul.Scheme,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if app.Component.Consul.Service.Register {
+		err = consul.RegisterService(app.NodeId, app.Name, app.Host, app.Port, app.Component.Consul.Service.Tags)
+		if err != nil {
+			panic(err)
+		}
+		defer consul.DeregisterService(app.NodeId)
+	}
+
+	runCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if keyring != nil {
+		interval, err := time.ParseDuration(app.Encryption.RotateInterval)
+		if err != nil {
+			panic(err)
+		}
+		go keyring.Run(runCtx, interval)
+	}
+
+	if cold != nil {
+		interval, err := time.ParseDuration(app.Tiered.Interval)
+		if err != nil {
+			panic(err)
+		}
+		age, err := time.ParseDuration(app.Tiered.Age)
+		if err != nil {
+			panic(err)
+		}
+		go cold.Schedule(runCtx, interval, age)
+	}
+
+	// 定期清理超过保留时间的时间线事件
+	if app.Timeline.TTL != "" {
+		ttl, err := time.ParseDuration(app.Timeline.TTL)
+		if err != nil {
+			panic(err)
+		}
+		if ttl > 0 {
+			interval, err := time.ParseDuration(app.Timeline.Interval)
+			if err != nil {
+				panic(err)
+			}
+			go timeline.Schedule(runCtx, events, interval, ttl)
+		}
+	}
+
+	// 按保留策略定期清理过期消息
+	if app.Retention.Enabled {
+		job, err := newRetentionJob(cfg, history, index)
+		if err != nil {
+			panic(err)
+		}
+		interval, err := time.ParseDuration(app.Retention.Interval)
+		if err != nil {
+			panic(err)
+		}
+		go job.Schedule(runCtx, interval, app.Retention.DryRun)
+	}
+
+	// 用户数据导出与擦除
+	audit, err := privacy.NewAuditLog(app.Privacy.AuditDir)
+	if err != nil {
+		panic(err)
+	}
+	var media privacy.MediaStore
+	if app.Privacy.Media.Enabled {
+		media = retention.NewObsClient(app.Privacy.Media.ObsUrl, app.Privacy.Media.Secret)
+	}
+	manager := privacy.NewManager(history, index, box, events, rdb, media, audit)
+
+	log.Println("[INFO] Starting storage server...")
+	err = core.StartgRPCServer(runCtx, &core.GrpcConfig{
+		Host: app.Host,
+		Port: app.Port,
+	}, func(s *grpc.Server) {
+		storagepb.RegisterSyncServiceServer(s, service.NewSyncHandle(box, history))
+		storagepb.RegisterHistoryServiceServer(s, service.NewHistoryHandle(history, index, rdb))
+		storagepb.RegisterSearchServiceServer(s, service.NewSearchHandle(history, index, rdb))
+		storagepb.RegisterPrivacyServiceServer(s, service.NewPrivacyHandle(manager))
+		storagepb.RegisterCheckpointServiceServer(s, service.NewCheckpointHandle(checkpoints, history, events))
+		storagepb.RegisterTimelineServiceServer(s, service.NewTimelineHandle(events, history))
+		if cold != nil {
+			storagepb.RegisterArchiveServiceServer(s, service.NewArchiveHandle(cold))
+		}
+	})
+	if err != nil {
+		log.Printf("[ERROR] storage server exited: %v", err)
+	}
+	log.Println("[INFO] storage server stopped")
+}
+
+// newKeyring 数据密钥与消息存储在一起: bolt 使用独立的文件, sql 存储在第一个分片
+func newKeyring(ctx context.Context, cfg *configs.Config) (*envelope.Keyring, envelope.KeyStore, error) {
+	conf := cfg.Application.Encryption
+	ttl, err := time.ParseDuration(conf.DataKeyTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	kms, err := envelope.NewFileKMS(conf.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys envelope.KeyStore
+	switch cfg.Application.Store.Driver {
+	case store.DRIVER_MYSQL, store.DRIVER_POSTGRES:
+		if len(cfg.Application.Store.Shards) == 0 {
+			return nil, nil, store.ErrInvalidArgument
+		}
+		dialect := store.MySQL
+		if cfg.Application.Store.Driver == store.DRIVER_POSTGRES {
+			dialect = store.Postgres
+		}
+		keys, err = envelope.NewSQLKeyStore(ctx, dialect, cfg.Application.Store.Shards[0])
+	default:
+		keys, err = envelope.NewBoltKeyStore(conf.KeyPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return envelope.NewKeyring(kms, keys, ttl), keys, nil
+}
+
+// newCheckpointStore 检查点与消息存储在一起: bolt 使用独立的文件, sql 存储在第一个分片
+func newCheckpointStore(ctx context.Context, cfg *configs.Config) (checkpoint.Store, error) {
+	switch cfg.Application.Store.Driver {
+	case store.DRIVER_MYSQL, store.DRIVER_POSTGRES:
+		if len(cfg.Application.Store.Shards) == 0 {
+			return nil, store.ErrInvalidArgument
+		}
+		dialect := store.MySQL
+		if cfg.Application.Store.Driver == store.DRIVER_POSTGRES {
+			dialect = store.Postgres
+		}
+		return checkpoint.NewSQLStore(ctx, dialect, cfg.Application.Store.Shards[0])
+	default:
+		return checkpoint.NewBoltStore(cfg.Application.Checkpoint.Path)
+	}
+}
+
+// newTimelineStore 时间线与消息存储在一起: bolt 使用独立的文件, sql 按uid分片
+func newTimelineStore(ctx context.Context, cfg *configs.Config) (timeline.Store, error) {
+	switch cfg.Application.Store.Driver {
+	case store.DRIVER_MYSQL, store.DRIVER_POSTGRES:
+		dialect := store.MySQL
+		if cfg.Application.Store.Driver == store.DRIVER_POSTGRES {
+			dialect = store.Postgres
+		}
+		return timeline.NewSQLStore(ctx, dialect, cfg.Application.Store.Shards)
+	default:
+		return timeline.NewBoltStore(cfg.Application.Timeline.Path)
+	}
+}
+
+func newTieredStore(cfg *configs.Config, history store.MessageStore) (*tiered.Store, *tiered.Index, error) {
+	conf := cfg.Application.Tiered
+	restoreTTL, err := time.ParseDuration(conf.RestoreTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects, err := tiered.NewFileObjectStore(conf.ObjectDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := tiered.NewIndex(conf.IndexPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tiered.NewStore(history, index, objects, &tiered.Config{
+		SegmentSize: conf.SegmentSize,
+		RestoreTTL:  restoreTTL,
+	}), index, nil
+}
+
+func newRetentionJob(cfg *configs.Config, history store.MessageStore, index *search.Index) (*retention.Job, error) {
+	conf := cfg.Application.Retention
+
+	confs := make([]retention.PolicyConfig, len(conf.Policies))
+	for i, p := range conf.Policies {
+		confs[i] = retention.PolicyConfig(p)
+	}
+	policies, err := retention.ParsePolicies(confs)
+	if err != nil {
+		return nil, err
+	}
+
+	archiver, err := retention.NewFileArchiver(conf.ArchiveDir)
+	if err != nil {
+		return nil, err
+	}
+	var media retention.MediaRemover
+	if conf.Media.Enabled {
+		media = retention.NewObsClient(conf.Media.ObsUrl, conf.Media.Secret)
+	}
+
+	return retention.NewJob(history, index, archiver, media, &retention.Config{
+		Policies:  policies,
+		BatchSize: conf.BatchSize,
+		ReportDir: conf.ReportDir,
+	})
+}
@@ -0,0 +1,56 @@
+package privacy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// AuditLog 导出与擦除的审计日志
+// 追加写入 {dir}/audit.jsonl, 每行为一条 AuditRecord 的json, 只追加不修改
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewAuditLog(dir string) (*AuditLog, error) {
+	if dir == "" {
+		dir = "data/privacy"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: filepath.Join(dir, "audit.jsonl")}, nil
+}
+
+func (a *AuditLog) Write(record *storagepb.AuditRecord) error {
+	line, err := protojson.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func newAuditId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,500 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protojson"
+	"storage/inbox"
+	"storage/retention"
+	"storage/search"
+	"storage/store"
+	"storage/timeline"
+)
+
+// Manager 用户数据导出与擦除
+//
+// 与用户相关的数据分布在:
+//  1. 消息历史 : 用户发送的消息及其全文索引
+//  2. obs     : 消息引用的媒体对象
+//  3. redis   : 在线状态、已读游标、群成员、黑名单、离线收件箱、送达状态等
+//  4. 时间线   : 用户的事件
+//
+// 用户资料由账号系统维护, 不在im中存储, 导出的 profile 只包含在线状态
+//
+// 导出结果为zip:
+//   manifest.json      审计记录及各类数据的数量
+//   profile.json       在线状态
+//   relations.json     所在的群及黑名单
+//   conversations.json 参与的会话及已读seq
+//   messages.jsonl     发送的消息, 每行一条
+//   media.json         消息引用的obs对象
+//   media/{objectId}   obs对象内容, 仅 include_media 时打包
+//
+// 擦除:
+//   ERASE_DELETE    删除用户发送的消息
+//   ERASE_ANONYMIZE 清空消息内容并保留占位, 其他成员的会话上下文(seq、回复位置)不变
+// 两种模式都会删除索引及媒体, 并清理redis中与用户相关的数据及用户的时间线
+// 其他用户离线收件箱中该用户发送的消息一并删除; 会话seq计数器与其他成员共享, 不删除
+// 每批消息先删除媒体再处理消息, 中途失败时重新执行即可继续
+
+const (
+	ACTION_EXPORT = "export"
+	ACTION_ERASE  = "erase"
+)
+
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// MediaStore obs对象的下载与删除, retention.ObsClient 实现了该接口
+type MediaStore interface {
+	Fetch(ctx context.Context, objectId string, w io.Writer) error
+	retention.MediaRemover
+}
+
+type Manager struct {
+	store    store.MessageStore
+	index    *search.Index
+	inbox    *inbox.Inbox
+	timeline timeline.Store
+	redis    redis.UniversalClient
+	media    MediaStore
+	audit    *AuditLog
+}
+
+// NewManager media 为nil时不导出也不删除媒体
+func NewManager(s store.MessageStore, index *search.Index, box *inbox.Inbox, events timeline.Store, redis redis.UniversalClient, media MediaStore, audit *AuditLog) *Manager {
+	return &Manager{store: s, index: index, inbox: box, timeline: events, redis: redis, media: media, audit: audit}
+}
+
+type Conversation struct {
+	ConversationId string `json:"conversation_id"`
+	ReadSeq        int64  `json:"read_seq"`
+}
+
+type MediaObject struct {
+	ObjectId     string `json:"object_id"`
+	LastSendTime int64  `json:"last_send_time"` // 最后一次引用该对象的消息发送时间
+	Error        string `json:"error,omitempty"`
+}
+
+// Export 将用户数据写入 w, 无论成功与否都会记录审计日志
+func (m *Manager) Export(ctx context.Context, in *storagepb.ExportUserRequest, w io.Writer) (*storagepb.AuditRecord, error) {
+	if in.Uid == "" || in.Operator == "" {
+		return nil, ErrInvalidArgument
+	}
+	record := begin(ACTION_EXPORT, in.Uid, in.Operator, in.Reason, "")
+	err := m.export(ctx, in, w, record)
+	return record, m.finish(record, err)
+}
+
+// Erase 擦除用户数据, 无论成功与否都会记录审计日志
+func (m *Manager) Erase(ctx context.Context, in *storagepb.EraseUserRequest) (*storagepb.AuditRecord, error) {
+	if in.Uid == "" || in.Operator == "" {
+		return nil, ErrInvalidArgument
+	}
+	mode := strings.ToLower(strings.TrimPrefix(in.Mode.String(), "ERASE_"))
+	record := begin(ACTION_ERASE, in.Uid, in.Operator, in.Reason, mode)
+	err := m.erase(ctx, in.Uid, in.Mode, record)
+	return record, m.finish(record, err)
+}
+
+func (m *Manager) export(ctx context.Context, in *storagepb.ExportUserRequest, w io.Writer, record *storagepb.AuditRecord) error {
+	uid := in.Uid
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("messages.jsonl")
+	if err != nil {
+		return err
+	}
+	sent := make(map[string]bool)
+	media := make(map[string]int64)
+	err = m.scanSent(ctx, uid, func(msgs []*pb.MessageData) error {
+		for _, msg := range msgs {
+			line, err := protojson.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(append(line, '\n')); err != nil {
+				return err
+			}
+			sent[store.ConversationOf(msg)] = true
+			if id := retention.MediaObject(msg); id != "" {
+				media[id] = max(media[id], msg.SendTime)
+			}
+		}
+		record.Counts["messages"] += int64(len(msgs))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	presence, err := m.redis.Get(ctx, STATUS_PREFIX+uid).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	err = writeJSON(zw, "profile.json", map[string]string{"uid": uid, "presence": presence})
+	if err != nil {
+		return err
+	}
+
+	groups, err := m.groups(ctx, uid)
+	if err != nil {
+		return err
+	}
+	blocked, err := m.redis.LRange(ctx, BLACK_LIST_PREFIX+uid, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	err = writeJSON(zw, "relations.json", map[string][]string{"groups": groups, "blocked": blocked})
+	if err != nil {
+		return err
+	}
+	record.Counts["groups"] = int64(len(groups))
+	record.Counts["blocked"] = int64(len(blocked))
+
+	convs, err := m.conversations(ctx, uid, sent, groups)
+	if err != nil {
+		return err
+	}
+	list := make([]Conversation, 0, len(convs))
+	for id, seq := range convs {
+		list = append(list, Conversation{ConversationId: id, ReadSeq: seq})
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].ConversationId < list[b].ConversationId })
+	if err := writeJSON(zw, "conversations.json", list); err != nil {
+		return err
+	}
+	record.Counts["conversations"] = int64(len(list))
+
+	objects := make([]*MediaObject, 0, len(media))
+	for id, sendTime := range media {
+		objects = append(objects, &MediaObject{ObjectId: id, LastSendTime: sendTime})
+	}
+	sort.Slice(objects, func(a, b int) bool { return objects[a].ObjectId < objects[b].ObjectId })
+	if in.IncludeMedia && m.media != nil {
+		for _, obj := range objects {
+			f, err := zw.Create("media/" + url.PathEscape(obj.ObjectId))
+			if err != nil {
+				return err
+			}
+			// 单个对象下载失败不影响导出, 在 media.json 中注明
+			if err := m.media.Fetch(ctx, obj.ObjectId, f); err != nil {
+				obj.Error = err.Error()
+				continue
+			}
+			record.Counts["media_files"]++
+		}
+	}
+	if err := writeJSON(zw, "media.json", objects); err != nil {
+		return err
+	}
+	record.Counts["media"] = int64(len(objects))
+
+	manifest := map[string]any{
+		"uid":         uid,
+		"operator":    record.Operator,
+		"reason":      record.Reason,
+		"audit_id":    record.Id,
+		"exported_at": time.Now().UnixMilli(),
+		"counts":      record.Counts,
+	}
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (m *Manager) erase(ctx context.Context, uid string, mode storagepb.EraseMode, record *storagepb.AuditRecord) error {
+	sent := make(map[string]bool)
+	err := m.scanSent(ctx, uid, func(msgs []*pb.MessageData) error {
+		ids := make([]string, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.Id
+			sent[store.ConversationOf(msg)] = true
+		}
+		if err := m.removeMedia(ctx, msgs, record); err != nil {
+			return err
+		}
+
+		if mode == storagepb.EraseMode_ERASE_ANONYMIZE {
+			for _, id := range ids {
+				if _, err := m.store.Redact(ctx, id); err != nil && err != store.ErrNotFound {
+					return err
+				}
+			}
+		} else if _, err := m.store.Delete(ctx, ids); err != nil {
+			return err
+		}
+		record.Counts["messages"] += int64(len(ids))
+
+		if err := m.index.Remove(ids...); err != nil {
+			return err
+		}
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = DELIVERY_PREFIX + id
+		}
+		_, err := m.del(ctx, keys)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := m.redis.Del(ctx, STATUS_PREFIX+uid).Result()
+	if err != nil {
+		return err
+	}
+	record.Counts["presence"] = n
+	if err := m.inbox.Clear(ctx, uid); err != nil {
+		return err
+	}
+	// 其他用户离线收件箱中的消息副本, 群成员可能已经变化, 因此遍历全部收件箱
+	boxes, err := scan(ctx, m.redis, inbox.INBOX_PREFIX+"*")
+	if err != nil {
+		return err
+	}
+	for _, key := range boxes {
+		owner := strings.TrimSuffix(strings.TrimPrefix(key, inbox.INBOX_PREFIX+"{"), "}")
+		n, err := m.inbox.RemoveSender(ctx, owner, uid)
+		if err != nil {
+			return err
+		}
+		record.Counts["inbox_entries"] += n
+	}
+
+	deleted, err := m.timeline.Delete(ctx, uid)
+	if err != nil {
+		return err
+	}
+	record.Counts["timeline_events"] = int64(deleted)
+
+	// 先找出所在的群, 已读游标清理完成后再退出群
+	groups, err := m.groups(ctx, uid)
+	if err != nil {
+		return err
+	}
+	convs, err := m.conversations(ctx, uid, sent, groups)
+	if err != nil {
+		return err
+	}
+	for conv := range convs {
+		n, err := m.redis.HDel(ctx, READ_CURSOR_PREFIX+conv, uid).Result()
+		if err != nil {
+			return err
+		}
+		record.Counts["read_cursors"] += n
+	}
+	for _, gid := range groups {
+		if err := m.redis.SRem(ctx, GROUP_MEMBER_PREFIX+gid, uid).Err(); err != nil {
+			return err
+		}
+	}
+	record.Counts["groups"] = int64(len(groups))
+
+	// 自己的黑名单整体删除, 其他用户及群黑名单中移除uid
+	if _, err := m.redis.Del(ctx, BLACK_LIST_PREFIX+uid).Result(); err != nil {
+		return err
+	}
+	for _, pattern := range []string{BLACK_LIST_PREFIX + "*", GROUP_BLACK_LIST_PREFIX + "*"} {
+		lists, err := scan(ctx, m.redis, pattern)
+		if err != nil {
+			return err
+		}
+		for _, key := range lists {
+			n, err := m.redis.LRem(ctx, key, 0, uid).Result()
+			if err != nil {
+				return err
+			}
+			record.Counts["blacklist_entries"] += n
+		}
+	}
+
+	u := escape(uid)
+	pending, err := scan(ctx, m.redis, DISAPPEAR_PENDING_PREFIX+"*:"+u)
+	if err != nil {
+		return err
+	}
+	if _, err = m.del(ctx, pending); err != nil {
+		return err
+	}
+
+	// 端到端加密的设备与公钥
+	keys, err := scan(ctx, m.redis, E2EE_PREKEYS_PREFIX+"{"+u+"}:*")
+	if err != nil {
+		return err
+	}
+	keys = append(keys, E2EE_DEVICES_PREFIX+"{"+uid+"}")
+	record.Counts["e2ee_keys"], err = m.del(ctx, keys)
+	return err
+}
+
+// removeMedia 请求obs删除消息引用的媒体
+// 对象按内容去重, 以消息发送时间为界, 之后被重新上传或秒传过的对象仍在使用, 由obs跳过
+// obs的时间精度为秒, 多留一秒避免跳过与消息同一秒上传的对象
+func (m *Manager) removeMedia(ctx context.Context, msgs []*pb.MessageData, record *storagepb.AuditRecord) error {
+	if m.media == nil {
+		return nil
+	}
+	for _, msg := range msgs {
+		id := retention.MediaObject(msg)
+		if id == "" {
+			continue
+		}
+		removed, err := m.media.Remove(ctx, id, time.UnixMilli(msg.SendTime).Add(time.Second))
+		if err != nil {
+			return err
+		}
+		if removed {
+			record.Counts["media"]++
+		}
+	}
+	return nil
+}
+
+// scanSent 按批遍历用户发送的消息
+func (m *Manager) scanSent(ctx context.Context, uid string, fn func(msgs []*pb.MessageData) error) error {
+	var after store.Cursor
+	for {
+		msgs, hasMore, err := m.store.ScanSender(ctx, uid, after, store.MAX_LIMIT)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		after = store.CursorOf(msgs[len(msgs)-1])
+		if err := fn(msgs); err != nil {
+			return err
+		}
+		if !hasMore {
+			return nil
+		}
+	}
+}
+
+// groups 用户所在的群, 群成员集合没有反向索引, 需要遍历全部群
+func (m *Manager) groups(ctx context.Context, uid string) ([]string, error) {
+	keys, err := scan(ctx, m.redis, GROUP_MEMBER_PREFIX+"*")
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, key := range keys {
+		joined, err := m.redis.SIsMember(ctx, key, uid).Result()
+		if err != nil {
+			return nil, err
+		}
+		if joined {
+			groups = append(groups, strings.TrimPrefix(key, GROUP_MEMBER_PREFIX))
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+// conversations 用户参与的会话及已读seq
+// 包括发送过消息的会话、所在的群, 以及只接收过消息但有已读游标的私聊
+func (m *Manager) conversations(ctx context.Context, uid string, sent map[string]bool, groups []string) (map[string]int64, error) {
+	convs := make(map[string]int64)
+	for conv := range sent {
+		convs[conv] = 0
+	}
+	for _, gid := range groups {
+		convs[store.ConversationKey(uid, gid, pb.SesstionType_GROUP)] = 0
+	}
+	u := escape(uid)
+	for _, pattern := range []string{READ_CURSOR_PREFIX + "s:" + u + ":*", READ_CURSOR_PREFIX + "s:*:" + u} {
+		keys, err := scan(ctx, m.redis, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			convs[strings.TrimPrefix(key, READ_CURSOR_PREFIX)] = 0
+		}
+	}
+
+	for conv := range convs {
+		seq, err := m.redis.HGet(ctx, READ_CURSOR_PREFIX+conv, uid).Int64()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		convs[conv] = seq
+	}
+	return convs, nil
+}
+
+// del 逐个删除key, 集群模式下多个key可能不在同一slot
+func (m *Manager) del(ctx context.Context, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := m.redis.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Del(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
+}
+
+func begin(action, uid, operator, reason, mode string) *storagepb.AuditRecord {
+	return &storagepb.AuditRecord{
+		Id:        newAuditId(),
+		Action:    action,
+		Uid:       uid,
+		Operator:  operator,
+		Reason:    reason,
+		Mode:      mode,
+		StartedAt: time.Now().UnixMilli(),
+		Counts:    make(map[string]int64),
+	}
+}
+
+// finish 记录审计日志, 返回操作本身的错误, 操作成功但审计日志写入失败时返回审计日志的错误
+func (m *Manager) finish(record *storagepb.AuditRecord, err error) error {
+	record.FinishedAt = time.Now().UnixMilli()
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if werr := m.audit.Write(record); werr != nil {
+		log.Printf("[ERROR] write privacy audit %s: %v", record.Id, werr)
+		if err == nil {
+			err = werr
+		}
+	}
+	log.Printf("[INFO] privacy %s uid=%s operator=%s audit=%s counts=%v error=%q",
+		record.Action, record.Uid, record.Operator, record.Id, record.Counts, record.Error)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,307 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"storage/inbox"
+	"storage/search"
+	"storage/store"
+	"storage/store/storetest"
+	"storage/timeline"
+)
+
+type fakeMedia struct {
+	removed []string
+}
+
+func (f *fakeMedia) Fetch(ctx context.Context, objectId string, w io.Writer) error {
+	_, err := w.Write([]byte("content of " + objectId))
+	return err
+}
+
+func (f *fakeMedia) Remove(ctx context.Context, objectId string, before time.Time) (bool, error) {
+	f.removed = append(f.removed, objectId)
+	return true, nil
+}
+
+// newTestManager 各存储都在测试的临时目录与miniredis中, 预置下面的用户数据
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := storetest.OpenBolt(t)
+	index, err := search.NewIndex(filepath.Join(dir, "search.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	box := inbox.NewInbox(rdb, 100, time.Hour)
+
+	events, err := timeline.NewBoltStore(filepath.Join(dir, "timeline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { events.Close() })
+
+	audit, err := NewAuditLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alice 与 bob 私聊, alice 与 carol 同在群 g1, dave 只给 alice 发过消息
+	now := time.Now().UnixMilli()
+	msgs := []*pb.MessageData{
+		{Id: "m1", SenderId: "alice", ReceiverId: "bob", SesstionType: pb.SesstionType_SINGLE, MessageType: pb.MessageType_TEXT, Payload: []byte("hello bob"), Seq: 1, SendTime: now},
+		{Id: "m2", SenderId: "bob", ReceiverId: "alice", SesstionType: pb.SesstionType_SINGLE, MessageType: pb.MessageType_TEXT, Payload: []byte("hello alice"), Seq: 1, SendTime: now + 1},
+		{Id: "m3", SenderId: "alice", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, MessageType: pb.MessageType_FILE, Payload: []byte(`{"object_id":"obj1","name":"a.txt"}`), Seq: 1, SendTime: now + 2},
+		{Id: "m4", SenderId: "carol", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, MessageType: pb.MessageType_TEXT, Payload: []byte("hi all"), Seq: 2, SendTime: now + 3},
+	}
+	if _, err := s.Save(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Add(msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	rdb.Set(ctx, STATUS_PREFIX+"alice", `{"node":"gw1"}`, 0)
+	rdb.Set(ctx, SEQ_PREFIX+"s:alice:bob", 2, 0)
+	rdb.Set(ctx, SEQ_PREFIX+"s:bob:carol", 3, 0)
+	rdb.Set(ctx, SEQ_PREFIX+"g:g1", 2, 0)
+	rdb.Set(ctx, SEQ_PREFIX+"u:alice", 3, 0)
+	rdb.HSet(ctx, READ_CURSOR_PREFIX+"s:alice:bob", "alice", 1, "bob", 1)
+	rdb.HSet(ctx, READ_CURSOR_PREFIX+"s:alice:dave", "alice", 5)
+	rdb.HSet(ctx, READ_CURSOR_PREFIX+"g:g1", "alice", 2, "carol", 2)
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g1", "alice", "carol")
+	rdb.SAdd(ctx, GROUP_MEMBER_PREFIX+"g2", "carol")
+	rdb.RPush(ctx, BLACK_LIST_PREFIX+"alice", "mallory")
+	rdb.RPush(ctx, BLACK_LIST_PREFIX+"bob", "alice", "mallory")
+	rdb.HSet(ctx, DELIVERY_PREFIX+"m1", "sender", "alice")
+	rdb.ZAdd(ctx, DISAPPEAR_PENDING_PREFIX+"s:alice:bob:alice", redis.Z{Score: 1, Member: "60|bob|m2"})
+	rdb.HSet(ctx, E2EE_DEVICES_PREFIX+"{alice}", "d1", "device")
+	rdb.RPush(ctx, E2EE_PREKEYS_PREFIX+"{alice}:d1", "prekey")
+	if _, err := box.Push(ctx, "alice", msgs[1]); err != nil {
+		t.Fatal(err)
+	}
+	for uid, msg := range map[string]*pb.MessageData{"bob": msgs[0], "carol": msgs[2]} {
+		if _, err := box.Push(ctx, uid, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = events.Append(ctx, []*storagepb.TimelineEvent{
+		{Uid: "alice", InboxSeq: 1, ConversationId: "s:alice:bob", Seq: 1, MessageId: "m1"},
+		{Uid: "bob", InboxSeq: 1, ConversationId: "s:alice:bob", Seq: 1, MessageId: "m1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewManager(s, index, box, events, rdb, &fakeMedia{}, audit)
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	var buf bytes.Buffer
+	record, err := m.Export(ctx, &storagepb.ExportUserRequest{
+		Uid: "alice", Operator: "admin", Reason: "dsar", IncludeMedia: true,
+	}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Counts["messages"] != 2 || record.Counts["groups"] != 1 || record.Counts["media_files"] != 1 {
+		t.Fatalf("unexpected counts %v", record.Counts)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, file := range zr.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[file.Name] = string(data)
+	}
+
+	for _, name := range []string{"manifest.json", "profile.json", "relations.json", "conversations.json", "messages.jsonl", "media.json", "media/obj1"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("missing %s in archive", name)
+		}
+	}
+	if lines := strings.Count(files["messages.jsonl"], "\n"); lines != 2 {
+		t.Fatalf("expected 2 messages, got %d", lines)
+	}
+	// 只接收过消息的私聊也通过已读游标导出
+	for _, conv := range []string{"s:alice:bob", "s:alice:dave", "g:g1"} {
+		if !strings.Contains(files["conversations.json"], conv) {
+			t.Fatalf("conversation %s not exported: %s", conv, files["conversations.json"])
+		}
+	}
+	if !strings.Contains(files["relations.json"], "mallory") || !strings.Contains(files["profile.json"], "gw1") {
+		t.Fatalf("unexpected relations %s, profile %s", files["relations.json"], files["profile.json"])
+	}
+	if files["media/obj1"] != "content of obj1" {
+		t.Fatalf("unexpected media %q", files["media/obj1"])
+	}
+
+	// 导出不修改数据
+	if n := m.redis.Exists(ctx, STATUS_PREFIX+"alice").Val(); n != 1 {
+		t.Fatal("export must not modify data")
+	}
+	assertAudit(t, m.audit, record.Id, ACTION_EXPORT)
+}
+
+func TestEraseDelete(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	record, err := m.Erase(ctx, &storagepb.EraseUserRequest{Uid: "alice", Operator: "admin", Reason: "account closed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Mode != "delete" || record.Counts["messages"] != 2 {
+		t.Fatalf("unexpected record %v", record)
+	}
+
+	// alice 发送的消息被删除, 其他人的消息保留
+	msgs, err := m.store.Get(ctx, []string{"m1", "m2", "m3", "m4"})
+	if err != nil || len(msgs) != 2 || msgs[0].Id != "m2" || msgs[1].Id != "m4" {
+		t.Fatalf("unexpected remaining messages %v, %v", msgs, err)
+	}
+	if removed := m.media.(*fakeMedia).removed; len(removed) != 1 || removed[0] != "obj1" {
+		t.Fatalf("unexpected removed media %v", removed)
+	}
+
+	assertErased(t, m)
+	assertAudit(t, m.audit, record.Id, ACTION_ERASE)
+}
+
+func TestEraseAnonymize(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	in := &storagepb.EraseUserRequest{Uid: "alice", Operator: "admin", Mode: storagepb.EraseMode_ERASE_ANONYMIZE}
+	if _, err := m.Erase(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+
+	// 占位保留在会话中, 内容被清空
+	msgs, err := m.store.Get(ctx, []string{"m1", "m3"})
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("expected placeholders, got %v, %v", msgs, err)
+	}
+	for _, msg := range msgs {
+		if msg.Status != store.STATUS_ERASED || len(msg.Payload) != 0 {
+			t.Fatalf("message %s not redacted: %v", msg.Id, msg)
+		}
+	}
+	assertErased(t, m)
+
+	// 可以重复执行
+	if _, err := m.Erase(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEraseRequiresOperator(t *testing.T) {
+	m := newTestManager(t)
+	if _, err := m.Erase(context.Background(), &storagepb.EraseUserRequest{Uid: "alice"}); err != ErrInvalidArgument {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func assertErased(t *testing.T, m *Manager) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, key := range []string{
+		STATUS_PREFIX + "alice",
+		BLACK_LIST_PREFIX + "alice",
+		DELIVERY_PREFIX + "m1",
+		DISAPPEAR_PENDING_PREFIX + "s:alice:bob:alice",
+		"inbox:{alice}",
+		E2EE_DEVICES_PREFIX + "{alice}",
+		E2EE_PREKEYS_PREFIX + "{alice}:d1",
+	} {
+		if m.redis.Exists(ctx, key).Val() != 0 {
+			t.Fatalf("key %s not erased", key)
+		}
+	}
+	// 会话seq与其他成员共享, 删除后会回退对方的seq
+	if m.redis.Exists(ctx, SEQ_PREFIX+"s:alice:bob", SEQ_PREFIX+"s:bob:carol", SEQ_PREFIX+"g:g1", SEQ_PREFIX+"u:alice").Val() != 4 {
+		t.Fatal("seq counters must be kept")
+	}
+
+	// 其他用户收件箱中alice发送的消息被删除
+	for _, uid := range []string{"bob", "carol"} {
+		entries, _, err := m.inbox.Sync(ctx, uid, 0, 10)
+		if err != nil || len(entries) != 0 {
+			t.Fatalf("inbox of %s not purged: %v, %v", uid, entries, err)
+		}
+	}
+	if page, _, _ := m.timeline.Sync(ctx, "alice", 0, 10); len(page) != 0 {
+		t.Fatalf("timeline of alice not erased: %v", page)
+	}
+	if page, _, _ := m.timeline.Sync(ctx, "bob", 0, 10); len(page) != 1 {
+		t.Fatalf("timeline of bob must be kept: %v", page)
+	}
+	for _, conv := range []string{"s:alice:bob", "s:alice:dave", "g:g1"} {
+		if m.redis.HExists(ctx, READ_CURSOR_PREFIX+conv, "alice").Val() {
+			t.Fatalf("read cursor of %s not erased", conv)
+		}
+	}
+	if m.redis.HGet(ctx, READ_CURSOR_PREFIX+"g:g1", "carol").Val() != "2" {
+		t.Fatal("other members' read cursors must be kept")
+	}
+	if m.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+"g1", "alice").Val() {
+		t.Fatal("group membership not erased")
+	}
+	if list := m.redis.LRange(ctx, BLACK_LIST_PREFIX+"bob", 0, -1).Val(); len(list) != 1 || list[0] != "mallory" {
+		t.Fatalf("unexpected blacklist of bob %v", list)
+	}
+
+	hits, _, _, err := m.index.Search("hello", store.Cursor{}, func(string) (bool, error) { return true, nil }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hit := range hits {
+		if hit.Id == "m1" {
+			t.Fatal("erased message still indexed")
+		}
+	}
+}
+
+func assertAudit(t *testing.T, audit *AuditLog, id, action string) {
+	t.Helper()
+	data, err := os.ReadFile(audit.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`"id":"%s"`, id)
+	if !strings.Contains(string(data), want) || !strings.Contains(string(data), `"operator":"admin"`) || !strings.Contains(string(data), action) {
+		t.Fatalf("audit record %s not found: %s", id, data)
+	}
+}
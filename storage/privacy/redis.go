@@ -0,0 +1,77 @@
+package privacy
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 其他服务写入的与用户相关的key, 擦除时需要一并清理
+//
+//	status:{uid}                         gateway 在线状态
+//	readCursor:{conversation}            center 已读游标, field 为uid
+//	groupMember:{groupId}                群成员集合
+//	blackList:{uid}                      uid 的黑名单列表
+//	groupBlackList:{groupId}             群黑名单列表
+//	delivery:{messageId}                 center 送达状态
+//	disappearPending:{conversation}:{uid} center 阅后即焚待读集合
+//	e2eeDevices:{uid}                    center 端到端加密设备列表, {uid} 为hash tag
+//	e2eePrekeys:{uid}:{deviceId}         center 端到端加密一次性公钥
+//
+// signal的seq计数器 seq:{conversation} 与会话其他成员共享, seq:u:{uid} 随时间线的检查点恢复,
+// 删除后会回退其他成员的seq或与检查点不一致, 擦除时保留
+const (
+	STATUS_PREFIX            = "status:"
+	SEQ_PREFIX               = "seq:"
+	READ_CURSOR_PREFIX       = "readCursor:"
+	GROUP_MEMBER_PREFIX      = "groupMember:"
+	BLACK_LIST_PREFIX        = "blackList:"
+	GROUP_BLACK_LIST_PREFIX  = "groupBlackList:"
+	DELIVERY_PREFIX          = "delivery:"
+	DISAPPEAR_PENDING_PREFIX = "disappearPending:"
+	E2EE_DEVICES_PREFIX      = "e2eeDevices:"
+	E2EE_PREKEYS_PREFIX      = "e2eePrekeys:"
+
+	// 每次SCAN的数量
+	SCAN_COUNT = 1000
+)
+
+// scan 遍历匹配 pattern 的全部key, 集群模式下遍历每个主节点
+func scan(ctx context.Context, rdb redis.UniversalClient, pattern string) ([]string, error) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	scanNode := func(ctx context.Context, c redis.UniversalClient) error {
+		iter := c.Scan(ctx, 0, pattern, SCAN_COUNT).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	if cluster, ok := rdb.(*redis.ClusterClient); ok {
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scanNode(ctx, node)
+		})
+		return keys, err
+	}
+	return keys, scanNode(ctx, rdb)
+}
+
+// escape 转义uid中的glob字符, 避免匹配到其他用户的key
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
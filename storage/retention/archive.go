@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Archiver 保存按 archive 策略清理的消息, 成功后消息才会从存储中删除
+type Archiver interface {
+	Archive(ctx context.Context, msgs []*pb.MessageData) error
+}
+
+// FileArchiver 按天写入 {dir}/{yyyy-mm-dd}.jsonl.gz, 每行为一条消息的json
+// 每次调用追加一个独立的gzip成员, 多成员的gzip文件可以直接用 zcat 读取
+type FileArchiver struct {
+	dir string
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+func NewFileArchiver(dir string) (*FileArchiver, error) {
+	if dir == "" {
+		dir = "data/archive"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileArchiver{dir: dir, now: time.Now}, nil
+}
+
+func (a *FileArchiver) Archive(ctx context.Context, msgs []*pb.MessageData) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := filepath.Join(a.dir, a.now().Format("2006-01-02")+".jsonl.gz")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	for _, msg := range msgs {
+		line, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := zw.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,248 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/search"
+	"storage/store"
+)
+
+// Job 按保留策略清理过期消息
+//
+// 1. 以最短的保留时间为界, 按 (send_time, id) 跨会话遍历较早的消息, 逐条匹配策略判断是否过期
+// 2. archive 策略的消息先写入归档, 成功后与 delete 策略的消息一起从存储和全文索引中删除
+// 3. 媒体消息引用的obs对象以最长的保留时间为界请求删除, 存在永久保留的消息时不删除媒体
+// 4. dry-run 只统计不修改, 两种模式都会生成清理报告
+//
+// 每批消息独立处理, 中途失败时已处理的批次不会回滚, 下次运行从头遍历即可继续
+
+const (
+	DEFAULT_BATCH = 200
+
+	// 报告中最多列出的消息id数
+	MAX_REPORT_IDS = 1000
+)
+
+type Config struct {
+	Policies  []*Policy
+	BatchSize int
+	ReportDir string // 为空时不写入报告文件
+}
+
+type Job struct {
+	store    store.MessageStore
+	index    *search.Index
+	archiver Archiver
+	media    MediaRemover
+	conf     *Config
+}
+
+// NewJob archiver 为nil时 archive 策略无法执行, media 为nil时不删除媒体
+func NewJob(s store.MessageStore, index *search.Index, archiver Archiver, media MediaRemover, conf *Config) (*Job, error) {
+	for _, p := range conf.Policies {
+		if p.Action == ACTION_ARCHIVE && archiver == nil {
+			return nil, fmt.Errorf("%w: %s: archive requires an archiver", ErrInvalidPolicy, p.Name)
+		}
+	}
+	if conf.BatchSize <= 0 || conf.BatchSize > store.MAX_LIMIT {
+		conf.BatchSize = DEFAULT_BATCH
+	}
+	if conf.ReportDir != "" {
+		if err := os.MkdirAll(conf.ReportDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Job{store: s, index: index, archiver: archiver, media: media, conf: conf}, nil
+}
+
+type PolicyStat struct {
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	TTL      string `json:"ttl"`
+	Messages int    `json:"messages"`
+	Media    int    `json:"media"`
+}
+
+// Report 一次清理的结果
+type Report struct {
+	DryRun     bool          `json:"dry_run"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Scanned    int           `json:"scanned"`
+	Deleted    int           `json:"deleted"`  // 已删除的消息数, 包括归档后删除的
+	Archived   int           `json:"archived"` // 已归档的消息数
+	Media      int           `json:"media"`    // 已删除的obs对象数, dry-run 时为待删除的对象数
+	Policies   []*PolicyStat `json:"policies"`
+	Messages   []string      `json:"messages"` // 被清理的消息id, 最多 MAX_REPORT_IDS 条
+	Truncated  bool          `json:"truncated"`
+	Error      string        `json:"error,omitempty"`
+}
+
+func (r *Report) addMessage(id string) {
+	if len(r.Messages) < MAX_REPORT_IDS {
+		r.Messages = append(r.Messages, id)
+	} else {
+		r.Truncated = true
+	}
+}
+
+// Run 执行一次清理, 返回的报告在出错时记录已完成的部分
+func (j *Job) Run(ctx context.Context, now time.Time, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, StartedAt: now}
+	stats := make(map[*Policy]*PolicyStat, len(j.conf.Policies))
+	for _, p := range j.conf.Policies {
+		stat := &PolicyStat{Name: p.Name, Action: p.Action, TTL: p.TTL.String()}
+		stats[p] = stat
+		report.Policies = append(report.Policies, stat)
+	}
+
+	err := j.run(ctx, now, dryRun, report, stats)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now()
+
+	if werr := j.writeReport(report); werr != nil {
+		log.Printf("[WARN] write retention report: %v", werr)
+	}
+	return report, err
+}
+
+func (j *Job) run(ctx context.Context, now time.Time, dryRun bool, report *Report, stats map[*Policy]*PolicyStat) error {
+	shortest, longest, forever := bounds(j.conf.Policies)
+	if shortest == 0 {
+		return nil
+	}
+	// 媒体以最长的保留时间为界, 保证仍被其他会话引用的对象不会被删除
+	mediaBefore := now.Add(-longest)
+	removeMedia := j.media != nil && !forever
+
+	var after store.Cursor
+	for {
+		msgs, hasMore, err := j.store.ScanBefore(ctx, now.Add(-shortest).UnixMilli(), after, j.conf.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		after = store.CursorOf(msgs[len(msgs)-1])
+		report.Scanned += len(msgs)
+
+		var (
+			expired  []string
+			archived []*pb.MessageData
+			media    = make(map[string]*PolicyStat)
+		)
+		for _, msg := range msgs {
+			p := Match(j.conf.Policies, msg)
+			if p == nil || msg.SendTime >= now.Add(-p.TTL).UnixMilli() {
+				continue
+			}
+			stats[p].Messages++
+			report.addMessage(msg.Id)
+			expired = append(expired, msg.Id)
+			if p.Action == ACTION_ARCHIVE {
+				archived = append(archived, msg)
+			}
+			if id := MediaObject(msg); id != "" && removeMedia {
+				media[id] = stats[p]
+			}
+		}
+
+		if dryRun {
+			report.Deleted += len(expired)
+			report.Archived += len(archived)
+			for _, stat := range media {
+				stat.Media++
+				report.Media++
+			}
+		} else if err := j.purge(ctx, expired, archived, media, mediaBefore, report); err != nil {
+			return err
+		}
+
+		if !hasMore {
+			return nil
+		}
+	}
+}
+
+// purge 归档并删除一批过期消息
+func (j *Job) purge(ctx context.Context, expired []string, archived []*pb.MessageData, media map[string]*PolicyStat, mediaBefore time.Time, report *Report) error {
+	if len(expired) == 0 {
+		return nil
+	}
+	if len(archived) > 0 {
+		if err := j.archiver.Archive(ctx, archived); err != nil {
+			return err
+		}
+		report.Archived += len(archived)
+	}
+
+	n, err := j.store.Delete(ctx, expired)
+	if err != nil {
+		return err
+	}
+	report.Deleted += n
+	if j.index != nil {
+		if err := j.index.Remove(expired...); err != nil {
+			return err
+		}
+	}
+
+	// 媒体删除失败只记录日志, 消息已经删除, 对象留待obs自行清理
+	for id, stat := range media {
+		removed, err := j.media.Remove(ctx, id, mediaBefore)
+		if err != nil {
+			log.Printf("[WARN] remove media %s: %v", id, err)
+			continue
+		}
+		if removed {
+			stat.Media++
+			report.Media++
+		}
+	}
+	return nil
+}
+
+func (j *Job) writeReport(r *Report) error {
+	if j.conf.ReportDir == "" {
+		return nil
+	}
+	name := "retention-" + r.StartedAt.Format("20060102T150405")
+	if r.DryRun {
+		name += "-dryrun"
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(j.conf.ReportDir, name+".json"), data, 0o644)
+}
+
+// Schedule 按 interval 定期执行, ctx 取消后返回
+func (j *Job) Schedule(ctx context.Context, interval time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r, err := j.Run(ctx, now, dryRun)
+			if err != nil {
+				log.Printf("[ERROR] retention: %v", err)
+				continue
+			}
+			log.Printf("[INFO] retention finished: dry_run=%v scanned=%d deleted=%d archived=%d media=%d",
+				r.DryRun, r.Scanned, r.Deleted, r.Archived, r.Media)
+		}
+	}
+}
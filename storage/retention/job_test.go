@@ -0,0 +1,165 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/store"
+)
+
+type fakeMedia struct {
+	removed []string
+	before  time.Time
+}
+
+func (f *fakeMedia) Remove(ctx context.Context, objectId string, before time.Time) (bool, error) {
+	f.removed = append(f.removed, objectId)
+	f.before = before
+	return true, nil
+}
+
+func policies(t *testing.T, confs ...PolicyConfig) []*Policy {
+	t.Helper()
+	p, err := ParsePolicies(confs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func message(id string, typ pb.SesstionType, msgType pb.MessageType, sendTime time.Time) *pb.MessageData {
+	msg := &pb.MessageData{
+		Id:           id,
+		SenderId:     "u1",
+		ReceiverId:   "u2",
+		SesstionType: typ,
+		MessageType:  msgType,
+		Payload:      []byte("hello"),
+		SendTime:     sendTime.UnixMilli(),
+	}
+	if typ == pb.SesstionType_GROUP {
+		msg.ReceiverId = "g1"
+	}
+	if msgType == pb.MessageType_FILE {
+		msg.Payload = []byte(fmt.Sprintf(`{"object_id":"obj-%s","name":"a.txt"}`, id))
+	}
+	return msg
+}
+
+func TestMatch(t *testing.T) {
+	ps := policies(t,
+		PolicyConfig{Name: "vip", Tenant: "acme", TTL: "0"},
+		PolicyConfig{Name: "files", MessageType: "file", TTL: "720h"},
+		PolicyConfig{Name: "group", SessionType: "GROUP", TTL: "4320h"},
+	)
+	now := time.Now()
+
+	tests := []struct {
+		msg  *pb.MessageData
+		want string
+	}{
+		{message("1", pb.SesstionType_GROUP, pb.MessageType_FILE, now), "files"},
+		{message("2", pb.SesstionType_GROUP, pb.MessageType_TEXT, now), "group"},
+		{message("3", pb.SesstionType_SINGLE, pb.MessageType_TEXT, now), ""},
+		{&pb.MessageData{MessageType: pb.MessageType_FILE, Ext: map[string]string{TENANT_KEY: "acme"}}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if p := Match(ps, tt.msg); p != nil {
+			got = p.Name
+		}
+		if got != tt.want {
+			t.Fatalf("%v: expected %q, got %q", tt.msg, tt.want, got)
+		}
+	}
+
+	if _, err := ParsePolicies([]PolicyConfig{{MessageType: "sticker", TTL: "1h"}}); err == nil {
+		t.Fatal("expected unknown message type to be rejected")
+	}
+}
+
+func TestJob(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := store.NewBoltStore(filepath.Join(dir, "messages.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	day := 24 * time.Hour
+	_, err = s.Save(ctx, []*pb.MessageData{
+		message("old-file", pb.SesstionType_SINGLE, pb.MessageType_FILE, now.Add(-40*day)),
+		message("new-file", pb.SesstionType_SINGLE, pb.MessageType_FILE, now.Add(-10*day)),
+		message("old-text", pb.SesstionType_SINGLE, pb.MessageType_TEXT, now.Add(-100*day)),
+		message("old-group", pb.SesstionType_GROUP, pb.MessageType_TEXT, now.Add(-200*day)),
+		message("new-group", pb.SesstionType_GROUP, pb.MessageType_TEXT, now.Add(-100*day)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archiver, err := NewFileArchiver(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := &fakeMedia{}
+	job, err := NewJob(s, nil, archiver, media, &Config{
+		Policies: policies(t,
+			PolicyConfig{Name: "files", MessageType: "file", TTL: "720h", Action: ACTION_ARCHIVE},
+			PolicyConfig{Name: "group", SessionType: "group", TTL: "4320h"},
+			PolicyConfig{Name: "default", TTL: "8760h"},
+		),
+		BatchSize: 2,
+		ReportDir: filepath.Join(dir, "reports"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dry-run 只统计; new-file 晚于最短保留时间, 不会被遍历
+	report, err := job.Run(ctx, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned != 4 || report.Deleted != 2 || report.Archived != 1 || report.Media != 1 || len(media.removed) != 0 {
+		t.Fatalf("unexpected dry-run report %+v", report)
+	}
+	if msgs, _ := s.Get(ctx, []string{"old-file", "old-group"}); len(msgs) != 2 {
+		t.Fatalf("dry-run must not delete, %d left", len(msgs))
+	}
+
+	report, err = job.Run(ctx, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 2 || report.Archived != 1 || report.Media != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Messages) != 2 || report.Policies[0].Messages != 1 || report.Policies[1].Messages != 1 {
+		t.Fatalf("unexpected report details %+v", report)
+	}
+	if len(media.removed) != 1 || media.removed[0] != "obj-old-file" || !media.before.Equal(now.Add(-365*day)) {
+		t.Fatalf("unexpected media removal %v before %v", media.removed, media.before)
+	}
+
+	msgs, err := s.Get(ctx, []string{"old-file", "new-file", "old-text", "old-group", "new-group"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages left, got %d", len(msgs))
+	}
+
+	archives, _ := os.ReadDir(filepath.Join(dir, "archive"))
+	reports, _ := os.ReadDir(filepath.Join(dir, "reports"))
+	if len(archives) != 1 || len(reports) != 2 {
+		t.Fatalf("expected 1 archive and 2 reports, got %d, %d", len(archives), len(reports))
+	}
+}
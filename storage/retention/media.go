@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// MediaRemover 删除过期消息引用的obs对象
+//
+// obs中的对象按内容去重, 同一对象可能被多个会话的消息引用,
+// 只有在 before 之后没有被再次上传(秒传)的对象才会真正删除, 其余由obs跳过
+type MediaRemover interface {
+	Remove(ctx context.Context, objectId string, before time.Time) (bool, error)
+}
+
+// MediaObject 消息引用的obs对象, 缩略图由obs随对象一起删除
+func MediaObject(msg *pb.MessageData) string {
+	switch msg.MessageType {
+	case pb.MessageType_IMAGE, pb.MessageType_AUDIO, pb.MessageType_VIDEO, pb.MessageType_FILE:
+	default:
+		return ""
+	}
+	var payload pb.MediaPayload
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(msg.Payload, &payload); err != nil {
+		return ""
+	}
+	return payload.ObjectId
+}
+
+// ObsClient 通过obs的http接口下载及删除对象
+//
+// GET    {baseUrl}/files/{id}?expires=&sig=
+// sig = hex(hmac_sha256(secret, id + ":" + expires)), 即obs的下载签名
+// DELETE {baseUrl}/files/{id}?before=&expires=&sig=
+// sig = hex(hmac_sha256(secret, "delete:" + id + ":" + expires)), 与obs下载签名使用相同的密钥
+type ObsClient struct {
+	baseUrl string
+	secret  []byte
+	client  *http.Client
+}
+
+func NewObsClient(baseUrl, secret string) *ObsClient {
+	return &ObsClient{
+		baseUrl: baseUrl,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch 下载对象写入 w
+func (c *ObsClient) Fetch(ctx context.Context, objectId string, w io.Writer) error {
+	expires, sig := c.sign(objectId)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("sig", sig)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseUrl+"/files/"+url.PathEscape(objectId)+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// 下载的对象可能较大, 不使用 client 的整体超时
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("obs fetch %s: %s", objectId, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Remove 返回对象是否被删除, 对象不存在或仍在使用时返回false
+func (c *ObsClient) Remove(ctx context.Context, objectId string, before time.Time) (bool, error) {
+	expires, sig := c.sign("delete:" + objectId)
+	q := url.Values{}
+	q.Set("before", strconv.FormatInt(before.Unix(), 10))
+	q.Set("expires", expires)
+	q.Set("sig", sig)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseUrl+"/files/"+url.PathEscape(objectId)+"?"+q.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound, http.StatusConflict:
+		return false, nil
+	default:
+		return false, fmt.Errorf("obs delete %s: %s", objectId, resp.Status)
+	}
+}
+
+// sign 对 subject 签名, 返回一分钟后的过期时间及签名
+func (c *ObsClient) sign(subject string) (string, string) {
+	expires := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte(subject + ":" + expires))
+	return expires, hex.EncodeToString(h.Sum(nil))
+}
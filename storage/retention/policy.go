@@ -0,0 +1,145 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// 消息保留策略
+//
+// 策略按 租户、会话类型、消息类型 匹配, 配置中靠前的策略优先, 只有第一条匹配的策略生效
+// 因此更具体的策略应写在前面, 例如:
+//   1. tenant=acme                  ttl=0     acme 的消息永久保留
+//   2. messageType=file             ttl=720h  文件消息保留30天
+//   3. sessionType=group            ttl=4320h 群聊保留180天
+// 没有匹配任何策略或 ttl 为0的消息永久保留
+//
+// 租户取自 MessageData.ext["tenant"], 未设置时为空, 只能被不限租户的策略匹配
+
+const (
+	TENANT_KEY = "tenant"
+
+	ACTION_DELETE  = "delete"  // 直接删除
+	ACTION_ARCHIVE = "archive" // 写入归档后删除
+)
+
+var ErrInvalidPolicy = errors.New("invalid retention policy")
+
+type Policy struct {
+	Name        string
+	Tenant      string          // 为空或 * 表示不限
+	SessionType pb.SesstionType // 为 SESSION_TYPE_UNSPECIFIED 表示不限
+	MessageType pb.MessageType  // 为 MSG_TYPE_UNSPECIFIED 表示不限
+	TTL         time.Duration
+	Action      string
+}
+
+// PolicyConfig 配置文件中的策略, 类型使用枚举名(不区分大小写)
+type PolicyConfig struct {
+	Name        string `mapstructure:"name"`
+	Tenant      string `mapstructure:"tenant"`
+	SessionType string `mapstructure:"sessionType"` // single | group | system
+	MessageType string `mapstructure:"messageType"` // text | image | audio | video | file | custom
+	TTL         string `mapstructure:"ttl"`
+	Action      string `mapstructure:"action"` // delete | archive, 默认 delete
+}
+
+// ParsePolicies 解析并校验配置中的策略
+func ParsePolicies(confs []PolicyConfig) ([]*Policy, error) {
+	policies := make([]*Policy, 0, len(confs))
+	for i, c := range confs {
+		p := &Policy{Name: c.Name, Tenant: c.Tenant, Action: c.Action}
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("policy-%d", i+1)
+		}
+		if p.Tenant == "*" {
+			p.Tenant = ""
+		}
+		if p.Action == "" {
+			p.Action = ACTION_DELETE
+		}
+		if p.Action != ACTION_DELETE && p.Action != ACTION_ARCHIVE {
+			return nil, fmt.Errorf("%w: %s: unknown action %q", ErrInvalidPolicy, p.Name, c.Action)
+		}
+
+		if c.SessionType != "" && c.SessionType != "*" {
+			v, ok := pb.SesstionType_value[strings.ToUpper(c.SessionType)]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: unknown session type %q", ErrInvalidPolicy, p.Name, c.SessionType)
+			}
+			p.SessionType = pb.SesstionType(v)
+		}
+		if c.MessageType != "" && c.MessageType != "*" {
+			v, ok := pb.MessageType_value[strings.ToUpper(c.MessageType)]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: unknown message type %q", ErrInvalidPolicy, p.Name, c.MessageType)
+			}
+			p.MessageType = pb.MessageType(v)
+		}
+
+		if c.TTL != "" {
+			ttl, err := time.ParseDuration(c.TTL)
+			if err != nil || ttl < 0 {
+				return nil, fmt.Errorf("%w: %s: invalid ttl %q", ErrInvalidPolicy, p.Name, c.TTL)
+			}
+			p.TTL = ttl
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (p *Policy) Matches(msg *pb.MessageData) bool {
+	if p.Tenant != "" && p.Tenant != msg.Ext[TENANT_KEY] {
+		return false
+	}
+	if p.SessionType != pb.SesstionType_SESSION_TYPE_UNSPECIFIED && p.SessionType != msg.SesstionType {
+		return false
+	}
+	if p.MessageType != pb.MessageType_MSG_TYPE_UNSPECIFIED && p.MessageType != msg.MessageType {
+		return false
+	}
+	return true
+}
+
+// Match 返回消息适用的策略, 永久保留时返回nil
+func Match(policies []*Policy, msg *pb.MessageData) *Policy {
+	for _, p := range policies {
+		if p.Matches(msg) {
+			if p.TTL == 0 {
+				return nil
+			}
+			return p
+		}
+	}
+	return nil
+}
+
+// bounds 最短与最长的保留时间
+// forever 为true表示存在永久保留的消息(没有兜底策略或某条策略 ttl 为0)
+func bounds(policies []*Policy) (shortest, longest time.Duration, forever bool) {
+	forever = true
+	for _, p := range policies {
+		if p.TTL == 0 {
+			continue
+		}
+		if shortest == 0 || p.TTL < shortest {
+			shortest = p.TTL
+		}
+		longest = max(longest, p.TTL)
+	}
+	for _, p := range policies {
+		if p.TTL == 0 {
+			break
+		}
+		if p.Tenant == "" && p.SessionType == pb.SesstionType_SESSION_TYPE_UNSPECIFIED && p.MessageType == pb.MessageType_MSG_TYPE_UNSPECIFIED {
+			forever = false
+			break
+		}
+	}
+	return shortest, longest, forever
+}
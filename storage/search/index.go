@@ -0,0 +1,307 @@
+package search
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+	bolt "go.etcd.io/bbolt"
+	"storage/store"
+)
+
+// Index 消息全文检索的倒排索引, 基于bbolt
+//
+// bucket :
+//   postings/{词}  send_time(8) + 消息id -> 会话id
+//   df             词 -> 包含该词的消息数
+//   docs           消息id -> send_time(8) + 词列表(以\x00分隔), 用于删除
+//
+// 1. 只索引文本消息, 已撤回的消息不入索引, 撤回时从索引中删除
+// 2. 查询按发送时间从新到旧返回, 多个词之间为"且"关系
+// 3. 可见性由调用方通过 filter 判断, 索引本身不区分用户
+// 4. 开启静态加密后索引中的词仍为明文, 运营方可通过 Policy 只为部分会话建立索引
+
+const (
+	// 单次查询最多检查的候选数, 超出后返回当前位置由客户端继续翻页
+	MAX_SCAN = 10000
+)
+
+var (
+	bucketPostings = []byte("postings")
+	bucketDF       = []byte("df")
+	bucketDocs     = []byte("docs")
+)
+
+var ErrEmptyQuery = errors.New("empty search query")
+
+type Hit struct {
+	Id             string
+	ConversationId string
+	SendTime       int64
+}
+
+// Filter 判断会话中的消息是否对查询者可见
+type Filter func(conversationId string) (bool, error)
+
+// Policy 运营方选择建立索引的消息, 为nil时索引全部文本消息
+type Policy func(msg *pb.MessageData) bool
+
+type Index struct {
+	db     *bolt.DB
+	policy Policy
+}
+
+func NewIndex(path string) (*Index, error) {
+	if path == "" {
+		path = "data/search.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPostings, bucketDF, bucketDocs} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Indexable 消息是否需要进入索引
+func Indexable(msg *pb.MessageData) bool {
+	return msg != nil && msg.Id != "" &&
+		msg.MessageType == pb.MessageType_TEXT &&
+		msg.Status != store.STATUS_RECALLED &&
+		len(msg.Payload) > 0
+}
+
+// SetPolicy 设置索引策略, 需在开始写入前调用
+func (i *Index) SetPolicy(p Policy) {
+	i.policy = p
+}
+
+// ParsePolicy 按会话类型选择建立索引的消息, 类型使用枚举名(不区分大小写)
+// 包含 * 时索引全部会话, 为空时不索引任何消息
+func ParsePolicy(sessionTypes []string) (Policy, error) {
+	allowed := make(map[pb.SesstionType]bool, len(sessionTypes))
+	for _, name := range sessionTypes {
+		if name == "*" {
+			return nil, nil
+		}
+		v, ok := pb.SesstionType_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown session type %q", name)
+		}
+		allowed[pb.SesstionType(v)] = true
+	}
+	return func(msg *pb.MessageData) bool {
+		return allowed[msg.SesstionType]
+	}, nil
+}
+
+// Add 索引消息, 已索引过的消息直接跳过
+func (i *Index) Add(msgs []*pb.MessageData) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		postings := tx.Bucket(bucketPostings)
+		df := tx.Bucket(bucketDF)
+		docs := tx.Bucket(bucketDocs)
+
+		for _, msg := range msgs {
+			if !Indexable(msg) || (i.policy != nil && !i.policy(msg)) || docs.Get([]byte(msg.Id)) != nil {
+				continue
+			}
+			terms := IndexTerms(string(msg.Payload))
+			if len(terms) == 0 {
+				continue
+			}
+
+			key := postingKey(msg.SendTime, msg.Id)
+			conversationId := []byte(store.ConversationOf(msg))
+			for _, term := range terms {
+				b, err := postings.CreateBucketIfNotExists([]byte(term))
+				if err != nil {
+					return err
+				}
+				if err := b.Put(key, conversationId); err != nil {
+					return err
+				}
+				if err := incr(df, term, 1); err != nil {
+					return err
+				}
+			}
+
+			doc := append(encodeInt(msg.SendTime), strings.Join(terms, "\x00")...)
+			if err := docs.Put([]byte(msg.Id), doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove 从索引中删除消息
+func (i *Index) Remove(ids ...string) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		postings := tx.Bucket(bucketPostings)
+		df := tx.Bucket(bucketDF)
+		docs := tx.Bucket(bucketDocs)
+
+		for _, id := range ids {
+			doc := docs.Get([]byte(id))
+			if len(doc) < 8 {
+				continue
+			}
+			key := postingKey(int64(binary.BigEndian.Uint64(doc)), id)
+			terms := strings.Split(string(doc[8:]), "\x00")
+
+			for _, term := range terms {
+				b := postings.Bucket([]byte(term))
+				if b == nil {
+					continue
+				}
+				if err := b.Delete(key); err != nil {
+					return err
+				}
+				if err := incr(df, term, -1); err != nil {
+					return err
+				}
+				if df.Get([]byte(term)) == nil {
+					if err := postings.DeleteBucket([]byte(term)); err != nil {
+						return err
+					}
+				}
+			}
+			if err := docs.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Search 查询同时包含全部查询词的消息, 按发送时间从新到旧
+//
+// before 为上一页返回的 next, 首页传零值
+// 返回的 next 为本次扫描停止的位置, hasMore 为false时无需继续翻页
+func (i *Index) Search(query string, before store.Cursor, filter Filter, limit int) (hits []Hit, next store.Cursor, hasMore bool, err error) {
+	terms := QueryTerms(query)
+	if len(terms) == 0 {
+		return nil, store.Cursor{}, false, ErrEmptyQuery
+	}
+
+	err = i.db.View(func(tx *bolt.Tx) error {
+		postings := tx.Bucket(bucketPostings)
+		df := tx.Bucket(bucketDF)
+
+		// 从包含文档最少的词开始遍历, 其余词只做存在性检查
+		var (
+			lists    = make([]*bolt.Bucket, 0, len(terms))
+			smallest = -1
+			minDF    uint64
+		)
+		for _, term := range terms {
+			b := postings.Bucket([]byte(term))
+			if b == nil {
+				return nil
+			}
+			n := decodeUint(df.Get([]byte(term)))
+			if smallest < 0 || n < minDF {
+				smallest, minDF = len(lists), n
+			}
+			lists = append(lists, b)
+		}
+
+		c := lists[smallest].Cursor()
+		var k, v []byte
+		if before.Id == "" {
+			k, v = c.Last()
+		} else {
+			// 定位到第一个不小于 before 的位置后回退一条
+			if k, _ = c.Seek(postingKey(before.SendTime, before.Id)); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		scanned := 0
+		for ; k != nil; k, v = c.Prev() {
+			if len(hits) == limit || scanned == MAX_SCAN {
+				hasMore = true
+				return nil
+			}
+			scanned++
+			next = store.Cursor{SendTime: int64(binary.BigEndian.Uint64(k)), Id: string(k[8:])}
+
+			if !containsAll(lists, smallest, k) {
+				continue
+			}
+			visible, err := filter(string(v))
+			if err != nil {
+				return err
+			}
+			if !visible {
+				continue
+			}
+			hits = append(hits, Hit{Id: next.Id, ConversationId: string(v), SendTime: next.SendTime})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, store.Cursor{}, false, err
+	}
+	return hits, next, hasMore, nil
+}
+
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+func containsAll(lists []*bolt.Bucket, skip int, key []byte) bool {
+	for j, b := range lists {
+		if j != skip && b.Get(key) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func incr(b *bolt.Bucket, term string, delta int) error {
+	n := int64(decodeUint(b.Get([]byte(term)))) + int64(delta)
+	if n <= 0 {
+		return b.Delete([]byte(term))
+	}
+	return b.Put([]byte(term), encodeInt(n))
+}
+
+func postingKey(sendTime int64, id string) []byte {
+	return append(encodeInt(sendTime), id...)
+}
+
+func encodeInt(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func decodeUint(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
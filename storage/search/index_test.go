@@ -0,0 +1,179 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/store"
+)
+
+func newIndex(t *testing.T) *Index {
+	t.Helper()
+	idx, err := NewIndex(filepath.Join(t.TempDir(), "search.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	return idx
+}
+
+func text(id, sender, receiver string, typ pb.SesstionType, sendTime int64, content string) *pb.MessageData {
+	return &pb.MessageData{
+		Id:           id,
+		SenderId:     sender,
+		ReceiverId:   receiver,
+		SesstionType: typ,
+		MessageType:  pb.MessageType_TEXT,
+		Payload:      []byte(content),
+		SendTime:     sendTime,
+	}
+}
+
+func all(string) (bool, error) { return true, nil }
+
+func ids(hits []Hit) []string {
+	out := make([]string, len(hits))
+	for i, h := range hits {
+		out[i] = h.Id
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	idx := newIndex(t)
+	err := idx.Add([]*pb.MessageData{
+		text("m1", "u1", "u2", pb.SesstionType_SINGLE, 1, "明天一起吃午饭吗"),
+		text("m2", "u2", "u1", pb.SesstionType_SINGLE, 2, "好的, 明天中午见"),
+		text("m3", "u3", "g1", pb.SesstionType_GROUP, 3, "明天的会议改到下午"),
+		text("m4", "u1", "u2", pb.SesstionType_SINGLE, 4, "Lunch tomorrow?"),
+		{Id: "m5", SenderId: "u1", ReceiverId: "u2", MessageType: pb.MessageType_IMAGE, Payload: []byte("明天"), SendTime: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _, hasMore, err := idx.Search("明天", store.Cursor{}, all, 10)
+	if err != nil || hasMore {
+		t.Fatalf("hasMore=%v, %v", hasMore, err)
+	}
+	if got := fmt.Sprint(ids(hits)); got != "[m3 m2 m1]" {
+		t.Fatalf("unexpected hits %s", got)
+	}
+
+	hits, _, _, err = idx.Search("明天 午", store.Cursor{}, all, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ids(hits)); got != "[m3 m2 m1]" {
+		t.Fatalf("unexpected hits %s", got)
+	}
+
+	hits, _, _, err = idx.Search("午饭", store.Cursor{}, all, 10)
+	if err != nil || fmt.Sprint(ids(hits)) != "[m1]" {
+		t.Fatalf("unexpected hits %v, %v", ids(hits), err)
+	}
+
+	hits, _, _, err = idx.Search("LUNCH", store.Cursor{}, all, 10)
+	if err != nil || fmt.Sprint(ids(hits)) != "[m4]" {
+		t.Fatalf("unexpected hits %v, %v", ids(hits), err)
+	}
+
+	if _, _, _, err := idx.Search("!!", store.Cursor{}, all, 10); err != ErrEmptyQuery {
+		t.Fatalf("expected ErrEmptyQuery, got %v", err)
+	}
+}
+
+func TestSearchFilterAndPaging(t *testing.T) {
+	idx := newIndex(t)
+	var msgs []*pb.MessageData
+	for i := 1; i <= 5; i++ {
+		msgs = append(msgs, text(fmt.Sprintf("s%d", i), "u1", "u2", pb.SesstionType_SINGLE, int64(i), "hello"))
+		msgs = append(msgs, text(fmt.Sprintf("g%d", i), "u3", "g1", pb.SesstionType_GROUP, int64(i), "hello"))
+	}
+	if err := idx.Add(msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	onlySingle := func(conversationId string) (bool, error) {
+		return conversationId == "s:u1:u2", nil
+	}
+
+	hits, next, hasMore, err := idx.Search("hello", store.Cursor{}, onlySingle, 2)
+	if err != nil || !hasMore || fmt.Sprint(ids(hits)) != "[s5 s4]" {
+		t.Fatalf("first page %v, hasMore=%v, %v", ids(hits), hasMore, err)
+	}
+	hits, next, hasMore, err = idx.Search("hello", next, onlySingle, 2)
+	if err != nil || !hasMore || fmt.Sprint(ids(hits)) != "[s3 s2]" {
+		t.Fatalf("second page %v, hasMore=%v, %v", ids(hits), hasMore, err)
+	}
+	hits, _, hasMore, err = idx.Search("hello", next, onlySingle, 2)
+	if err != nil || hasMore || fmt.Sprint(ids(hits)) != "[s1]" {
+		t.Fatalf("last page %v, hasMore=%v, %v", ids(hits), hasMore, err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	idx := newIndex(t)
+	err := idx.Add([]*pb.MessageData{
+		text("m1", "u1", "u2", pb.SesstionType_SINGLE, 1, "撤回这条消息"),
+		text("m2", "u1", "u2", pb.SesstionType_SINGLE, 2, "这条消息保留"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := idx.Remove("m1", "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _, _, err := idx.Search("消息", store.Cursor{}, all, 10)
+	if err != nil || fmt.Sprint(ids(hits)) != "[m2]" {
+		t.Fatalf("unexpected hits %v, %v", ids(hits), err)
+	}
+	hits, _, _, err = idx.Search("撤回", store.Cursor{}, all, 10)
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("expected removed message to be gone, got %v, %v", ids(hits), err)
+	}
+
+	// 已撤回的消息不再入索引
+	recalled := text("m3", "u1", "u2", pb.SesstionType_SINGLE, 3, "撤回")
+	recalled.Status = store.STATUS_RECALLED
+	if err := idx.Add([]*pb.MessageData{recalled}); err != nil {
+		t.Fatal(err)
+	}
+	hits, _, _, _ = idx.Search("撤回", store.Cursor{}, all, 10)
+	if len(hits) != 0 {
+		t.Fatalf("recalled message indexed: %v", ids(hits))
+	}
+}
+
+// 测试按会话类型选择建立索引的消息
+func TestPolicy(t *testing.T) {
+	idx := newIndex(t)
+	policy, err := ParsePolicy([]string{"group"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.SetPolicy(policy)
+
+	err = idx.Add([]*pb.MessageData{
+		text("m1", "u1", "u2", pb.SesstionType_SINGLE, 1, "hello world"),
+		text("m2", "u1", "g1", pb.SesstionType_GROUP, 2, "hello group"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, _, _, err := idx.Search("hello", store.Cursor{}, all, 10)
+	if err != nil || len(hits) != 1 || hits[0].Id != "m2" {
+		t.Fatalf("expected only group message indexed, got %v, %v", ids(hits), err)
+	}
+
+	if p, err := ParsePolicy([]string{"*"}); p != nil || err != nil {
+		t.Fatalf("expected nil policy for *, got %v", err)
+	}
+	if _, err := ParsePolicy([]string{"channel"}); err == nil {
+		t.Fatal("expected error for unknown session type")
+	}
+}
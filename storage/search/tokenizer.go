@@ -0,0 +1,120 @@
+package search
+
+import (
+	"unicode"
+)
+
+// 分词
+//
+// 1. 英文、数字等按连续的字母数字切分为词, 统一转小写
+// 2. 中日韩文字没有空格分隔, 按单字及相邻两字(bigram)切分
+//    文档同时索引单字与bigram, 查询时单字查询用单字, 多字查询用bigram
+//    这样 "你好世界" 可以被 "好"、"好世"、"你好世界" 命中
+// 3. 其余字符(标点、空白、emoji等)作为分隔符
+
+// 单个词最多保留的字符数, 避免超长无意义字符串撑大索引
+const MAX_TERM_RUNES = 32
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// segments 将文本切分为连续的词或中日韩文字片段
+func segments(text string, fn func(run []rune, cjk bool)) {
+	var (
+		run []rune
+		cjk bool
+	)
+	flush := func() {
+		if len(run) > 0 {
+			fn(run, cjk)
+			run = nil
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !cjk {
+				flush()
+			}
+			cjk = true
+			run = append(run, r)
+		case isWord(r):
+			if cjk {
+				flush()
+			}
+			cjk = false
+			run = append(run, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// IndexTerms 文档需要索引的词, 已去重
+func IndexTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	segments(text, func(run []rune, cjk bool) {
+		if !cjk {
+			add(truncate(run))
+			return
+		}
+		for i := range run {
+			add(string(run[i]))
+			if i+1 < len(run) {
+				add(string(run[i : i+2]))
+			}
+		}
+	})
+	return terms
+}
+
+// QueryTerms 查询需要同时命中的词, 已去重
+func QueryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	segments(query, func(run []rune, cjk bool) {
+		switch {
+		case !cjk:
+			add(truncate(run))
+		case len(run) == 1:
+			add(string(run))
+		default:
+			for i := 0; i+1 < len(run); i++ {
+				add(string(run[i : i+2]))
+			}
+		}
+	})
+	return terms
+}
+
+func truncate(run []rune) string {
+	if len(run) > MAX_TERM_RUNES {
+		run = run[:MAX_TERM_RUNES]
+	}
+	return string(run)
+}
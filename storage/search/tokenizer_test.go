@@ -0,0 +1,41 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"你好世界", []string{"你", "你好", "好", "好世", "世", "世界", "界"}},
+		{"周五meeting改到3点", []string{"周", "周五", "五", "meeting", "改", "改到", "到", "3", "点"}},
+		{"go go GO", []string{"go"}},
+		{"  ...  ", nil},
+	}
+	for _, tt := range tests {
+		if got := IndexTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IndexTerms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"好", []string{"好"}},
+		{"你好世界", []string{"你好", "好世", "世界"}},
+		{"Meeting 3点", []string{"meeting", "3", "点"}},
+		{"こんにちは", []string{"こん", "んに", "にち", "ちは"}},
+	}
+	for _, tt := range tests {
+		if got := QueryTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
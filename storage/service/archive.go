@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/tiered"
+)
+
+// ArchiveHandle 冷热分层存储的分段查询与恢复, 由gateway的管理接口调用
+
+type ArchiveHandle struct {
+	storagepb.UnimplementedArchiveServiceServer
+	store *tiered.Store
+}
+
+func NewArchiveHandle(store *tiered.Store) *ArchiveHandle {
+	return &ArchiveHandle{store: store}
+}
+
+func (a *ArchiveHandle) ListSegments(ctx context.Context, in *storagepb.ListSegmentsRequest) (*storagepb.ListSegmentsResponse, error) {
+	if in.ConversationId == "" {
+		return nil, ErrInvalidArgument
+	}
+	segs, err := a.store.Segments(in.ConversationId)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.ListSegmentsResponse{Segments: segs}, nil
+}
+
+func (a *ArchiveHandle) RestoreRange(ctx context.Context, in *storagepb.RestoreRangeRequest) (*storagepb.RestoreRangeResponse, error) {
+	segments, messages, err := a.store.Restore(ctx, in.ConversationId, in.FromSeq, in.ToSeq)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.RestoreRangeResponse{Segments: int64(segments), Messages: int64(messages)}, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/checkpoint"
+	"storage/store"
+	"storage/timeline"
+)
+
+// CheckpointHandle 会话seq检查点, 由signal调用
+// 用户的inbox seq同样通过检查点恢复, id为 u:{uid}, 最大seq取自时间线
+
+type CheckpointHandle struct {
+	storagepb.UnimplementedCheckpointServiceServer
+	checkpoints checkpoint.Store
+	history     store.MessageStore
+	timeline    timeline.Store
+}
+
+func NewCheckpointHandle(checkpoints checkpoint.Store, history store.MessageStore, timeline timeline.Store) *CheckpointHandle {
+	return &CheckpointHandle{checkpoints: checkpoints, history: history, timeline: timeline}
+}
+
+func (c *CheckpointHandle) SaveCheckpoints(ctx context.Context, in *storagepb.SaveCheckpointsRequest) (*storagepb.SaveCheckpointsResponse, error) {
+	if len(in.Checkpoints) > store.MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+	checkpoints := make([]checkpoint.Checkpoint, len(in.Checkpoints))
+	for i, cp := range in.Checkpoints {
+		checkpoints[i] = checkpoint.Checkpoint{ConversationId: cp.ConversationId, Seq: cp.Seq}
+	}
+	if err := c.checkpoints.Save(ctx, checkpoints); err != nil {
+		return nil, err
+	}
+	return &storagepb.SaveCheckpointsResponse{}, nil
+}
+
+// GetSeqStates 检查点与已存储消息中最大的seq, 消息被删除后检查点仍能保证seq不回退
+func (c *CheckpointHandle) GetSeqStates(ctx context.Context, in *storagepb.GetSeqStatesRequest) (*storagepb.GetSeqStatesResponse, error) {
+	if len(in.ConversationIds) > store.MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+	found, err := c.checkpoints.Get(ctx, in.ConversationIds)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*storagepb.SeqState, 0, len(in.ConversationIds))
+	for _, id := range in.ConversationIds {
+		if id == "" {
+			return nil, ErrInvalidArgument
+		}
+		state := &storagepb.SeqState{ConversationId: id, Checkpoint: found[id]}
+		if state.MaxSeq, err = c.maxSeq(ctx, id); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return &storagepb.GetSeqStatesResponse{States: states}, nil
+}
+
+// maxSeq 已存储的最大seq, 会话取自消息历史, 用户的inbox seq取自时间线
+func (c *CheckpointHandle) maxSeq(ctx context.Context, id string) (int64, error) {
+	if uid, ok := strings.CutPrefix(id, timeline.CHECKPOINT_PREFIX); ok {
+		return c.timeline.Max(ctx, uid)
+	}
+	latest, _, err := c.history.QueryBySeq(ctx, id, 0, true, 1)
+	if err != nil || len(latest) == 0 {
+		return 0, err
+	}
+	return latest[0].Seq, nil
+}
+
+func (c *CheckpointHandle) ListCheckpoints(ctx context.Context, in *storagepb.ListCheckpointsRequest) (*storagepb.ListCheckpointsResponse, error) {
+	limit := int(in.Limit)
+	if limit <= 0 || limit > store.MAX_LIMIT {
+		limit = store.MAX_LIMIT
+	}
+	checkpoints, hasMore, err := c.checkpoints.Scan(ctx, in.After, limit)
+	if err != nil {
+		return nil, err
+	}
+	resp := &storagepb.ListCheckpointsResponse{HasMore: hasMore}
+	for _, cp := range checkpoints {
+		resp.Checkpoints = append(resp.Checkpoints, &storagepb.SeqCheckpoint{ConversationId: cp.ConversationId, Seq: cp.Seq})
+	}
+	return resp, nil
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"storage/search"
+	"storage/store"
+)
+
+// HistoryHandle 消息历史查询
+//
+// center 在消息投递前调用 SaveMessages 持久化消息
+// 客户端通过 gateway 按会话seq、发送时间或消息id查询历史消息
+// 查询者必须是会话参与者: 私聊为收发双方之一, 群聊为群成员
+// 写入的文本消息同时进入全文索引, 撤回时从索引中删除
+
+// 群成员集合
+// key : groupMember:{groupId}
+// value : set(uid)
+const GROUP_MEMBER_PREFIX = "groupMember:"
+
+var (
+	ErrNotMember = errors.New("user is not a member of the conversation")
+	ErrNotSender = errors.New("only the sender can recall the message")
+)
+
+type HistoryHandle struct {
+	storagepb.UnimplementedHistoryServiceServer
+	store store.MessageStore
+	index *search.Index
+	redis redis.UniversalClient
+}
+
+func NewHistoryHandle(store store.MessageStore, index *search.Index, redis redis.UniversalClient) *HistoryHandle {
+	return &HistoryHandle{store: store, index: index, redis: redis}
+}
+
+func (h *HistoryHandle) SaveMessages(ctx context.Context, in *storagepb.SaveMessagesRequest) (*storagepb.SaveMessagesResponse, error) {
+	for _, msg := range in.Messages {
+		if msg == nil || msg.Id == "" || msg.SenderId == "" || msg.ReceiverId == "" {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	saved, err := h.store.Save(ctx, in.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	// 消息历史为准, 索引失败只影响搜索
+	if err := h.index.Add(in.Messages); err != nil {
+		log.Printf("[WARN] 消息索引失败: %v", err)
+	}
+	return &storagepb.SaveMessagesResponse{Saved: int32(saved)}, nil
+}
+
+func (h *HistoryHandle) QueryBySeq(ctx context.Context, in *storagepb.QueryBySeqRequest) (*storagepb.QueryMessagesResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.AnchorSeq < 0 {
+		return nil, ErrInvalidArgument
+	}
+	if err := h.checkMember(ctx, in.Uid, in.ConversationId, in.SessionType); err != nil {
+		return nil, err
+	}
+
+	backward := in.Direction == storagepb.Direction_BACKWARD
+	msgs, hasMore, err := h.store.QueryBySeq(ctx,
+		store.ConversationKey(in.Uid, in.ConversationId, in.SessionType),
+		in.AnchorSeq, backward, int(in.Limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &storagepb.QueryMessagesResponse{Messages: msgs, HasMore: hasMore, NextSeq: in.AnchorSeq}
+	if len(msgs) > 0 {
+		// 结果按seq升序, 向前翻页以第一条为下一页锚点, 向后翻页以最后一条为锚点
+		if backward {
+			resp.NextSeq = msgs[0].Seq
+		} else {
+			resp.NextSeq = msgs[len(msgs)-1].Seq
+		}
+	}
+	return resp, nil
+}
+
+func (h *HistoryHandle) QueryByTime(ctx context.Context, in *storagepb.QueryByTimeRequest) (*storagepb.QueryMessagesResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.StartTime < 0 || in.EndTime < 0 {
+		return nil, ErrInvalidArgument
+	}
+	after, err := store.ParseCursor(in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkMember(ctx, in.Uid, in.ConversationId, in.SessionType); err != nil {
+		return nil, err
+	}
+
+	msgs, hasMore, err := h.store.QueryByTime(ctx,
+		store.ConversationKey(in.Uid, in.ConversationId, in.SessionType),
+		in.StartTime, in.EndTime, after, int(in.Limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &storagepb.QueryMessagesResponse{Messages: msgs, HasMore: hasMore}
+	if hasMore {
+		resp.NextPageToken = store.CursorOf(msgs[len(msgs)-1]).Encode()
+	}
+	return resp, nil
+}
+
+// GetMessages 按id查询, 查询者不可见的消息直接过滤
+func (h *HistoryHandle) GetMessages(ctx context.Context, in *storagepb.GetMessagesRequest) (*storagepb.GetMessagesResponse, error) {
+	if in.Uid == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	msgs, err := h.store.Get(ctx, in.MessageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]bool)
+	visible := make([]*pb.MessageData, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.SesstionType != pb.SesstionType_GROUP {
+			if msg.SenderId == in.Uid || msg.ReceiverId == in.Uid {
+				visible = append(visible, msg)
+			}
+			continue
+		}
+
+		joined, ok := groups[msg.ReceiverId]
+		if !ok {
+			joined, err = h.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+msg.ReceiverId, in.Uid).Result()
+			if err != nil {
+				return nil, err
+			}
+			groups[msg.ReceiverId] = joined
+		}
+		if joined {
+			visible = append(visible, msg)
+		}
+	}
+	return &storagepb.GetMessagesResponse{Messages: visible}, nil
+}
+
+func (h *HistoryHandle) RecallMessage(ctx context.Context, in *storagepb.RecallMessageRequest) (*storagepb.RecallMessageResponse, error) {
+	if in.Uid == "" || in.MessageId == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	msgs, err := h.store.Get(ctx, []string{in.MessageId})
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, store.ErrNotFound
+	}
+	if msgs[0].SenderId != in.Uid {
+		return nil, ErrNotSender
+	}
+
+	msg, err := h.store.SetStatus(ctx, in.MessageId, store.STATUS_RECALLED)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.index.Remove(in.MessageId); err != nil {
+		return nil, err
+	}
+	return &storagepb.RecallMessageResponse{Message: msg}, nil
+}
+
+func (h *HistoryHandle) DeleteMessages(ctx context.Context, in *storagepb.DeleteMessagesRequest) (*storagepb.DeleteMessagesResponse, error) {
+	if len(in.MessageIds) == 0 {
+		return &storagepb.DeleteMessagesResponse{}, nil
+	}
+	if len(in.MessageIds) > store.MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+
+	deleted, err := h.store.Delete(ctx, in.MessageIds)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.index.Remove(in.MessageIds...); err != nil {
+		return nil, err
+	}
+	return &storagepb.DeleteMessagesResponse{Deleted: int32(deleted)}, nil
+}
+
+// checkMember 群聊时校验查询者是否为群成员, 私聊的会话id本身包含查询者
+func (h *HistoryHandle) checkMember(ctx context.Context, uid, conversationId string, typ pb.SesstionType) error {
+	if typ != pb.SesstionType_GROUP {
+		return nil
+	}
+	joined, err := h.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+conversationId, uid).Result()
+	if err != nil {
+		return err
+	}
+	if !joined {
+		return ErrNotMember
+	}
+	return nil
+}
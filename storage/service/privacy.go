@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/privacy"
+)
+
+// PrivacyHandle 用户数据导出与擦除, 由gateway的管理接口调用
+
+// 导出时每个分块的大小
+const EXPORT_CHUNK_SIZE = 256 << 10
+
+type PrivacyHandle struct {
+	storagepb.UnimplementedPrivacyServiceServer
+	manager *privacy.Manager
+}
+
+func NewPrivacyHandle(manager *privacy.Manager) *PrivacyHandle {
+	return &PrivacyHandle{manager: manager}
+}
+
+func (p *PrivacyHandle) ExportUser(in *storagepb.ExportUserRequest, stream storagepb.PrivacyService_ExportUserServer) error {
+	w := &chunkWriter{stream: stream, buf: make([]byte, 0, EXPORT_CHUNK_SIZE)}
+	if _, err := p.manager.Export(stream.Context(), in, w); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+func (p *PrivacyHandle) EraseUser(ctx context.Context, in *storagepb.EraseUserRequest) (*storagepb.EraseUserResponse, error) {
+	record, err := p.manager.Erase(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.EraseUserResponse{Audit: record}, nil
+}
+
+// chunkWriter 将导出的zip按 EXPORT_CHUNK_SIZE 分块发送
+type chunkWriter struct {
+	stream storagepb.PrivacyService_ExportUserServer
+	buf    []byte
+}
+
+func (w *chunkWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		m := min(len(b), cap(w.buf)-len(w.buf))
+		w.buf = append(w.buf, b[:m]...)
+		b = b[m:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.stream.Send(&storagepb.ExportChunk{Data: w.buf}); err != nil {
+		return err
+	}
+	w.buf = make([]byte, 0, EXPORT_CHUNK_SIZE)
+	return nil
+}
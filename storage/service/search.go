@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"storage/search"
+	"storage/store"
+)
+
+// SearchHandle 全文检索
+//
+// 索引中不区分用户, 查询时逐条判断命中消息所在会话对查询者是否可见
+// 私聊会话id包含双方uid, 群聊通过群成员集合判断, 同一次查询内缓存判断结果
+
+const (
+	DEFAULT_SEARCH_LIMIT = 20
+	MAX_SEARCH_LIMIT     = 100
+)
+
+type SearchHandle struct {
+	storagepb.UnimplementedSearchServiceServer
+	store store.MessageStore
+	index *search.Index
+	redis redis.UniversalClient
+}
+
+func NewSearchHandle(store store.MessageStore, index *search.Index, redis redis.UniversalClient) *SearchHandle {
+	return &SearchHandle{store: store, index: index, redis: redis}
+}
+
+func (s *SearchHandle) Search(ctx context.Context, in *storagepb.SearchRequest) (*storagepb.SearchResponse, error) {
+	if in.Uid == "" || strings.TrimSpace(in.Query) == "" {
+		return nil, ErrInvalidArgument
+	}
+	before, err := store.ParseCursor(in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int(in.Limit)
+	if limit <= 0 {
+		limit = DEFAULT_SEARCH_LIMIT
+	}
+	if limit > MAX_SEARCH_LIMIT {
+		limit = MAX_SEARCH_LIMIT
+	}
+
+	filter := s.visibleTo(ctx, in.Uid)
+	if in.ConversationId != "" {
+		key := store.ConversationKey(in.Uid, in.ConversationId, in.SessionType)
+		visible := filter
+		filter = func(conversationId string) (bool, error) {
+			if conversationId != key {
+				return false, nil
+			}
+			return visible(conversationId)
+		}
+	}
+
+	hits, next, hasMore, err := s.index.Search(in.Query, before, filter, limit)
+	if err != nil {
+		if err == search.ErrEmptyQuery {
+			return &storagepb.SearchResponse{}, nil
+		}
+		return nil, err
+	}
+
+	resp := &storagepb.SearchResponse{HasMore: hasMore}
+	if hasMore {
+		resp.NextPageToken = next.Encode()
+	}
+	if len(hits) == 0 {
+		return resp, nil
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.Id
+	}
+	msgs, err := s.store.Get(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按命中顺序(从新到旧)返回, 跳过索引未及时清理的已撤回消息
+	byId := make(map[string]*pb.MessageData, len(msgs))
+	for _, msg := range msgs {
+		byId[msg.Id] = msg
+	}
+	for _, id := range ids {
+		if msg, ok := byId[id]; ok && msg.Status != store.STATUS_RECALLED {
+			resp.Messages = append(resp.Messages, msg)
+		}
+	}
+	return resp, nil
+}
+
+// visibleTo 判断会话对uid是否可见, 群成员关系在同一次查询内缓存
+func (s *SearchHandle) visibleTo(ctx context.Context, uid string) search.Filter {
+	groups := make(map[string]bool)
+
+	return func(conversationId string) (bool, error) {
+		switch {
+		case strings.HasPrefix(conversationId, "s:"):
+			a, b, _ := strings.Cut(strings.TrimPrefix(conversationId, "s:"), ":")
+			return a == uid || b == uid, nil
+		case strings.HasPrefix(conversationId, "g:"):
+			gid := strings.TrimPrefix(conversationId, "g:")
+			if joined, ok := groups[gid]; ok {
+				return joined, nil
+			}
+			joined, err := s.redis.SIsMember(ctx, GROUP_MEMBER_PREFIX+gid, uid).Result()
+			if err != nil {
+				return false, err
+			}
+			groups[gid] = joined
+			return joined, nil
+		}
+		return false, nil
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/inbox"
+	"storage/store"
+)
+
+// SyncHandle 离线消息同步
+//
+// 接收方离线时由上游写入其收件箱
+// 客户端重连后通过 SyncInbox 按游标拉取全部离线消息, 或通过 SyncMessages 按会话拉取
+// 客户端确认后通过 AckInbox 清理收件箱
+// 收件箱只保存消息引用, 拉取时从消息历史中读取消息内容
+// 已过期删除(阅后即焚、保留策略)或已撤回的消息不再返回, 撤回与删除事件由在线推送及时间线通知客户端
+
+var ErrInvalidArgument = errors.New("invalid argument")
+
+type SyncHandle struct {
+	storagepb.UnimplementedSyncServiceServer
+	inbox   *inbox.Inbox
+	history store.MessageStore
+}
+
+func NewSyncHandle(inbox *inbox.Inbox, history store.MessageStore) *SyncHandle {
+	return &SyncHandle{inbox: inbox, history: history}
+}
+
+// PushOffline 消息须已分配id与seq, 否则客户端无法按会话seq同步与去重
+func (s *SyncHandle) PushOffline(ctx context.Context, in *storagepb.PushOfflineRequest) (*storagepb.PushOfflineResponse, error) {
+	if in.Uid == "" || in.Message == nil || in.Message.Id == "" || in.Message.Seq <= 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	cursor, err := s.inbox.Push(ctx, in.Uid, in.Message)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.PushOfflineResponse{Cursor: cursor}, nil
+}
+
+func (s *SyncHandle) SyncMessages(ctx context.Context, in *storagepb.SyncMessagesRequest) (*storagepb.SyncMessagesResponse, error) {
+	if in.Uid == "" || in.ConversationId == "" || in.FromSeq < 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	refs, hasMore, err := s.inbox.SyncConversation(ctx, in.Uid, in.ConversationId, in.FromSeq, int(in.Limit))
+	if err != nil {
+		return nil, err
+	}
+	byId, err := s.load(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]*pb.MessageData, 0, len(refs))
+	for _, ref := range refs {
+		if msg, ok := byId[ref.Id]; ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return &storagepb.SyncMessagesResponse{Messages: msgs, HasMore: hasMore}, nil
+}
+
+func (s *SyncHandle) SyncInbox(ctx context.Context, in *storagepb.SyncInboxRequest) (*storagepb.SyncInboxResponse, error) {
+	if in.Uid == "" || in.Cursor < 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	entries, hasMore, err := s.inbox.Sync(ctx, in.Uid, in.Cursor, int(in.Limit))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]*pb.MessageData, len(entries))
+	for i, e := range entries {
+		refs[i] = e.Message
+	}
+	byId, err := s.load(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &storagepb.SyncInboxResponse{
+		Entries:    make([]*storagepb.InboxEntry, 0, len(entries)),
+		NextCursor: in.Cursor,
+		HasMore:    hasMore,
+	}
+	// 跳过的条目同样推进游标
+	for _, e := range entries {
+		resp.NextCursor = e.Cursor
+		if msg, ok := byId[e.Message.Id]; ok {
+			resp.Entries = append(resp.Entries, &storagepb.InboxEntry{Cursor: e.Cursor, Message: msg})
+		}
+	}
+	return resp, nil
+}
+
+func (s *SyncHandle) AckInbox(ctx context.Context, in *storagepb.AckInboxRequest) (*storagepb.AckInboxResponse, error) {
+	if in.Uid == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	trimmed, err := s.inbox.Ack(ctx, in.Uid, in.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.AckInboxResponse{Trimmed: trimmed}, nil
+}
+
+// load 从消息历史中读取收件箱引用的消息, 跳过已撤回的消息
+// 收件箱单页的上限大于消息历史单次查询的上限, 分批读取
+func (s *SyncHandle) load(ctx context.Context, refs []*pb.MessageData) (map[string]*pb.MessageData, error) {
+	byId := make(map[string]*pb.MessageData, len(refs))
+	for start := 0; start < len(refs); start += store.MAX_LIMIT {
+		batch := refs[start:min(start+store.MAX_LIMIT, len(refs))]
+		ids := make([]string, len(batch))
+		for i, ref := range batch {
+			ids[i] = ref.Id
+		}
+		msgs, err := s.history.Get(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if msg.Status != store.STATUS_RECALLED {
+				byId[msg.Id] = msg
+			}
+		}
+	}
+	return byId, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"github.com/redis/go-redis/v9"
+	"storage/inbox"
+	"storage/store"
+	"storage/store/storetest"
+)
+
+// 测试离线消息从消息历史读取内容, 过期删除及撤回的消息不再返回
+func TestSyncSkipsExpiredAndRecalled(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	history := storetest.OpenBolt(t)
+	s := NewSyncHandle(inbox.NewInbox(rdb, 100, time.Hour), history)
+
+	now := time.Now().UnixMilli()
+	var msgs []*pb.MessageData
+	for i, id := range []string{"m1", "m2", "m3"} {
+		msgs = append(msgs, &pb.MessageData{
+			Id: id, SenderId: "alice", ReceiverId: "bob", SesstionType: pb.SesstionType_SINGLE,
+			Payload: []byte("hello " + id), Seq: int64(i + 1), SendTime: now,
+		})
+	}
+	if _, err := history.Save(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if _, err := s.PushOffline(ctx, &storagepb.PushOfflineRequest{Uid: "bob", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := history.Delete(ctx, []string{"m2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.SetStatus(ctx, "m3", store.STATUS_RECALLED); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.SyncInbox(ctx, &storagepb.SyncInboxRequest{Uid: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 1 || string(resp.Entries[0].Message.Payload) != "hello m1" || resp.NextCursor != 3 {
+		t.Fatalf("unexpected inbox %v", resp)
+	}
+
+	conv, err := s.SyncMessages(ctx, &storagepb.SyncMessagesRequest{Uid: "bob", ConversationId: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conv.Messages) != 1 || conv.Messages[0].Id != "m1" {
+		t.Fatalf("unexpected messages %v", conv.Messages)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/store"
+	"storage/timeline"
+)
+
+// TimelineHandle 用户时间线
+//
+// 上游向signal申请用户的inbox seq后调用 AppendEvents 写入, 消息事件须在消息落库之后写入
+// 客户端通过 SyncTimeline 以一个游标拉取全部会话的消息、撤回、编辑、表情回应及已读事件
+//
+// 申请inbox seq与写入是两步, 并发的写入可能乱序提交, 游标越过空洞后会漏掉稍后才写入的事件
+// 拉取时只返回到第一个新近的空洞为止, 空洞之后的事件写入超过 TIMELINE_SETTLE 仍未补齐时视为永久空洞
+
+const TIMELINE_SETTLE = 5 * time.Second
+
+type TimelineHandle struct {
+	storagepb.UnimplementedTimelineServiceServer
+	timeline timeline.Store
+	history  store.MessageStore
+	now      func() time.Time
+}
+
+func NewTimelineHandle(timeline timeline.Store, history store.MessageStore) *TimelineHandle {
+	return &TimelineHandle{timeline: timeline, history: history, now: time.Now}
+}
+
+func (t *TimelineHandle) AppendEvents(ctx context.Context, in *storagepb.AppendEventsRequest) (*storagepb.AppendEventsResponse, error) {
+	if len(in.Events) > store.MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+	for _, e := range in.Events {
+		if e == nil || e.Uid == "" || e.InboxSeq <= 0 || e.ConversationId == "" {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	appended, err := t.timeline.Append(ctx, in.Events)
+	if err != nil {
+		return nil, err
+	}
+	return &storagepb.AppendEventsResponse{Appended: int32(appended)}, nil
+}
+
+// SyncTimeline 事件引用的消息从消息历史中读取当前状态, 已删除的消息 message 为空
+func (t *TimelineHandle) SyncTimeline(ctx context.Context, in *storagepb.SyncTimelineRequest) (*storagepb.SyncTimelineResponse, error) {
+	if in.Uid == "" || in.AfterSeq < 0 {
+		return nil, ErrInvalidArgument
+	}
+	limit := int(in.Limit)
+	if limit <= 0 {
+		limit = store.DEFAULT_LIMIT
+	}
+	limit = min(limit, store.MAX_LIMIT)
+
+	events, hasMore, err := t.timeline.Sync(ctx, in.Uid, in.AfterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	settled := t.now().Add(-TIMELINE_SETTLE).UnixMilli()
+	prev := in.AfterSeq
+	for i, e := range events {
+		if e.InboxSeq != prev+1 && e.CommittedAt > settled {
+			events, hasMore = events[:i], false
+			break
+		}
+		prev = e.InboxSeq
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		if e.MessageId != "" {
+			ids = append(ids, e.MessageId)
+		}
+	}
+	msgs, err := t.history.Get(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*pb.MessageData, len(msgs))
+	for _, msg := range msgs {
+		byId[msg.Id] = msg
+	}
+
+	resp := &storagepb.SyncTimelineResponse{Events: events, NextSeq: in.AfterSeq, HasMore: hasMore}
+	for _, e := range events {
+		e.Message = byId[e.MessageId]
+		resp.NextSeq = e.InboxSeq
+	}
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"storage/store/storetest"
+	"storage/timeline"
+)
+
+// 测试游标不越过新近的空洞, 空洞超过等待时间后视为永久空洞
+func TestSyncTimelineStopsAtRecentHole(t *testing.T) {
+	ctx := context.Background()
+	events, err := timeline.NewBoltStore(filepath.Join(t.TempDir(), "timeline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	h := NewTimelineHandle(events, storetest.OpenBolt(t))
+
+	// inbox seq 3 已分配但尚未写入
+	var appended []*storagepb.TimelineEvent
+	for _, seq := range []int64{1, 2, 4, 5} {
+		appended = append(appended, &storagepb.TimelineEvent{Uid: "bob", InboxSeq: seq, ConversationId: "g:1", Seq: seq})
+	}
+	if _, err := h.AppendEvents(ctx, &storagepb.AppendEventsRequest{Events: appended}); err != nil {
+		t.Fatal(err)
+	}
+
+	sync := func(after int64) []int64 {
+		t.Helper()
+		resp, err := h.SyncTimeline(ctx, &storagepb.SyncTimelineRequest{Uid: "bob", AfterSeq: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var seqs []int64
+		for _, e := range resp.Events {
+			seqs = append(seqs, e.InboxSeq)
+		}
+		if len(seqs) > 0 && resp.NextSeq != seqs[len(seqs)-1] || len(seqs) == 0 && resp.NextSeq != after {
+			t.Fatalf("unexpected next seq %d for %v", resp.NextSeq, seqs)
+		}
+		return seqs
+	}
+	if seqs := sync(0); len(seqs) != 2 || seqs[1] != 2 {
+		t.Fatalf("expected to stop before the hole, got %v", seqs)
+	}
+	if seqs := sync(2); len(seqs) != 0 {
+		t.Fatalf("expected nothing after the hole, got %v", seqs)
+	}
+
+	// 空洞补齐后继续
+	if _, err := h.AppendEvents(ctx, &storagepb.AppendEventsRequest{Events: []*storagepb.TimelineEvent{
+		{Uid: "bob", InboxSeq: 3, ConversationId: "g:1", Seq: 3},
+		{Uid: "bob", InboxSeq: 7, ConversationId: "g:1", Seq: 7},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if seqs := sync(2); len(seqs) != 3 || seqs[2] != 5 {
+		t.Fatalf("expected 3..5, got %v", seqs)
+	}
+
+	// 超过等待时间仍未写入的 inbox seq 6 视为永久空洞
+	h.now = func() time.Time { return time.Now().Add(TIMELINE_SETTLE + time.Second) }
+	if seqs := sync(5); len(seqs) != 1 || seqs[0] != 7 {
+		t.Fatalf("expected 7 after the settled hole, got %v", seqs)
+	}
+}
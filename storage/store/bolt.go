@@ -0,0 +1,475 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+
+	pb "github.com/atoncooper/im/proto"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+// BoltStore 基于bbolt的嵌入式消息存储, 适用于单机部署及测试
+//
+// bucket :
+//   messages                    id -> 序列化后的 MessageData
+//   conversations/{会话id}/seq   seq(8) + send_time(8) + id -> nil
+//   conversations/{会话id}/time  send_time(8) + id -> nil
+//   timeline                    send_time(8) + id -> 会话id, 跨会话按时间遍历
+//   senders/{发送者uid}          send_time(8) + id -> nil, 按发送者遍历
+// 整数按大端编码, 保证字节序与数值序一致
+
+var (
+	bucketMessages      = []byte("messages")
+	bucketConversations = []byte("conversations")
+	bucketSeq           = []byte("seq")
+	bucketTime          = []byte("time")
+	bucketTimeline      = []byte("timeline")
+	bucketSenders       = []byte("senders")
+)
+
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		path = "data/messages.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMessages, bucketConversations, bucketTimeline, bucketSenders} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Save(ctx context.Context, msgs []*pb.MessageData) (int, error) {
+	saved := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		conversations := tx.Bucket(bucketConversations)
+		timeline := tx.Bucket(bucketTimeline)
+		senders := tx.Bucket(bucketSenders)
+
+		for _, msg := range msgs {
+			if msg == nil || msg.Id == "" {
+				continue
+			}
+			id := []byte(msg.Id)
+			if messages.Get(id) != nil {
+				continue
+			}
+
+			data, err := proto.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if err := messages.Put(id, data); err != nil {
+				return err
+			}
+
+			conversationId := []byte(ConversationOf(msg))
+			conv, err := conversations.CreateBucketIfNotExists(conversationId)
+			if err != nil {
+				return err
+			}
+			seqs, err := conv.CreateBucketIfNotExists(bucketSeq)
+			if err != nil {
+				return err
+			}
+			times, err := conv.CreateBucketIfNotExists(bucketTime)
+			if err != nil {
+				return err
+			}
+			if err := seqs.Put(seqKey(msg.Seq, msg.SendTime, msg.Id), nil); err != nil {
+				return err
+			}
+			if err := times.Put(timeKey(msg.SendTime, msg.Id), nil); err != nil {
+				return err
+			}
+			if err := timeline.Put(timeKey(msg.SendTime, msg.Id), conversationId); err != nil {
+				return err
+			}
+			sender, err := senders.CreateBucketIfNotExists([]byte(msg.SenderId))
+			if err != nil {
+				return err
+			}
+			if err := sender.Put(timeKey(msg.SendTime, msg.Id), nil); err != nil {
+				return err
+			}
+			saved++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
+
+func (b *BoltStore) QueryBySeq(ctx context.Context, conversationId string, anchor int64, backward bool, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+
+	var (
+		msgs    []*pb.MessageData
+		hasMore bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		seqs := conversationBucket(tx, conversationId, bucketSeq)
+		if seqs == nil {
+			return nil
+		}
+		messages := tx.Bucket(bucketMessages)
+		c := seqs.Cursor()
+
+		var k []byte
+		next := c.Next
+		switch {
+		case !backward:
+			k, _ = c.Seek(seqKey(anchor+1, 0, ""))
+		case anchor > 0:
+			// 定位到第一个 seq >= anchor 的位置后回退一条
+			if k, _ = c.Seek(seqKey(anchor, 0, "")); k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+			next = c.Prev
+		default:
+			k, _ = c.Last()
+			next = c.Prev
+		}
+
+		for ; k != nil; k, _ = next() {
+			if len(msgs) == limit {
+				hasMore = true
+				break
+			}
+			msg, err := decodeMessage(messages, k[16:])
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if backward {
+		reverse(msgs)
+	}
+	return msgs, hasMore, nil
+}
+
+func (b *BoltStore) QueryByTime(ctx context.Context, conversationId string, start, end int64, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	end = normalizeEnd(end)
+	if start >= end {
+		return nil, false, nil
+	}
+
+	var (
+		msgs    []*pb.MessageData
+		hasMore bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		times := conversationBucket(tx, conversationId, bucketTime)
+		if times == nil {
+			return nil
+		}
+		messages := tx.Bucket(bucketMessages)
+		c := times.Cursor()
+
+		from := timeKey(start, "")
+		afterKey := timeKey(after.SendTime, after.Id)
+		if bytes.Compare(afterKey, from) >= 0 {
+			from = afterKey
+		}
+		upper := timeKey(end, "")
+
+		for k, _ := c.Seek(from); k != nil && bytes.Compare(k, upper) < 0; k, _ = c.Next() {
+			if bytes.Equal(k, afterKey) {
+				continue
+			}
+			if len(msgs) == limit {
+				hasMore = true
+				break
+			}
+			msg, err := decodeMessage(messages, k[8:])
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, nil
+}
+
+func (b *BoltStore) Get(ctx context.Context, ids []string) ([]*pb.MessageData, error) {
+	if len(ids) > MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+
+	var msgs []*pb.MessageData
+	err := b.db.View(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		seen := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			if seen[id] || messages.Get([]byte(id)) == nil {
+				continue
+			}
+			seen[id] = true
+			msg, err := decodeMessage(messages, []byte(id))
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(msgs, func(a, b int) bool {
+		if msgs[a].SendTime != msgs[b].SendTime {
+			return msgs[a].SendTime < msgs[b].SendTime
+		}
+		return msgs[a].Id < msgs[b].Id
+	})
+	return msgs, nil
+}
+
+func (b *BoltStore) SetStatus(ctx context.Context, id string, status int32) (*pb.MessageData, error) {
+	return b.update(id, func(msg *pb.MessageData) {
+		msg.Status = status
+	})
+}
+
+func (b *BoltStore) Redact(ctx context.Context, id string) (*pb.MessageData, error) {
+	return b.update(id, redact)
+}
+
+// update 读取消息, 由 fn 修改后写回
+func (b *BoltStore) update(id string, fn func(msg *pb.MessageData)) (*pb.MessageData, error) {
+	var msg *pb.MessageData
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		if messages.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		var err error
+		if msg, err = decodeMessage(messages, []byte(id)); err != nil {
+			return err
+		}
+		fn(msg)
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return messages.Put([]byte(id), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (b *BoltStore) ScanBefore(ctx context.Context, before int64, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+
+	var (
+		msgs    []*pb.MessageData
+		hasMore bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		c := tx.Bucket(bucketTimeline).Cursor()
+
+		afterKey := timeKey(after.SendTime, after.Id)
+		upper := timeKey(before, "")
+		for k, _ := c.Seek(afterKey); k != nil && bytes.Compare(k, upper) < 0; k, _ = c.Next() {
+			if bytes.Equal(k, afterKey) {
+				continue
+			}
+			if len(msgs) == limit {
+				hasMore = true
+				break
+			}
+			msg, err := decodeMessage(messages, k[8:])
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, nil
+}
+
+func (b *BoltStore) Delete(ctx context.Context, ids []string) (int, error) {
+	if len(ids) > MAX_LIMIT {
+		return 0, ErrInvalidArgument
+	}
+
+	deleted := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		conversations := tx.Bucket(bucketConversations)
+		timeline := tx.Bucket(bucketTimeline)
+		senders := tx.Bucket(bucketSenders)
+
+		for _, id := range ids {
+			if messages.Get([]byte(id)) == nil {
+				continue
+			}
+			msg, err := decodeMessage(messages, []byte(id))
+			if err != nil {
+				return err
+			}
+
+			conversationId := []byte(ConversationOf(msg))
+			if conv := conversations.Bucket(conversationId); conv != nil {
+				if seqs := conv.Bucket(bucketSeq); seqs != nil {
+					if err := seqs.Delete(seqKey(msg.Seq, msg.SendTime, msg.Id)); err != nil {
+						return err
+					}
+				}
+				if times := conv.Bucket(bucketTime); times != nil {
+					if err := times.Delete(timeKey(msg.SendTime, msg.Id)); err != nil {
+						return err
+					}
+					// 会话中已没有消息
+					if k, _ := times.Cursor().First(); k == nil {
+						if err := conversations.DeleteBucket(conversationId); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if err := timeline.Delete(timeKey(msg.SendTime, msg.Id)); err != nil {
+				return err
+			}
+			if sender := senders.Bucket([]byte(msg.SenderId)); sender != nil {
+				if err := sender.Delete(timeKey(msg.SendTime, msg.Id)); err != nil {
+					return err
+				}
+				if k, _ := sender.Cursor().First(); k == nil {
+					if err := senders.DeleteBucket([]byte(msg.SenderId)); err != nil {
+						return err
+					}
+				}
+			}
+			if err := messages.Delete([]byte(id)); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (b *BoltStore) ScanSender(ctx context.Context, senderId string, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+
+	var (
+		msgs    []*pb.MessageData
+		hasMore bool
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		sender := tx.Bucket(bucketSenders).Bucket([]byte(senderId))
+		if sender == nil {
+			return nil
+		}
+		messages := tx.Bucket(bucketMessages)
+		c := sender.Cursor()
+
+		afterKey := timeKey(after.SendTime, after.Id)
+		for k, _ := c.Seek(afterKey); k != nil; k, _ = c.Next() {
+			if bytes.Equal(k, afterKey) {
+				continue
+			}
+			if len(msgs) == limit {
+				hasMore = true
+				break
+			}
+			msg, err := decodeMessage(messages, k[8:])
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return msgs, hasMore, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func conversationBucket(tx *bolt.Tx, conversationId string, name []byte) *bolt.Bucket {
+	conv := tx.Bucket(bucketConversations).Bucket([]byte(conversationId))
+	if conv == nil {
+		return nil
+	}
+	return conv.Bucket(name)
+}
+
+func decodeMessage(messages *bolt.Bucket, id []byte) (*pb.MessageData, error) {
+	var msg pb.MessageData
+	if err := proto.Unmarshal(messages.Get(id), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func seqKey(seq, sendTime int64, id string) []byte {
+	k := make([]byte, 16, 16+len(id))
+	binary.BigEndian.PutUint64(k, uint64(seq))
+	binary.BigEndian.PutUint64(k[8:], uint64(sendTime))
+	return append(k, id...)
+}
+
+func timeKey(sendTime int64, id string) []byte {
+	k := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(k, uint64(sendTime))
+	return append(k, id...)
+}
@@ -0,0 +1,19 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"storage/store"
+	"storage/store/storetest"
+)
+
+func TestBoltStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.MessageStore {
+		s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "messages.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
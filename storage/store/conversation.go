@@ -0,0 +1,28 @@
+package store
+
+import (
+	pb "github.com/atoncooper/im/proto"
+)
+
+// 会话id
+//
+// 与center的已读游标保持一致
+// 私聊 : s:{较小uid}:{较大uid}, 双方共用同一个会话
+// 群聊 : g:{groupId}
+
+// ConversationKey 由用户视角的会话(私聊为对方uid, 群聊为群id)得到会话id
+func ConversationKey(uid, conversationId string, typ pb.SesstionType) string {
+	if typ == pb.SesstionType_GROUP {
+		return "g:" + conversationId
+	}
+	a, b := uid, conversationId
+	if a > b {
+		a, b = b, a
+	}
+	return "s:" + a + ":" + b
+}
+
+// ConversationOf 消息所属的会话id
+func ConversationOf(msg *pb.MessageData) string {
+	return ConversationKey(msg.SenderId, msg.ReceiverId, msg.SesstionType)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// Cursor 按时间翻页的位置, 即上一页最后一条消息的 (send_time, id)
+type Cursor struct {
+	SendTime int64
+	Id       string
+}
+
+func CursorOf(msg *pb.MessageData) Cursor {
+	return Cursor{SendTime: msg.SendTime, Id: msg.Id}
+}
+
+// Encode 编码为对客户端不透明的 page_token
+func (c Cursor) Encode() string {
+	if c.Id == "" {
+		return ""
+	}
+	raw := strconv.FormatInt(c.SendTime, 10) + ":" + c.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor 解析 page_token, 空字符串表示首页
+func ParseCursor(token string) (Cursor, error) {
+	if token == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidArgument
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidArgument
+	}
+	sendTime, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidArgument
+	}
+	return Cursor{SendTime: sendTime, Id: id}, nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := CursorOf(&pb.MessageData{Id: "m:1", SendTime: 1700000000000})
+
+	got, err := ParseCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	if c, err := ParseCursor(""); err != nil || c != (Cursor{}) {
+		t.Fatalf("empty token: %+v, %v", c, err)
+	}
+	for _, token := range []string{"!!", "MTIz", "YWJjOmlk"} {
+		if _, err := ParseCursor(token); err != ErrInvalidArgument {
+			t.Fatalf("token %q: expected ErrInvalidArgument, got %v", token, err)
+		}
+	}
+}
+
+func TestConversationKey(t *testing.T) {
+	if a, b := ConversationKey("u1", "u2", pb.SesstionType_SINGLE), ConversationKey("u2", "u1", pb.SesstionType_SINGLE); a != b || a != "s:u1:u2" {
+		t.Fatalf("single conversation keys differ: %s, %s", a, b)
+	}
+	if k := ConversationOf(&pb.MessageData{SenderId: "u1", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP}); k != "g:g1" {
+		t.Fatalf("unexpected group key %s", k)
+	}
+}
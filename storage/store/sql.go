@@ -0,0 +1,419 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/atoncooper/im/proto"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"google.golang.org/protobuf/proto"
+)
+
+// SQLStore 消息历史的sql存储, 支持mysql与postgres
+//
+// 每条消息存为一行, data 为序列化后的 MessageData, 其余列用于索引
+// 1. 主键为消息id, 重复写入直接忽略, 上游可以放心重试
+// 2. (conversation_id, seq) 用于按seq翻页
+// 3. (conversation_id, send_time, id) 用于按时间翻页
+// 4. (send_time, id) 用于过期清理时跨会话按时间遍历
+// 5. (sender_id, send_time, id) 用于导出或擦除某个用户发送的消息, sender_id 按字节比较, 不同大小写的uid是不同的用户
+// 6. 按会话id的crc32哈希分片, 同一会话的消息总在同一分片; 按id查询时需要查询全部分片
+
+// Dialect 不同数据库的sql差异
+type Dialect struct {
+	Driver string
+	Schema []string
+	Insert string // 忽略主键冲突的插入语句前缀
+	Rebind func(query string) string
+
+	// 其他表建表时使用, 会话id、uid等按字节比较, 区分大小写
+	Collate string // 按字节比较的排序规则
+	Options string // 建表选项
+}
+
+var MySQL = &Dialect{
+	Driver: "mysql",
+	Schema: []string{`
+CREATE TABLE IF NOT EXISTS messages (
+	id              VARCHAR(64)  COLLATE utf8mb4_bin NOT NULL,
+	conversation_id VARCHAR(160) COLLATE utf8mb4_bin NOT NULL,
+	seq             BIGINT       NOT NULL,
+	sender_id       VARCHAR(64)  COLLATE utf8mb4_bin NOT NULL,
+	receiver_id     VARCHAR(64)  NOT NULL,
+	session_type    INT          NOT NULL,
+	message_type    INT          NOT NULL,
+	send_time       BIGINT       NOT NULL,
+	data            MEDIUMBLOB   NOT NULL,
+	PRIMARY KEY (id),
+	KEY idx_conversation_seq (conversation_id, seq),
+	KEY idx_conversation_time (conversation_id, send_time, id),
+	KEY idx_send_time (send_time, id),
+	KEY idx_sender (sender_id, send_time, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`},
+	Insert:  "INSERT IGNORE INTO messages",
+	Rebind:  func(query string) string { return query },
+	Collate: "utf8mb4_bin",
+	Options: "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+}
+
+var Postgres = &Dialect{
+	Driver: "pgx",
+	Schema: []string{`
+CREATE TABLE IF NOT EXISTS messages (
+	id              VARCHAR(64)  COLLATE "C" NOT NULL PRIMARY KEY,
+	conversation_id VARCHAR(160) COLLATE "C" NOT NULL,
+	seq             BIGINT       NOT NULL,
+	sender_id       VARCHAR(64)  COLLATE "C" NOT NULL,
+	receiver_id     VARCHAR(64)  NOT NULL,
+	session_type    INT          NOT NULL,
+	message_type    INT          NOT NULL,
+	send_time       BIGINT       NOT NULL,
+	data            BYTEA        NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_seq ON messages (conversation_id, seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_time ON messages (conversation_id, send_time, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_send_time ON messages (send_time, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sender ON messages (sender_id, send_time, id)`,
+	},
+	Insert:  "INSERT INTO messages",
+	Collate: `"C"`,
+	Rebind: func(query string) string {
+		// 占位符 ? 替换为 $1, $2 ...
+		var (
+			b strings.Builder
+			n int
+		)
+		for _, c := range query {
+			if c == '?' {
+				n++
+				b.WriteString("$" + strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(c)
+		}
+		return b.String()
+	},
+}
+
+type SQLStore struct {
+	dialect *Dialect
+	shards  []*sql.DB
+}
+
+// NewSQLStore 连接全部分片并建表
+func NewSQLStore(ctx context.Context, dialect *Dialect, conf *Config) (*SQLStore, error) {
+	if len(conf.Shards) == 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	s := &SQLStore{dialect: dialect}
+	for _, dsn := range conf.Shards {
+		db, err := sql.Open(dialect.Driver, dsn)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+		db.SetMaxIdleConns(conf.MaxIdleConns)
+		db.SetConnMaxLifetime(conf.MaxLifetime)
+		s.shards = append(s.shards, db)
+
+		for _, stmt := range dialect.Schema {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// shard 会话所在的分片
+func (s *SQLStore) shard(conversationId string) int {
+	return int(crc32.ChecksumIEEE([]byte(conversationId)) % uint32(len(s.shards)))
+}
+
+func (s *SQLStore) Save(ctx context.Context, msgs []*pb.MessageData) (int, error) {
+	var (
+		holders = make([][]string, len(s.shards))
+		args    = make([][]any, len(s.shards))
+	)
+	for _, msg := range msgs {
+		if msg == nil || msg.Id == "" {
+			continue
+		}
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return 0, err
+		}
+		conversationId := ConversationOf(msg)
+		i := s.shard(conversationId)
+		holders[i] = append(holders[i], "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args[i] = append(args[i],
+			msg.Id, conversationId, msg.Seq,
+			msg.SenderId, msg.ReceiverId, int32(msg.SesstionType), int32(msg.MessageType),
+			msg.SendTime, data,
+		)
+	}
+
+	saved := 0
+	for i, db := range s.shards {
+		if len(holders[i]) == 0 {
+			continue
+		}
+		query := s.dialect.Insert +
+			" (id, conversation_id, seq, sender_id, receiver_id, session_type, message_type, send_time, data) VALUES " +
+			strings.Join(holders[i], ", ")
+		if s.dialect == Postgres {
+			query += " ON CONFLICT (id) DO NOTHING"
+		}
+
+		res, err := db.ExecContext(ctx, s.dialect.Rebind(query), args[i]...)
+		if err != nil {
+			return saved, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return saved, err
+		}
+		saved += int(n)
+	}
+	return saved, nil
+}
+
+func (s *SQLStore) QueryBySeq(ctx context.Context, conversationId string, anchor int64, backward bool, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+
+	var (
+		query string
+		args  = []any{conversationId}
+	)
+	switch {
+	case !backward:
+		query = "SELECT data FROM messages WHERE conversation_id = ? AND seq > ? ORDER BY seq ASC, send_time ASC, id ASC LIMIT ?"
+		args = append(args, anchor)
+	case anchor > 0:
+		query = "SELECT data FROM messages WHERE conversation_id = ? AND seq < ? ORDER BY seq DESC, send_time DESC, id DESC LIMIT ?"
+		args = append(args, anchor)
+	default:
+		query = "SELECT data FROM messages WHERE conversation_id = ? ORDER BY seq DESC, send_time DESC, id DESC LIMIT ?"
+	}
+	args = append(args, limit+1)
+
+	msgs, err := s.query(ctx, s.shards[s.shard(conversationId)], query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(msgs) > limit
+	if hasMore {
+		msgs = msgs[:limit]
+	}
+	if backward {
+		reverse(msgs)
+	}
+	return msgs, hasMore, nil
+}
+
+func (s *SQLStore) QueryByTime(ctx context.Context, conversationId string, start, end int64, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	end = normalizeEnd(end)
+	if start >= end {
+		return nil, false, nil
+	}
+
+	msgs, err := s.query(ctx, s.shards[s.shard(conversationId)],
+		"SELECT data FROM messages WHERE conversation_id = ? AND send_time >= ? AND send_time < ? "+
+			"AND (send_time > ? OR (send_time = ? AND id > ?)) ORDER BY send_time ASC, id ASC LIMIT ?",
+		conversationId, start, end, after.SendTime, after.SendTime, after.Id, limit+1,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(msgs) > limit
+	if hasMore {
+		msgs = msgs[:limit]
+	}
+	return msgs, hasMore, nil
+}
+
+// Get 消息id不包含会话信息, 需要查询全部分片后合并
+func (s *SQLStore) Get(ctx context.Context, ids []string) ([]*pb.MessageData, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > MAX_LIMIT {
+		return nil, ErrInvalidArgument
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := "SELECT data FROM messages WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+
+	var msgs []*pb.MessageData
+	for _, db := range s.shards {
+		found, err := s.query(ctx, db, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, found...)
+	}
+
+	sort.Slice(msgs, func(a, b int) bool {
+		if msgs[a].SendTime != msgs[b].SendTime {
+			return msgs[a].SendTime < msgs[b].SendTime
+		}
+		return msgs[a].Id < msgs[b].Id
+	})
+	return msgs, nil
+}
+
+func (s *SQLStore) SetStatus(ctx context.Context, id string, status int32) (*pb.MessageData, error) {
+	return s.update(ctx, id, func(msg *pb.MessageData) {
+		msg.Status = status
+	})
+}
+
+func (s *SQLStore) Redact(ctx context.Context, id string) (*pb.MessageData, error) {
+	return s.update(ctx, id, redact)
+}
+
+// update 读取消息, 由 fn 修改后写回
+func (s *SQLStore) update(ctx context.Context, id string, fn func(msg *pb.MessageData)) (*pb.MessageData, error) {
+	for _, db := range s.shards {
+		found, err := s.query(ctx, db, "SELECT data FROM messages WHERE id = ?", id)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		msg := found[0]
+		fn(msg)
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := db.ExecContext(ctx, s.dialect.Rebind("UPDATE messages SET data = ? WHERE id = ?"), data, id); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	return nil, ErrNotFound
+}
+
+// ScanBefore 每个分片各取 limit+1 条后合并, 取全局最早的 limit 条
+func (s *SQLStore) ScanBefore(ctx context.Context, before int64, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	return s.scan(ctx,
+		"SELECT data FROM messages WHERE send_time < ? "+
+			"AND (send_time > ? OR (send_time = ? AND id > ?)) ORDER BY send_time ASC, id ASC LIMIT ?",
+		limit, before, after.SendTime, after.SendTime, after.Id,
+	)
+}
+
+// ScanSender 发送者的消息分布在各个分片, 与 ScanBefore 相同的方式合并
+func (s *SQLStore) ScanSender(ctx context.Context, senderId string, after Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	return s.scan(ctx,
+		"SELECT data FROM messages WHERE sender_id = ? "+
+			"AND (send_time > ? OR (send_time = ? AND id > ?)) ORDER BY send_time ASC, id ASC LIMIT ?",
+		limit, senderId, after.SendTime, after.SendTime, after.Id,
+	)
+}
+
+// scan 在每个分片执行按 (send_time, id) 升序的查询, 各取 limit+1 条后合并
+// query 的最后一个参数为 LIMIT
+func (s *SQLStore) scan(ctx context.Context, query string, limit int, args ...any) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	args = append(args, limit+1)
+
+	var msgs []*pb.MessageData
+	for _, db := range s.shards {
+		found, err := s.query(ctx, db, query, args...)
+		if err != nil {
+			return nil, false, err
+		}
+		msgs = append(msgs, found...)
+	}
+
+	sort.Slice(msgs, func(a, b int) bool {
+		if msgs[a].SendTime != msgs[b].SendTime {
+			return msgs[a].SendTime < msgs[b].SendTime
+		}
+		return msgs[a].Id < msgs[b].Id
+	})
+	hasMore := len(msgs) > limit
+	if hasMore {
+		msgs = msgs[:limit]
+	}
+	return msgs, hasMore, nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if len(ids) > MAX_LIMIT {
+		return 0, ErrInvalidArgument
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := s.dialect.Rebind("DELETE FROM messages WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")")
+
+	deleted := 0
+	for _, db := range s.shards {
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return deleted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+func (s *SQLStore) Close() error {
+	var err error
+	for _, db := range s.shards {
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *SQLStore) query(ctx context.Context, db *sql.DB, query string, args ...any) ([]*pb.MessageData, error) {
+	rows, err := db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*pb.MessageData
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg pb.MessageData
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &msg)
+	}
+	return msgs, rows.Err()
+}
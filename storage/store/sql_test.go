@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"storage/store"
+	"storage/store/storetest"
+)
+
+// sql后端需要真实数据库, 通过环境变量指定, 多个分片以逗号分隔
+// STORAGE_TEST_MYSQL_DSN    : im:im@tcp(127.0.0.1:3306)/im_test
+// STORAGE_TEST_POSTGRES_DSN : postgres://im:im@127.0.0.1:5432/im_test
+
+func TestMySQLStore(t *testing.T) {
+	runSQL(t, store.MySQL, "STORAGE_TEST_MYSQL_DSN")
+}
+
+func TestPostgresStore(t *testing.T) {
+	runSQL(t, store.Postgres, "STORAGE_TEST_POSTGRES_DSN")
+}
+
+func runSQL(t *testing.T, dialect *store.Dialect, env string) {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s not set", env)
+	}
+	shards := strings.Split(dsn, ",")
+
+	storetest.Run(t, func(t *testing.T) store.MessageStore {
+		s, err := store.NewSQLStore(context.Background(), dialect, &store.Config{Shards: shards})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 清空上一个用例写入的数据
+		for _, dsn := range shards {
+			db, err := sql.Open(dialect.Driver, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = db.Exec("DELETE FROM messages")
+			db.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	})
+}
+
+func TestPostgresRebind(t *testing.T) {
+	got := store.Postgres.Rebind("SELECT data FROM messages WHERE conversation_id = ? AND seq > ? LIMIT ?")
+	want := "SELECT data FROM messages WHERE conversation_id = $1 AND seq > $2 LIMIT $3"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+)
+
+// MessageStore 消息历史存储
+//
+// 所有实现需满足相同的语义, 由 storetest 中的一致性测试保证
+// 1. Save 按消息id去重, 重复写入不报错也不计数
+// 2. 同一会话内按 (seq, send_time, id) 排序, 翻页结果统一升序
+// 3. 查询不存在的会话或id返回空结果
+type MessageStore interface {
+	// Save 批量写入消息, 返回新写入的条数
+	Save(ctx context.Context, msgs []*pb.MessageData) (int, error)
+
+	// QueryBySeq 按seq翻页, 结果按seq升序
+	// backward 为false时返回 seq > anchor 的最早 limit 条
+	// backward 为true时返回 seq < anchor 的最近 limit 条, anchor <= 0 表示从最新消息开始
+	QueryBySeq(ctx context.Context, conversationId string, anchor int64, backward bool, limit int) ([]*pb.MessageData, bool, error)
+
+	// QueryByTime 按发送时间 [start, end) 翻页, 结果按 (send_time, id) 升序
+	// after 为上一页最后一条消息, 首页传零值, end <= 0 表示不限制
+	QueryByTime(ctx context.Context, conversationId string, start, end int64, after Cursor, limit int) ([]*pb.MessageData, bool, error)
+
+	// Get 按消息id批量查询, 不存在的id直接忽略, 结果按 (send_time, id) 升序
+	Get(ctx context.Context, ids []string) ([]*pb.MessageData, error)
+
+	// SetStatus 修改消息状态, 返回修改后的消息, 消息不存在时返回 ErrNotFound
+	SetStatus(ctx context.Context, id string, status int32) (*pb.MessageData, error)
+
+	// ScanBefore 跨会话遍历 send_time < before 的消息, 结果按 (send_time, id) 升序, 用于过期清理
+	// after 为上一页最后一条消息, 首页传零值
+	ScanBefore(ctx context.Context, before int64, after Cursor, limit int) ([]*pb.MessageData, bool, error)
+
+	// Delete 按消息id批量删除, 不存在的id直接忽略, 返回删除的条数
+	Delete(ctx context.Context, ids []string) (int, error)
+
+	// ScanSender 遍历 senderId 发送的全部消息, 结果按 (send_time, id) 升序, 用于用户数据导出与擦除
+	// after 为上一页最后一条消息, 首页传零值
+	ScanSender(ctx context.Context, senderId string, after Cursor, limit int) ([]*pb.MessageData, bool, error)
+
+	// Redact 清空消息内容(payload 与 ext)并将状态置为 STATUS_ERASED, 消息在会话中的位置保持不变
+	// 返回修改后的消息, 消息不存在时返回 ErrNotFound
+	Redact(ctx context.Context, id string) (*pb.MessageData, error)
+
+	Close() error
+}
+
+const (
+	DRIVER_BOLT     = "bolt"
+	DRIVER_MYSQL    = "mysql"
+	DRIVER_POSTGRES = "postgres"
+
+	DEFAULT_LIMIT = 50
+	MAX_LIMIT     = 200
+)
+
+// 消息状态 MessageData.status
+const (
+	STATUS_NORMAL   int32 = 0
+	STATUS_RECALLED int32 = 1 // 已撤回, 不再出现在搜索结果中
+	STATUS_ERASED   int32 = 2 // 发送者的数据已被擦除, 只保留占位
+)
+
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrUnknownDriver   = errors.New("unknown message store driver")
+	ErrNotFound        = errors.New("message not found")
+)
+
+type Config struct {
+	Driver string
+
+	// bolt
+	Path string
+
+	// mysql / postgres, 按会话id哈希分片, 每个分片一个dsn
+	// 分片数量确定后不可修改, 否则已有会话会被路由到其他分片
+	Shards       []string
+	MaxOpenConns int
+	MaxIdleConns int
+	MaxLifetime  time.Duration
+}
+
+// New 按配置创建消息存储
+func New(ctx context.Context, conf *Config) (MessageStore, error) {
+	switch conf.Driver {
+	case DRIVER_BOLT, "":
+		return NewBoltStore(conf.Path)
+	case DRIVER_MYSQL:
+		return NewSQLStore(ctx, MySQL, conf)
+	case DRIVER_POSTGRES:
+		return NewSQLStore(ctx, Postgres, conf)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, conf.Driver)
+	}
+}
+
+// redact 清空消息内容, 保留会话、seq及发送时间等定位信息
+func redact(msg *pb.MessageData) {
+	msg.Payload = nil
+	msg.Ext = nil
+	msg.Status = STATUS_ERASED
+}
+
+func reverse(msgs []*pb.MessageData) {
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DEFAULT_LIMIT
+	}
+	if limit > MAX_LIMIT {
+		return MAX_LIMIT
+	}
+	return limit
+}
+
+// normalizeEnd end <= 0 表示不限制结束时间
+func normalizeEnd(end int64) int64 {
+	if end <= 0 {
+		return math.MaxInt64
+	}
+	return end
+}
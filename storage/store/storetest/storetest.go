@@ -0,0 +1,391 @@
+// Package storetest 消息存储的一致性测试
+//
+// 每个 store.MessageStore 实现都应通过 Run, 保证切换后端时查询语义不变
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/store"
+)
+
+// Factory 返回一个空的消息存储, 测试结束后由 Run 关闭
+type Factory func(t *testing.T) store.MessageStore
+
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.MessageStore)
+	}{
+		{"SaveDeduplicates", testSaveDeduplicates},
+		{"QueryBySeqForward", testQueryBySeqForward},
+		{"QueryBySeqBackward", testQueryBySeqBackward},
+		{"QueryBySeqIsolatesConversations", testQueryBySeqIsolates},
+		{"QueryByTime", testQueryByTime},
+		{"Get", testGet},
+		{"SetStatus", testSetStatus},
+		{"ScanBefore", testScanBefore},
+		{"Delete", testDelete},
+		{"ScanSender", testScanSender},
+		{"Redact", testRedact},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := factory(t)
+			defer s.Close()
+			tt.fn(t, s)
+		})
+	}
+}
+
+// OpenBolt 在测试的临时目录中打开bolt消息存储, 测试结束时关闭
+func OpenBolt(t *testing.T) *store.BoltStore {
+	t.Helper()
+	s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+const base = int64(1700000000000)
+
+// conversation 生成 u1 与 u2 私聊中 seq 为 1..n 的消息, 双方交替发送
+func conversation(prefix string, n int) []*pb.MessageData {
+	msgs := make([]*pb.MessageData, n)
+	for i := range msgs {
+		sender, receiver := "u1", "u2"
+		if i%2 == 1 {
+			sender, receiver = receiver, sender
+		}
+		msgs[i] = &pb.MessageData{
+			Id:           fmt.Sprintf("%s%03d", prefix, i+1),
+			SenderId:     sender,
+			ReceiverId:   receiver,
+			SesstionType: pb.SesstionType_SINGLE,
+			MessageType:  pb.MessageType_TEXT,
+			Payload:      []byte(fmt.Sprintf("hello %d", i+1)),
+			Seq:          int64(i + 1),
+			SendTime:     base + int64(i)*1000,
+		}
+	}
+	return msgs
+}
+
+func save(t *testing.T, s store.MessageStore, msgs []*pb.MessageData) {
+	t.Helper()
+	if _, err := s.Save(context.Background(), msgs); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+}
+
+func assertSeqs(t *testing.T, msgs []*pb.MessageData, want ...int64) {
+	t.Helper()
+	if len(msgs) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(msgs))
+	}
+	for i, msg := range msgs {
+		if msg.Seq != want[i] {
+			t.Fatalf("message %d: expected seq %d, got %d", i, want[i], msg.Seq)
+		}
+	}
+}
+
+func testSaveDeduplicates(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	msgs := conversation("m", 3)
+
+	n, err := s.Save(ctx, msgs)
+	if err != nil || n != 3 {
+		t.Fatalf("first save: %d, %v", n, err)
+	}
+	n, err = s.Save(ctx, append(msgs, conversation("n", 1)...))
+	if err != nil || n != 1 {
+		t.Fatalf("expected only the new message to be saved, got %d, %v", n, err)
+	}
+
+	got, err := s.Get(ctx, []string{"m001"})
+	if err != nil || len(got) != 1 || string(got[0].Payload) != "hello 1" {
+		t.Fatalf("unexpected message after resave: %v, %v", got, err)
+	}
+}
+
+func testQueryBySeqForward(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 5))
+	conv := store.ConversationKey("u2", "u1", pb.SesstionType_SINGLE)
+
+	msgs, hasMore, err := s.QueryBySeq(ctx, conv, 0, false, 2)
+	if err != nil || !hasMore {
+		t.Fatalf("first page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, msgs, 1, 2)
+
+	msgs, hasMore, err = s.QueryBySeq(ctx, conv, 2, false, 3)
+	if err != nil || hasMore {
+		t.Fatalf("last page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, msgs, 3, 4, 5)
+
+	msgs, hasMore, err = s.QueryBySeq(ctx, conv, 5, false, 3)
+	if err != nil || hasMore || len(msgs) != 0 {
+		t.Fatalf("expected empty page past the end, got %d, %v", len(msgs), err)
+	}
+}
+
+func testQueryBySeqBackward(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 5))
+	conv := store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE)
+
+	// 从最新消息开始, 结果仍为升序
+	msgs, hasMore, err := s.QueryBySeq(ctx, conv, 0, true, 2)
+	if err != nil || !hasMore {
+		t.Fatalf("latest page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, msgs, 4, 5)
+
+	msgs, hasMore, err = s.QueryBySeq(ctx, conv, 4, true, 2)
+	if err != nil || !hasMore {
+		t.Fatalf("middle page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, msgs, 2, 3)
+
+	msgs, hasMore, err = s.QueryBySeq(ctx, conv, 2, true, 2)
+	if err != nil || hasMore {
+		t.Fatalf("oldest page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, msgs, 1)
+
+	// 锚点超过最大seq时返回最新的消息
+	msgs, _, err = s.QueryBySeq(ctx, conv, 100, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 5)
+}
+
+func testQueryBySeqIsolates(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 3))
+	save(t, s, []*pb.MessageData{
+		{Id: "g001", SenderId: "u1", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 1, SendTime: base},
+		{Id: "g002", SenderId: "u3", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 2, SendTime: base + 1},
+		{Id: "x001", SenderId: "u1", ReceiverId: "u3", SesstionType: pb.SesstionType_SINGLE, Seq: 1, SendTime: base},
+	})
+
+	msgs, _, err := s.QueryBySeq(ctx, store.ConversationKey("u3", "g1", pb.SesstionType_GROUP), 0, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 1, 2)
+	if msgs[0].Id != "g001" || msgs[1].Id != "g002" {
+		t.Fatalf("unexpected group messages %s, %s", msgs[0].Id, msgs[1].Id)
+	}
+
+	msgs, _, err = s.QueryBySeq(ctx, store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE), 0, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 1, 2, 3)
+
+	msgs, hasMore, err := s.QueryBySeq(ctx, "s:nobody:none", 0, true, 10)
+	if err != nil || hasMore || len(msgs) != 0 {
+		t.Fatalf("expected empty result for unknown conversation, got %d, %v", len(msgs), err)
+	}
+}
+
+func testQueryByTime(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	msgs := conversation("m", 5)
+	// 同一毫秒内的两条消息按id排序
+	msgs[2].SendTime = msgs[1].SendTime
+	save(t, s, msgs)
+	conv := store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE)
+
+	page, hasMore, err := s.QueryByTime(ctx, conv, base+1000, base+4000, store.Cursor{}, 2)
+	if err != nil || !hasMore {
+		t.Fatalf("first page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, page, 2, 3)
+
+	page, hasMore, err = s.QueryByTime(ctx, conv, base+1000, base+4000, store.CursorOf(page[1]), 2)
+	if err != nil || hasMore {
+		t.Fatalf("second page: hasMore=%v, %v", hasMore, err)
+	}
+	assertSeqs(t, page, 4)
+
+	// 结束时间不限制
+	page, _, err = s.QueryByTime(ctx, conv, base+3000, 0, store.Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, page, 4, 5)
+
+	page, _, err = s.QueryByTime(ctx, conv, base+4000, base+4000, store.Cursor{}, 10)
+	if err != nil || len(page) != 0 {
+		t.Fatalf("expected empty range, got %d, %v", len(page), err)
+	}
+}
+
+func testGet(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 3))
+
+	msgs, err := s.Get(ctx, []string{"m003", "missing", "m001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 1, 3)
+
+	msgs, err = s.Get(ctx, nil)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("expected empty result, got %d, %v", len(msgs), err)
+	}
+}
+
+func testSetStatus(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 2))
+
+	msg, err := s.SetStatus(ctx, "m002", store.STATUS_RECALLED)
+	if err != nil || msg.Status != store.STATUS_RECALLED || msg.Seq != 2 {
+		t.Fatalf("unexpected result %v, %v", msg, err)
+	}
+
+	msgs, _, err := s.QueryBySeq(ctx, store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE), 0, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 1, 2)
+	if msgs[0].Status != store.STATUS_NORMAL || msgs[1].Status != store.STATUS_RECALLED {
+		t.Fatalf("unexpected status %d, %d", msgs[0].Status, msgs[1].Status)
+	}
+
+	if _, err := s.SetStatus(ctx, "missing", store.STATUS_RECALLED); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testScanBefore(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 3))
+	save(t, s, []*pb.MessageData{
+		{Id: "g001", SenderId: "u1", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 1, SendTime: base + 500},
+		{Id: "g002", SenderId: "u3", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 2, SendTime: base + 1000},
+	})
+
+	// 跨会话按 (send_time, id) 升序
+	msgs, hasMore, err := s.ScanBefore(ctx, base+2000, store.Cursor{}, 3)
+	if err != nil || !hasMore {
+		t.Fatalf("first page: hasMore=%v, %v", hasMore, err)
+	}
+	if msgs[0].Id != "m001" || msgs[1].Id != "g001" || msgs[2].Id != "g002" {
+		t.Fatalf("unexpected order %s, %s, %s", msgs[0].Id, msgs[1].Id, msgs[2].Id)
+	}
+
+	msgs, hasMore, err = s.ScanBefore(ctx, base+2000, store.CursorOf(msgs[2]), 3)
+	if err != nil || hasMore || len(msgs) != 1 || msgs[0].Id != "m002" {
+		t.Fatalf("unexpected last page %v, %v, %v", msgs, hasMore, err)
+	}
+}
+
+func testDelete(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 3))
+	conv := store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE)
+
+	n, err := s.Delete(ctx, []string{"m001", "m003", "missing"})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 deleted, got %d, %v", n, err)
+	}
+
+	msgs, _, err := s.QueryBySeq(ctx, conv, 0, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 2)
+	msgs, _, err = s.QueryByTime(ctx, conv, 0, 0, store.Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 2)
+	msgs, _, err = s.ScanBefore(ctx, base+10000, store.Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 2)
+
+	// 删除后可以重新写入
+	save(t, s, conversation("m", 1))
+	if msgs, err = s.Get(ctx, []string{"m001"}); err != nil || len(msgs) != 1 {
+		t.Fatalf("expected message to be saved again, got %d, %v", len(msgs), err)
+	}
+}
+
+func testScanSender(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	save(t, s, conversation("m", 5))
+	save(t, s, []*pb.MessageData{
+		{Id: "g001", SenderId: "u1", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 1, SendTime: base + 500},
+		{Id: "g002", SenderId: "u3", ReceiverId: "g1", SesstionType: pb.SesstionType_GROUP, Seq: 2, SendTime: base + 1000},
+	})
+
+	// u1 发送了 m001, m003, m005 及群消息 g001, 跨会话按 (send_time, id) 升序
+	msgs, hasMore, err := s.ScanSender(ctx, "u1", store.Cursor{}, 2)
+	if err != nil || !hasMore || len(msgs) != 2 {
+		t.Fatalf("first page: %d, hasMore=%v, %v", len(msgs), hasMore, err)
+	}
+	if msgs[0].Id != "m001" || msgs[1].Id != "g001" {
+		t.Fatalf("unexpected order %s, %s", msgs[0].Id, msgs[1].Id)
+	}
+
+	msgs, hasMore, err = s.ScanSender(ctx, "u1", store.CursorOf(msgs[1]), 10)
+	if err != nil || hasMore || len(msgs) != 2 || msgs[0].Id != "m003" || msgs[1].Id != "m005" {
+		t.Fatalf("unexpected last page %v, %v, %v", msgs, hasMore, err)
+	}
+
+	// 删除后不再出现
+	if _, err := s.Delete(ctx, []string{"m003"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _, err = s.ScanSender(ctx, "u1", store.Cursor{}, 10)
+	if err != nil || len(msgs) != 3 {
+		t.Fatalf("expected 3 messages after delete, got %d, %v", len(msgs), err)
+	}
+
+	if msgs, _, err = s.ScanSender(ctx, "nobody", store.Cursor{}, 10); err != nil || len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %d, %v", len(msgs), err)
+	}
+}
+
+func testRedact(t *testing.T, s store.MessageStore) {
+	ctx := context.Background()
+	msgs := conversation("m", 2)
+	msgs[0].Ext = map[string]string{"k": "v"}
+	save(t, s, msgs)
+
+	msg, err := s.Redact(ctx, "m001")
+	if err != nil || msg.Status != store.STATUS_ERASED || len(msg.Payload) != 0 || len(msg.Ext) != 0 {
+		t.Fatalf("unexpected result %v, %v", msg, err)
+	}
+
+	// 会话中仍保留占位
+	msgs, _, err = s.QueryBySeq(ctx, store.ConversationKey("u1", "u2", pb.SesstionType_SINGLE), 0, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSeqs(t, msgs, 1, 2)
+	if msgs[0].Status != store.STATUS_ERASED || len(msgs[0].Payload) != 0 || string(msgs[1].Payload) != "hello 2" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+
+	if _, err := s.Redact(ctx, "missing"); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
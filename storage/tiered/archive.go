@@ -0,0 +1,229 @@
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"storage/store"
+)
+
+// 归档与恢复
+//
+// 1. 跨会话遍历主存储中早于阈值的消息, 找出需要归档的会话
+// 2. 每个会话从最早的消息开始按seq顺序切分, 遇到不早于阈值的消息即停止, 之后的消息仍为热数据
+// 3. 每个分段先写入对象存储, 再写入索引, 最后从主存储删除
+//    中途失败时消息会同时存在于两层, 查询按id去重, 下次归档时发现已归档的id直接从主存储删除
+// 4. 恢复时分段整体写回主存储并删除归档文件, 恢复的范围在 RestoreTTL 内不再归档
+
+type ArchiveResult struct {
+	Conversations int
+	Segments      int
+	Messages      int
+	Bytes         int64
+}
+
+// Archive 归档 send_time < before 的消息
+func (s *Store) Archive(ctx context.Context, before time.Time) (*ArchiveResult, error) {
+	result := &ArchiveResult{}
+	if _, err := s.index.Unpin(s.now().UnixMilli()); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var after store.Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		msgs, hasMore, err := s.MessageStore.ScanBefore(ctx, before.UnixMilli(), after, store.MAX_LIMIT)
+		if err != nil {
+			return result, err
+		}
+		for _, msg := range msgs {
+			conv := store.ConversationOf(msg)
+			if seen[conv] {
+				continue
+			}
+			seen[conv] = true
+			if err := s.archiveConversation(ctx, conv, before.UnixMilli(), result); err != nil {
+				return result, err
+			}
+			result.Conversations++
+		}
+		if !hasMore || len(msgs) == 0 {
+			return result, nil
+		}
+		after = store.CursorOf(msgs[len(msgs)-1])
+	}
+}
+
+func (s *Store) archiveConversation(ctx context.Context, conversationId string, before int64, result *ArchiveResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pinned, err := s.index.Pinned(conversationId, s.now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	var pending []*pb.MessageData
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := s.flush(ctx, conversationId, pending, result)
+		pending = nil
+		return err
+	}
+
+	anchor := int64(-1)
+	for {
+		msgs, hasMore, err := s.MessageStore.QueryBySeq(ctx, conversationId, anchor, false, store.MAX_LIMIT)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if msg.SendTime >= before {
+				return flush()
+			}
+			if isPinned(pinned, msg.Seq) {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			pending = append(pending, msg)
+			if len(pending) >= s.conf.SegmentSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if !hasMore || len(msgs) == 0 {
+			return flush()
+		}
+		anchor = msgs[len(msgs)-1].Seq
+	}
+}
+
+// flush 写入一个分段后从主存储删除, 已归档过的消息只从主存储删除
+func (s *Store) flush(ctx context.Context, conversationId string, msgs []*pb.MessageData, result *ArchiveResult) error {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.Id
+	}
+	archived, err := s.index.Locate(ids)
+	if err != nil {
+		return err
+	}
+	fresh := make([]*pb.MessageData, 0, len(msgs))
+	for _, msg := range msgs {
+		if _, ok := archived[msg.Id]; !ok {
+			fresh = append(fresh, msg)
+		}
+	}
+
+	if len(fresh) > 0 {
+		seg := newSegment(conversationId, fresh, s.now().UnixMilli())
+		data, err := encodeSegment(fresh)
+		if err != nil {
+			return err
+		}
+		if seg.Size, err = s.objects.Put(ctx, seg.Object, bytes.NewReader(data)); err != nil {
+			return err
+		}
+		if err := s.index.Add(seg, fresh); err != nil {
+			return err
+		}
+		result.Segments++
+		result.Messages += len(fresh)
+		result.Bytes += seg.Size
+	}
+
+	for len(ids) > 0 {
+		n := min(len(ids), store.MAX_LIMIT)
+		if _, err := s.MessageStore.Delete(ctx, ids[:n]); err != nil {
+			return err
+		}
+		ids = ids[n:]
+	}
+	return nil
+}
+
+// Restore 将会话中与 [fromSeq, toSeq] 有交集的分段恢复到主存储, 返回恢复的分段数与消息数
+func (s *Store) Restore(ctx context.Context, conversationId string, fromSeq, toSeq int64) (int, int, error) {
+	if conversationId == "" || fromSeq > toSeq {
+		return 0, 0, store.ErrInvalidArgument
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segs, err := s.index.Segments(conversationId)
+	if err != nil {
+		return 0, 0, err
+	}
+	until := s.now().Add(s.conf.RestoreTTL).UnixMilli()
+	segments, messages := 0, 0
+	for _, seg := range segs {
+		if seg.ToSeq < fromSeq || seg.FromSeq > toSeq {
+			continue
+		}
+		msgs, err := readSegment(ctx, s.objects, seg.Object)
+		if err != nil {
+			return segments, messages, err
+		}
+		if err := s.index.Pin(conversationId, seg.FromSeq, seg.ToSeq, until); err != nil {
+			return segments, messages, err
+		}
+		for batch := msgs; len(batch) > 0; {
+			n := min(len(batch), store.MAX_LIMIT)
+			if _, err := s.MessageStore.Save(ctx, batch[:n]); err != nil {
+				return segments, messages, err
+			}
+			batch = batch[n:]
+		}
+		s.evict(seg.Object)
+		if err := s.index.Remove(seg, msgs); err != nil {
+			return segments, messages, err
+		}
+		if err := s.objects.Delete(ctx, seg.Object); err != nil {
+			return segments, messages, err
+		}
+		segments++
+		messages += len(msgs)
+	}
+	return segments, messages, nil
+}
+
+// Schedule 每隔 interval 归档早于 age 的消息
+func (s *Store) Schedule(ctx context.Context, interval, age time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r, err := s.Archive(ctx, now.Add(-age))
+			if err != nil {
+				log.Printf("[ERROR] archive: %v", err)
+			}
+			if r != nil && r.Segments > 0 {
+				log.Printf("[INFO] archive finished: conversations=%d segments=%d messages=%d bytes=%d",
+					r.Conversations, r.Segments, r.Messages, r.Bytes)
+			}
+		}
+	}
+}
+
+func isPinned(ranges [][2]int64, seq int64) bool {
+	for _, r := range ranges {
+		if seq >= r[0] && seq <= r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,373 @@
+package tiered
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+	"storage/store"
+)
+
+// Index 归档分段的索引, 基于bbolt
+//
+// bucket :
+//   segments                 对象key -> 序列化后的 Segment
+//   conversations/{会话id}    from_seq(8) + 对象key -> nil, 按seq范围查找分段
+//   timeline                 from_time(8) + 对象key -> nil, 跨会话按时间查找分段
+//   ids                      消息id -> 对象key, 按id查询及修改已归档的消息
+//   senders/{发送者uid}       send_time(8) + id -> nil, 按发送者遍历已归档的消息
+//   pins/{会话id}             from_seq(8) + to_seq(8) -> 截止时间(8), 恢复后暂不归档的范围
+// 整数按大端编码, seq 与时间均为非负数
+
+var (
+	bucketSegments      = []byte("segments")
+	bucketConversations = []byte("conversations")
+	bucketTimeline      = []byte("timeline")
+	bucketIds           = []byte("ids")
+	bucketSenders       = []byte("senders")
+	bucketPins          = []byte("pins")
+)
+
+var (
+	ErrInvalidKey      = errors.New("invalid object key")
+	ErrSegmentNotFound = errors.New("segment not found")
+)
+
+type Index struct {
+	db *bolt.DB
+}
+
+func NewIndex(path string) (*Index, error) {
+	if path == "" {
+		path = "data/segments.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketSegments, bucketConversations, bucketTimeline, bucketIds, bucketSenders, bucketPins} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Add 写入新分段及其中的消息
+func (i *Index) Add(seg *storagepb.Segment, msgs []*pb.MessageData) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		if err := putSegment(tx, seg); err != nil {
+			return err
+		}
+		ids := tx.Bucket(bucketIds)
+		senders := tx.Bucket(bucketSenders)
+		for _, msg := range msgs {
+			if err := ids.Put([]byte(msg.Id), []byte(seg.Object)); err != nil {
+				return err
+			}
+			sender, err := senders.CreateBucketIfNotExists([]byte(msg.SenderId))
+			if err != nil {
+				return err
+			}
+			if err := sender.Put(timeKey(msg.SendTime, msg.Id), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Replace 分段重写后更新索引, removed 为从分段中移除的消息
+// seg.Count 为0时删除分段
+func (i *Index) Replace(old, seg *storagepb.Segment, removed []*pb.MessageData) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteSegment(tx, old); err != nil {
+			return err
+		}
+		if err := removeMessages(tx, removed); err != nil {
+			return err
+		}
+		if seg.Count == 0 {
+			return nil
+		}
+		return putSegment(tx, seg)
+	})
+}
+
+// Remove 删除分段及其中全部消息的索引
+func (i *Index) Remove(seg *storagepb.Segment, msgs []*pb.MessageData) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteSegment(tx, seg); err != nil {
+			return err
+		}
+		return removeMessages(tx, msgs)
+	})
+}
+
+// Segment 按对象key查询分段
+func (i *Index) Segment(object string) (*storagepb.Segment, error) {
+	var seg *storagepb.Segment
+	err := i.db.View(func(tx *bolt.Tx) error {
+		var err error
+		seg, err = getSegment(tx, object)
+		return err
+	})
+	return seg, err
+}
+
+// Segments 会话的全部分段, 按 from_seq 升序
+func (i *Index) Segments(conversationId string) ([]*storagepb.Segment, error) {
+	var segs []*storagepb.Segment
+	err := i.db.View(func(tx *bolt.Tx) error {
+		conv := tx.Bucket(bucketConversations).Bucket([]byte(conversationId))
+		if conv == nil {
+			return nil
+		}
+		return conv.ForEach(func(k, _ []byte) error {
+			seg, err := getSegment(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			segs = append(segs, seg)
+			return nil
+		})
+	})
+	return segs, err
+}
+
+// Before 跨会话 from_time < before 的分段, 按 from_time 升序
+func (i *Index) Before(before int64) ([]*storagepb.Segment, error) {
+	var segs []*storagepb.Segment
+	err := i.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTimeline).Cursor()
+		upper := timeKey(before, "")
+		for k, _ := c.First(); k != nil && bytes.Compare(k, upper) < 0; k, _ = c.Next() {
+			seg, err := getSegment(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			segs = append(segs, seg)
+		}
+		return nil
+	})
+	return segs, err
+}
+
+// Locate 已归档消息所在的对象key, 未归档的id不在结果中
+func (i *Index) Locate(ids []string) (map[string]string, error) {
+	found := make(map[string]string)
+	err := i.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketIds)
+		for _, id := range ids {
+			if v := bucket.Get([]byte(id)); v != nil {
+				found[id] = string(v)
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Sender 按 (send_time, id) 升序遍历发送者已归档的消息id, 最多返回 limit 个
+func (i *Index) Sender(senderId string, after store.Cursor, limit int) ([]string, bool, error) {
+	var (
+		ids     []string
+		hasMore bool
+	)
+	err := i.db.View(func(tx *bolt.Tx) error {
+		sender := tx.Bucket(bucketSenders).Bucket([]byte(senderId))
+		if sender == nil {
+			return nil
+		}
+		c := sender.Cursor()
+		afterKey := timeKey(after.SendTime, after.Id)
+		for k, _ := c.Seek(afterKey); k != nil; k, _ = c.Next() {
+			if bytes.Equal(k, afterKey) {
+				continue
+			}
+			if len(ids) == limit {
+				hasMore = true
+				break
+			}
+			ids = append(ids, string(k[8:]))
+		}
+		return nil
+	})
+	return ids, hasMore, err
+}
+
+// Pin 恢复后的范围在 until(毫秒) 之前不再归档
+func (i *Index) Pin(conversationId string, fromSeq, toSeq, until int64) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		pins, err := tx.Bucket(bucketPins).CreateBucketIfNotExists([]byte(conversationId))
+		if err != nil {
+			return err
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(until))
+		return pins.Put(rangeKey(fromSeq, toSeq), v)
+	})
+}
+
+// Pinned 会话中 now(毫秒) 时仍不允许归档的seq范围
+func (i *Index) Pinned(conversationId string, now int64) ([][2]int64, error) {
+	var ranges [][2]int64
+	err := i.db.View(func(tx *bolt.Tx) error {
+		pins := tx.Bucket(bucketPins).Bucket([]byte(conversationId))
+		if pins == nil {
+			return nil
+		}
+		return pins.ForEach(func(k, v []byte) error {
+			if int64(binary.BigEndian.Uint64(v)) > now {
+				ranges = append(ranges, [2]int64{int64(binary.BigEndian.Uint64(k)), int64(binary.BigEndian.Uint64(k[8:]))})
+			}
+			return nil
+		})
+	})
+	return ranges, err
+}
+
+// Unpin 清理 now(毫秒) 之前到期的范围, 返回清理的数量
+func (i *Index) Unpin(now int64) (int, error) {
+	removed := 0
+	err := i.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucketPins)
+		var convs [][]byte
+		err := root.ForEach(func(name, _ []byte) error {
+			convs = append(convs, append([]byte(nil), name...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range convs {
+			pins := root.Bucket(name)
+			var expired [][]byte
+			pins.ForEach(func(k, v []byte) error {
+				if int64(binary.BigEndian.Uint64(v)) <= now {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range expired {
+				if err := pins.Delete(k); err != nil {
+					return err
+				}
+				removed++
+			}
+			if k, _ := pins.Cursor().First(); k == nil {
+				if err := root.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+func putSegment(tx *bolt.Tx, seg *storagepb.Segment) error {
+	data, err := proto.Marshal(seg)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketSegments).Put([]byte(seg.Object), data); err != nil {
+		return err
+	}
+	conv, err := tx.Bucket(bucketConversations).CreateBucketIfNotExists([]byte(seg.ConversationId))
+	if err != nil {
+		return err
+	}
+	if err := conv.Put(timeKey(seg.FromSeq, seg.Object), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketTimeline).Put(timeKey(seg.FromTime, seg.Object), nil)
+}
+
+func deleteSegment(tx *bolt.Tx, seg *storagepb.Segment) error {
+	if err := tx.Bucket(bucketSegments).Delete([]byte(seg.Object)); err != nil {
+		return err
+	}
+	conversations := tx.Bucket(bucketConversations)
+	if conv := conversations.Bucket([]byte(seg.ConversationId)); conv != nil {
+		if err := conv.Delete(timeKey(seg.FromSeq, seg.Object)); err != nil {
+			return err
+		}
+		if k, _ := conv.Cursor().First(); k == nil {
+			if err := conversations.DeleteBucket([]byte(seg.ConversationId)); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket(bucketTimeline).Delete(timeKey(seg.FromTime, seg.Object))
+}
+
+func removeMessages(tx *bolt.Tx, msgs []*pb.MessageData) error {
+	ids := tx.Bucket(bucketIds)
+	senders := tx.Bucket(bucketSenders)
+	for _, msg := range msgs {
+		if err := ids.Delete([]byte(msg.Id)); err != nil {
+			return err
+		}
+		sender := senders.Bucket([]byte(msg.SenderId))
+		if sender == nil {
+			continue
+		}
+		if err := sender.Delete(timeKey(msg.SendTime, msg.Id)); err != nil {
+			return err
+		}
+		if k, _ := sender.Cursor().First(); k == nil {
+			if err := senders.DeleteBucket([]byte(msg.SenderId)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getSegment(tx *bolt.Tx, object string) (*storagepb.Segment, error) {
+	data := tx.Bucket(bucketSegments).Get([]byte(object))
+	if data == nil {
+		return nil, ErrSegmentNotFound
+	}
+	var seg storagepb.Segment
+	if err := proto.Unmarshal(data, &seg); err != nil {
+		return nil, err
+	}
+	return &seg, nil
+}
+
+// timeKey 8字节大端整数 + 字符串, 同时用于 (send_time, id) 与 (from_seq, 对象key)
+func timeKey(n int64, s string) []byte {
+	k := make([]byte, 8, 8+len(s))
+	binary.BigEndian.PutUint64(k, uint64(n))
+	return append(k, s...)
+}
+
+func rangeKey(from, to int64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k, uint64(from))
+	binary.BigEndian.PutUint64(k[8:], uint64(to))
+	return k
+}
@@ -0,0 +1,96 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore 归档文件所在的对象存储
+// key 由 / 分隔, Put 需保证原子性, 读到的对象要么是旧内容要么是完整的新内容
+type ObjectStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// FileObjectStore 以本地目录模拟对象存储, {dir}/{key}
+type FileObjectStore struct {
+	dir string
+}
+
+func NewFileObjectStore(dir string) (*FileObjectStore, error) {
+	if dir == "" {
+		dir = "data/cold"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileObjectStore{dir: dir}, nil
+}
+
+// Put 先写入临时文件, 落盘后重命名
+func (f *FileObjectStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
+
+func (f *FileObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+func (f *FileObjectStore) Delete(ctx context.Context, key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path key 不允许跳出目录
+func (f *FileObjectStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(f.dir, clean), nil
+}
@@ -0,0 +1,125 @@
+package tiered
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// 归档文件
+//
+// 每个分段一个对象 : {url转义后的会话id}/{from_seq}-{to_seq}-{归档时间毫秒}.jsonl.gz
+// 内容为gzip压缩的jsonl, 每行一条消息, 按 (seq, send_time, id) 升序
+// 与 retention.FileArchiver 的格式一致, 可以直接用 zcat 查看
+
+// MAX_LINE 单条消息json的最大长度
+const MAX_LINE = 16 << 20
+
+func objectKey(seg *storagepb.Segment) string {
+	return fmt.Sprintf("%s/%d-%d-%d.jsonl.gz", url.PathEscape(seg.ConversationId), seg.FromSeq, seg.ToSeq, seg.ArchivedAt)
+}
+
+// newSegment 由会话中的一段消息生成分段信息, msgs 非空
+func newSegment(conversationId string, msgs []*pb.MessageData, archivedAt int64) *storagepb.Segment {
+	seg := &storagepb.Segment{ConversationId: conversationId, ArchivedAt: archivedAt}
+	measure(seg, msgs)
+	seg.Object = objectKey(seg)
+	return seg
+}
+
+// measure 重新计算分段的范围与数量, 对象key保持不变
+func measure(seg *storagepb.Segment, msgs []*pb.MessageData) {
+	seg.Count = int64(len(msgs))
+	if len(msgs) == 0 {
+		return
+	}
+	seg.FromSeq, seg.ToSeq = msgs[0].Seq, msgs[0].Seq
+	seg.FromTime, seg.ToTime = msgs[0].SendTime, msgs[0].SendTime
+	for _, msg := range msgs[1:] {
+		seg.FromSeq = min(seg.FromSeq, msg.Seq)
+		seg.ToSeq = max(seg.ToSeq, msg.Seq)
+		seg.FromTime = min(seg.FromTime, msg.SendTime)
+		seg.ToTime = max(seg.ToTime, msg.SendTime)
+	}
+}
+
+func encodeSegment(msgs []*pb.MessageData) ([]byte, error) {
+	sortBySeq(msgs)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, msg := range msgs {
+		line, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(append(line, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readSegment 从对象存储读取分段的全部消息
+func readSegment(ctx context.Context, objects ObjectStore, key string) ([]*pb.MessageData, error) {
+	rc, err := objects.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var msgs []*pb.MessageData
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64<<10), MAX_LINE)
+	for scanner.Scan() {
+		var msg pb.MessageData
+		if err := protojson.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func sortBySeq(msgs []*pb.MessageData) {
+	sort.Slice(msgs, func(a, b int) bool { return lessSeq(msgs[a], msgs[b]) })
+}
+
+func sortByTime(msgs []*pb.MessageData) {
+	sort.Slice(msgs, func(a, b int) bool { return lessTime(msgs[a], msgs[b]) })
+}
+
+// lessSeq 会话内的排序 (seq, send_time, id)
+func lessSeq(a, b *pb.MessageData) bool {
+	if a.Seq != b.Seq {
+		return a.Seq < b.Seq
+	}
+	return lessTime(a, b)
+}
+
+// lessTime 按时间的排序 (send_time, id)
+func lessTime(a, b *pb.MessageData) bool {
+	if a.SendTime != b.SendTime {
+		return a.SendTime < b.SendTime
+	}
+	return a.Id < b.Id
+}
@@ -0,0 +1,531 @@
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+	"google.golang.org/protobuf/proto"
+	"storage/store"
+)
+
+// 冷热分层存储
+//
+// 热数据在主存储(store.MessageStore)中, 冷数据按会话分段压缩后保存在对象存储, 两者不重叠
+// Store 包装主存储, 查询时合并两层的结果, 调用方无需感知消息在哪一层
+// 1. 按seq或时间翻页时按分段的范围只读取有交集的分段, 分段内容在内存中缓存
+// 2. 修改或删除已归档的消息时重写所在分段, 不恢复到主存储
+// 3. 已归档的id再次写入时忽略, 保持按id去重的语义
+//
+// 启用静态加密时 Store 位于加密层之下, 归档文件中的payload仍为密文
+
+const (
+	DEFAULT_SEGMENT_SIZE = 1000
+	DEFAULT_RESTORE_TTL  = 7 * 24 * time.Hour
+
+	// 内存中最多缓存的分段数, 超出后清空重新加载
+	MAX_CACHED_SEGMENTS = 64
+)
+
+type Config struct {
+	SegmentSize int           // 每个分段最多的消息数
+	RestoreTTL  time.Duration // 恢复后多长时间内不再归档
+}
+
+type Store struct {
+	store.MessageStore
+	index   *Index
+	objects ObjectStore
+	conf    *Config
+	now     func() time.Time
+
+	// 分段的重写、归档与恢复互斥
+	mu sync.Mutex
+
+	cacheMu sync.RWMutex
+	cache   map[string][]*pb.MessageData
+}
+
+func NewStore(inner store.MessageStore, index *Index, objects ObjectStore, conf *Config) *Store {
+	if conf.SegmentSize <= 0 {
+		conf.SegmentSize = DEFAULT_SEGMENT_SIZE
+	}
+	if conf.RestoreTTL <= 0 {
+		conf.RestoreTTL = DEFAULT_RESTORE_TTL
+	}
+	return &Store{
+		MessageStore: inner,
+		index:        index,
+		objects:      objects,
+		conf:         conf,
+		now:          time.Now,
+		cache:        make(map[string][]*pb.MessageData),
+	}
+}
+
+func (s *Store) Save(ctx context.Context, msgs []*pb.MessageData) (int, error) {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg != nil {
+			ids = append(ids, msg.Id)
+		}
+	}
+	archived, err := s.index.Locate(ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(archived) == 0 {
+		return s.MessageStore.Save(ctx, msgs)
+	}
+
+	hot := make([]*pb.MessageData, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		if _, ok := archived[msg.Id]; !ok {
+			hot = append(hot, msg)
+		}
+	}
+	return s.MessageStore.Save(ctx, hot)
+}
+
+func (s *Store) QueryBySeq(ctx context.Context, conversationId string, anchor int64, backward bool, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	hot, hotMore, err := s.MessageStore.QueryBySeq(ctx, conversationId, anchor, backward, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	segs, err := s.index.Segments(conversationId)
+	if err != nil || len(segs) == 0 {
+		return hot, hotMore, err
+	}
+
+	var (
+		candidates []*storagepb.Segment
+		cold       []*pb.MessageData
+		inRange    func(msg *pb.MessageData) bool
+		done       func(seg *storagepb.Segment) bool
+	)
+	if !backward {
+		for _, seg := range segs {
+			if seg.ToSeq > anchor {
+				candidates = append(candidates, seg)
+			}
+		}
+		inRange = func(msg *pb.MessageData) bool { return msg.Seq > anchor }
+		// 分段按 from_seq 升序, 已取到 limit+1 条且之后的分段都在其后时停止
+		done = func(seg *storagepb.Segment) bool {
+			return len(cold) > limit && seg.FromSeq > cold[limit].Seq
+		}
+	} else {
+		for i := len(segs) - 1; i >= 0; i-- {
+			if anchor <= 0 || segs[i].FromSeq < anchor {
+				candidates = append(candidates, segs[i])
+			}
+		}
+		sort.Slice(candidates, func(a, b int) bool { return candidates[a].ToSeq > candidates[b].ToSeq })
+		inRange = func(msg *pb.MessageData) bool { return anchor <= 0 || msg.Seq < anchor }
+		done = func(seg *storagepb.Segment) bool {
+			return len(cold) > limit && seg.ToSeq < cold[len(cold)-1-limit].Seq
+		}
+	}
+
+	for _, seg := range candidates {
+		if done(seg) {
+			break
+		}
+		msgs, err := s.load(ctx, seg.Object)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, msg := range msgs {
+			if inRange(msg) {
+				cold = append(cold, msg)
+			}
+		}
+		sortBySeq(cold)
+	}
+
+	msgs := merge(hot, cold, lessSeq)
+	hasMore := hotMore || len(msgs) > limit
+	if len(msgs) > limit {
+		if backward {
+			msgs = msgs[len(msgs)-limit:]
+		} else {
+			msgs = msgs[:limit]
+		}
+	}
+	return msgs, hasMore, nil
+}
+
+func (s *Store) QueryByTime(ctx context.Context, conversationId string, start, end int64, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	hot, hotMore, err := s.MessageStore.QueryByTime(ctx, conversationId, start, end, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	segs, err := s.index.Segments(conversationId)
+	if err != nil || len(segs) == 0 {
+		return hot, hotMore, err
+	}
+	if end <= 0 {
+		end = 1<<63 - 1
+	}
+
+	var candidates []*storagepb.Segment
+	for _, seg := range segs {
+		if seg.ToTime >= start && seg.FromTime < end && seg.ToTime >= after.SendTime {
+			candidates = append(candidates, seg)
+		}
+	}
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].FromTime < candidates[b].FromTime })
+
+	cold, err := s.collect(ctx, candidates, limit, func(msg *pb.MessageData) bool {
+		return msg.SendTime >= start && msg.SendTime < end && afterCursor(msg, after)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return page(hot, hotMore, cold, limit)
+}
+
+func (s *Store) Get(ctx context.Context, ids []string) ([]*pb.MessageData, error) {
+	hot, err := s.MessageStore.Get(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(hot))
+	for _, msg := range hot {
+		found[msg.Id] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return hot, nil
+	}
+
+	cold, err := s.archived(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	return merge(hot, cold, lessTime), nil
+}
+
+func (s *Store) SetStatus(ctx context.Context, id string, status int32) (*pb.MessageData, error) {
+	msg, err := s.MessageStore.SetStatus(ctx, id, status)
+	if !errors.Is(err, store.ErrNotFound) {
+		return msg, err
+	}
+	return s.update(ctx, id, func(msg *pb.MessageData) {
+		msg.Status = status
+	})
+}
+
+func (s *Store) Redact(ctx context.Context, id string) (*pb.MessageData, error) {
+	msg, err := s.MessageStore.Redact(ctx, id)
+	if !errors.Is(err, store.ErrNotFound) {
+		return msg, err
+	}
+	return s.update(ctx, id, func(msg *pb.MessageData) {
+		msg.Payload = nil
+		msg.Ext = nil
+		msg.Status = store.STATUS_ERASED
+	})
+}
+
+// ScanBefore 合并主存储与 from_time < before 的分段
+func (s *Store) ScanBefore(ctx context.Context, before int64, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	hot, hotMore, err := s.MessageStore.ScanBefore(ctx, before, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	segs, err := s.index.Before(before)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var candidates []*storagepb.Segment
+	for _, seg := range segs {
+		if seg.ToTime >= after.SendTime {
+			candidates = append(candidates, seg)
+		}
+	}
+	cold, err := s.collect(ctx, candidates, limit, func(msg *pb.MessageData) bool {
+		return msg.SendTime < before && afterCursor(msg, after)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return page(hot, hotMore, cold, limit)
+}
+
+func (s *Store) Delete(ctx context.Context, ids []string) (int, error) {
+	deleted, err := s.MessageStore.Delete(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	located, err := s.index.Locate(ids)
+	if err != nil || len(located) == 0 {
+		return deleted, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for object, set := range byObject(located) {
+		var n int
+		err := s.rewrite(ctx, object, func(msgs []*pb.MessageData) ([]*pb.MessageData, []*pb.MessageData) {
+			kept := msgs[:0:0]
+			var removed []*pb.MessageData
+			for _, msg := range msgs {
+				if set[msg.Id] {
+					removed = append(removed, msg)
+				} else {
+					kept = append(kept, msg)
+				}
+			}
+			n = len(removed)
+			return kept, removed
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func (s *Store) ScanSender(ctx context.Context, senderId string, after store.Cursor, limit int) ([]*pb.MessageData, bool, error) {
+	limit = normalizeLimit(limit)
+	hot, hotMore, err := s.MessageStore.ScanSender(ctx, senderId, after, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	ids, coldMore, err := s.index.Sender(senderId, after, limit)
+	if err != nil || len(ids) == 0 {
+		return hot, hotMore, err
+	}
+	cold, err := s.archived(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+	msgs, hasMore, err := page(hot, hotMore, cold, limit)
+	return msgs, hasMore || coldMore, err
+}
+
+// Segments 会话的全部归档分段
+func (s *Store) Segments(conversationId string) ([]*storagepb.Segment, error) {
+	return s.index.Segments(conversationId)
+}
+
+// collect 按顺序读取候选分段中满足 match 的消息, 结果按 (send_time, id) 升序
+// 候选分段按 from_time 升序, 已取到 limit+1 条且之后的分段都在其后时停止
+func (s *Store) collect(ctx context.Context, candidates []*storagepb.Segment, limit int, match func(msg *pb.MessageData) bool) ([]*pb.MessageData, error) {
+	var cold []*pb.MessageData
+	for _, seg := range candidates {
+		if len(cold) > limit && seg.FromTime > cold[limit].SendTime {
+			break
+		}
+		msgs, err := s.load(ctx, seg.Object)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if match(msg) {
+				cold = append(cold, msg)
+			}
+		}
+		sortByTime(cold)
+	}
+	return cold, nil
+}
+
+// archived 按id读取已归档的消息, 结果按 (send_time, id) 升序
+func (s *Store) archived(ctx context.Context, ids []string) ([]*pb.MessageData, error) {
+	located, err := s.index.Locate(ids)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*pb.MessageData
+	for object, set := range byObject(located) {
+		all, err := s.load(ctx, object)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range all {
+			if set[msg.Id] {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	sortByTime(msgs)
+	return msgs, nil
+}
+
+// update 修改已归档的消息, 不存在时返回 store.ErrNotFound
+func (s *Store) update(ctx context.Context, id string, fn func(msg *pb.MessageData)) (*pb.MessageData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	located, err := s.index.Locate([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	object, ok := located[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	var updated *pb.MessageData
+	err = s.rewrite(ctx, object, func(msgs []*pb.MessageData) ([]*pb.MessageData, []*pb.MessageData) {
+		for _, msg := range msgs {
+			if msg.Id == id {
+				fn(msg)
+				updated = proto.Clone(msg).(*pb.MessageData)
+			}
+		}
+		return msgs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, store.ErrNotFound
+	}
+	return updated, nil
+}
+
+// rewrite 由 fn 修改分段的消息后覆盖写回, 分段为空时删除对象, 调用方需持有 s.mu
+// fn 返回保留的消息及移除的消息
+func (s *Store) rewrite(ctx context.Context, object string, fn func(msgs []*pb.MessageData) ([]*pb.MessageData, []*pb.MessageData)) error {
+	old, err := s.index.Segment(object)
+	if err != nil {
+		return err
+	}
+	msgs, err := readSegment(ctx, s.objects, object)
+	if err != nil {
+		return err
+	}
+	kept, removed := fn(msgs)
+
+	seg := proto.Clone(old).(*storagepb.Segment)
+	measure(seg, kept)
+	if len(kept) > 0 {
+		data, err := encodeSegment(kept)
+		if err != nil {
+			return err
+		}
+		if seg.Size, err = s.objects.Put(ctx, object, bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	s.evict(object)
+	if err := s.index.Replace(old, seg, removed); err != nil {
+		return err
+	}
+	if len(kept) == 0 {
+		return s.objects.Delete(ctx, object)
+	}
+	return nil
+}
+
+// load 读取分段的全部消息, 返回副本, 调用方可以修改
+func (s *Store) load(ctx context.Context, object string) ([]*pb.MessageData, error) {
+	s.cacheMu.RLock()
+	msgs, ok := s.cache[object]
+	s.cacheMu.RUnlock()
+	if !ok {
+		var err error
+		if msgs, err = readSegment(ctx, s.objects, object); err != nil {
+			return nil, err
+		}
+		s.cacheMu.Lock()
+		if len(s.cache) >= MAX_CACHED_SEGMENTS {
+			s.cache = make(map[string][]*pb.MessageData)
+		}
+		s.cache[object] = msgs
+		s.cacheMu.Unlock()
+	}
+
+	copies := make([]*pb.MessageData, len(msgs))
+	for i, msg := range msgs {
+		copies[i] = proto.Clone(msg).(*pb.MessageData)
+	}
+	return copies, nil
+}
+
+func (s *Store) evict(object string) {
+	s.cacheMu.Lock()
+	delete(s.cache, object)
+	s.cacheMu.Unlock()
+}
+
+// byObject 将 id -> 对象key 按对象分组
+func byObject(located map[string]string) map[string]map[string]bool {
+	groups := make(map[string]map[string]bool)
+	for id, object := range located {
+		if groups[object] == nil {
+			groups[object] = make(map[string]bool)
+		}
+		groups[object][id] = true
+	}
+	return groups
+}
+
+// merge 合并两个有序的结果, 同一id只保留主存储中的一条
+func merge(hot, cold []*pb.MessageData, less func(a, b *pb.MessageData) bool) []*pb.MessageData {
+	if len(cold) == 0 {
+		return hot
+	}
+	seen := make(map[string]bool, len(hot))
+	for _, msg := range hot {
+		seen[msg.Id] = true
+	}
+	msgs := make([]*pb.MessageData, 0, len(hot)+len(cold))
+	i := 0
+	for _, msg := range cold {
+		if seen[msg.Id] {
+			continue
+		}
+		for i < len(hot) && less(hot[i], msg) {
+			msgs = append(msgs, hot[i])
+			i++
+		}
+		msgs = append(msgs, msg)
+	}
+	return append(msgs, hot[i:]...)
+}
+
+// page 按 (send_time, id) 合并后取前 limit 条
+func page(hot []*pb.MessageData, hotMore bool, cold []*pb.MessageData, limit int) ([]*pb.MessageData, bool, error) {
+	msgs := merge(hot, cold, lessTime)
+	hasMore := hotMore || len(msgs) > limit
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, hasMore, nil
+}
+
+func afterCursor(msg *pb.MessageData, after store.Cursor) bool {
+	if msg.SendTime != after.SendTime {
+		return msg.SendTime > after.SendTime
+	}
+	return msg.Id > after.Id
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return store.DEFAULT_LIMIT
+	}
+	return min(limit, store.MAX_LIMIT)
+}
@@ -0,0 +1,281 @@
+package tiered
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	"google.golang.org/protobuf/proto"
+	"storage/store"
+	"storage/store/storetest"
+)
+
+const base = int64(1700000000000)
+
+// newTestStore 以 hot 为主存储, 归档到临时目录
+func newTestStore(t *testing.T, hot store.MessageStore, segmentSize int) (*Store, *FileObjectStore) {
+	t.Helper()
+	dir := t.TempDir()
+	index, err := NewIndex(filepath.Join(dir, "segments.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+	objects, err := NewFileObjectStore(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(hot, index, objects, &Config{SegmentSize: segmentSize, RestoreTTL: time.Hour}), objects
+}
+
+// messages 生成 u1 与 u2 私聊中 seq 为 from..to 的消息, 每秒一条, 双方交替发送
+func messages(from, to int) []*pb.MessageData {
+	var msgs []*pb.MessageData
+	for i := from; i <= to; i++ {
+		sender, receiver := "u1", "u2"
+		if i%2 == 0 {
+			sender, receiver = receiver, sender
+		}
+		msgs = append(msgs, &pb.MessageData{
+			Id:           fmt.Sprintf("m%03d", i),
+			SenderId:     sender,
+			ReceiverId:   receiver,
+			SesstionType: pb.SesstionType_SINGLE,
+			MessageType:  pb.MessageType_TEXT,
+			Payload:      []byte(fmt.Sprintf("hello %d", i)),
+			Seq:          int64(i),
+			SendTime:     base + int64(i)*1000,
+		})
+	}
+	return msgs
+}
+
+// save 将消息写入每个存储, 参照存储保存全部消息, 用于比较查询结果
+func save(t *testing.T, msgs []*pb.MessageData, stores ...store.MessageStore) {
+	t.Helper()
+	for _, s := range stores {
+		if _, err := s.Save(context.Background(), msgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(msgs []*pb.MessageData) string {
+	s := ""
+	for _, msg := range msgs {
+		s += msg.Id + " "
+	}
+	return s
+}
+
+func assertSame(t *testing.T, name string, got, want []*pb.MessageData, gotMore, wantMore bool) {
+	t.Helper()
+	if ids(got) != ids(want) || gotMore != wantMore {
+		t.Fatalf("%s: got [%s] more=%v, want [%s] more=%v", name, ids(got), gotMore, ids(want), wantMore)
+	}
+	for i := range got {
+		if !proto.Equal(got[i], want[i]) {
+			t.Fatalf("%s: message %s differs: %v != %v", name, got[i].Id, got[i], want[i])
+		}
+	}
+}
+
+// 没有归档时与主存储语义一致
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.MessageStore {
+		s, _ := newTestStore(t, storetest.OpenBolt(t), 10)
+		return s
+	})
+}
+
+// 归档后的查询结果与全部在主存储时一致
+func TestArchiveTransparent(t *testing.T) {
+	ctx := context.Background()
+	hot, ref := storetest.OpenBolt(t), storetest.OpenBolt(t)
+	s, _ := newTestStore(t, hot, 10)
+	save(t, messages(1, 35), s, ref)
+
+	r, err := s.Archive(ctx, time.UnixMilli(base+31*1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Segments != 3 || r.Messages != 30 || r.Conversations != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	kept, _, _ := hot.QueryBySeq(ctx, "s:u1:u2", -1, false, 100)
+	if len(kept) != 5 || kept[0].Seq != 31 {
+		t.Fatalf("expected seq 31..35 to stay hot, got [%s]", ids(kept))
+	}
+
+	conv := "s:u1:u2"
+	for _, anchor := range []int64{-1, 0, 5, 9, 10, 19, 28, 30, 33, 35, 40} {
+		for _, limit := range []int{1, 3, 10, 12, 50} {
+			for _, backward := range []bool{false, true} {
+				got, gotMore, err := s.QueryBySeq(ctx, conv, anchor, backward, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, wantMore, _ := ref.QueryBySeq(ctx, conv, anchor, backward, limit)
+				assertSame(t, fmt.Sprintf("QueryBySeq(%d, %v, %d)", anchor, backward, limit), got, want, gotMore, wantMore)
+			}
+		}
+	}
+
+	for _, limit := range []int{4, 11, 50} {
+		var after store.Cursor
+		for page := 0; ; page++ {
+			got, gotMore, err := s.QueryByTime(ctx, conv, base+3*1000, base+33*1000, after, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, wantMore, _ := ref.QueryByTime(ctx, conv, base+3*1000, base+33*1000, after, limit)
+			assertSame(t, fmt.Sprintf("QueryByTime(%d) page %d", limit, page), got, want, gotMore, wantMore)
+			if !gotMore {
+				break
+			}
+			after = store.CursorOf(got[len(got)-1])
+		}
+	}
+
+	var after store.Cursor
+	for {
+		got, gotMore, err := s.ScanBefore(ctx, base+34*1000, after, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantMore, _ := ref.ScanBefore(ctx, base+34*1000, after, 7)
+		assertSame(t, "ScanBefore", got, want, gotMore, wantMore)
+		if !gotMore {
+			break
+		}
+		after = store.CursorOf(got[len(got)-1])
+	}
+
+	after = store.Cursor{}
+	for {
+		got, gotMore, err := s.ScanSender(ctx, "u1", after, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantMore, _ := ref.ScanSender(ctx, "u1", after, 6)
+		assertSame(t, "ScanSender", got, want, gotMore, wantMore)
+		if !gotMore {
+			break
+		}
+		after = store.CursorOf(got[len(got)-1])
+	}
+
+	got, err := s.Get(ctx, []string{"m035", "m002", "m020", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ref.Get(ctx, []string{"m035", "m002", "m020", "missing"})
+	assertSame(t, "Get", got, want, false, false)
+
+	// 已归档的id再次写入时忽略
+	if n, err := s.Save(ctx, messages(2, 2)); err != nil || n != 0 {
+		t.Fatalf("expected archived message to be deduplicated, got %d, %v", n, err)
+	}
+}
+
+func TestModifyArchived(t *testing.T) {
+	ctx := context.Background()
+	s, objects := newTestStore(t, storetest.OpenBolt(t), 10)
+	save(t, messages(1, 20), s)
+	if _, err := s.Archive(ctx, time.UnixMilli(base+100*1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := s.SetStatus(ctx, "m003", store.STATUS_RECALLED)
+	if err != nil || msg.Status != store.STATUS_RECALLED {
+		t.Fatalf("unexpected result %v, %v", msg, err)
+	}
+	if msg, err = s.Redact(ctx, "m004"); err != nil || msg.Payload != nil || msg.Status != store.STATUS_ERASED {
+		t.Fatalf("unexpected result %v, %v", msg, err)
+	}
+	if _, err := s.SetStatus(ctx, "missing", store.STATUS_RECALLED); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	msgs, err := s.Get(ctx, []string{"m003", "m004"})
+	if err != nil || len(msgs) != 2 || msgs[0].Status != store.STATUS_RECALLED || msgs[1].Status != store.STATUS_ERASED {
+		t.Fatalf("modification not persisted: %v, %v", msgs, err)
+	}
+
+	// 删除一个分段中的全部消息后分段也被删除
+	var all []string
+	for i := 11; i <= 20; i++ {
+		all = append(all, fmt.Sprintf("m%03d", i))
+	}
+	n, err := s.Delete(ctx, append(all, "m001"))
+	if err != nil || n != 11 {
+		t.Fatalf("expected 11 deleted, got %d, %v", n, err)
+	}
+	segs, err := s.Segments("s:u1:u2")
+	if err != nil || len(segs) != 1 || segs[0].Count != 9 || segs[0].FromSeq != 2 {
+		t.Fatalf("unexpected segments %v, %v", segs, err)
+	}
+	msgs, _, err = s.QueryBySeq(ctx, "s:u1:u2", -1, false, 50)
+	if err != nil || len(msgs) != 9 || msgs[0].Id != "m002" {
+		t.Fatalf("unexpected messages [%s], %v", ids(msgs), err)
+	}
+	if _, err := objects.Get(ctx, segs[0].Object); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	hot := storetest.OpenBolt(t)
+	s, objects := newTestStore(t, hot, 10)
+	save(t, messages(1, 30), s)
+	if _, err := s.Archive(ctx, time.UnixMilli(base+100*1000)); err != nil {
+		t.Fatal(err)
+	}
+	segs, _ := s.Segments("s:u1:u2")
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segs))
+	}
+
+	segments, restored, err := s.Restore(ctx, "s:u1:u2", 12, 15)
+	if err != nil || segments != 1 || restored != 10 {
+		t.Fatalf("expected 1 segment with 10 messages, got %d, %d, %v", segments, restored, err)
+	}
+	kept, _, _ := hot.QueryBySeq(ctx, "s:u1:u2", -1, false, 100)
+	if len(kept) != 10 || kept[0].Seq != 11 {
+		t.Fatalf("unexpected hot messages [%s]", ids(kept))
+	}
+	if _, err := objects.Get(ctx, segs[1].Object); err != ErrObjectNotFound {
+		t.Fatalf("archive file not removed: %v", err)
+	}
+
+	// 恢复的范围在 RestoreTTL 内不再归档
+	r, err := s.Archive(ctx, time.UnixMilli(base+100*1000))
+	if err != nil || r.Segments != 0 {
+		t.Fatalf("restored range archived again: %+v, %v", r, err)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if r, err = s.Archive(ctx, time.UnixMilli(base+100*1000)); err != nil || r.Segments != 1 {
+		t.Fatalf("expected range to be archived after pin expired: %+v, %v", r, err)
+	}
+
+	msgs, _, err := s.QueryBySeq(ctx, "s:u1:u2", -1, false, 50)
+	if err != nil || len(msgs) != 30 {
+		t.Fatalf("expected 30 messages, got %d, %v", len(msgs), err)
+	}
+}
+
+func TestFileObjectStoreRejectsEscape(t *testing.T) {
+	objects, err := NewFileObjectStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "../x", "/etc/passwd", "a/../../x"} {
+		if _, err := objects.Get(context.Background(), key); err != ErrInvalidKey {
+			t.Fatalf("key %q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
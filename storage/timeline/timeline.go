@@ -0,0 +1,413 @@
+package timeline
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	storagepb "github.com/atoncooper/im/proto/storage"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+	"storage/store"
+)
+
+// 用户时间线
+//
+// 投递给用户的每个事件按 (uid, inbox_seq) 存储, inbox_seq 由signal按用户分配, 单调递增
+// 客户端以一个游标拉取全部会话的变化, 不需要逐个会话同步
+// 1. 按 (uid, inbox_seq) 去重, 上游可以放心重试
+// 2. 只保存对消息的引用, 消息内容由调用方从消息历史中读取, 加密、撤回、擦除对时间线同样生效
+// 3. inbox_seq 可能有空洞, 拉取时直接跳过
+// 4. 写入时记录 committed_at, 超过保留时间的事件按它清理
+//
+// signal中用户的inbox seq计数器与会话seq共用检查点, 检查点中的id为 u:{uid}
+
+const CHECKPOINT_PREFIX = "u:"
+
+type Store interface {
+	// Append 批量写入事件, 返回新写入的条数
+	Append(ctx context.Context, events []*storagepb.TimelineEvent) (int, error)
+	// Sync 拉取 inbox_seq > after 的最早 limit 个事件, 按 inbox_seq 升序
+	Sync(ctx context.Context, uid string, after int64, limit int) ([]*storagepb.TimelineEvent, bool, error)
+	// Max 用户已写入的最大 inbox_seq, 没有事件时为0
+	Max(ctx context.Context, uid string) (int64, error)
+	// Delete 删除用户的全部事件, 返回删除的条数, 用于擦除用户数据
+	Delete(ctx context.Context, uid string) (int, error)
+	// Expire 删除 before(毫秒) 之前写入的事件, 返回删除的条数
+	Expire(ctx context.Context, before int64) (int, error)
+	Close() error
+}
+
+// Schedule 每隔 interval 清理写入超过 ttl 的事件, ctx 取消后返回
+func Schedule(ctx context.Context, s Store, interval, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := s.Expire(ctx, now.Add(-ttl).UnixMilli())
+			if err != nil {
+				log.Printf("[ERROR] expire timeline: %v", err)
+				continue
+			}
+			log.Printf("[INFO] expired %d timeline events", n)
+		}
+	}
+}
+
+// encode 序列化事件, 不保存消息内容, committed 为写入时间
+func encode(e *storagepb.TimelineEvent, committed int64) ([]byte, error) {
+	if e == nil || e.Uid == "" || e.InboxSeq <= 0 || strings.IndexByte(e.Uid, 0) >= 0 {
+		return nil, store.ErrInvalidArgument
+	}
+	ref := proto.Clone(e).(*storagepb.TimelineEvent)
+	if ref.MessageId == "" && ref.Message != nil {
+		ref.MessageId = ref.Message.Id
+	}
+	ref.Message = nil
+	ref.CommittedAt = committed
+	return proto.Marshal(ref)
+}
+
+func decode(data []byte) (*storagepb.TimelineEvent, error) {
+	var e storagepb.TimelineEvent
+	if err := proto.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// BoltStore 与 BoltStore 消息存储配合的嵌入式时间线存储
+//
+// bucket timeline : uid | 0x00 | inbox_seq(8) -> 序列化后的事件
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var bucketTimeline = []byte("timeline")
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		path = "data/timeline.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketTimeline)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func prefix(uid string) []byte {
+	return append([]byte(uid), 0)
+}
+
+func key(uid string, seq int64) []byte {
+	return binary.BigEndian.AppendUint64(prefix(uid), uint64(seq))
+}
+
+func (b *BoltStore) Append(ctx context.Context, events []*storagepb.TimelineEvent) (int, error) {
+	appended := 0
+	now := time.Now().UnixMilli()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTimeline)
+		for _, e := range events {
+			data, err := encode(e, now)
+			if err != nil {
+				return err
+			}
+			k := key(e.Uid, e.InboxSeq)
+			if bucket.Get(k) != nil {
+				continue
+			}
+			if err := bucket.Put(k, data); err != nil {
+				return err
+			}
+			appended++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return appended, nil
+}
+
+func (b *BoltStore) Sync(ctx context.Context, uid string, after int64, limit int) ([]*storagepb.TimelineEvent, bool, error) {
+	var (
+		events  []*storagepb.TimelineEvent
+		hasMore bool
+	)
+	p := prefix(uid)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTimeline).Cursor()
+		for k, v := c.Seek(key(uid, max(after+1, 0))); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			if len(events) == limit {
+				hasMore = true
+				break
+			}
+			e, err := decode(v)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	return events, hasMore, err
+}
+
+func (b *BoltStore) Max(ctx context.Context, uid string) (int64, error) {
+	var seq int64
+	p := prefix(uid)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTimeline).Cursor()
+		k, _ := c.Seek(append(prefix(uid), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff))
+		if k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		if k != nil && bytes.HasPrefix(k, p) && len(k) == len(p)+8 {
+			seq = int64(binary.BigEndian.Uint64(k[len(p):]))
+		}
+		return nil
+	})
+	return seq, err
+}
+
+func (b *BoltStore) Delete(ctx context.Context, uid string) (int, error) {
+	deleted := 0
+	p := prefix(uid)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTimeline)
+		// 先收集再删除, 遍历过程中删除会使游标跳过下一个key
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p) && len(k) == len(p)+8; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// Expire 遍历全部事件, 嵌入式存储的数据量有限
+func (b *BoltStore) Expire(ctx context.Context, before int64) (int, error) {
+	deleted := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTimeline)
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			e, err := decode(v)
+			if err != nil {
+				return err
+			}
+			if e.CommittedAt < before {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// SQLStore 与 SQLStore 消息存储配合的时间线存储, 使用消息存储的分片
+// 按uid的crc32哈希分片, 同一用户的事件总在同一分片
+type SQLStore struct {
+	dialect *store.Dialect
+	shards  []*sql.DB
+}
+
+// uid 与 messages 表的id一致按字节比较, 不同大小写的uid是不同的用户
+const schema = `
+CREATE TABLE IF NOT EXISTS timeline_events (
+	uid          VARCHAR(64) COLLATE %s NOT NULL,
+	inbox_seq    BIGINT      NOT NULL,
+	committed_at BIGINT      NOT NULL,
+	data         %s          NOT NULL,
+	PRIMARY KEY (uid, inbox_seq)%s
+) %s`
+
+// 按写入时间清理过期事件的索引, MySQL 在建表语句中创建
+const committedIndex = `CREATE INDEX IF NOT EXISTS idx_timeline_committed ON timeline_events (committed_at)`
+
+func NewSQLStore(ctx context.Context, dialect *store.Dialect, shards []string) (*SQLStore, error) {
+	if len(shards) == 0 {
+		return nil, store.ErrInvalidArgument
+	}
+	blob, keys := "MEDIUMBLOB", ",\n\tKEY idx_timeline_committed (committed_at)"
+	if dialect == store.Postgres {
+		blob, keys = "BYTEA", ""
+	}
+
+	s := &SQLStore{dialect: dialect}
+	for _, dsn := range shards {
+		db, err := sql.Open(dialect.Driver, dsn)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards = append(s.shards, db)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(schema, dialect.Collate, blob, keys, dialect.Options)); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if dialect == store.Postgres {
+			if _, err := db.ExecContext(ctx, committedIndex); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *SQLStore) shard(uid string) *sql.DB {
+	return s.shards[crc32.ChecksumIEEE([]byte(uid))%uint32(len(s.shards))]
+}
+
+func (s *SQLStore) Append(ctx context.Context, events []*storagepb.TimelineEvent) (int, error) {
+	query := "INSERT IGNORE INTO timeline_events (uid, inbox_seq, committed_at, data) VALUES (?, ?, ?, ?)"
+	if s.dialect == store.Postgres {
+		query = "INSERT INTO timeline_events (uid, inbox_seq, committed_at, data) VALUES (?, ?, ?, ?) ON CONFLICT (uid, inbox_seq) DO NOTHING"
+	}
+	query = s.dialect.Rebind(query)
+
+	appended := 0
+	now := time.Now().UnixMilli()
+	for _, e := range events {
+		data, err := encode(e, now)
+		if err != nil {
+			return appended, err
+		}
+		res, err := s.shard(e.Uid).ExecContext(ctx, query, e.Uid, e.InboxSeq, now, data)
+		if err != nil {
+			return appended, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return appended, err
+		}
+		appended += int(n)
+	}
+	return appended, nil
+}
+
+func (s *SQLStore) Sync(ctx context.Context, uid string, after int64, limit int) ([]*storagepb.TimelineEvent, bool, error) {
+	rows, err := s.shard(uid).QueryContext(ctx, s.dialect.Rebind(
+		"SELECT data FROM timeline_events WHERE uid = ? AND inbox_seq > ? ORDER BY inbox_seq LIMIT ?"),
+		uid, after, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var events []*storagepb.TimelineEvent
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, false, err
+		}
+		e, err := decode(data)
+		if err != nil {
+			return nil, false, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(events) > limit {
+		return events[:limit], true, nil
+	}
+	return events, false, nil
+}
+
+func (s *SQLStore) Max(ctx context.Context, uid string) (int64, error) {
+	var seq sql.NullInt64
+	err := s.shard(uid).QueryRowContext(ctx, s.dialect.Rebind(
+		"SELECT MAX(inbox_seq) FROM timeline_events WHERE uid = ?"), uid).Scan(&seq)
+	return seq.Int64, err
+}
+
+func (s *SQLStore) Delete(ctx context.Context, uid string) (int, error) {
+	res, err := s.shard(uid).ExecContext(ctx, s.dialect.Rebind("DELETE FROM timeline_events WHERE uid = ?"), uid)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) Expire(ctx context.Context, before int64) (int, error) {
+	deleted := 0
+	for _, db := range s.shards {
+		res, err := db.ExecContext(ctx, s.dialect.Rebind("DELETE FROM timeline_events WHERE committed_at < ?"), before)
+		if err != nil {
+			return deleted, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+func (s *SQLStore) Close() error {
+	var err error
+	for _, db := range s.shards {
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package timeline
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/atoncooper/im/proto"
+	storagepb "github.com/atoncooper/im/proto/storage"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "timeline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	events := []*storagepb.TimelineEvent{
+		{Uid: "u1", InboxSeq: 1, Type: storagepb.EventType_EVENT_MESSAGE, ConversationId: "s:u1:u2", Seq: 1, Message: &pb.MessageData{Id: "m1", Payload: []byte("hi")}},
+		{Uid: "u1", InboxSeq: 2, Type: storagepb.EventType_EVENT_MESSAGE, ConversationId: "g:1", Seq: 7, MessageId: "m2"},
+		// inbox seq 3 分配后未写入
+		{Uid: "u1", InboxSeq: 4, Type: storagepb.EventType_EVENT_RECALL, ConversationId: "s:u1:u2", Seq: 1, MessageId: "m1"},
+		{Uid: "u1", InboxSeq: 5, Type: storagepb.EventType_EVENT_READ, ConversationId: "g:1", Seq: 7},
+		{Uid: "u10", InboxSeq: 1, Type: storagepb.EventType_EVENT_MESSAGE, ConversationId: "g:1", Seq: 7, MessageId: "m2"},
+	}
+	n, err := s.Append(ctx, events)
+	if err != nil || n != 5 {
+		t.Fatalf("append %d, %v", n, err)
+	}
+	// 重复写入不计数
+	if n, err := s.Append(ctx, events[:2]); err != nil || n != 0 {
+		t.Fatalf("append again %d, %v", n, err)
+	}
+	if _, err := s.Append(ctx, []*storagepb.TimelineEvent{{Uid: "u1"}}); err == nil {
+		t.Fatal("expected error for missing inbox seq")
+	}
+
+	var seqs []int64
+	after := int64(0)
+	for {
+		page, hasMore, err := s.Sync(ctx, "u1", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page {
+			if e.Uid != "u1" || e.Message != nil {
+				t.Fatalf("unexpected event %v", e)
+			}
+			seqs = append(seqs, e.InboxSeq)
+			after = e.InboxSeq
+		}
+		if !hasMore {
+			break
+		}
+	}
+	if len(seqs) != 4 || seqs[0] != 1 || seqs[1] != 2 || seqs[2] != 4 || seqs[3] != 5 {
+		t.Fatalf("unexpected seqs %v", seqs)
+	}
+
+	first, _, _ := s.Sync(ctx, "u1", 0, 1)
+	if first[0].MessageId != "m1" {
+		t.Fatalf("message id not kept: %v", first[0])
+	}
+
+	for uid, want := range map[string]int64{"u1": 5, "u10": 1, "u": 0, "u2": 0} {
+		if got, err := s.Max(ctx, uid); err != nil || got != want {
+			t.Fatalf("max %s = %d, %v, want %d", uid, got, err, want)
+		}
+	}
+
+	// 删除只影响该用户
+	if n, err := s.Delete(ctx, "u1"); err != nil || n != 4 {
+		t.Fatalf("delete %d, %v", n, err)
+	}
+	if page, _, _ := s.Sync(ctx, "u1", 0, 10); len(page) != 0 {
+		t.Fatalf("events not deleted: %v", page)
+	}
+	if got, _ := s.Max(ctx, "u10"); got != 1 {
+		t.Fatalf("events of u10 deleted, max %d", got)
+	}
+}
+
+// 测试按写入时间清理过期事件
+func TestBoltExpire(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "timeline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Now().UnixMilli()
+	old := []*storagepb.TimelineEvent{
+		{Uid: "u1", InboxSeq: 1, ConversationId: "g:1", MessageId: "m1"},
+		{Uid: "u2", InboxSeq: 1, ConversationId: "g:1", MessageId: "m1"},
+	}
+	if _, err := s.Append(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	page, _, _ := s.Sync(ctx, "u1", 0, 10)
+	if len(page) != 1 || page[0].CommittedAt < start {
+		t.Fatalf("committed_at not recorded: %v", page)
+	}
+
+	if n, err := s.Expire(ctx, start); err != nil || n != 0 {
+		t.Fatalf("expire %d, %v", n, err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	before := time.Now().UnixMilli()
+	time.Sleep(2 * time.Millisecond)
+	if _, err := s.Append(ctx, []*storagepb.TimelineEvent{{Uid: "u1", InboxSeq: 2, ConversationId: "g:1", MessageId: "m2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Expire(ctx, before); err != nil || n != 2 {
+		t.Fatalf("expire %d, %v", n, err)
+	}
+	if page, _, _ := s.Sync(ctx, "u1", 0, 10); len(page) != 1 || page[0].InboxSeq != 2 {
+		t.Fatalf("unexpected events %v", page)
+	}
+	if got, _ := s.Max(ctx, "u2"); got != 0 {
+		t.Fatalf("events of u2 not expired, max %d", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/atoncooper/im/utils/idgen"
+)
+
+// idinfo 解析消息id
+//
+//	idinfo [-layout snowflake|center] [-json] id...
+//
+// snowflake 为signal生成的消息id, center 为 center/utils.GeneratorID 生成的id
+
+type output struct {
+	Id         string `json:"id"`
+	Layout     string `json:"layout"`
+	Timestamp  int64  `json:"timestamp"`
+	Time       string `json:"time"`
+	Biz        int64  `json:"biz"`
+	Datacenter int64  `json:"datacenter"`
+	MachineId  int64  `json:"machineId"`
+	Sequence   int64  `json:"sequence"`
+	Borrowed   bool   `json:"borrowed"`
+}
+
+func main() {
+	name := flag.String("layout", "snowflake", "id layout: snowflake | center")
+	asJson := flag.Bool("json", false, "print as json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: idinfo [-layout snowflake|center] [-json] id...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	layout, err := idgen.ParseLayout(*name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, arg := range flag.Args() {
+		out, err := decode(layout, *name, arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", arg, err)
+			failed = true
+			continue
+		}
+		if *asJson {
+			data, _ := json.Marshal(out)
+			fmt.Println(string(data))
+			continue
+		}
+		fmt.Printf("id:         %s\n", out.Id)
+		fmt.Printf("layout:     %s\n", out.Layout)
+		fmt.Printf("time:       %s (%d)\n", out.Time, out.Timestamp)
+		fmt.Printf("biz:        %d\n", out.Biz)
+		fmt.Printf("datacenter: %d\n", out.Datacenter)
+		fmt.Printf("machine id: %d\n", out.MachineId)
+		fmt.Printf("sequence:   %d\n", out.Sequence)
+		fmt.Printf("borrowed:   %t\n\n", out.Borrowed)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func decode(layout idgen.Layout, name, arg string) (*output, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	d, err := layout.Decode(id)
+	if err != nil {
+		return nil, err
+	}
+	return &output{
+		Id:         arg,
+		Layout:     name,
+		Timestamp:  d.Timestamp,
+		Time:       d.Time.UTC().Format(time.RFC3339Nano),
+		Biz:        d.Biz,
+		Datacenter: d.Datacenter,
+		MachineId:  d.Machine,
+		Sequence:   d.Sequence,
+		Borrowed:   d.Borrowed,
+	}, nil
+}
@@ -0,0 +1,444 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 时间有序的id生成器
+//
+// id = (时间戳 - Epoch) << (WorkerBits + SeqBits) | worker << SeqBits | 序列号
+// 序列号最高的 ReservedBits 位留作借用空间, 正常生成只使用低位, 两者不会重叠
+//
+// 时钟回拨时按策略处理:
+//   POLICY_WAIT   : 回拨不超过 MaxWait 时等待时钟追上, 否则失败
+//   POLICY_BORROW : 从借用空间分配, 借用游标从上次的时间戳开始只增不减, 领先当前时间超过 MaxBorrow 时失败
+//   POLICY_FAIL   : 直接失败
+//
+// 已使用的最大时间戳定期持久化, 重启后以 持久化的值 + PersistInterval 作为起点, 避免重启前后的id重复
+
+// Layout worker 从高位到低位依次为 业务 | 数据中心 | 机器id, 业务与数据中心可以为0位
+type Layout struct {
+	Epoch        int64 // 毫秒
+	TimeBits     uint
+	WorkerBits   uint
+	SeqBits      uint
+	ReservedBits uint
+
+	BizBits uint
+	DcBits  uint
+}
+
+var (
+	// SnowflakeLayout 与 bwmarrin/snowflake 的默认布局一致: 41位时间戳, 10位机器id, 12位序列号
+	// 序列号的最高位留作借用空间, 之前由 bwmarrin/snowflake 生成的id使用全部12位, 解析时 Borrowed 不可信
+	SnowflakeLayout = Layout{Epoch: 1288834974657, TimeBits: 41, WorkerBits: 10, SeqBits: 12, ReservedBits: 1}
+	// CenterLayout center的布局: 41位时间戳, 4位业务, 3位数据中心, 6位机器id, 9位序列号
+	CenterLayout = Layout{Epoch: 1704067200000, TimeBits: 41, WorkerBits: 13, SeqBits: 9, ReservedBits: 1, BizBits: 4, DcBits: 3}
+)
+
+// ParseLayout snowflake | center
+func ParseLayout(s string) (Layout, error) {
+	switch s {
+	case "snowflake":
+		return SnowflakeLayout, nil
+	case "center":
+		return CenterLayout, nil
+	}
+	return Layout{}, fmt.Errorf("unknown id layout %q", s)
+}
+
+func (l Layout) valid() bool {
+	return l.TimeBits+l.WorkerBits+l.SeqBits == 63 && l.ReservedBits < l.SeqBits && l.BizBits+l.DcBits <= l.WorkerBits
+}
+
+// Worker 由业务、数据中心、机器id组成worker, 任一部分超出位数时返回 ErrWorkerOverflow
+func (l Layout) Worker(biz, dc, machine int64) (int64, error) {
+	machineBits := l.WorkerBits - l.BizBits - l.DcBits
+	if biz < 0 || biz >= 1<<l.BizBits || dc < 0 || dc >= 1<<l.DcBits || machine < 0 || machine >= 1<<machineBits {
+		return 0, ErrWorkerOverflow
+	}
+	return biz<<(l.DcBits+machineBits) | dc<<machineBits | machine, nil
+}
+
+// ID 解析后的id
+type ID struct {
+	Time       time.Time
+	Timestamp  int64 // 毫秒
+	Worker     int64
+	Biz        int64
+	Datacenter int64
+	Machine    int64
+	Sequence   int64
+	Borrowed   bool // 序列号落在借用空间, 布局启用 ReservedBits 之前生成的id没有借用空间, 此时只表示序列号较大
+}
+
+// Decode 按布局解析id, 布局与生成时不一致时结果没有意义
+// id中没有记录生成时是否预留了借用空间, Borrowed 只按序列号的位置判断
+func (l Layout) Decode(id int64) (ID, error) {
+	if !l.valid() {
+		return ID{}, fmt.Errorf("invalid layout %+v", l)
+	}
+	if id < 0 {
+		return ID{}, ErrInvalidID
+	}
+	machineBits := l.WorkerBits - l.BizBits - l.DcBits
+	ts := id>>(l.WorkerBits+l.SeqBits) + l.Epoch
+	worker := id >> l.SeqBits & (1<<l.WorkerBits - 1)
+	seq := id & (1<<l.SeqBits - 1)
+	return ID{
+		Time:       time.UnixMilli(ts),
+		Timestamp:  ts,
+		Worker:     worker,
+		Biz:        worker >> (l.DcBits + machineBits),
+		Datacenter: worker >> machineBits & (1<<l.DcBits - 1),
+		Machine:    worker & (1<<machineBits - 1),
+		Sequence:   seq,
+		Borrowed:   l.ReservedBits > 0 && seq >= 1<<(l.SeqBits-l.ReservedBits),
+	}, nil
+}
+
+type Policy int
+
+const (
+	POLICY_WAIT Policy = iota
+	POLICY_BORROW
+	POLICY_FAIL
+)
+
+const (
+	DEFAULT_MAX_WAIT         = time.Second
+	DEFAULT_MAX_BORROW       = 10 * time.Second
+	DEFAULT_PERSIST_INTERVAL = time.Second
+)
+
+var (
+	ErrClockRollback  = errors.New("clock moved backwards")
+	ErrWorkerOverflow = errors.New("worker id overflow")
+	ErrNoReserved     = errors.New("layout has no reserved sequence space")
+	ErrInvalidID      = errors.New("invalid id")
+)
+
+func (p Policy) String() string {
+	switch p {
+	case POLICY_WAIT:
+		return "wait"
+	case POLICY_BORROW:
+		return "borrow"
+	case POLICY_FAIL:
+		return "fail"
+	}
+	return fmt.Sprintf("policy(%d)", int(p))
+}
+
+// ParsePolicy wait | borrow | fail, 为空时为 wait
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "", "wait":
+		return POLICY_WAIT, nil
+	case "borrow":
+		return POLICY_BORROW, nil
+	case "fail":
+		return POLICY_FAIL, nil
+	}
+	return 0, fmt.Errorf("unknown rollback policy %q", s)
+}
+
+// Store 持久化已使用的最大时间戳
+type Store interface {
+	// Load 不存在时返回0
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, ts int64) error
+}
+
+// Rollback 一次时钟回拨, 回拨开始及处理失败时通知
+type Rollback struct {
+	Worker int64
+	Drift  time.Duration
+	Policy Policy
+	Err    error
+}
+
+type Stats struct {
+	Rollbacks int64         // 回拨次数, 连续的回拨计为一次
+	MaxDrift  time.Duration // 最大回拨幅度
+	Waits     int64         // 等待时钟追上的次数
+	Borrowed  int64         // 从借用空间分配的id数
+	Failures  int64         // 因回拨失败的次数
+}
+
+type Config struct {
+	Layout    Layout
+	Worker    int64
+	Policy    Policy
+	MaxWait   time.Duration
+	MaxBorrow time.Duration
+
+	Store           Store // 为空时不持久化
+	PersistInterval time.Duration
+
+	// OnRollback 为空时输出日志
+	OnRollback func(Rollback)
+}
+
+type Generator struct {
+	layout     Layout
+	worker     int64
+	policy     Policy
+	maxWait    int64 // 毫秒
+	maxBorrow  int64 // 毫秒
+	store      Store
+	interval   time.Duration
+	onRollback func(Rollback)
+	now        func() int64
+
+	normalMax int64 // 正常生成的最大序列号
+	borrowMax int64 // 借用空间的最大偏移
+
+	mu        sync.Mutex
+	lastTs    int64
+	sequence  int64
+	borrowTs  int64
+	borrowSeq int64
+	rollback  bool // 处于回拨中
+	failed    bool // 本次回拨已通知过失败
+	persisted int64
+	stats     Stats
+}
+
+// New 创建生成器, 配置了 Store 时从中恢复起点
+// 恢复的起点领先当前时间不超过 PersistInterval 时等待时钟追上
+func New(ctx context.Context, conf *Config) (*Generator, error) {
+	l := conf.Layout
+	if !l.valid() {
+		return nil, fmt.Errorf("invalid layout %+v", l)
+	}
+	if conf.Worker < 0 || conf.Worker >= 1<<l.WorkerBits {
+		return nil, ErrWorkerOverflow
+	}
+	if conf.Policy == POLICY_BORROW && l.ReservedBits == 0 {
+		return nil, ErrNoReserved
+	}
+
+	g := &Generator{
+		layout:     l,
+		worker:     conf.Worker,
+		policy:     conf.Policy,
+		maxWait:    conf.MaxWait.Milliseconds(),
+		maxBorrow:  conf.MaxBorrow.Milliseconds(),
+		store:      conf.Store,
+		interval:   conf.PersistInterval,
+		onRollback: conf.OnRollback,
+		now:        func() int64 { return time.Now().UnixMilli() },
+		normalMax:  1<<(l.SeqBits-l.ReservedBits) - 1,
+		borrowMax:  1<<l.SeqBits - 1<<(l.SeqBits-l.ReservedBits) - 1,
+	}
+	if conf.MaxWait <= 0 {
+		g.maxWait = DEFAULT_MAX_WAIT.Milliseconds()
+	}
+	if conf.MaxBorrow <= 0 {
+		g.maxBorrow = DEFAULT_MAX_BORROW.Milliseconds()
+	}
+	if g.interval <= 0 {
+		g.interval = DEFAULT_PERSIST_INTERVAL
+	}
+	if g.onRollback == nil {
+		g.onRollback = logRollback
+	}
+
+	if g.store != nil {
+		last, err := g.store.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if last > 0 {
+			g.restore(last + g.interval.Milliseconds())
+			if ahead := g.lastTs - g.now(); ahead > 0 && ahead <= g.interval.Milliseconds() {
+				time.Sleep(time.Duration(ahead) * time.Millisecond)
+			}
+		}
+	}
+	return g, nil
+}
+
+// restore 以 ts 为起点, ts 及之前的正常空间与借用空间都视为已使用
+func (g *Generator) restore(ts int64) {
+	g.lastTs = ts
+	g.sequence = g.normalMax
+	g.borrowTs = ts
+	g.borrowSeq = g.borrowMax + 1
+	g.persisted = ts
+}
+
+func (g *Generator) Next() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.next()
+}
+
+// NextN 连续生成 n 个id, 中途失败时返回已生成的部分及错误
+func (g *Generator) NextN(n int) ([]int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		id, err := g.next()
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// next 调用方持有 g.mu, 等待时钟追上期间临时释放
+func (g *Generator) next() (int64, error) {
+	now := g.now()
+	if now < g.lastTs {
+		drift := g.lastTs - now
+		g.startRollback(drift)
+		switch g.policy {
+		case POLICY_WAIT:
+			if drift > g.maxWait {
+				return 0, g.fail(drift)
+			}
+			g.stats.Waits++
+			// 等待期间不阻塞 Stats、Persist 及其他调用方, 醒来后按最新的状态重新判断
+			g.mu.Unlock()
+			time.Sleep(time.Duration(drift) * time.Millisecond)
+			g.mu.Lock()
+			if now = g.now(); now < g.lastTs {
+				return 0, g.fail(g.lastTs - now)
+			}
+		case POLICY_BORROW:
+			return g.borrow(now)
+		default:
+			return 0, g.fail(drift)
+		}
+	}
+	g.rollback, g.failed = false, false
+
+	if now == g.lastTs {
+		if g.sequence < g.normalMax {
+			g.sequence++
+		} else {
+			for now <= g.lastTs {
+				now = g.now()
+			}
+			g.sequence = 0
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTs = now
+	return g.compose(now, g.sequence), nil
+}
+
+// borrow 从借用空间分配, 游标只增不减, 不会与正常生成及之前的借用重叠
+func (g *Generator) borrow(now int64) (int64, error) {
+	ts, seq := g.borrowTs, g.borrowSeq
+	if ts < g.lastTs {
+		ts, seq = g.lastTs, 0
+	}
+	if seq > g.borrowMax {
+		ts, seq = ts+1, 0
+	}
+	if ts-now > g.maxBorrow {
+		return 0, g.fail(ts - now)
+	}
+	g.borrowTs, g.borrowSeq = ts, seq+1
+	g.stats.Borrowed++
+	return g.compose(ts, g.normalMax+1+seq), nil
+}
+
+func (g *Generator) compose(ts, seq int64) int64 {
+	return (ts-g.layout.Epoch)<<(g.layout.WorkerBits+g.layout.SeqBits) | g.worker<<g.layout.SeqBits | seq
+}
+
+func (g *Generator) startRollback(drift int64) {
+	if d := time.Duration(drift) * time.Millisecond; d > g.stats.MaxDrift {
+		g.stats.MaxDrift = d
+	}
+	if g.rollback {
+		return
+	}
+	g.rollback = true
+	g.stats.Rollbacks++
+	g.onRollback(Rollback{Worker: g.worker, Drift: time.Duration(drift) * time.Millisecond, Policy: g.policy})
+}
+
+// fail 每次回拨只通知第一次失败, 避免告警过多
+func (g *Generator) fail(drift int64) error {
+	g.stats.Failures++
+	err := fmt.Errorf("%w by %dms", ErrClockRollback, drift)
+	if !g.failed {
+		g.failed = true
+		g.onRollback(Rollback{Worker: g.worker, Drift: time.Duration(drift) * time.Millisecond, Policy: g.policy, Err: err})
+	}
+	return err
+}
+
+// Stats 回拨处理的累计统计
+func (g *Generator) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+// Run 定期持久化已使用的最大时间戳, ctx结束时再持久化一次
+func (g *Generator) Run(ctx context.Context) {
+	if g.store == nil {
+		return
+	}
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := g.Persist(ctx); err != nil {
+				log.Printf("[ERROR] persist id generator timestamp: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := g.Persist(ctx); err != nil {
+				log.Printf("[WARN] persist id generator timestamp: %v", err)
+			}
+		}
+	}
+}
+
+// Persist 持久化已使用的最大时间戳
+func (g *Generator) Persist(ctx context.Context) error {
+	if g.store == nil {
+		return nil
+	}
+	g.mu.Lock()
+	last := max(g.lastTs, g.borrowTs)
+	if last <= g.persisted {
+		g.mu.Unlock()
+		return nil
+	}
+	g.mu.Unlock()
+
+	if err := g.store.Save(ctx, last); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.persisted = max(g.persisted, last)
+	g.mu.Unlock()
+	return nil
+}
+
+func logRollback(r Rollback) {
+	if r.Err != nil {
+		log.Printf("[ALERT] worker %d clock moved backwards by %v, policy %s: %v", r.Worker, r.Drift, r.Policy, r.Err)
+		return
+	}
+	log.Printf("[ALERT] worker %d clock moved backwards by %v, policy %s", r.Worker, r.Drift, r.Policy)
+}
@@ -0,0 +1,205 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	ms int64
+}
+
+func (c *clock) now() int64 { return c.ms }
+
+func newGenerator(t *testing.T, c *clock, conf *Config) *Generator {
+	t.Helper()
+	if conf.Layout.SeqBits == 0 {
+		conf.Layout = CenterLayout
+	}
+	g, err := New(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.now = c.now
+	return g
+}
+
+func collect(t *testing.T, g *Generator, n int, seen map[int64]bool) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		id, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestLayoutCompatible(t *testing.T) {
+	c := &clock{ms: CenterLayout.Epoch + 1000}
+	g := newGenerator(t, c, &Config{Worker: 0b1010_101_110011})
+	id, _ := g.Next()
+	if want := int64(1000)<<22 | 0b1010_101_110011<<9; id != want {
+		t.Fatalf("got %b, want %b", id, want)
+	}
+}
+
+func TestWait(t *testing.T) {
+	c := &clock{ms: CenterLayout.Epoch + 1000}
+	var alerts []Rollback
+	g := newGenerator(t, c, &Config{Policy: POLICY_WAIT, MaxWait: 5 * time.Millisecond, OnRollback: func(r Rollback) { alerts = append(alerts, r) }})
+	seen := map[int64]bool{}
+	collect(t, g, 10, seen)
+
+	// 回拨超过 MaxWait 时失败, 多次失败只告警一次
+	c.ms -= 100
+	for i := 0; i < 3; i++ {
+		if _, err := g.Next(); !errors.Is(err, ErrClockRollback) {
+			t.Fatalf("expected ErrClockRollback, got %v", err)
+		}
+	}
+	stats := g.Stats()
+	if stats.Rollbacks != 1 || stats.Failures != 3 || stats.MaxDrift != 100*time.Millisecond || len(alerts) != 2 {
+		t.Fatalf("unexpected stats %+v, alerts %v", stats, alerts)
+	}
+
+	c.ms += 101
+	collect(t, g, 10, seen)
+}
+
+// 等待时钟追上期间不持有锁, 其他调用不被阻塞
+func TestWaitUnlocked(t *testing.T) {
+	var ms atomic.Int64
+	ms.Store(CenterLayout.Epoch + 1000)
+	g := newGenerator(t, &clock{}, &Config{Policy: POLICY_WAIT, MaxWait: time.Second})
+	g.now = ms.Load
+	if _, err := g.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	ms.Add(-200)
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.Next()
+		done <- err
+	}()
+	for g.Stats().Waits == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	g.Stats()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("stats blocked for %v while waiting", elapsed)
+	}
+	ms.Add(201)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBorrow(t *testing.T) {
+	c := &clock{ms: CenterLayout.Epoch + 1000}
+	g := newGenerator(t, c, &Config{Policy: POLICY_BORROW, MaxBorrow: 50 * time.Millisecond})
+	seen := map[int64]bool{}
+	collect(t, g, 200, seen)
+
+	// 两次回拨借用的id不重叠, 也不与时钟追上后正常生成的id重叠
+	for round := 0; round < 2; round++ {
+		c.ms -= 20
+		collect(t, g, 600, seen)
+		c.ms += 21
+		collect(t, g, 200, seen)
+	}
+	if stats := g.Stats(); stats.Rollbacks != 2 || stats.Borrowed != 1200 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// 借用的时间戳领先超过 MaxBorrow 时失败
+	c.ms -= 1000
+	if _, err := g.Next(); !errors.Is(err, ErrClockRollback) {
+		t.Fatalf("expected ErrClockRollback, got %v", err)
+	}
+}
+
+func TestFail(t *testing.T) {
+	c := &clock{ms: CenterLayout.Epoch + 1000}
+	g := newGenerator(t, c, &Config{Policy: POLICY_FAIL})
+	g.Next()
+	c.ms--
+	if _, err := g.Next(); !errors.Is(err, ErrClockRollback) {
+		t.Fatalf("expected ErrClockRollback, got %v", err)
+	}
+}
+
+// 重启后即使时钟回拨也不会生成重复的id
+func TestPersist(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{ms: time.Now().UnixMilli() - 10000}
+	conf := func() *Config {
+		return &Config{Policy: POLICY_BORROW, MaxBorrow: time.Minute, Store: store, PersistInterval: time.Second}
+	}
+	g := newGenerator(t, c, conf())
+	seen := map[int64]bool{}
+	collect(t, g, 100, seen)
+	if err := g.Persist(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.ms += 500
+	collect(t, g, 100, seen)
+
+	g = newGenerator(t, c, conf())
+	c.ms -= 2000
+	collect(t, g, 100, seen)
+	c.ms += 5000
+	collect(t, g, 100, seen)
+}
+
+func TestDecode(t *testing.T) {
+	worker, err := CenterLayout.Worker(5, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{ms: CenterLayout.Epoch + 123456}
+	g := newGenerator(t, c, &Config{Worker: worker, Policy: POLICY_BORROW})
+	g.Next()
+	id, _ := g.Next()
+	got, err := CenterLayout.Decode(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timestamp != c.ms || got.Biz != 5 || got.Datacenter != 3 || got.Machine != 42 || got.Sequence != 1 || got.Borrowed {
+		t.Fatalf("unexpected %+v", got)
+	}
+
+	c.ms--
+	id, _ = g.Next()
+	if got, _ = CenterLayout.Decode(id); !got.Borrowed || got.Sequence != 256 {
+		t.Fatalf("unexpected %+v", got)
+	}
+
+	// 雪花布局: 2022-01-01 00:00:00 UTC, 机器id 7, 序列号 3
+	got, _ = SnowflakeLayout.Decode(1477067041797074947)
+	if got.Time.UTC() != time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC) || got.Machine != 7 || got.Sequence != 3 || got.Biz != 0 {
+		t.Fatalf("unexpected %+v", got)
+	}
+
+	// bwmarrin/snowflake 生成的id使用全部12位序列号, 序列号 >= 2048 时同样解析为借用
+	got, _ = SnowflakeLayout.Decode(1477067041797074947&^(1<<12-1) | 3000)
+	if got.Sequence != 3000 || got.Machine != 7 || !got.Borrowed {
+		t.Fatalf("unexpected %+v", got)
+	}
+
+	if _, err := CenterLayout.Worker(16, 0, 0); !errors.Is(err, ErrWorkerOverflow) {
+		t.Fatalf("expected ErrWorkerOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileStore 以本地文件持久化时间戳, 适用于worker与主机固定的部署
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{path: path}, nil
+}
+
+func (f *FileStore) Load(ctx context.Context) (int64, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Save 先写临时文件再重命名, 中途崩溃时保留旧值
+func (f *FileStore) Save(ctx context.Context, ts int64) error {
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(ts, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}